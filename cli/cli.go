@@ -1,9 +1,18 @@
 package cli
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"my-blockchain/core"
 	"my-blockchain/network"
@@ -12,6 +21,70 @@ import (
 
 type CLI struct{}
 
+// walletFile is the wallet file commands use: $WALLET_FILE, or wallets.dat.
+func walletFile() string {
+	if path := os.Getenv("WALLET_FILE"); path != "" {
+		return path
+	}
+	return wallet.DefaultWalletFile
+}
+
+// loadWallets loads walletFile(). Encrypted wallets are unlocked with $WALLET_PASSPHRASE
+// when it is set, and stay locked otherwise.
+func loadWallets() (*wallet.Wallets, error) {
+	ws, err := wallet.NewWalletsAt(walletFile())
+	if err != nil {
+		return nil, err
+	}
+	if passphrase := os.Getenv("WALLET_PASSPHRASE"); passphrase != "" && ws.IsEncrypted() {
+		if err := ws.Unlock(passphrase); err != nil {
+			return nil, fmt.Errorf("$WALLET_PASSPHRASE: %w", err)
+		}
+	}
+	return ws, nil
+}
+
+// defaultChain is the chain profile used when -chain is not given: $CHAIN, or "main".
+func defaultChain() string {
+	if chain := os.Getenv("CHAIN"); chain != "" {
+		return chain
+	}
+	return "main"
+}
+
+// defaultLogLevel is the node's log level when -loglevel is not given: $LOG_LEVEL, or "info".
+func defaultLogLevel() string {
+	if level := os.Getenv("LOG_LEVEL"); level != "" {
+		return level
+	}
+	return "info"
+}
+
+// listenAddr is the host:port of the current node when $LISTEN_ADDR sets one; empty means
+// localhost:<NODE_ID>.
+func listenAddr() string {
+	return os.Getenv("LISTEN_ADDR")
+}
+
+// rpcToken is the token the node's API requires, from $RPC_TOKEN; empty means none.
+func rpcToken() string {
+	return os.Getenv("RPC_TOKEN")
+}
+
+// printOpenError reports a failure to open the local chain.
+func printOpenError(err error) {
+	fmt.Println("Failed to open blockchain:", err)
+	printDBLockedHint(err)
+}
+
+// printDBLockedHint says which node to stop when err is a core.DBLockedError.
+func printDBLockedHint(err error) {
+	var locked *core.DBLockedError
+	if errors.As(err, &locked) {
+		fmt.Printf("Stop the node running with NODE_ID=%s (or anything else using %s) and retry, or wait longer with $DB_LOCK_TIMEOUT.\n", locked.NodeID, locked.Path)
+	}
+}
+
 func nodeID() string {
 	id := os.Getenv("NODE_ID")
 	if id == "" {
@@ -20,210 +93,1589 @@ func nodeID() string {
 	return id
 }
 
+// applyDBEnv sets how long opening the DB waits for a lock from $DB_LOCK_TIMEOUT, a
+// duration such as 10s, if set.
+func applyDBEnv() {
+	v := os.Getenv("DB_LOCK_TIMEOUT")
+	if v == "" {
+		return
+	}
+	timeout, err := time.ParseDuration(v)
+	if err != nil || timeout <= 0 {
+		fmt.Printf("Invalid DB_LOCK_TIMEOUT: %s (want a duration such as 10s)\n", v)
+		os.Exit(1)
+	}
+	core.DBLockTimeout = timeout
+}
+
+// chainConfig returns the built-in chain profile called name with the
+// $COINBASE_MATURITY, $HALVING_INTERVAL, $MAX_BLOCK_SIZE, $MAX_TX_SIZE and $DUST_THRESHOLD
+// overrides applied. It only matters for new chains; an existing DB keeps the config it
+// was created with.
+func chainConfig(name string) (core.ChainConfig, error) {
+	cfg, err := core.ChainConfigByName(name)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.CoinbaseMaturity = envInt("COINBASE_MATURITY", cfg.CoinbaseMaturity, 0)
+	cfg.HalvingInterval = envInt("HALVING_INTERVAL", cfg.HalvingInterval, 1)
+	cfg.MaxBlockSize = envInt("MAX_BLOCK_SIZE", cfg.MaxBlockSize, 1)
+	cfg.MaxTxSize = envInt("MAX_TX_SIZE", cfg.MaxTxSize, 1)
+	cfg.DustThreshold = envInt("DUST_THRESHOLD", cfg.DustThreshold, 0)
+	if v := os.Getenv("CHECKPOINTS"); v != "" {
+		checkpoints, err := parseCheckpoints(v)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.Checkpoints = checkpoints
+	}
+	return cfg, nil
+}
+
+// chainOverrides holds the createblockchain flags that replace a profile's parameters.
+// Nil fields keep the profile's value.
+type chainOverrides struct {
+	Subsidy, Maturity, TargetBits, HalvingInterval *int
+}
+
+func (o chainOverrides) apply(cfg *core.ChainConfig) {
+	if o.Subsidy != nil {
+		cfg.Subsidy = *o.Subsidy
+	}
+	if o.Maturity != nil {
+		cfg.CoinbaseMaturity = *o.Maturity
+	}
+	if o.TargetBits != nil {
+		cfg.TargetBits = *o.TargetBits
+	}
+	if o.HalvingInterval != nil {
+		cfg.HalvingInterval = *o.HalvingInterval
+	}
+}
+
+// parseCheckpoints reads a comma-separated list of HEIGHT:HASH pairs.
+func parseCheckpoints(s string) (map[int][]byte, error) {
+	checkpoints := make(map[int][]byte)
+	for _, pair := range strings.Split(s, ",") {
+		heightStr, hashHex, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		height, err := strconv.Atoi(heightStr)
+		if !ok || err != nil || height < 1 {
+			return nil, fmt.Errorf("invalid checkpoint %q (want HEIGHT:HASH)", pair)
+		}
+		hash, err := hex.DecodeString(hashHex)
+		if err != nil || len(hash) == 0 {
+			return nil, fmt.Errorf("invalid checkpoint hash %q", hashHex)
+		}
+		checkpoints[height] = hash
+	}
+	return checkpoints, nil
+}
+
+// parseOutputs parses a sendmany -outputs list "ADDR:AMOUNT,...".
+func parseOutputs(s string) (map[string]int, error) {
+	outputs := make(map[string]int)
+	for _, pair := range strings.Split(s, ",") {
+		address, amountStr, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		amount, err := strconv.Atoi(amountStr)
+		if !ok || err != nil || amount <= 0 {
+			return nil, fmt.Errorf("invalid output %q (want ADDRESS:AMOUNT with AMOUNT > 0)", pair)
+		}
+		if err := wallet.CheckAddress(address); err != nil {
+			return nil, fmt.Errorf("invalid address %q: %v", address, err)
+		}
+		if _, dup := outputs[address]; dup {
+			return nil, fmt.Errorf("address %s is listed twice", address)
+		}
+		outputs[address] = amount
+	}
+	return outputs, nil
+}
+
+// envInt returns $name as an integer, or def when unset. Values below min exit.
+func envInt(name string, def, min int) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < min {
+		fmt.Printf("Invalid %s: %s\n", name, v)
+		os.Exit(1)
+	}
+	return n
+}
+
 func (c *CLI) printUsage() {
 	fmt.Println("Usage:")
-	fmt.Println("  createwallet")
-	fmt.Println("  listaddresses")
-	fmt.Println("  createblockchain -address YOUR_ADDRESS")
-	fmt.Println("  printchain")
-	fmt.Println("  getbalance -address YOUR_ADDRESS")
-	fmt.Println("  send -from FROM -to TO -amount AMOUNT")
-	fmt.Println("  startnode -miner MINER_ADDRESS(optional)")
+	fmt.Println("  createwallet -compressed(optional)")
+	fmt.Println("  createhdwallet")
+	fmt.Println("  restorewallet -mnemonic \"WORDS...\" -count N(optional)")
+	fmt.Println("  listaddresses -json(optional)")
+	fmt.Println("  signmessage -address ADDRESS -message MESSAGE")
+	fmt.Println("  verifymessage -address ADDRESS -message MESSAGE -signature SIGNATURE")
+	fmt.Println("  dumpprivkey -address ADDRESS")
+	fmt.Println("  walletinfo -address ADDRESS")
+	fmt.Println("  importprivkey -key WIF")
+	fmt.Println("  importaddress -address ADDRESS")
+	fmt.Println("  encryptwallet -passphrase PASSPHRASE")
+	fmt.Println("  walletpassphrase -passphrase PASSPHRASE -timeout SECONDS")
+	fmt.Println("  walletlock")
+	fmt.Println("  getwalletbalance -json(optional)")
+	fmt.Println("  rescanblockchain -start HEIGHT(optional) -stop HEIGHT(optional)")
+	fmt.Println("  dumpwallet -file FILE")
+	fmt.Println("  importwallet -file FILE")
+	fmt.Println("  createmultisig -required M -addresses ADDR1,ADDR2,...")
+	fmt.Println("  createblockchain -address YOUR_ADDRESS -chain main|test|regtest(optional) -subsidy N(optional) -maturity N(optional) -bits N(optional) -halving N(optional)")
+	fmt.Println("  printchain -json(optional)")
+	fmt.Println("  getbalance -address YOUR_ADDRESS -json(optional)")
+	fmt.Println("  listtransactions -address ADDRESS")
+	fmt.Println("  listunspent -address ADDRESS")
+	fmt.Println("  getrawtransaction -txid TXID")
+	fmt.Println("  decoderawtransaction -hex RAW")
+	fmt.Println("  getmerkleproof -txid TXID")
+	fmt.Println("  getblockhash -height N")
+	fmt.Println("  getblock -hash HASH")
+	fmt.Println("  nodestatus")
+	fmt.Println("  getmempoolinfo")
+	fmt.Println("  getrawmempool")
+	fmt.Println("  minework -address REWARD_ADDRESS(optional)")
+	fmt.Println("  generate -count N(optional) -address REWARD_ADDRESS(optional with a node running)")
+	fmt.Println("  faucet -address ADDRESS -amount AMOUNT")
+	fmt.Println("  send -from FROM -to TO -amount AMOUNT -fee FEE(optional) -locktime HEIGHT_OR_TIME(optional) -rbf(optional) -data TEXT(optional) -coins largest|smallest|bnb(optional)")
+	fmt.Println("  testsend -from FROM -to TO -amount AMOUNT -fee FEE(optional) -coins largest|smallest|bnb(optional)")
+	fmt.Println("  sendmany -from FROM -outputs ADDR1:AMOUNT1,ADDR2:AMOUNT2,... -fee FEE(optional) -coins largest|smallest|bnb(optional)")
+	fmt.Println("  startnode -miner MINER_ADDRESS(optional) -peers PEERS_FILE(optional) -listen HOST:PORT(optional) -rpc PORT(optional) -threads N(optional) -chain main|test|regtest(optional) -wallet FILE(optional) -prune DEPTH(optional) -maxreorg DEPTH(optional) -loglevel debug|info|warn|error(optional) -rpctoken TOKEN(optional) -tcpauth(optional) -maxpeers N(optional)")
+	fmt.Println("  reindexutxo")
+	fmt.Println("  verifychain")
+}
+
+func (c *CLI) validateArgs() {
+	if len(os.Args) < 2 {
+		c.printUsage()
+		os.Exit(1)
+	}
+}
+
+func (c *CLI) createBlockchain(address, chain string, overrides chainOverrides) {
+	if err := wallet.CheckAddress(address); err != nil {
+		fmt.Println("Invalid address:", err)
+		return
+	}
+	if core.DBExists(nodeID()) {
+		fmt.Printf("Blockchain already exists. Delete %s to recreate.\n", "blockchain_"+nodeID()+".db")
+		return
+	}
+	cfg, err := chainConfig(chain)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	overrides.apply(&cfg)
+	if err := cfg.Validate(); err != nil {
+		fmt.Println(err)
+		return
+	}
+	if ws, err := loadWallets(); err != nil || !ws.Owns(address) {
+		fmt.Printf("Warning: %s has no key for %s; only its key holder can spend the genesis reward.\n", walletFile(), address)
+	}
+	bc, err := core.CreateBlockchainForNode(address, nodeID(), cfg)
+	if err != nil {
+		fmt.Println("Failed to create blockchain:", err)
+		printDBLockedHint(err)
+		return
+	}
+	defer func() { _ = bc.Close() }()
+
+	reward := 0
+	for _, out := range (core.UTXOSet{Blockchain: bc}).FindUTXO(wallet.PubKeyHashFromAddress(address)) {
+		reward += out.Value
+	}
+	fmt.Printf("Done! Created a new %s blockchain.\n", cfg.Name)
+	fmt.Printf("Genesis reward of %d credited to %s.\n", reward, address)
+	cfg = bc.Config()
+	retarget := "retargeted"
+	if cfg.NoRetargeting {
+		retarget = "never retargeted"
+	}
+	fmt.Printf("Subsidy: %d, halving every %d blocks\n", cfg.Subsidy, cfg.HalvingInterval)
+	fmt.Printf("Coinbase maturity: %d blocks\n", cfg.CoinbaseMaturity)
+	fmt.Printf("Genesis difficulty: %d bits, %s\n", cfg.TargetBits, retarget)
+	fmt.Printf("Size limits: %d bytes per block, %d per transaction; dust threshold %d\n", cfg.MaxBlockSize, cfg.MaxTxSize, cfg.DustThreshold)
+	if cfg.LowSHeight > 0 {
+		fmt.Printf("Low-S signatures required from block %d\n", cfg.LowSHeight)
+	}
+}
+
+// printChain prints the main chain, tip first, as text or (asJSON) as GET /chain does.
+func (c *CLI) printChain(asJSON bool) {
+	// Ask the running node to print chain state.
+	blocks, msg, err := network.GetChainRequest(nodeID())
+	if err != nil {
+		// Fallback for offline/single-process usage.
+		if !core.DBExists(nodeID()) {
+			fmt.Println("No blockchain found. Run: createblockchain -address YOUR_ADDRESS")
+			return
+		}
+		bc, err := core.OpenBlockchainReadOnlyForNode(nodeID())
+		if err != nil {
+			printOpenError(err)
+			return
+		}
+		defer func() { _ = bc.Close() }()
+
+		if len(bc.Tip()) == 0 && !asJSON {
+			fmt.Printf("Blockchain DB exists for node %s, but it has no blocks yet.\n", nodeID())
+			fmt.Println("If this is a networking node, run: startnode (and make sure node 3000 is running).")
+			fmt.Println("If you want a standalone chain on this node, run: createblockchain -address YOUR_ADDRESS (after deleting the DB).")
+			return
+		}
+		blocks, msg = network.ChainSnapshot(bc), ""
+	}
+
+	if asJSON {
+		printJSON(network.ChainJSON(blocks))
+		return
+	}
+	if msg != "" {
+		fmt.Println(msg)
+	}
+	for _, b := range blocks {
+		fmt.Printf("===== Block %d =====\n", b.Index)
+		fmt.Printf("Timestamp: %d\n", b.Timestamp)
+		fmt.Printf("Prev. hash: %x\n", b.PrevHash)
+		fmt.Printf("Hash: %x\n", b.Hash)
+		fmt.Printf("Nonce: %d\n", b.Nonce)
+		fmt.Printf("Merkle: %x\n", b.Merkle)
+		fmt.Printf("Bits: %d\n", b.Bits)
+		fmt.Printf("Confirmations: %d\n", b.Confirmations)
+		if b.Pruned {
+			fmt.Println("Transactions: pruned")
+		} else {
+			fmt.Printf("Tx count: %d\n", len(b.TxIDs))
+		}
+		for _, txid := range b.TxIDs {
+			fmt.Printf("  TxID: %x\n", txid)
+		}
+		fmt.Println()
+	}
+}
+
+func (c *CLI) getBalance(address string, asJSON bool) {
+	if err := wallet.CheckAddress(address); err != nil {
+		fmt.Println("Invalid address:", err)
+		return
+	}
+
+	// Ask the running node to compute balance.
+	balance, err := network.GetBalanceRequest(nodeID(), address)
+	if err == nil {
+		printBalance(address, balance, asJSON)
+		return
+	}
+	if !errors.Is(err, network.ErrNodeUnreachable) {
+		fmt.Println("Error:", err)
+		return
+	}
+
+	// Fallback for offline/single-process usage.
+	if !core.DBExists(nodeID()) {
+		fmt.Println("No blockchain found. Run: createblockchain -address YOUR_ADDRESS")
+		return
+	}
+	bc, err := core.OpenBlockchainReadOnlyForNode(nodeID())
+	if err != nil {
+		printOpenError(err)
+		return
+	}
+	defer func() { _ = bc.Close() }()
+
+	pubKeyHash := wallet.PubKeyHashFromAddress(address)
+	UTXOs := core.UTXOSet{Blockchain: bc}.FindUTXO(pubKeyHash)
+	balance = 0
+	for _, out := range UTXOs {
+		balance += out.Value
+	}
+	printBalance(address, balance, asJSON)
+}
+
+func printBalance(address string, balance int, asJSON bool) {
+	if asJSON {
+		printJSON(network.BalanceJSON(address, balance))
+		return
+	}
+	fmt.Printf("Balance of '%s': %d\n", address, balance)
+}
+
+// printJSON writes v as indented JSON for -json output.
+func printJSON(v any) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		fmt.Println("Failed to encode JSON:", err)
+	}
+}
+
+func (c *CLI) getRawTransaction(txidHex string) {
+	txid, err := hex.DecodeString(txidHex)
+	if err != nil || len(txid) == 0 {
+		fmt.Println("Invalid transaction ID")
+		return
+	}
+
+	// Ask the running node, which also knows about pending transactions.
+	raw, err := network.GetRawTxRequest(nodeID(), txid)
+	var remoteErr *network.RemoteError
+	if errors.As(err, &remoteErr) {
+		fmt.Println("Error:", remoteErr)
+		return
+	}
+	if err != nil {
+		// Fallback for offline/single-process usage.
+		if !core.DBExists(nodeID()) {
+			fmt.Println("No blockchain found. Run: createblockchain -address YOUR_ADDRESS")
+			return
+		}
+		bc, err := core.OpenBlockchainReadOnlyForNode(nodeID())
+		if err != nil {
+			printOpenError(err)
+			return
+		}
+		defer func() { _ = bc.Close() }()
+
+		tx, blockHash, height, err := bc.FindTransactionLocation(txid)
+		if err != nil {
+			fmt.Printf("Error: transaction %x not found\n", txid)
+			return
+		}
+		raw = network.NewRawTx(&tx, blockHash)
+		raw.Confirmations = bc.BestHeight() - height + 1
+	}
+
+	fmt.Printf("TxID: %x\n", raw.ID)
+	fmt.Printf("Coinbase: %t\n", raw.Coinbase)
+	if raw.LockTime != 0 {
+		fmt.Printf("LockTime: %d\n", raw.LockTime)
+	}
+	if raw.Replaceable {
+		fmt.Println("Replaceable: yes")
+	}
+	if len(raw.BlockHash) == 0 {
+		fmt.Println("Block: (pending in mempool)")
+	} else {
+		fmt.Printf("Block: %x\n", raw.BlockHash)
+		fmt.Printf("Confirmations: %d\n", raw.Confirmations)
+	}
+	printTxIO(raw)
+	fmt.Printf("Raw: %x\n", raw.Raw)
+}
+
+// decodeRawTransaction prints a serialized transaction, as shown by getrawtransaction's
+// Raw line, without looking it up.
+func (c *CLI) decodeRawTransaction(rawHex string) {
+	data, err := hex.DecodeString(strings.TrimSpace(rawHex))
+	if err != nil || len(data) == 0 {
+		fmt.Println("Invalid hex")
+		return
+	}
+	tx, err := core.DecodeTransaction(data)
+	if err != nil {
+		fmt.Println("Not a serialized transaction:", err)
+		return
+	}
+	if !bytes.Equal(tx.ID, tx.Hash()) {
+		fmt.Printf("Not a valid transaction: ID %x does not match its contents\n", tx.ID)
+		return
+	}
+
+	raw := network.NewRawTx(tx, nil)
+	fmt.Printf("TxID: %x\n", raw.ID)
+	fmt.Printf("Coinbase: %t\n", raw.Coinbase)
+	if raw.LockTime != 0 {
+		fmt.Printf("LockTime: %d\n", raw.LockTime)
+	}
+	if raw.Replaceable {
+		fmt.Println("Replaceable: yes")
+	}
+	printTxIO(raw)
+}
+
+// printTxIO prints the inputs and outputs of raw.
+func printTxIO(raw network.RawTx) {
+	for i, in := range raw.Inputs {
+		fmt.Printf("Input %d:\n", i)
+		if raw.Coinbase {
+			fmt.Printf("  Data: %s\n", in.PubKey)
+			continue
+		}
+		fmt.Printf("  TXID: %x\n", in.Txid)
+		fmt.Printf("  Out:  %d\n", in.Vout)
+		fmt.Printf("  Sig:  %x\n", in.Signature)
+		fmt.Printf("  Pub:  %x\n", in.PubKey)
+	}
+	for i, out := range raw.Outputs {
+		fmt.Printf("Output %d:\n", i)
+		if out.Address == "" {
+			fmt.Printf("  Data:    %q\n", out.Data)
+			continue
+		}
+		fmt.Printf("  Value:   %d\n", out.Value)
+		fmt.Printf("  Address: %s\n", out.Address)
+	}
+}
+
+func (c *CLI) getMerkleProof(txidHex string) {
+	txid, err := hex.DecodeString(txidHex)
+	if err != nil || len(txid) == 0 {
+		fmt.Println("Invalid transaction ID")
+		return
+	}
+
+	res, err := network.GetMerkleProofRequest(nodeID(), txid)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
+	fmt.Printf("Block: %x\n", res.BlockHash)
+	fmt.Printf("Merkle root: %x\n", res.MerkleRoot)
+	for i, sibling := range res.Proof {
+		side := "right"
+		if res.Left[i] {
+			side = "left"
+		}
+		fmt.Printf("  %2d %-5s %x\n", i, side, sibling)
+	}
+	fmt.Printf("Verified: %t\n", core.VerifyMerkleProof(res.MerkleRoot, txid, res.Proof, res.Left))
+}
+
+func (c *CLI) getBlockHash(height int) {
+	// Ask the running node, then fall back to the local DB.
+	hash, err := network.GetBlockHashRequest(nodeID(), height)
+	var remoteErr *network.RemoteError
+	if errors.As(err, &remoteErr) {
+		fmt.Println("Error:", remoteErr)
+		return
+	}
+	if err != nil {
+		if !core.DBExists(nodeID()) {
+			fmt.Println("No blockchain found. Run: createblockchain -address YOUR_ADDRESS")
+			return
+		}
+		bc, err := core.OpenBlockchainReadOnlyForNode(nodeID())
+		if err != nil {
+			printOpenError(err)
+			return
+		}
+		defer func() { _ = bc.Close() }()
+
+		if hash, err = bc.GetBlockHash(height); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+	}
+	fmt.Printf("%x\n", hash)
+}
+
+func (c *CLI) getBlock(hashHex string) {
+	hash, err := hex.DecodeString(hashHex)
+	if err != nil || len(hash) == 0 {
+		fmt.Println("Invalid block hash")
+		return
+	}
+
+	// Ask the running node, then fall back to the local DB.
+	info, err := network.GetBlockRequest(nodeID(), hash)
+	var remoteErr *network.RemoteError
+	if errors.As(err, &remoteErr) {
+		fmt.Println("Error:", remoteErr)
+		return
+	}
+	if err != nil {
+		if !core.DBExists(nodeID()) {
+			fmt.Println("No blockchain found. Run: createblockchain -address YOUR_ADDRESS")
+			return
+		}
+		bc, err := core.OpenBlockchainReadOnlyForNode(nodeID())
+		if err != nil {
+			printOpenError(err)
+			return
+		}
+		defer func() { _ = bc.Close() }()
+
+		if info, err = network.BlockInfoFor(bc, hash); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+	}
+
+	b := info.Block
+	fmt.Printf("Hash: %x\n", b.Hash)
+	fmt.Printf("Height: %d\n", b.Index)
+	fmt.Printf("Prev. hash: %x\n", b.PrevHash)
+	fmt.Printf("Timestamp: %d\n", b.Timestamp)
+	fmt.Printf("Nonce: %d\n", b.Nonce)
+	fmt.Printf("Merkle: %x\n", b.Merkle)
+	fmt.Printf("Bits: %d\n", b.Bits)
+	if info.MainChain {
+		fmt.Printf("Confirmations: %d\n", b.Confirmations)
+	} else {
+		fmt.Println("Confirmations: 0 (side branch)")
+	}
+	if b.Pruned {
+		fmt.Println("Transactions: pruned")
+		return
+	}
+	fmt.Printf("Tx count: %d\n", len(info.Txs))
+	for _, raw := range info.Txs {
+		fmt.Println()
+		fmt.Printf("TxID: %x\n", raw.ID)
+		fmt.Printf("Coinbase: %t\n", raw.Coinbase)
+		printTxIO(raw)
+	}
 }
 
-func (c *CLI) validateArgs() {
-	if len(os.Args) < 2 {
-		c.printUsage()
-		os.Exit(1)
+func (c *CLI) nodeStatus() {
+	status, err := network.GetStatusRequest(nodeID())
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
+	fmt.Printf("Protocol version: %d\n", status.ProtocolVersion)
+	fmt.Printf("Height: %d\n", status.BestHeight)
+	fmt.Printf("Tip: %x\n", status.Tip)
+	if s := status.Sync; s.Syncing {
+		fmt.Printf("Sync: %d/%d blocks (%.1f%%)\n", s.Height, s.Target, s.Percent)
+	} else {
+		fmt.Println("Sync: up to date")
+	}
+	fmt.Printf("Peers: %d", status.Peers)
+	if status.MaxPeers > 0 {
+		fmt.Printf(" (max %d, %d evicted)", status.MaxPeers, status.PeersEvicted)
+	}
+	fmt.Println()
+	for _, p := range status.PeerHealth {
+		state := "ok"
+		switch {
+		case p.Dead:
+			state = "dead"
+		case p.Failures > 0:
+			state = "failing"
+		case p.LastSeen == 0:
+			state = "not contacted"
+		}
+		fmt.Printf("  %s: %s", p.Address, state)
+		if p.Latency > 0 {
+			fmt.Printf(", ping %v", p.Latency.Round(time.Microsecond))
+		}
+		if p.Failures > 0 {
+			fmt.Printf(", failures: %d", p.Failures)
+		}
+		fmt.Println()
+	}
+	fmt.Printf("Mempool: %d transactions\n", status.Mempool)
+	if status.Miner != "" {
+		fmt.Printf("Mining to: %s\n", status.Miner)
+	} else {
+		fmt.Println("Mining: off")
+	}
+	if a := status.ReorgAlert; a != nil {
+		fmt.Printf("ALERT: at %s refused a reorg from %x to %x disconnecting %d blocks above height %d\n",
+			time.Unix(a.Time, 0).Format(time.RFC3339), a.From, a.To, a.Depth, a.ForkHeight)
+	}
+}
+
+// getMempoolInfo prints a summary of the running node's pending transactions.
+func (c *CLI) getMempoolInfo() {
+	info, err := network.GetMempoolRequest(nodeID())
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	fmt.Printf("Transactions: %d\n", len(info.Txs))
+	fmt.Printf("Size: %d bytes\n", info.Bytes)
+	fmt.Printf("Fees: %d\n", info.Fees)
+}
+
+// getRawMempool lists the running node's pending transactions, oldest first.
+func (c *CLI) getRawMempool() {
+	info, err := network.GetMempoolRequest(nodeID())
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	for _, tx := range info.Txs {
+		fmt.Printf("%x fee=%d size=%d\n", tx.ID, tx.Fee, tx.Size)
+	}
+}
+
+// listTransactions prints every confirmed transaction paying to or spending from address,
+// oldest first, with what it received and spent.
+func (c *CLI) listTransactions(address string) {
+	if err := wallet.CheckAddress(address); err != nil {
+		fmt.Println("Invalid address:", err)
+		return
+	}
+
+	history, err := network.GetHistoryRequest(nodeID(), address)
+	var remoteErr *network.RemoteError
+	if errors.As(err, &remoteErr) {
+		fmt.Println("Error:", remoteErr)
+		return
+	}
+	if err != nil {
+		// Fallback for offline/single-process usage.
+		if !core.DBExists(nodeID()) {
+			fmt.Println("No blockchain found. Run: createblockchain -address YOUR_ADDRESS")
+			return
+		}
+		bc, err := core.OpenBlockchainReadOnlyForNode(nodeID())
+		if err != nil {
+			printOpenError(err)
+			return
+		}
+		defer func() { _ = bc.Close() }()
+
+		if history, err = bc.FindTransactionsForAddress(wallet.PubKeyHashFromAddress(address)); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+	}
+
+	if len(history) == 0 {
+		fmt.Printf("No transactions for '%s'\n", address)
+		return
+	}
+	// Every output the address spends was paid to it by an earlier entry of its history.
+	pubKeyHash := wallet.PubKeyHashFromAddress(address)
+	seen := make(map[string]core.Transaction, len(history))
+	for _, entry := range history {
+		received, spent := 0, 0
+		for _, out := range entry.Tx.Vout {
+			if out.IsLockedWithKey(pubKeyHash) {
+				received += out.Value
+			}
+		}
+		if !entry.Tx.IsCoinbase() {
+			for _, in := range entry.Tx.Vin {
+				if prev, ok := seen[string(in.Txid)]; ok && in.UsesKey(pubKeyHash) && in.Vout < len(prev.Vout) {
+					spent += prev.Vout[in.Vout].Value
+				}
+			}
+		}
+		seen[string(entry.Tx.ID)] = entry.Tx
+		fmt.Printf("Height %d  %x  received %d  spent %d\n", entry.Height, entry.Tx.ID, received, spent)
+	}
+}
+
+// listUnspent prints the individual unspent outputs that make up address's balance.
+func (c *CLI) listUnspent(address string) {
+	if err := wallet.CheckAddress(address); err != nil {
+		fmt.Println("Invalid address:", err)
+		return
+	}
+
+	utxos, err := network.ListUnspentRequest(nodeID(), address)
+	var remoteErr *network.RemoteError
+	if errors.As(err, &remoteErr) {
+		fmt.Println("Error:", remoteErr)
+		return
+	}
+	if err != nil {
+		// Fallback for offline/single-process usage.
+		if !core.DBExists(nodeID()) {
+			fmt.Println("No blockchain found. Run: createblockchain -address YOUR_ADDRESS")
+			return
+		}
+		bc, err := core.OpenBlockchainReadOnlyForNode(nodeID())
+		if err != nil {
+			printOpenError(err)
+			return
+		}
+		defer func() { _ = bc.Close() }()
+		utxos = bc.ListUTXOs(wallet.PubKeyHashFromAddress(address))
+	}
+
+	if len(utxos) == 0 {
+		fmt.Printf("No unspent outputs for '%s'\n", address)
+		return
+	}
+	total := 0
+	for _, u := range utxos {
+		note := ""
+		if u.Coinbase {
+			note = "  coinbase"
+			if !u.Mature {
+				note += " (immature)"
+			}
+		}
+		fmt.Printf("%x:%d  value %d  height %d%s\n", u.TxID, u.Vout, u.Value, u.Height, note)
+		total += u.Value
+	}
+	fmt.Printf("%d outputs, total %d\n", len(utxos), total)
+}
+
+// mineWork acts as an external miner: it fetches a template from the running node, solves
+// it here and submits the nonce back.
+func (c *CLI) mineWork(address string) {
+	if address != "" {
+		if err := wallet.CheckAddress(address); err != nil {
+			fmt.Println("Invalid reward address:", err)
+			return
+		}
+	}
+
+	work, err := network.GetWorkRequest(nodeID(), address)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	fmt.Printf("Mining block %d on %x (%d bits)...\n", work.Height, work.PrevBlockHash, work.TargetBits)
+
+	nonce := 0
+	for !work.Solves(nonce) {
+		nonce++
+	}
+	hash, err := network.SubmitWorkRequest(nodeID(), work.ID, nonce)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	fmt.Printf("Success! Block %s accepted (nonce %d).\n", hash, nonce)
+}
+
+// generate mines count blocks now: through the running node, draining its mempool, or
+// offline as empty blocks paying address.
+func (c *CLI) generate(count int, address string) {
+	if count < 1 {
+		fmt.Println("-count must be at least 1")
+		return
+	}
+	if address != "" {
+		if err := wallet.CheckAddress(address); err != nil {
+			fmt.Println("Invalid reward address:", err)
+			return
+		}
+	}
+
+	hashes, err := network.GenerateRequest(nodeID(), count, address)
+	if err != nil && errors.Is(err, network.ErrNodeUnreachable) {
+		// Fallback for offline/single-process usage: no mempool, so the blocks are empty.
+		if address == "" {
+			fmt.Println("No node is running; give -address to mine offline.")
+			return
+		}
+		if !core.DBExists(nodeID()) {
+			fmt.Println("No blockchain found. Run: createblockchain -address YOUR_ADDRESS")
+			return
+		}
+		bc, openErr := core.OpenBlockchainForNode(nodeID())
+		if openErr != nil {
+			printOpenError(openErr)
+			return
+		}
+		defer func() { _ = bc.Close() }()
+		hashes, err = nil, nil
+		for len(hashes) < count && err == nil {
+			var hash []byte
+			if hash, err = bc.AddBlock([]*core.Transaction{bc.Config().CoinbaseTx(address, "", bc.BestHeight()+1)}); err == nil {
+				hashes = append(hashes, hash)
+			}
+		}
+		if len(hashes) > 0 {
+			network.BroadcastNewBlock(nodeID(), hashes[len(hashes)-1])
+		}
+	}
+
+	for _, hash := range hashes {
+		fmt.Printf("%x\n", hash)
+	}
+	switch {
+	case err != nil && len(hashes) == 0:
+		fmt.Println("Error:", err)
+	case err != nil:
+		fmt.Printf("Mined %d of %d blocks: %v\n", len(hashes), count, err)
+	default:
+		fmt.Printf("Success! Mined %d blocks.\n", len(hashes))
+	}
+}
+
+// faucet asks the running regtest node to pay amount to address from its miner's coins.
+func (c *CLI) faucet(address string, amount int) {
+	if err := wallet.CheckAddress(address); err != nil {
+		fmt.Println("Invalid address:", err)
+		return
+	}
+	if amount <= 0 {
+		fmt.Println("-amount must be positive")
+		return
+	}
+
+	txID, hashes, err := network.FaucetRequest(nodeID(), address, amount)
+	switch {
+	case errors.Is(err, network.ErrNodeUnreachable):
+		fmt.Println("No node is running; start a regtest node with -miner to use the faucet.")
+		return
+	case err != nil:
+		fmt.Println("Error:", err)
+		if len(hashes) > 0 {
+			fmt.Printf("Mined %d blocks before failing.\n", len(hashes))
+		}
+		return
+	}
+	fmt.Printf("Success! Sent %d to %s in transaction %x (mined %d blocks).\n", amount, address, txID, len(hashes))
+}
+
+func (c *CLI) send(from, to string, amount int, opts core.TxOptions) {
+	if err := wallet.CheckAddress(from); err != nil {
+		fmt.Println("Invalid from address:", err)
+		return
+	}
+	if err := wallet.CheckAddress(to); err != nil {
+		fmt.Println("Invalid to address:", err)
+		return
+	}
+	c.sendMany(from, map[string]int{to: amount}, opts)
+}
+
+// sendMany pays every address in outputs from one transaction, through the running
+// node if there is one.
+func (c *CLI) sendMany(from string, outputs map[string]int, opts core.TxOptions) {
+	if err := wallet.CheckAddress(from); err != nil {
+		fmt.Println("Invalid from address:", err)
+		return
+	}
+
+	msg, err := network.SendManyRequest(nodeID(), from, outputs, opts)
+	switch {
+	case errors.Is(err, network.ErrInsufficientFunds):
+		fmt.Println("Send rejected by node:", err)
+		fmt.Println("Coinbase rewards only become spendable once mature, and coins spent by pending transactions are reserved; check getbalance.")
+		return
+	case errors.Is(err, network.ErrUnknownSender):
+		fmt.Println("Send rejected by node:", err)
+		fmt.Printf("The node signs with its own wallet file; start it with -wallet pointing at the file holding the key for %s.\n", from)
+		return
+	case err != nil && !errors.Is(err, network.ErrNodeUnreachable):
+		fmt.Println("Send rejected by node:", err)
+		return
+	}
+	if err != nil {
+		// Fallback for single-node/offline usage: mine locally if no server is running.
+		fmt.Println("Send via running node failed:", err)
+		fmt.Println("Falling back to local mining (startnode not required).")
+		if !core.DBExists(nodeID()) {
+			fmt.Println("No blockchain found. Run: createblockchain -address YOUR_ADDRESS")
+			return
+		}
+		ws, werr := loadWallets()
+		if werr != nil {
+			fmt.Println("Failed to load wallets:", werr)
+			return
+		}
+		bc, err := core.OpenBlockchainForNode(nodeID())
+		if err != nil {
+			printOpenError(err)
+			return
+		}
+		defer func() { _ = bc.Close() }()
+		tx, err := core.NewUTXOTransactionMultiWithOptions(from, outputs, opts, bc, ws)
+		if err != nil {
+			fmt.Println("Send failed:", err)
+			return
+		}
+		if err := bc.CheckFinal(tx); err != nil {
+			fmt.Println("Send failed:", err)
+			return
+		}
+		cb := bc.Config().CoinbaseTxWithFees(from, "", bc.BestHeight()+1, opts.Fee)
+		newTip, err := bc.AddBlock([]*core.Transaction{cb, tx})
+		if err != nil {
+			fmt.Println("Send failed:", err)
+			return
+		}
+		fmt.Println("Success! Transaction mined into a new block.")
+		network.BroadcastNewBlock(nodeID(), newTip)
+		return
+	}
+	fmt.Println(msg)
+}
+
+// testSend reports whether send would succeed and which outputs it would spend, without
+// signing or sending anything.
+func (c *CLI) testSend(from, to string, amount int, opts core.TxOptions) {
+	if err := wallet.CheckAddress(from); err != nil {
+		fmt.Println("Invalid from address:", err)
+		return
+	}
+	if err := wallet.CheckAddress(to); err != nil {
+		fmt.Println("Invalid to address:", err)
+		return
+	}
+
+	outputs := map[string]int{to: amount}
+	funding, err := network.TestSendRequest(nodeID(), from, outputs, opts)
+	if err != nil && errors.Is(err, network.ErrNodeUnreachable) {
+		// Fallback for offline usage: fund it as a locally mined send would be.
+		if !core.DBExists(nodeID()) {
+			fmt.Println("No blockchain found. Run: createblockchain -address YOUR_ADDRESS")
+			return
+		}
+		ws, werr := loadWallets()
+		if werr != nil {
+			fmt.Println("Failed to load wallets:", werr)
+			return
+		}
+		bc, oerr := core.OpenBlockchainReadOnlyForNode(nodeID())
+		if oerr != nil {
+			printOpenError(oerr)
+			return
+		}
+		defer func() { _ = bc.Close() }()
+		funding, err = core.FundTransaction(from, outputs, opts, bc, ws, nil)
+	}
+	if err != nil {
+		fmt.Println("Send would fail:", err)
+		if errors.Is(err, network.ErrInsufficientFunds) || errors.Is(err, core.ErrInsufficientFunds) {
+			fmt.Println("Coinbase rewards only become spendable once mature, and coins spent by pending transactions are reserved; check getbalance.")
+		}
+		return
+	}
+
+	fmt.Printf("Send of %d from %s is fundable. Nothing was signed or sent.\n", funding.Amount, from)
+	for _, in := range funding.Inputs {
+		note := ""
+		if in.Unconfirmed {
+			note = "  unconfirmed"
+		}
+		fmt.Printf("  input %x:%d  value %d%s\n", in.TxID, in.Vout, in.Value, note)
+	}
+	fmt.Printf("Inputs %d, change %d, fee %d\n", funding.InputValue, funding.Change, funding.Fee)
+}
+
+func (c *CLI) reindexUTXO() {
+	if !core.DBExists(nodeID()) {
+		fmt.Println("No blockchain found. Run: createblockchain -address YOUR_ADDRESS")
+		return
+	}
+	bc, err := core.OpenBlockchainForNode(nodeID())
+	if err != nil {
+		printOpenError(err)
+		return
+	}
+	defer func() { _ = bc.Close() }()
+
+	if err := (core.UTXOSet{Blockchain: bc}).Reindex(); err != nil {
+		fmt.Println("Failed to rebuild the UTXO set:", err)
+		return
+	}
+	fmt.Println("Done! Rebuilt the UTXO set.")
+}
+
+// verifyChain re-validates every stored block of the local chain. The DB must not be
+// held by a running node.
+func (c *CLI) verifyChain() {
+	if !core.DBExists(nodeID()) {
+		fmt.Println("No blockchain found. Run: createblockchain -address YOUR_ADDRESS")
+		return
+	}
+	bc, err := core.OpenBlockchainReadOnlyForNode(nodeID())
+	if err != nil {
+		printOpenError(err)
+		return
+	}
+	defer func() { _ = bc.Close() }()
+
+	if err := bc.VerifyChain(); err != nil {
+		fmt.Println("Verification failed:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Chain OK: %d blocks verified.\n", bc.BestHeight())
+}
+
+func (c *CLI) startNode(miner, peersFile, rpcPort, listen string, threads int, chain, walletPath string, prune, maxReorg int, logLevel, token string, tcpAuth bool, maxPeers int) {
+	if miner != "" {
+		if err := wallet.CheckAddress(miner); err != nil {
+			fmt.Println("Invalid miner address:", err)
+			return
+		}
+	}
+	if listen != "" {
+		if err := network.ValidatePeerAddr(listen); err != nil {
+			fmt.Println("-listen:", err)
+			return
+		}
+	}
+	network.ListenAddr = listen
+	if tcpAuth && token == "" {
+		fmt.Println("-tcpauth needs a token: set -rpctoken or $RPC_TOKEN")
+		return
+	}
+	if listen != "" {
+		if err := network.CheckListenAuth(listen, token, tcpAuth); err != nil {
+			fmt.Println("-listen:", err, "(set -rpctoken and -tcpauth)")
+			return
+		}
+	}
+	network.RPCToken = token
+	network.TCPAuth = tcpAuth
+	if maxPeers < 0 {
+		fmt.Println("-maxpeers must be 0 or more")
+		return
+	}
+	network.MaxPeers = maxPeers
+	level, err := core.ParseLogLevel(logLevel)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	core.Log = core.NewLogger(os.Stderr, level)
+	if threads < 1 {
+		fmt.Println("-threads must be at least 1")
+		return
+	}
+	network.MinerThreads = threads
+	if prune != 0 && prune < core.MinPruneDepth {
+		fmt.Printf("-prune must be 0 or at least %d\n", core.MinPruneDepth)
+		return
+	}
+	network.PruneDepth = prune
+	if maxReorg < 0 {
+		fmt.Println("-maxreorg must not be negative")
+		return
+	}
+	core.MaxReorgDepth = maxReorg
+	cfg, err := chainConfig(chain)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	network.Chain = cfg
+	network.WalletFile = walletPath
+	if peersFile == "" {
+		peersFile = os.Getenv("PEERS_FILE")
+	}
+	if peersFile == "" {
+		peersFile = network.PeersFile(nodeID())
+	}
+	network.StartServer(nodeID(), miner, peersFile, rpcPort)
+}
+
+func (c *CLI) createWallet(compressed bool) {
+	ws, err := loadWallets()
+	if err != nil {
+		fmt.Println("Failed to load wallets:", err)
+		return
+	}
+	create := ws.CreateWallet
+	if compressed {
+		create = ws.CreateCompressedWallet
+	}
+	address, err := create()
+	if err != nil {
+		fmt.Println("Failed to create wallet:", err)
+		return
+	}
+	fmt.Println("New address:", address)
+}
+
+func (c *CLI) createHDWallet() {
+	ws, err := loadWallets()
+	if err != nil {
+		fmt.Println("Failed to load wallets:", err)
+		return
+	}
+	mnemonic, address, err := ws.CreateHDWallet()
+	if err != nil {
+		fmt.Println("Failed to create HD wallet:", err)
+		return
+	}
+	fmt.Println("Mnemonic (write it down, it restores every derived address):")
+	fmt.Println(" ", mnemonic)
+	fmt.Println("New address:", address)
+}
+
+func (c *CLI) restoreWallet(mnemonic string, count int) {
+	ws, err := loadWallets()
+	if err != nil {
+		fmt.Println("Failed to load wallets:", err)
+		return
+	}
+	addresses, err := ws.RestoreHDWallet(mnemonic, count)
+	if err != nil {
+		fmt.Println("Failed to restore wallet:", err)
+		return
+	}
+	for _, addr := range addresses {
+		fmt.Println("Restored address:", addr)
+	}
+}
+
+func (c *CLI) listAddresses(asJSON bool) {
+	ws, err := loadWallets()
+	if err != nil {
+		fmt.Println("Failed to load wallets:", err)
+		return
+	}
+	if asJSON {
+		type jsonAddress struct {
+			Address   string `json:"address"`
+			WatchOnly bool   `json:"watchOnly,omitempty"`
+		}
+		list := []jsonAddress{}
+		for _, addr := range ws.GetAddresses() {
+			list = append(list, jsonAddress{Address: addr})
+		}
+		for _, addr := range ws.WatchedAddresses() {
+			list = append(list, jsonAddress{Address: addr, WatchOnly: true})
+		}
+		printJSON(list)
+		return
+	}
+	for _, addr := range ws.GetAddresses() {
+		fmt.Println(addr)
+	}
+	for _, addr := range ws.WatchedAddresses() {
+		fmt.Println(addr, "(watch-only)")
+	}
+}
+
+func (c *CLI) importAddress(address string) {
+	ws, err := loadWallets()
+	if err != nil {
+		fmt.Println("Failed to load wallets:", err)
+		return
+	}
+	if err := ws.ImportAddress(address); err != nil {
+		fmt.Println("Failed to import address:", err)
+		return
+	}
+	fmt.Println("Watching address:", address)
+}
+
+// encryptWallet encrypts the private keys and HD seed of the local wallet file.
+func (c *CLI) encryptWallet(passphrase string) {
+	ws, err := loadWallets()
+	if err != nil {
+		fmt.Println("Failed to load wallets:", err)
+		return
+	}
+	if err := ws.Encrypt(passphrase); err != nil {
+		fmt.Println("Failed to encrypt wallet:", err)
+		return
+	}
+	fmt.Printf("Encrypted %d keys in %s. A running node signs only after walletpassphrase; local commands need $WALLET_PASSPHRASE.\n", len(ws.Wallets), ws.File())
+}
+
+// walletPassphrase unlocks the running node's encrypted wallet file for timeout seconds.
+func (c *CLI) walletPassphrase(passphrase string, timeout int) {
+	msg, err := network.WalletPassphraseRequest(nodeID(), passphrase, timeout)
+	if err != nil {
+		fmt.Println("Unlock failed:", err)
+		return
+	}
+	fmt.Println(msg)
+}
+
+// walletLock locks the running node's wallet file at once.
+func (c *CLI) walletLock() {
+	msg, err := network.WalletLockRequest(nodeID())
+	if err != nil {
+		fmt.Println("Lock failed:", err)
+		return
+	}
+	fmt.Println(msg)
+}
+
+// getWalletBalance prints the balance of every owned and watch-only address and their totals.
+func (c *CLI) getWalletBalance(asJSON bool) {
+	ws, err := loadWallets()
+	if err != nil {
+		fmt.Println("Failed to load wallets:", err)
+		return
+	}
+	owned, watched := ws.GetAddresses(), ws.WatchedAddresses()
+	sort.Strings(owned)
+	balances, err := c.balances(append(append([]string(nil), owned...), watched...))
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
+	type jsonBalance struct {
+		Address   string `json:"address"`
+		Balance   int    `json:"balance"`
+		WatchOnly bool   `json:"watchOnly,omitempty"`
 	}
-}
+	var summary struct {
+		Addresses []jsonBalance `json:"addresses"`
+		Owned     int           `json:"owned"`
+		WatchOnly int           `json:"watchOnly"`
+		Total     int           `json:"total"`
+	}
+	summary.Addresses = []jsonBalance{}
+	for _, addr := range owned {
+		summary.Addresses = append(summary.Addresses, jsonBalance{Address: addr, Balance: balances[addr]})
+		summary.Owned += balances[addr]
+	}
+	for _, addr := range watched {
+		summary.Addresses = append(summary.Addresses, jsonBalance{Address: addr, Balance: balances[addr], WatchOnly: true})
+		summary.WatchOnly += balances[addr]
+	}
+	summary.Total = summary.Owned + summary.WatchOnly
 
-func (c *CLI) createBlockchain(address string) {
-	if core.DBExists(nodeID()) {
-		fmt.Printf("Blockchain already exists. Delete %s to recreate.\n", "blockchain_"+nodeID()+".db")
+	if asJSON {
+		printJSON(summary)
 		return
 	}
-	bc := core.CreateBlockchainForNode(address, nodeID())
-	defer func() { _ = bc.Close() }()
-	fmt.Println("Done! Created a new blockchain.")
+	for _, b := range summary.Addresses {
+		if b.WatchOnly {
+			fmt.Printf("%s  %d  (watch-only)\n", b.Address, b.Balance)
+		} else {
+			fmt.Printf("%s  %d\n", b.Address, b.Balance)
+		}
+	}
+	fmt.Printf("Owned: %d\n", summary.Owned)
+	fmt.Printf("Watch-only: %d\n", summary.WatchOnly)
+	fmt.Printf("Total: %d\n", summary.Total)
 }
 
-func (c *CLI) printChain() {
-	// Ask the running node to print chain state.
-	blocks, msg, err := network.GetChainRequest(nodeID())
-	if err == nil {
-		if msg != "" {
-			fmt.Println(msg)
-		}
-		for _, b := range blocks {
-			fmt.Printf("===== Block %d =====\n", b.Index)
-			fmt.Printf("Timestamp: %d\n", b.Timestamp)
-			fmt.Printf("Prev. hash: %x\n", b.PrevHash)
-			fmt.Printf("Hash: %x\n", b.Hash)
-			fmt.Printf("Nonce: %d\n", b.Nonce)
-			fmt.Printf("Merkle: %x\n", b.Merkle)
-			fmt.Printf("Tx count: %d\n", len(b.TxIDs))
-			for _, txid := range b.TxIDs {
-				fmt.Printf("  TxID: %x\n", txid)
-			}
-			fmt.Println()
+// balances returns the balance of each address, from the running node or, if there is
+// none, from the local DB.
+func (c *CLI) balances(addresses []string) (map[string]int, error) {
+	balances := make(map[string]int, len(addresses))
+	for _, addr := range addresses {
+		balance, err := network.GetBalanceRequest(nodeID(), addr)
+		if errors.Is(err, network.ErrNodeUnreachable) {
+			break
 		}
-		return
+		if err != nil {
+			return nil, err
+		}
+		balances[addr] = balance
+	}
+	if len(balances) == len(addresses) {
+		return balances, nil
 	}
 
 	// Fallback for offline/single-process usage.
 	if !core.DBExists(nodeID()) {
-		fmt.Println("No blockchain found. Run: createblockchain -address YOUR_ADDRESS")
-		return
+		return nil, errors.New("no blockchain found. Run: createblockchain -address YOUR_ADDRESS")
+	}
+	bc, err := core.OpenBlockchainReadOnlyForNode(nodeID())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open blockchain: %w", err)
 	}
-	bc := core.OpenBlockchainReadOnlyForNode(nodeID())
 	defer func() { _ = bc.Close() }()
+	utxos := core.UTXOSet{Blockchain: bc}
+	for _, addr := range addresses {
+		balance := 0
+		for _, out := range utxos.FindUTXO(wallet.PubKeyHashFromAddress(addr)) {
+			balance += out.Value
+		}
+		balances[addr] = balance
+	}
+	return balances, nil
+}
 
-	if len(bc.Tip()) == 0 {
-		fmt.Printf("Blockchain DB exists for node %s, but it has no blocks yet.\n", nodeID())
-		fmt.Println("If this is a networking node, run: startnode (and make sure node 3000 is running).")
-		fmt.Println("If you want a standalone chain on this node, run: createblockchain -address YOUR_ADDRESS (after deleting the DB).")
+// rescanBlockchain walks the main chain from start to stop (the tip when 0) for
+// transactions of the wallet's addresses, through the running node if there is one.
+func (c *CLI) rescanBlockchain(start, stop int) {
+	res, addresses, err := network.RescanBlockchainRequest(nodeID(), start, stop)
+	var remoteErr *network.RemoteError
+	if errors.As(err, &remoteErr) {
+		fmt.Println("Rescan failed:", remoteErr)
 		return
 	}
-
-	it := bc.Iterator()
-	index := 0
-	for {
-		block := it.Next()
-		if block == nil {
-			break
+	if err != nil {
+		// Fallback for offline/single-process usage.
+		if !core.DBExists(nodeID()) {
+			fmt.Println("No blockchain found. Run: createblockchain -address YOUR_ADDRESS")
+			return
 		}
-		fmt.Printf("===== Block %d =====\n", index)
-		fmt.Printf("Timestamp: %d\n", block.Timestamp)
-		fmt.Printf("Prev. hash: %x\n", block.PrevBlockHash)
-		fmt.Printf("Hash: %x\n", block.Hash)
-		fmt.Printf("Nonce: %d\n", block.Nonce)
-		fmt.Printf("Merkle: %x\n", block.MerkleRoot)
-		fmt.Printf("Tx count: %d\n", len(block.Transactions))
-		for _, tx := range block.Transactions {
-			fmt.Printf("  TxID: %x\n", tx.ID)
+		ws, err := loadWallets()
+		if err != nil {
+			fmt.Println("Failed to load wallets:", err)
+			return
 		}
-		fmt.Println()
-		index++
-
-		if len(block.PrevBlockHash) == 0 {
-			break
+		bc, err := core.OpenBlockchainReadOnlyForNode(nodeID())
+		if err != nil {
+			printOpenError(err)
+			return
+		}
+		defer func() { _ = bc.Close() }()
+		pubKeyHashes := ws.PubKeyHashes()
+		addresses = len(pubKeyHashes)
+		if res, err = bc.Rescan(pubKeyHashes, start, stop); err != nil {
+			fmt.Println("Rescan failed:", err)
+			return
 		}
 	}
+
+	fmt.Printf("Scanned blocks %d to %d for %d wallet addresses: %d transactions found.\n", res.StartHeight, res.StopHeight, addresses, res.Transactions)
+	if res.Pruned > 0 {
+		fmt.Printf("%d pruned blocks were skipped.\n", res.Pruned)
+	}
 }
 
-func (c *CLI) getBalance(address string) {
-	if !wallet.ValidateAddress(address) {
-		fmt.Println("Invalid address")
+func (c *CLI) dumpPrivKey(address string) {
+	ws, err := loadWallets()
+	if err != nil {
+		fmt.Println("Failed to load wallets:", err)
 		return
 	}
-
-	// Ask the running node to compute balance.
-	balance, err := network.GetBalanceRequest(nodeID(), address)
-	if err == nil {
-		fmt.Printf("Balance of '%s': %d\n", address, balance)
+	wif, err := ws.ExportWIF(address)
+	if err != nil {
+		fmt.Println("Failed to export key:", err)
 		return
 	}
+	fmt.Println(wif)
+}
 
-	// Fallback for offline/single-process usage.
-	if !core.DBExists(nodeID()) {
-		fmt.Println("No blockchain found. Run: createblockchain -address YOUR_ADDRESS")
+func (c *CLI) walletInfo(address string) {
+	ws, err := loadWallets()
+	if err != nil {
+		fmt.Println("Failed to load wallets:", err)
 		return
 	}
-	bc := core.OpenBlockchainReadOnlyForNode(nodeID())
-	defer func() { _ = bc.Close() }()
-
-	pubKeyHash := wallet.PubKeyHashFromAddress(address)
-	UTXOs := bc.FindUTXO(pubKeyHash)
-	balance = 0
-	for _, out := range UTXOs {
-		balance += out.Value
+	w, ok := ws.GetWallet(address)
+	if !ok {
+		fmt.Println("No key for address in the wallet:", address)
+		return
+	}
+	version, pubKeyHash, check, err := wallet.DecodeAddress(address)
+	if err != nil {
+		fmt.Println("Failed to decode address:", err)
+		return
+	}
+	fmt.Println("Address:", address)
+	fmt.Printf("Public key: %x\n", w.PublicKey)
+	if w.Compressed {
+		fmt.Printf("Compressed public key: %x\n", w.CompressedPubKey())
+	}
+	fmt.Printf("Public key hash: %x\n", wallet.HashPubKey(w.PubKey()))
+	fmt.Printf("Version: 0x%02x\n", version)
+	fmt.Printf("Checksum: %x\n", check)
+	// The address should encode the hash of the key the wallet spends with; say so if not.
+	if !bytes.Equal(pubKeyHash, wallet.HashPubKey(w.PubKey())) {
+		fmt.Printf("Warning: the address encodes public key hash %x, which is not this key's\n", pubKeyHash)
 	}
-	fmt.Printf("Balance of '%s': %d\n", address, balance)
 }
 
-func (c *CLI) send(from, to string, amount int) {
-	if !wallet.ValidateAddress(from) || !wallet.ValidateAddress(to) {
-		fmt.Println("Invalid from/to address")
+func (c *CLI) importPrivKey(wif string) {
+	ws, err := loadWallets()
+	if err != nil {
+		fmt.Println("Failed to load wallets:", err)
+		return
+	}
+	address, err := ws.ImportWIF(wif)
+	if err != nil {
+		fmt.Println("Failed to import key:", err)
 		return
 	}
+	fmt.Println("Imported address:", address)
+}
 
-	msg, err := network.SendTxRequest(nodeID(), from, to, amount)
+func (c *CLI) dumpWallet(file string) {
+	ws, err := loadWallets()
 	if err != nil {
-		// Fallback for single-node/offline usage: mine locally if no server is running.
-		fmt.Println("Send via running node failed:", err)
-		fmt.Println("Falling back to local mining (startnode not required).")
-		if !core.DBExists(nodeID()) {
-			fmt.Println("No blockchain found. Run: createblockchain -address YOUR_ADDRESS")
-			return
-		}
-		ws, werr := wallet.NewWallets()
-		if werr != nil {
-			fmt.Println("Failed to load wallets:", werr)
-			return
-		}
-		bc := core.OpenBlockchainForNode(nodeID())
-		defer func() { _ = bc.Close() }()
-		tx := core.NewUTXOTransaction(from, to, amount, bc, ws)
-		cb := core.CoinbaseTx(from, "")
-		newTip := bc.AddBlock([]*core.Transaction{cb, tx})
-		fmt.Println("Success! Transaction mined into a new block.")
-		network.BroadcastNewBlock(nodeID(), newTip)
+		fmt.Println("Failed to load wallets:", err)
 		return
 	}
-	fmt.Println(msg)
+	data, err := ws.Dump()
+	if err != nil {
+		fmt.Println("Failed to dump wallets:", err)
+		return
+	}
+	if err := os.WriteFile(file, data, 0o600); err != nil {
+		fmt.Println("Failed to write backup:", err)
+		return
+	}
+	fmt.Printf("Wrote %d addresses to %s. It holds your private keys; keep it safe.\n", len(ws.Wallets)+len(ws.Multisig), file)
 }
 
-func (c *CLI) startNode(miner string) {
-	if miner != "" && !wallet.ValidateAddress(miner) {
-		fmt.Println("Invalid miner address")
+func (c *CLI) importWallet(file string) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		fmt.Println("Failed to read backup:", err)
+		return
+	}
+	ws, err := loadWallets()
+	if err != nil {
+		fmt.Println("Failed to load wallets:", err)
 		return
 	}
-	network.StartServer(nodeID(), miner)
+	added, err := ws.Import(data)
+	if err != nil {
+		fmt.Println("Failed to import wallets:", err)
+		return
+	}
+	fmt.Printf("Imported %d new addresses.\n", added)
 }
 
-func (c *CLI) createWallet() {
-	ws, err := wallet.NewWallets()
+func (c *CLI) createMultisig(required int, addresses []string) {
+	ws, err := loadWallets()
 	if err != nil {
 		fmt.Println("Failed to load wallets:", err)
 		return
 	}
-	address, err := ws.CreateWallet()
+	address, err := ws.AddMultisig(required, addresses)
 	if err != nil {
-		fmt.Println("Failed to create wallet:", err)
+		fmt.Println("Failed to create multisig address:", err)
 		return
 	}
-	fmt.Println("New address:", address)
+	fmt.Printf("New %d-of-%d address: %s\n", required, len(addresses), address)
 }
 
-func (c *CLI) listAddresses() {
-	ws, err := wallet.NewWallets()
+func (c *CLI) signMessage(address, message string) {
+	ws, err := loadWallets()
 	if err != nil {
 		fmt.Println("Failed to load wallets:", err)
 		return
 	}
-	for _, addr := range ws.GetAddresses() {
-		fmt.Println(addr)
+	sig, err := ws.SignMessage(address, message)
+	if err != nil {
+		fmt.Println("Failed to sign message:", err)
+		return
+	}
+	fmt.Println(base64.StdEncoding.EncodeToString(sig))
+}
+
+func (c *CLI) verifyMessage(address, message, signature string) {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		fmt.Println("Invalid signature encoding:", err)
+		return
+	}
+	if wallet.VerifyMessage(address, message, sig) {
+		fmt.Println("Signature is valid.")
+	} else {
+		fmt.Println("Signature is NOT valid.")
 	}
 }
 
 func (c *CLI) Run() {
 	c.validateArgs()
+	applyDBEnv()
+	network.ListenAddr = listenAddr()
+	network.RPCToken = rpcToken()
 
 	createBlockchainCmd := flag.NewFlagSet("createblockchain", flag.ExitOnError)
 	printChainCmd := flag.NewFlagSet("printchain", flag.ExitOnError)
 	getBalanceCmd := flag.NewFlagSet("getbalance", flag.ExitOnError)
 	sendCmd := flag.NewFlagSet("send", flag.ExitOnError)
+	sendManyCmd := flag.NewFlagSet("sendmany", flag.ExitOnError)
+	testSendCmd := flag.NewFlagSet("testsend", flag.ExitOnError)
 	createWalletCmd := flag.NewFlagSet("createwallet", flag.ExitOnError)
 	listAddressesCmd := flag.NewFlagSet("listaddresses", flag.ExitOnError)
 	startNodeCmd := flag.NewFlagSet("startnode", flag.ExitOnError)
+	reindexUTXOCmd := flag.NewFlagSet("reindexutxo", flag.ExitOnError)
+	verifyChainCmd := flag.NewFlagSet("verifychain", flag.ExitOnError)
+	createHDWalletCmd := flag.NewFlagSet("createhdwallet", flag.ExitOnError)
+	restoreWalletCmd := flag.NewFlagSet("restorewallet", flag.ExitOnError)
+	signMessageCmd := flag.NewFlagSet("signmessage", flag.ExitOnError)
+	verifyMessageCmd := flag.NewFlagSet("verifymessage", flag.ExitOnError)
+	dumpPrivKeyCmd := flag.NewFlagSet("dumpprivkey", flag.ExitOnError)
+	walletInfoCmd := flag.NewFlagSet("walletinfo", flag.ExitOnError)
+	importPrivKeyCmd := flag.NewFlagSet("importprivkey", flag.ExitOnError)
+	importAddressCmd := flag.NewFlagSet("importaddress", flag.ExitOnError)
+	encryptWalletCmd := flag.NewFlagSet("encryptwallet", flag.ExitOnError)
+	walletPassphraseCmd := flag.NewFlagSet("walletpassphrase", flag.ExitOnError)
+	walletLockCmd := flag.NewFlagSet("walletlock", flag.ExitOnError)
+	getWalletBalanceCmd := flag.NewFlagSet("getwalletbalance", flag.ExitOnError)
+	rescanBlockchainCmd := flag.NewFlagSet("rescanblockchain", flag.ExitOnError)
+	createMultisigCmd := flag.NewFlagSet("createmultisig", flag.ExitOnError)
+	dumpWalletCmd := flag.NewFlagSet("dumpwallet", flag.ExitOnError)
+	importWalletCmd := flag.NewFlagSet("importwallet", flag.ExitOnError)
+	getRawTxCmd := flag.NewFlagSet("getrawtransaction", flag.ExitOnError)
+	decodeRawTxCmd := flag.NewFlagSet("decoderawtransaction", flag.ExitOnError)
+	getMerkleProofCmd := flag.NewFlagSet("getmerkleproof", flag.ExitOnError)
+	getBlockHashCmd := flag.NewFlagSet("getblockhash", flag.ExitOnError)
+	getBlockCmd := flag.NewFlagSet("getblock", flag.ExitOnError)
+	listTransactionsCmd := flag.NewFlagSet("listtransactions", flag.ExitOnError)
+	listUnspentCmd := flag.NewFlagSet("listunspent", flag.ExitOnError)
+	mineWorkCmd := flag.NewFlagSet("minework", flag.ExitOnError)
+	generateCmd := flag.NewFlagSet("generate", flag.ExitOnError)
+	faucetCmd := flag.NewFlagSet("faucet", flag.ExitOnError)
+	nodeStatusCmd := flag.NewFlagSet("nodestatus", flag.ExitOnError)
+	getMempoolInfoCmd := flag.NewFlagSet("getmempoolinfo", flag.ExitOnError)
+	getRawMempoolCmd := flag.NewFlagSet("getrawmempool", flag.ExitOnError)
 
-	createBlockchainAddress := createBlockchainCmd.String("address", "", "The address to receive genesis reward (not used yet)")
+	createWalletCompressed := createWalletCmd.Bool("compressed", false, "Use a 33-byte compressed public key for the address")
+	createBlockchainAddress := createBlockchainCmd.String("address", "", "Address credited with the genesis block's coinbase reward")
 	getBalanceAddress := getBalanceCmd.String("address", "", "The address")
+	getBalanceJSON := getBalanceCmd.Bool("json", false, "Print JSON instead of text (optional)")
+	printChainJSON := printChainCmd.Bool("json", false, "Print JSON instead of text (optional)")
+	listAddressesJSON := listAddressesCmd.Bool("json", false, "Print JSON instead of text (optional)")
 	sendFrom := sendCmd.String("from", "", "Source address")
 	sendTo := sendCmd.String("to", "", "Destination address")
 	sendAmount := sendCmd.Int("amount", 0, "Amount to send")
+	sendFee := sendCmd.Int("fee", 0, "Fee paid to the miner (optional)")
+	sendData := sendCmd.String("data", "", fmt.Sprintf("Text to embed in an unspendable data output, at most %d bytes (optional)", core.MaxDataSize))
+	sendReplaceable := sendCmd.Bool("rbf", false, "Let a higher-fee transaction replace this one while it is pending (optional)")
+	sendLockTime := sendCmd.Int("locktime", 0, "Earliest block height, or Unix time if >= 500000000, that may include the transaction (optional)")
+	sendCoins := sendCmd.String("coins", "", "Coin selection: largest, smallest or bnb (optional, default first fit)")
+	testSendFrom := testSendCmd.String("from", "", "Source address")
+	testSendTo := testSendCmd.String("to", "", "Destination address")
+	testSendAmount := testSendCmd.Int("amount", 0, "Amount to send")
+	testSendFee := testSendCmd.Int("fee", 0, "Fee paid to the miner (optional)")
+	testSendCoins := testSendCmd.String("coins", "", "Coin selection: largest, smallest or bnb (optional, default first fit)")
+	sendManyFrom := sendManyCmd.String("from", "", "Source address")
+	sendManyOutputs := sendManyCmd.String("outputs", "", "Comma-separated ADDRESS:AMOUNT payments")
+	sendManyFee := sendManyCmd.Int("fee", 0, "Fee paid to the miner (optional)")
+	sendManyCoins := sendManyCmd.String("coins", "", "Coin selection: largest, smallest or bnb (optional, default first fit)")
 	startNodeMiner := startNodeCmd.String("miner", "", "Miner address (optional)")
+	restoreWalletMnemonic := restoreWalletCmd.String("mnemonic", "", "The 12-word mnemonic")
+	restoreWalletCount := restoreWalletCmd.Int("count", 1, "How many addresses to derive")
+	signMessageAddress := signMessageCmd.String("address", "", "The address whose key signs")
+	signMessageMessage := signMessageCmd.String("message", "", "The message to sign")
+	verifyMessageAddress := verifyMessageCmd.String("address", "", "The address that signed")
+	verifyMessageMessage := verifyMessageCmd.String("message", "", "The signed message")
+	verifyMessageSignature := verifyMessageCmd.String("signature", "", "Base64 signature from signmessage")
+	dumpPrivKeyAddress := dumpPrivKeyCmd.String("address", "", "The address whose key to export")
+	walletInfoAddress := walletInfoCmd.String("address", "", "The address whose key to show")
+	importPrivKeyKey := importPrivKeyCmd.String("key", "", "Private key in WIF")
+	importAddressAddress := importAddressCmd.String("address", "", "The address to watch")
+	encryptWalletPassphrase := encryptWalletCmd.String("passphrase", "", "Passphrase to encrypt the keys with")
+	walletPassphrasePassphrase := walletPassphraseCmd.String("passphrase", "", "The wallet passphrase")
+	walletPassphraseTimeout := walletPassphraseCmd.Int("timeout", 60, "Seconds until the node locks the wallet again")
+	getWalletBalanceJSON := getWalletBalanceCmd.Bool("json", false, "Print JSON instead of text (optional)")
+	rescanStart := rescanBlockchainCmd.Int("start", 1, "First block height to scan (optional)")
+	rescanStop := rescanBlockchainCmd.Int("stop", 0, "Last block height to scan (optional, default the tip)")
+	dumpWalletFile := dumpWalletCmd.String("file", "", "Backup file to write")
+	importWalletFile := importWalletCmd.String("file", "", "Backup file written by dumpwallet")
+	createMultisigRequired := createMultisigCmd.Int("required", 2, "Signatures required to spend")
+	createMultisigAddresses := createMultisigCmd.String("addresses", "", "Comma-separated addresses whose keys may sign")
+	getRawTxID := getRawTxCmd.String("txid", "", "Transaction ID (hex)")
+	decodeRawTxHex := decodeRawTxCmd.String("hex", "", "Serialized transaction (hex)")
+	getMerkleProofTxID := getMerkleProofCmd.String("txid", "", "Transaction ID (hex)")
+	getBlockHashHeight := getBlockHashCmd.Int("height", 0, "Main-chain height (genesis is 1)")
+	getBlockHash := getBlockCmd.String("hash", "", "Block hash (hex)")
+	listTransactionsAddress := listTransactionsCmd.String("address", "", "The address")
+	listUnspentAddress := listUnspentCmd.String("address", "", "The address")
+	mineWorkAddress := mineWorkCmd.String("address", "", "Reward address (optional, defaults to the node's -miner address)")
+	generateCount := generateCmd.Int("count", 1, "Blocks to mine")
+	generateAddress := generateCmd.String("address", "", "Reward address (defaults to the node's -miner address; required offline)")
+	faucetAddress := faucetCmd.String("address", "", "Address to fund")
+	faucetAmount := faucetCmd.Int("amount", 0, "Amount to send")
+	startNodeRPC := startNodeCmd.String("rpc", "", "Port for the HTTP/JSON API (optional)")
+	startNodeListen := startNodeCmd.String("listen", listenAddr(), "HOST:PORT to listen on and announce to peers (defaults to $LISTEN_ADDR or localhost:<NODE_ID>)")
+	startNodePeers := startNodeCmd.String("peers", "", "Peers file (optional, defaults to $PEERS_FILE or peers_<NODE_ID>.json)")
+	startNodeThreads := startNodeCmd.Int("threads", 1, "Goroutines mining each block")
+	startNodePrune := startNodeCmd.Int("prune", 0, fmt.Sprintf("Discard transactions of blocks this many blocks below the tip, at least %d (optional, 0 keeps everything)", core.MinPruneDepth))
+	createBlockchainChain := createBlockchainCmd.String("chain", defaultChain(), "Chain profile: main, test or regtest (defaults to $CHAIN or main)")
+	createBlockchainSubsidy := createBlockchainCmd.Int("subsidy", 0, "Block reward before any halving, at least 1 (optional, defaults to the profile's)")
+	createBlockchainMaturity := createBlockchainCmd.Int("maturity", 0, "Blocks before a coinbase can be spent (optional, defaults to $COINBASE_MATURITY or the profile's)")
+	createBlockchainBits := createBlockchainCmd.Int("bits", 0, "Genesis proof-of-work difficulty in bits, 1 to 255 (optional, defaults to the profile's)")
+	createBlockchainHalving := createBlockchainCmd.Int("halving", 0, "Blocks between halvings of the reward, at least 1 (optional, defaults to $HALVING_INTERVAL or the profile's)")
+	startNodeWallet := startNodeCmd.String("wallet", walletFile(), "Wallet file the node signs with (defaults to $WALLET_FILE or wallets.dat)")
+	startNodeChain := startNodeCmd.String("chain", defaultChain(), "Chain profile for a new, empty DB: main, test or regtest (defaults to $CHAIN or main)")
+	startNodeMaxReorg := startNodeCmd.Int("maxreorg", core.MaxReorgDepth, "Refuse, and alert on, reorgs disconnecting more blocks than this (0 for no limit)")
+	startNodeRPCToken := startNodeCmd.String("rpctoken", rpcToken(), "Token the JSON API requires as a Bearer token or basic-auth password (optional, defaults to $RPC_TOKEN)")
+	startNodeTCPAuth := startNodeCmd.Bool("tcpauth", false, "Also require the token on CLI requests over the TCP port")
+	startNodeMaxPeers := startNodeCmd.Int("maxpeers", network.MaxPeers, "Most peers to keep; more evict the least recently reached (0 for no cap)")
+	startNodeLogLevel := startNodeCmd.String("loglevel", defaultLogLevel(), "Least severe log records shown: debug, info, warn or error (defaults to $LOG_LEVEL or info)")
 
 	switch os.Args[1] {
 	case "createwallet":
@@ -238,8 +1690,74 @@ func (c *CLI) Run() {
 		_ = getBalanceCmd.Parse(os.Args[2:])
 	case "send":
 		_ = sendCmd.Parse(os.Args[2:])
+	case "sendmany":
+		_ = sendManyCmd.Parse(os.Args[2:])
+	case "testsend":
+		_ = testSendCmd.Parse(os.Args[2:])
 	case "startnode":
 		_ = startNodeCmd.Parse(os.Args[2:])
+	case "reindexutxo":
+		_ = reindexUTXOCmd.Parse(os.Args[2:])
+	case "verifychain":
+		_ = verifyChainCmd.Parse(os.Args[2:])
+	case "createhdwallet":
+		_ = createHDWalletCmd.Parse(os.Args[2:])
+	case "restorewallet":
+		_ = restoreWalletCmd.Parse(os.Args[2:])
+	case "signmessage":
+		_ = signMessageCmd.Parse(os.Args[2:])
+	case "verifymessage":
+		_ = verifyMessageCmd.Parse(os.Args[2:])
+	case "dumpprivkey":
+		_ = dumpPrivKeyCmd.Parse(os.Args[2:])
+	case "walletinfo":
+		_ = walletInfoCmd.Parse(os.Args[2:])
+	case "importprivkey":
+		_ = importPrivKeyCmd.Parse(os.Args[2:])
+	case "importaddress":
+		_ = importAddressCmd.Parse(os.Args[2:])
+	case "encryptwallet":
+		_ = encryptWalletCmd.Parse(os.Args[2:])
+	case "walletpassphrase":
+		_ = walletPassphraseCmd.Parse(os.Args[2:])
+	case "walletlock":
+		_ = walletLockCmd.Parse(os.Args[2:])
+	case "getwalletbalance":
+		_ = getWalletBalanceCmd.Parse(os.Args[2:])
+	case "rescanblockchain":
+		_ = rescanBlockchainCmd.Parse(os.Args[2:])
+	case "createmultisig":
+		_ = createMultisigCmd.Parse(os.Args[2:])
+	case "dumpwallet":
+		_ = dumpWalletCmd.Parse(os.Args[2:])
+	case "importwallet":
+		_ = importWalletCmd.Parse(os.Args[2:])
+	case "getrawtransaction":
+		_ = getRawTxCmd.Parse(os.Args[2:])
+	case "decoderawtransaction":
+		_ = decodeRawTxCmd.Parse(os.Args[2:])
+	case "getmerkleproof":
+		_ = getMerkleProofCmd.Parse(os.Args[2:])
+	case "getblockhash":
+		_ = getBlockHashCmd.Parse(os.Args[2:])
+	case "getblock":
+		_ = getBlockCmd.Parse(os.Args[2:])
+	case "listtransactions":
+		_ = listTransactionsCmd.Parse(os.Args[2:])
+	case "listunspent":
+		_ = listUnspentCmd.Parse(os.Args[2:])
+	case "minework":
+		_ = mineWorkCmd.Parse(os.Args[2:])
+	case "generate":
+		_ = generateCmd.Parse(os.Args[2:])
+	case "faucet":
+		_ = faucetCmd.Parse(os.Args[2:])
+	case "nodestatus":
+		_ = nodeStatusCmd.Parse(os.Args[2:])
+	case "getmempoolinfo":
+		_ = getMempoolInfoCmd.Parse(os.Args[2:])
+	case "getrawmempool":
+		_ = getRawMempoolCmd.Parse(os.Args[2:])
 	default:
 		c.printUsage()
 		os.Exit(1)
@@ -251,19 +1769,32 @@ func (c *CLI) Run() {
 			createBlockchainCmd.Usage()
 			os.Exit(1)
 		}
-		c.createBlockchain(*createBlockchainAddress)
+		var overrides chainOverrides
+		createBlockchainCmd.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "subsidy":
+				overrides.Subsidy = createBlockchainSubsidy
+			case "maturity":
+				overrides.Maturity = createBlockchainMaturity
+			case "bits":
+				overrides.TargetBits = createBlockchainBits
+			case "halving":
+				overrides.HalvingInterval = createBlockchainHalving
+			}
+		})
+		c.createBlockchain(*createBlockchainAddress, *createBlockchainChain, overrides)
 	}
 
 	if createWalletCmd.Parsed() {
-		c.createWallet()
+		c.createWallet(*createWalletCompressed)
 	}
 
 	if listAddressesCmd.Parsed() {
-		c.listAddresses()
+		c.listAddresses(*listAddressesJSON)
 	}
 
 	if printChainCmd.Parsed() {
-		c.printChain()
+		c.printChain(*printChainJSON)
 	}
 
 	if getBalanceCmd.Parsed() {
@@ -272,7 +1803,7 @@ func (c *CLI) Run() {
 			getBalanceCmd.Usage()
 			os.Exit(1)
 		}
-		c.getBalance(*getBalanceAddress)
+		c.getBalance(*getBalanceAddress, *getBalanceJSON)
 	}
 
 	if sendCmd.Parsed() {
@@ -281,10 +1812,295 @@ func (c *CLI) Run() {
 			sendCmd.Usage()
 			os.Exit(1)
 		}
-		c.send(*sendFrom, *sendTo, *sendAmount)
+		if *sendFee < 0 {
+			fmt.Println("Error: -fee must be >= 0")
+			sendCmd.Usage()
+			os.Exit(1)
+		}
+		if *sendLockTime < 0 {
+			fmt.Println("Error: -locktime must be >= 0")
+			sendCmd.Usage()
+			os.Exit(1)
+		}
+		coins, err := core.ParseCoinSelection(*sendCoins)
+		if err != nil {
+			fmt.Println("Error:", err)
+			sendCmd.Usage()
+			os.Exit(1)
+		}
+		c.send(*sendFrom, *sendTo, *sendAmount, core.TxOptions{Fee: *sendFee, LockTime: *sendLockTime, Replaceable: *sendReplaceable, Data: []byte(*sendData), CoinSelection: coins})
+	}
+
+	if testSendCmd.Parsed() {
+		if *testSendFrom == "" || *testSendTo == "" || *testSendAmount <= 0 {
+			fmt.Println("Error: -from, -to, and -amount (>0) are required")
+			testSendCmd.Usage()
+			os.Exit(1)
+		}
+		if *testSendFee < 0 {
+			fmt.Println("Error: -fee must be >= 0")
+			testSendCmd.Usage()
+			os.Exit(1)
+		}
+		coins, err := core.ParseCoinSelection(*testSendCoins)
+		if err != nil {
+			fmt.Println("Error:", err)
+			testSendCmd.Usage()
+			os.Exit(1)
+		}
+		c.testSend(*testSendFrom, *testSendTo, *testSendAmount, core.TxOptions{Fee: *testSendFee, CoinSelection: coins})
+	}
+
+	if sendManyCmd.Parsed() {
+		if *sendManyFrom == "" || *sendManyOutputs == "" {
+			fmt.Println("Error: -from and -outputs are required")
+			sendManyCmd.Usage()
+			os.Exit(1)
+		}
+		if *sendManyFee < 0 {
+			fmt.Println("Error: -fee must be >= 0")
+			sendManyCmd.Usage()
+			os.Exit(1)
+		}
+		outputs, err := parseOutputs(*sendManyOutputs)
+		if err != nil {
+			fmt.Println("Error:", err)
+			sendManyCmd.Usage()
+			os.Exit(1)
+		}
+		coins, err := core.ParseCoinSelection(*sendManyCoins)
+		if err != nil {
+			fmt.Println("Error:", err)
+			sendManyCmd.Usage()
+			os.Exit(1)
+		}
+		c.sendMany(*sendManyFrom, outputs, core.TxOptions{Fee: *sendManyFee, CoinSelection: coins})
 	}
 
 	if startNodeCmd.Parsed() {
-		c.startNode(*startNodeMiner)
+		c.startNode(*startNodeMiner, *startNodePeers, *startNodeRPC, *startNodeListen, *startNodeThreads, *startNodeChain, *startNodeWallet, *startNodePrune, *startNodeMaxReorg, *startNodeLogLevel, *startNodeRPCToken, *startNodeTCPAuth, *startNodeMaxPeers)
+	}
+
+	if reindexUTXOCmd.Parsed() {
+		c.reindexUTXO()
+	}
+
+	if verifyChainCmd.Parsed() {
+		c.verifyChain()
+	}
+
+	if createHDWalletCmd.Parsed() {
+		c.createHDWallet()
+	}
+
+	if restoreWalletCmd.Parsed() {
+		if *restoreWalletMnemonic == "" || *restoreWalletCount <= 0 {
+			fmt.Println("Error: -mnemonic and -count (>0) are required")
+			restoreWalletCmd.Usage()
+			os.Exit(1)
+		}
+		c.restoreWallet(*restoreWalletMnemonic, *restoreWalletCount)
+	}
+
+	if signMessageCmd.Parsed() {
+		if *signMessageAddress == "" {
+			fmt.Println("Error: -address is required")
+			signMessageCmd.Usage()
+			os.Exit(1)
+		}
+		c.signMessage(*signMessageAddress, *signMessageMessage)
+	}
+
+	if verifyMessageCmd.Parsed() {
+		if *verifyMessageAddress == "" || *verifyMessageSignature == "" {
+			fmt.Println("Error: -address and -signature are required")
+			verifyMessageCmd.Usage()
+			os.Exit(1)
+		}
+		c.verifyMessage(*verifyMessageAddress, *verifyMessageMessage, *verifyMessageSignature)
+	}
+
+	if dumpPrivKeyCmd.Parsed() {
+		if *dumpPrivKeyAddress == "" {
+			fmt.Println("Error: -address is required")
+			dumpPrivKeyCmd.Usage()
+			os.Exit(1)
+		}
+		c.dumpPrivKey(*dumpPrivKeyAddress)
+	}
+
+	if walletInfoCmd.Parsed() {
+		if *walletInfoAddress == "" {
+			fmt.Println("Error: -address is required")
+			walletInfoCmd.Usage()
+			os.Exit(1)
+		}
+		c.walletInfo(*walletInfoAddress)
+	}
+
+	if importPrivKeyCmd.Parsed() {
+		if *importPrivKeyKey == "" {
+			fmt.Println("Error: -key is required")
+			importPrivKeyCmd.Usage()
+			os.Exit(1)
+		}
+		c.importPrivKey(*importPrivKeyKey)
+	}
+
+	if importAddressCmd.Parsed() {
+		if *importAddressAddress == "" {
+			fmt.Println("Error: -address is required")
+			importAddressCmd.Usage()
+			os.Exit(1)
+		}
+		c.importAddress(*importAddressAddress)
+	}
+
+	if encryptWalletCmd.Parsed() {
+		if *encryptWalletPassphrase == "" {
+			fmt.Println("Error: -passphrase is required")
+			encryptWalletCmd.Usage()
+			os.Exit(1)
+		}
+		c.encryptWallet(*encryptWalletPassphrase)
+	}
+
+	if walletPassphraseCmd.Parsed() {
+		if *walletPassphrasePassphrase == "" || *walletPassphraseTimeout <= 0 {
+			fmt.Println("Error: -passphrase and -timeout (>0) are required")
+			walletPassphraseCmd.Usage()
+			os.Exit(1)
+		}
+		c.walletPassphrase(*walletPassphrasePassphrase, *walletPassphraseTimeout)
+	}
+
+	if walletLockCmd.Parsed() {
+		c.walletLock()
+	}
+
+	if getWalletBalanceCmd.Parsed() {
+		c.getWalletBalance(*getWalletBalanceJSON)
+	}
+
+	if rescanBlockchainCmd.Parsed() {
+		c.rescanBlockchain(*rescanStart, *rescanStop)
+	}
+
+	if dumpWalletCmd.Parsed() {
+		if *dumpWalletFile == "" {
+			fmt.Println("Error: -file is required")
+			dumpWalletCmd.Usage()
+			os.Exit(1)
+		}
+		c.dumpWallet(*dumpWalletFile)
+	}
+
+	if importWalletCmd.Parsed() {
+		if *importWalletFile == "" {
+			fmt.Println("Error: -file is required")
+			importWalletCmd.Usage()
+			os.Exit(1)
+		}
+		c.importWallet(*importWalletFile)
+	}
+
+	if createMultisigCmd.Parsed() {
+		if *createMultisigAddresses == "" {
+			fmt.Println("Error: -addresses is required")
+			createMultisigCmd.Usage()
+			os.Exit(1)
+		}
+		c.createMultisig(*createMultisigRequired, strings.Split(*createMultisigAddresses, ","))
+	}
+
+	if getRawTxCmd.Parsed() {
+		if *getRawTxID == "" {
+			fmt.Println("Error: -txid is required")
+			getRawTxCmd.Usage()
+			os.Exit(1)
+		}
+		c.getRawTransaction(*getRawTxID)
+	}
+
+	if decodeRawTxCmd.Parsed() {
+		if *decodeRawTxHex == "" {
+			fmt.Println("Error: -hex is required")
+			decodeRawTxCmd.Usage()
+			os.Exit(1)
+		}
+		c.decodeRawTransaction(*decodeRawTxHex)
+	}
+
+	if getMerkleProofCmd.Parsed() {
+		if *getMerkleProofTxID == "" {
+			fmt.Println("Error: -txid is required")
+			getMerkleProofCmd.Usage()
+			os.Exit(1)
+		}
+		c.getMerkleProof(*getMerkleProofTxID)
+	}
+
+	if getBlockHashCmd.Parsed() {
+		if *getBlockHashHeight == 0 {
+			fmt.Println("Error: -height is required")
+			getBlockHashCmd.Usage()
+			os.Exit(1)
+		}
+		c.getBlockHash(*getBlockHashHeight)
+	}
+
+	if getBlockCmd.Parsed() {
+		if *getBlockHash == "" {
+			fmt.Println("Error: -hash is required")
+			getBlockCmd.Usage()
+			os.Exit(1)
+		}
+		c.getBlock(*getBlockHash)
+	}
+
+	if listTransactionsCmd.Parsed() {
+		if *listTransactionsAddress == "" {
+			fmt.Println("Error: -address is required")
+			listTransactionsCmd.Usage()
+			os.Exit(1)
+		}
+		c.listTransactions(*listTransactionsAddress)
+	}
+
+	if listUnspentCmd.Parsed() {
+		if *listUnspentAddress == "" {
+			fmt.Println("Error: -address is required")
+			listUnspentCmd.Usage()
+			os.Exit(1)
+		}
+		c.listUnspent(*listUnspentAddress)
+	}
+
+	if mineWorkCmd.Parsed() {
+		c.mineWork(*mineWorkAddress)
+	}
+
+	if generateCmd.Parsed() {
+		c.generate(*generateCount, *generateAddress)
+	}
+
+	if faucetCmd.Parsed() {
+		if *faucetAddress == "" {
+			faucetCmd.Usage()
+			os.Exit(1)
+		}
+		c.faucet(*faucetAddress, *faucetAmount)
+	}
+
+	if nodeStatusCmd.Parsed() {
+		c.nodeStatus()
+	}
+
+	if getMempoolInfoCmd.Parsed() {
+		c.getMempoolInfo()
+	}
+
+	if getRawMempoolCmd.Parsed() {
+		c.getRawMempool()
 	}
 }
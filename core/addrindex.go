@@ -0,0 +1,191 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"go.etcd.io/bbolt"
+
+	"my-blockchain/wallet"
+)
+
+// addrIndexBucket maps pubKeyHash | height (4 bytes) | position in block (4 bytes) to the
+// hash of the main-chain block holding a transaction that pays to or spends from that
+// pubKeyHash. Keys sort in chain order, so an address's history is one cursor scan.
+const addrIndexBucket = "addrindex"
+
+// AddressTx is one entry of an address's transaction history.
+type AddressTx struct {
+	Tx        Transaction
+	BlockHash []byte
+	Height    int
+}
+
+func addrIndexKey(pubKeyHash []byte, height, pos int) []byte {
+	key := make([]byte, len(pubKeyHash), len(pubKeyHash)+8)
+	copy(key, pubKeyHash)
+	key = binary.BigEndian.AppendUint32(key, uint32(height))
+	return binary.BigEndian.AppendUint32(key, uint32(pos))
+}
+
+// txAddresses returns the distinct pubKeyHashes tx pays to or spends from.
+func txAddresses(tx *Transaction) [][]byte {
+	var hashes [][]byte
+	seen := make(map[string]bool)
+	add := func(pubKeyHash []byte) {
+		if !seen[string(pubKeyHash)] {
+			seen[string(pubKeyHash)] = true
+			hashes = append(hashes, pubKeyHash)
+		}
+	}
+	if !tx.IsCoinbase() {
+		for _, in := range tx.Vin {
+			add(wallet.HashPubKey(in.PubKey))
+		}
+	}
+	for _, out := range tx.Vout {
+		if !out.IsData() {
+			add(out.PubKeyHash)
+		}
+	}
+	return hashes
+}
+
+// indexBlockAddresses adds a newly connected main-chain block at height to the address
+// index, inside the caller's write transaction.
+func indexBlockAddresses(tx *bbolt.Tx, block *Block, height int) error {
+	b, err := tx.CreateBucketIfNotExists([]byte(addrIndexBucket))
+	if err != nil {
+		return err
+	}
+	for pos, t := range block.Transactions {
+		for _, pubKeyHash := range txAddresses(t) {
+			if err := b.Put(addrIndexKey(pubKeyHash, height, pos), block.Hash); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// unindexBlockAddresses removes a block leaving the main chain from the address index.
+func unindexBlockAddresses(tx *bbolt.Tx, block *Block) error {
+	b := tx.Bucket([]byte(addrIndexBucket))
+	if b == nil {
+		return nil
+	}
+	for _, t := range block.Transactions {
+		for _, pubKeyHash := range txAddresses(t) {
+			c := b.Cursor()
+			for k, v := c.Seek(pubKeyHash); k != nil && bytes.HasPrefix(k, pubKeyHash); k, v = c.Next() {
+				if len(k) != len(pubKeyHash)+8 || !bytes.Equal(v, block.Hash) {
+					continue
+				}
+				if err := c.Delete(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ReindexAddresses rebuilds the address index from a full scan of the main chain.
+func (bc *Blockchain) ReindexAddresses() error {
+	hashes := bc.GetBlockHashes()
+	return bc.db.Update(func(tx *bbolt.Tx) error {
+		if tx.Bucket([]byte(addrIndexBucket)) != nil {
+			if err := tx.DeleteBucket([]byte(addrIndexBucket)); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.CreateBucket([]byte(addrIndexBucket)); err != nil {
+			return err
+		}
+		blocks := tx.Bucket([]byte(blocksBucket))
+		for i, hash := range hashes {
+			data := blocks.Get(hash)
+			if data == nil {
+				return fmt.Errorf("main-chain block %x missing", hash)
+			}
+			if err := indexBlockAddresses(tx, DeserializeBlock(data), i+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ensureAddrIndex builds the address index for databases created before it existed.
+func (bc *Blockchain) ensureAddrIndex() error {
+	found := false
+	_ = bc.db.View(func(tx *bbolt.Tx) error {
+		found = tx.Bucket([]byte(addrIndexBucket)) != nil
+		return nil
+	})
+	if len(bc.Tip()) > 0 && !found {
+		return bc.ReindexAddresses()
+	}
+	return nil
+}
+
+// FindTransactionsForAddress returns every main-chain transaction paying to or spending
+// from pubKeyHash, oldest first, leaving out those in pruned blocks. Databases without the address index (older ones opened
+// read-only) fall back to a full chain scan.
+func (bc *Blockchain) FindTransactionsForAddress(pubKeyHash []byte) ([]AddressTx, error) {
+	var history []AddressTx
+	indexed := false
+	err := bc.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(addrIndexBucket))
+		if b == nil {
+			return nil
+		}
+		indexed = true
+		blocks := tx.Bucket([]byte(blocksBucket))
+		var block *Block
+		c := b.Cursor()
+		for k, v := c.Seek(pubKeyHash); k != nil && bytes.HasPrefix(k, pubKeyHash); k, v = c.Next() {
+			if len(k) != len(pubKeyHash)+8 {
+				continue
+			}
+			if block == nil || !bytes.Equal(block.Hash, v) {
+				data := blocks.Get(v)
+				if data == nil {
+					return fmt.Errorf("indexed block %x missing", v)
+				}
+				block = DeserializeBlock(data)
+			}
+			if block.Pruned {
+				continue
+			}
+			height := int(binary.BigEndian.Uint32(k[len(pubKeyHash):]))
+			pos := int(binary.BigEndian.Uint32(k[len(pubKeyHash)+4:]))
+			if pos >= len(block.Transactions) {
+				return fmt.Errorf("indexed transaction %d of block %x missing", pos, v)
+			}
+			history = append(history, AddressTx{Tx: *block.Transactions[pos], BlockHash: block.Hash, Height: height})
+		}
+		return nil
+	})
+	if err != nil || indexed {
+		return history, err
+	}
+
+	for i, hash := range bc.GetBlockHashes() {
+		data, err := bc.GetBlock(hash)
+		if err != nil {
+			return nil, err
+		}
+		block := DeserializeBlock(data)
+		for _, t := range block.Transactions {
+			for _, h := range txAddresses(t) {
+				if bytes.Equal(h, pubKeyHash) {
+					history = append(history, AddressTx{Tx: *t, BlockHash: block.Hash, Height: i + 1})
+					break
+				}
+			}
+		}
+	}
+	return history, nil
+}
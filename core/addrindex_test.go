@@ -0,0 +1,46 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+
+	"my-blockchain/wallet"
+)
+
+func TestAddressHistoryInOrder(t *testing.T) {
+	bc, alice := newTestChain(t)
+	bob := wallet.NewWallet()
+	genesis := mustBlock(t, bc, bc.Tip())
+
+	toBob := payTo(t, bc, alice, genesis.Transactions[0], 0, 10, string(bob.GetAddress()))
+	block2 := mineOn(t, bc, genesis, 2, toBob)
+	putAll(t, bc, block2)
+	toAlice := payTo(t, bc, bob, toBob, 0, 10, string(alice.GetAddress()))
+	block3 := mineOn(t, bc, block2, 3, toAlice)
+	block4 := mineOn(t, bc, block3, 4)
+	putAll(t, bc, block3, block4)
+
+	check := func(name string, w *wallet.Wallet, want []*Transaction, heights []int) {
+		t.Helper()
+		history, err := bc.FindTransactionsForAddress(wallet.HashPubKey(w.PubKey()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(history) != len(want) {
+			t.Fatalf("%s's history has %d entries, want %d", name, len(history), len(want))
+		}
+		for i, entry := range history {
+			if !bytes.Equal(entry.Tx.ID, want[i].ID) || entry.Height != heights[i] {
+				t.Errorf("%s's entry %d is %x at height %d, want %x at height %d", name, i, entry.Tx.ID, entry.Height, want[i].ID, heights[i])
+			}
+		}
+	}
+	// Alice receives the genesis reward, spends it to Bob and receives it back.
+	check("alice", alice, []*Transaction{genesis.Transactions[0], toBob, toAlice}, []int{1, 2, 3})
+	check("bob", bob, []*Transaction{toBob, toAlice}, []int{2, 3})
+
+	if err := bc.ReindexAddresses(); err != nil {
+		t.Fatal(err)
+	}
+	check("alice after reindexing", alice, []*Transaction{genesis.Transactions[0], toBob, toAlice}, []int{1, 2, 3})
+}
@@ -2,6 +2,7 @@ package core
 
 import (
 	"bytes"
+	"context"
 	"encoding/gob"
 	"log"
 	"time"
@@ -14,6 +15,12 @@ type Block struct {
 	Hash          []byte
 	Nonce         int
 	MerkleRoot    []byte
+	// TargetBits is the number of leading zero bits the block hash must have.
+	// Blocks stored before retargeting existed decode with 0 and mean Difficulty.
+	TargetBits int
+	// Pruned is set on stored blocks whose transactions were discarded by Prune; only
+	// the header fields remain.
+	Pruned bool
 }
 
 func (b *Block) Serialize() []byte {
@@ -26,24 +33,72 @@ func (b *Block) Serialize() []byte {
 }
 
 func DeserializeBlock(data []byte) *Block {
+	block, err := DecodeBlock(data)
+	if err != nil {
+		log.Panic(err)
+	}
+	return block
+}
+
+// DecodeBlock is DeserializeBlock for untrusted input: it returns an error instead of panicking.
+func DecodeBlock(data []byte) (*Block, error) {
 	var block Block
 	decoder := gob.NewDecoder(bytes.NewReader(data))
 	if err := decoder.Decode(&block); err != nil {
-		log.Panic(err)
+		return nil, err
 	}
-	return &block
+	return &block, nil
 }
 
 func (b *Block) HashTransactions() []byte {
+	return b.merkleTree().RootNode.Data
+}
+
+// MerkleProof returns the proof that the transaction txID is committed to by MerkleRoot.
+func (b *Block) MerkleProof(txID []byte) ([][]byte, []bool, error) {
+	return b.merkleTree().Proof(txID)
+}
+
+func (b *Block) merkleTree() *MerkleTree {
 	txHashes := make([][]byte, 0, len(b.Transactions))
 	for _, tx := range b.Transactions {
 		txHashes = append(txHashes, tx.ID)
 	}
-	tree := NewMerkleTree(txHashes)
-	return tree.RootNode.Data
+	return NewMerkleTree(txHashes)
 }
 
-func NewBlock(transactions []*Transaction, prevBlockHash []byte) *Block {
+// Bits returns the block's proof-of-work difficulty in leading zero bits.
+func (b *Block) Bits() int {
+	if b.TargetBits == 0 {
+		return Difficulty
+	}
+	return b.TargetBits
+}
+
+func NewBlock(transactions []*Transaction, prevBlockHash []byte, targetBits int) *Block {
+	block := newBlockTemplate(transactions, prevBlockHash, targetBits)
+	pow := NewProofOfWork(block)
+	nonce, hash := pow.Run()
+	block.Nonce = nonce
+	block.Hash = hash
+	return block
+}
+
+// MineBlock is NewBlock mined by workers goroutines. It gives up with ctx's error once ctx
+// is cancelled, e.g. because a new tip made the block stale.
+func MineBlock(ctx context.Context, transactions []*Transaction, prevBlockHash []byte, targetBits int, workers int) (*Block, error) {
+	block := newBlockTemplate(transactions, prevBlockHash, targetBits)
+	nonce, hash, err := NewProofOfWork(block).RunParallel(ctx, workers)
+	if err != nil {
+		return nil, err
+	}
+	block.Nonce = nonce
+	block.Hash = hash
+	return block, nil
+}
+
+// newBlockTemplate returns the unmined block: everything but Nonce and Hash.
+func newBlockTemplate(transactions []*Transaction, prevBlockHash []byte, targetBits int) *Block {
 	block := &Block{
 		Timestamp:     time.Now().Unix(),
 		Transactions:  transactions,
@@ -51,11 +106,8 @@ func NewBlock(transactions []*Transaction, prevBlockHash []byte) *Block {
 		Hash:          nil,
 		Nonce:         0,
 		MerkleRoot:    nil,
+		TargetBits:    targetBits,
 	}
 	block.MerkleRoot = block.HashTransactions()
-	pow := NewProofOfWork(block)
-	nonce, hash := pow.Run()
-	block.Nonce = nonce
-	block.Hash = hash
 	return block
 }
@@ -0,0 +1,58 @@
+package core
+
+import (
+	"container/list"
+	"sync"
+)
+
+// maxBlockCacheEntries bounds the block cache. Most blocks are a few KiB, so a full cache
+// stays small next to the 1 MiB a single block may reach.
+const maxBlockCacheEntries = 1024
+
+// blockCache keeps the most recently read blocks, deserialized and keyed by hash, so
+// walks over the chain skip the read transaction and gob decoding for blocks already
+// seen. A stored block never changes until it is pruned, and pruning resets the cache.
+// Cached blocks are shared by every reader and must not be modified.
+type blockCache struct {
+	mu      sync.Mutex
+	order   *list.List // of *Block, most recently used first
+	entries map[string]*list.Element
+}
+
+func newBlockCache() *blockCache {
+	return &blockCache{order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+func (c *blockCache) get(hash []byte) (*Block, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[string(hash)]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*Block), true
+}
+
+func (c *blockCache) put(block *Block) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[string(block.Hash)]; ok {
+		e.Value = block
+		c.order.MoveToFront(e)
+		return
+	}
+	c.entries[string(block.Hash)] = c.order.PushFront(block)
+	if c.order.Len() > maxBlockCacheEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, string(oldest.Value.(*Block).Hash))
+	}
+}
+
+func (c *blockCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	clear(c.entries)
+}
@@ -0,0 +1,86 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// walkHashes returns the hashes of the blocks it yields, tip first.
+func walkHashes(it *BlockchainIterator) [][]byte {
+	var hashes [][]byte
+	for b := it.Next(); b != nil; b = it.Next() {
+		hashes = append(hashes, b.Hash)
+	}
+	return hashes
+}
+
+func TestBlockCacheWalksNewBranchAfterReorg(t *testing.T) {
+	bc, _ := newTestChain(t)
+	genesis := mustBlock(t, bc, bc.Tip())
+	a2 := mineOn(t, bc, genesis, 2)
+	a3 := mineOn(t, bc, a2, 3)
+	putAll(t, bc, a2, a3)
+	walkHashes(bc.Iterator()) // fills the cache with branch A
+
+	b2 := mineOn(t, bc, genesis, 2)
+	b3 := mineOn(t, bc, b2, 3)
+	b4 := mineOn(t, bc, b3, 4)
+	putAll(t, bc, b2, b3, b4)
+
+	cached := walkHashes(bc.Iterator())
+	uncached := walkHashes(&BlockchainIterator{currentHash: bc.Tip(), db: bc.db, cache: newBlockCache()})
+	want := [][]byte{b4.Hash, b3.Hash, b2.Hash, genesis.Hash}
+	for _, got := range [][][]byte{cached, uncached} {
+		if len(got) != len(want) {
+			t.Fatalf("walked %d blocks, want %d", len(got), len(want))
+		}
+		for i := range want {
+			if !bytes.Equal(got[i], want[i]) {
+				t.Fatalf("block %d of the walk is %x, want %x", i, got[i], want[i])
+			}
+		}
+	}
+	if block, ok := bc.blocks.get(a3.Hash); !ok || !bytes.Equal(block.Hash, a3.Hash) {
+		t.Fatal("block of the abandoned branch lost from the cache; it is still stored")
+	}
+}
+
+func TestBlockCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newBlockCache()
+	block := func(i int) *Block { return &Block{Hash: []byte(fmt.Sprint(i))} }
+	for i := 0; i < maxBlockCacheEntries; i++ {
+		c.put(block(i))
+	}
+	c.get(block(0).Hash) // 0 is now the most recently used, 1 the least
+	c.put(block(maxBlockCacheEntries))
+
+	if _, ok := c.get(block(1).Hash); ok {
+		t.Fatal("least recently used block not evicted")
+	}
+	for _, i := range []int{0, 2, maxBlockCacheEntries} {
+		if _, ok := c.get(block(i).Hash); !ok {
+			t.Fatalf("block %d evicted", i)
+		}
+	}
+}
+
+func BenchmarkChainWalk(b *testing.B) {
+	bc, w := newTestChain(b)
+	for height := 2; height <= 200; height++ {
+		if _, err := bc.AddBlock([]*Transaction{bc.config.CoinbaseTx(string(w.GetAddress()), "", height)}); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.Run("cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			walkHashes(bc.Iterator())
+		}
+	})
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			walkHashes(&BlockchainIterator{currentHash: bc.Tip(), db: bc.db, cache: newBlockCache()})
+		}
+	})
+}
@@ -2,12 +2,15 @@ package core
 
 import (
 	"bytes"
+	"context"
 	"crypto/ecdsa"
 	"encoding/hex"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.etcd.io/bbolt"
@@ -18,14 +21,16 @@ import (
 const blocksBucket = "blocks"
 const lastHashKey = "l"
 
-const dbLockTimeout = 2 * time.Second
+// DBLockTimeout is how long opening a node's DB waits for another process holding it,
+// such as a running node, before failing with a DBLockedError.
+var DBLockTimeout = 2 * time.Second
 
 func openDB(nodeID string) (*bbolt.DB, error) {
-	return bbolt.Open(nodeDBFile(nodeID), 0o600, &bbolt.Options{Timeout: dbLockTimeout})
+	return bbolt.Open(nodeDBFile(nodeID), 0o600, &bbolt.Options{Timeout: DBLockTimeout})
 }
 
 func openDBReadOnly(nodeID string) (*bbolt.DB, error) {
-	return bbolt.Open(nodeDBFile(nodeID), 0o600, &bbolt.Options{Timeout: dbLockTimeout, ReadOnly: true})
+	return bbolt.Open(nodeDBFile(nodeID), 0o600, &bbolt.Options{Timeout: DBLockTimeout, ReadOnly: true})
 }
 
 func nodeDBFile(nodeID string) string {
@@ -36,11 +41,43 @@ func nodeDBFile(nodeID string) string {
 }
 
 type Blockchain struct {
-	db  *bbolt.DB
-	tip []byte
+	db     *bbolt.DB
+	config ChainConfig
+
+	// tipMu guards tip, which handlers on other goroutines read while blocks arrive.
+	tipMu sync.RWMutex
+	tip   []byte
+
+	// putMu serializes changes to the chain: PutBlock (so a parent and its orphan children
+	// cannot race), locally mined blocks and pruning.
+	putMu sync.Mutex
+
+	// utxoCache holds FindUTXO results for the current tip.
+	utxoCache utxoCache
+
+	// blocks caches blocks read by iterators, on every branch.
+	blocks *blockCache
+
+	// log is where chain operations log; nil means Log.
+	log *slog.Logger
+	// events and metrics are where the chain announces new tips and counts its work.
+	events  *EventBus
+	metrics *Metrics
+
+	// reorgAlert is the last reorg refused for being deeper than MaxReorgDepth.
+	reorgAlert atomic.Pointer[ReorgAlert]
+
+	orphansMu sync.Mutex
+	// orphans holds blocks whose parent has not arrived yet, keyed by hex PrevBlockHash.
+	orphans     map[string][]*Block
+	orphanCount int
 }
 
 func NewGenesisBlock(coinbase *Transaction) *Block {
+	return newGenesisBlock(coinbase, Difficulty)
+}
+
+func newGenesisBlock(coinbase *Transaction, targetBits int) *Block {
 	genesis := &Block{
 		Timestamp:     0,
 		Transactions:  []*Transaction{coinbase},
@@ -48,6 +85,7 @@ func NewGenesisBlock(coinbase *Transaction) *Block {
 		Hash:          nil,
 		Nonce:         0,
 		MerkleRoot:    nil,
+		TargetBits:    targetBits,
 	}
 	genesis.MerkleRoot = genesis.HashTransactions()
 	pow := NewProofOfWork(genesis)
@@ -67,28 +105,63 @@ func DBExists(nodeID string) bool {
 	return dbExists(nodeID)
 }
 
-// CreateBlockchain initializes a brand-new blockchain database.
-func CreateBlockchain(address string) *Blockchain {
-	if !wallet.ValidateAddress(address) {
-		log.Panic("invalid address")
+var (
+	// ErrNoBlockchain is returned when opening a node whose database does not exist yet.
+	ErrNoBlockchain   = errors.New("no existing blockchain database found; run createblockchain first")
+	ErrDBExists       = errors.New("blockchain database already exists")
+	ErrInvalidAddress = errors.New("invalid address")
+	// ErrDBLocked is wrapped by every DBLockedError.
+	ErrDBLocked = errors.New("blockchain database is locked")
+)
+
+// DBLockedError is returned when a node's DB stayed locked by another process, usually
+// a node running with the same ID, for all of DBLockTimeout.
+type DBLockedError struct {
+	NodeID string
+	Path   string
+	Waited time.Duration
+}
+
+func (e *DBLockedError) Error() string {
+	return fmt.Sprintf("%v: %s of node %s is held by another process (waited %v)", ErrDBLocked, e.Path, e.NodeID, e.Waited)
+}
+
+func (e *DBLockedError) Unwrap() error {
+	return ErrDBLocked
+}
+
+// openError turns a lock timeout opening nodeID's DB into a DBLockedError.
+func openError(nodeID string, err error) error {
+	if errors.Is(err, bbolt.ErrTimeout) {
+		if nodeID == "" {
+			nodeID = "3000"
+		}
+		return &DBLockedError{NodeID: nodeID, Path: nodeDBFile(nodeID), Waited: DBLockTimeout}
 	}
-	return CreateBlockchainForNode(address, os.Getenv("NODE_ID"))
+	return err
 }
 
-func CreateBlockchainForNode(address string, nodeID string) *Blockchain {
+// CreateBlockchain initializes a brand-new blockchain database with MainConfig.
+func CreateBlockchain(address string) (*Blockchain, error) {
+	return CreateBlockchainForNode(address, os.Getenv("NODE_ID"), MainConfig)
+}
+
+// CreateBlockchainForNode initializes a brand-new blockchain database for a node and
+// stores cfg in it.
+func CreateBlockchainForNode(address string, nodeID string, cfg ChainConfig) (*Blockchain, error) {
 	if !wallet.ValidateAddress(address) {
-		log.Panic("invalid address")
+		return nil, ErrInvalidAddress
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
 	}
 	if dbExists(nodeID) {
-		log.Panic("blockchain database already exists")
+		return nil, ErrDBExists
 	}
 
 	db, err := openDB(nodeID)
 	if err != nil {
-		if errors.Is(err, bbolt.ErrTimeout) {
-			log.Panicf("failed to open blockchain DB %q: timeout (if a node is running with the same NODE_ID, stop it and retry)", nodeDBFile(nodeID))
-		}
-		log.Panic(err)
+		return nil, openError(nodeID, err)
 	}
 
 	var tip []byte
@@ -98,98 +171,139 @@ func CreateBlockchainForNode(address string, nodeID string) *Blockchain {
 			return createErr
 		}
 
-		coinbase := CoinbaseTx(address, "Genesis")
-		genesis := NewGenesisBlock(coinbase)
+		if putErr := putChainConfig(tx, cfg); putErr != nil {
+			return putErr
+		}
+		if putErr := putDBVersion(tx); putErr != nil {
+			return putErr
+		}
+		coinbase := cfg.CoinbaseTx(address, cfg.GenesisMessage, 1)
+		genesis := newGenesisBlock(coinbase, cfg.TargetBits)
 		if putErr := b.Put(genesis.Hash, genesis.Serialize()); putErr != nil {
 			return putErr
 		}
+		if putErr := putBlockHeight(tx, genesis); putErr != nil {
+			return putErr
+		}
 		if putErr := b.Put([]byte(lastHashKey), genesis.Hash); putErr != nil {
 			return putErr
 		}
 		tip = genesis.Hash
-		return nil
+		return updateUTXOSet(tx, genesis, 1)
 	})
 	if err != nil {
-		log.Panic(err)
+		_ = db.Close()
+		return nil, err
 	}
 
-	return &Blockchain{db: db, tip: tip}
+	return &Blockchain{db: db, tip: tip, config: cfg, blocks: newBlockCache(), events: NewEventBus(), metrics: new(Metrics)}, nil
 }
 
 // OpenBlockchain opens an existing blockchain database.
-func OpenBlockchain() *Blockchain {
+func OpenBlockchain() (*Blockchain, error) {
 	return OpenBlockchainForNode(os.Getenv("NODE_ID"))
 }
 
-func OpenBlockchainForNode(nodeID string) *Blockchain {
+func OpenBlockchainForNode(nodeID string) (*Blockchain, error) {
 	if !dbExists(nodeID) {
-		log.Panic("no existing blockchain database found; run createblockchain first")
+		return nil, ErrNoBlockchain
 	}
 
 	db, err := openDB(nodeID)
 	if err != nil {
-		if errors.Is(err, bbolt.ErrTimeout) {
-			log.Panicf("failed to open blockchain DB %q: timeout (if a node is running with the same NODE_ID, stop it and retry)", nodeDBFile(nodeID))
-		}
-		log.Panic(err)
+		return nil, openError(nodeID, err)
 	}
 
-	var tip []byte
-	err = db.View(func(tx *bbolt.Tx) error {
-		b := tx.Bucket([]byte(blocksBucket))
-		if b == nil {
-			log.Panic("blockchain database is missing blocks bucket")
-		}
-		tip = b.Get([]byte(lastHashKey))
-		return nil
-	})
+	tip, err := readTip(db)
 	if err != nil {
-		log.Panic(err)
+		_ = db.Close()
+		return nil, err
+	}
+	if err := checkDBVersion(db, nodeID, tip, true); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	cfg, err := loadChainConfig(db)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
 	}
 
-	return &Blockchain{db: db, tip: tip}
+	bc := &Blockchain{db: db, tip: tip, config: cfg, blocks: newBlockCache(), events: NewEventBus(), metrics: new(Metrics)}
+	if err := bc.ensureUTXOIndex(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if err := bc.ensureAddrIndex(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if err := bc.ensureHeightIndex(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if err := bc.ensureMainChainIndex(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return bc, nil
 }
 
 // OpenBlockchainReadOnlyForNode opens an existing blockchain database in read-only mode.
 // This allows commands like printchain/getbalance to run while a node process is running.
-func OpenBlockchainReadOnlyForNode(nodeID string) *Blockchain {
+func OpenBlockchainReadOnlyForNode(nodeID string) (*Blockchain, error) {
 	if !dbExists(nodeID) {
-		log.Panic("no existing blockchain database found; run createblockchain first")
+		return nil, ErrNoBlockchain
 	}
 
 	db, err := openDBReadOnly(nodeID)
 	if err != nil {
-		if errors.Is(err, bbolt.ErrTimeout) {
-			log.Panicf("failed to open blockchain DB %q: timeout (if a node is running with the same NODE_ID, stop it and retry)", nodeDBFile(nodeID))
-		}
-		log.Panic(err)
+		return nil, openError(nodeID, err)
+	}
+
+	tip, err := readTip(db)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if err := checkDBVersion(db, nodeID, tip, false); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	cfg, err := loadChainConfig(db)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
 	}
 
+	return &Blockchain{db: db, tip: tip, config: cfg, blocks: newBlockCache(), events: NewEventBus(), metrics: new(Metrics)}, nil
+}
+
+// readTip returns the stored tip hash of an existing database.
+func readTip(db *bbolt.DB) ([]byte, error) {
 	var tip []byte
-	err = db.View(func(tx *bbolt.Tx) error {
+	err := db.View(func(tx *bbolt.Tx) error {
 		b := tx.Bucket([]byte(blocksBucket))
 		if b == nil {
-			log.Panic("blockchain database is missing blocks bucket")
+			return errors.New("blockchain database is missing blocks bucket")
 		}
-		tip = b.Get([]byte(lastHashKey))
+		// bbolt's slices are only valid inside the transaction; the tip outlives it.
+		tip = append([]byte(nil), b.Get([]byte(lastHashKey))...)
 		return nil
 	})
-	if err != nil {
-		log.Panic(err)
-	}
-
-	return &Blockchain{db: db, tip: tip}
+	return tip, err
 }
 
 // InitBlockchainForNode opens the DB for a node and ensures the bucket exists.
 // It does NOT create a genesis block. Used by networking nodes that will sync from peers.
-func InitBlockchainForNode(nodeID string) *Blockchain {
+// A new, empty DB adopts cfg; an existing one keeps the config it was created with.
+func InitBlockchainForNode(nodeID string, cfg ChainConfig) (*Blockchain, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
 	db, err := openDB(nodeID)
 	if err != nil {
-		if errors.Is(err, bbolt.ErrTimeout) {
-			log.Panicf("failed to open blockchain DB %q: timeout (if a node is running with the same NODE_ID, stop it and retry)", nodeDBFile(nodeID))
-		}
-		log.Panic(err)
+		return nil, openError(nodeID, err)
 	}
 
 	var tip []byte
@@ -202,14 +316,53 @@ func InitBlockchainForNode(nodeID string) *Blockchain {
 				return createErr
 			}
 		}
-		tip = b.Get([]byte(lastHashKey))
-		return nil
+		tip = append([]byte(nil), b.Get([]byte(lastHashKey))...)
+		if _, ok, readErr := readChainConfig(tx); ok || readErr != nil || len(tip) > 0 {
+			return readErr
+		}
+		return putChainConfig(tx, cfg)
 	})
 	if err != nil {
-		log.Panic(err)
+		_ = db.Close()
+		return nil, err
+	}
+	if err := checkDBVersion(db, nodeID, tip, true); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	loaded, err := loadChainConfig(db)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	bc := &Blockchain{db: db, tip: tip, config: loaded, blocks: newBlockCache(), events: NewEventBus(), metrics: new(Metrics)}
+	if err := bc.ensureUTXOIndex(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if err := bc.ensureAddrIndex(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if err := bc.ensureHeightIndex(); err != nil {
+		_ = db.Close()
+		return nil, err
 	}
+	if err := bc.ensureMainChainIndex(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return bc, nil
+}
 
-	return &Blockchain{db: db, tip: tip}
+// ensureUTXOIndex builds the chainstate bucket for databases created before it existed.
+func (bc *Blockchain) ensureUTXOIndex() error {
+	u := UTXOSet{Blockchain: bc}
+	if len(bc.Tip()) > 0 && !u.indexed() {
+		return u.Reindex()
+	}
+	return nil
 }
 
 func (bc *Blockchain) Close() error {
@@ -220,85 +373,264 @@ func (bc *Blockchain) Close() error {
 }
 
 func (bc *Blockchain) Tip() []byte {
+	bc.tipMu.RLock()
+	defer bc.tipMu.RUnlock()
 	return bc.tip
 }
 
-func (bc *Blockchain) AddBlock(transactions []*Transaction) []byte {
-	for _, tx := range transactions {
-		if !bc.VerifyTransaction(tx) {
-			log.Panic("invalid transaction")
-		}
-	}
+func (bc *Blockchain) setTip(hash []byte) {
+	bc.tipMu.Lock()
+	defer bc.tipMu.Unlock()
+	bc.tip = hash
+}
 
-	var lastHash []byte
+// at returns a read-only view of the chain ending at tip, which need not be the main
+// chain's, for checking a side branch without moving the real tip.
+func (bc *Blockchain) at(tip []byte) *Blockchain {
+	return &Blockchain{db: bc.db, tip: tip, config: bc.config, log: bc.log, events: bc.events, metrics: bc.metrics, blocks: bc.blocks}
+}
 
-	err := bc.db.View(func(tx *bbolt.Tx) error {
-		b := tx.Bucket([]byte(blocksBucket))
-		lastHash = b.Get([]byte(lastHashKey))
-		return nil
-	})
+// ErrStaleTip is returned by AddBlockContext when the tip moved while the block was mined.
+var ErrStaleTip = errors.New("tip changed while mining")
+
+// AddBlock mines transactions into a new block on top of the tip and returns its hash.
+func (bc *Blockchain) AddBlock(transactions []*Transaction) ([]byte, error) {
+	return bc.AddBlockContext(context.Background(), transactions, 1)
+}
+
+// AddBlockContext is AddBlock mining with workers goroutines. Cancelling ctx abandons the
+// block and returns ctx's error.
+func (bc *Blockchain) AddBlockContext(ctx context.Context, transactions []*Transaction, workers int) ([]byte, error) {
+	tmpl, err := bc.NewBlockTemplate(transactions)
 	if err != nil {
-		log.Panic(err)
+		return nil, err
 	}
+	pow := NewProofOfWork(tmpl.block)
+	pow.attempts = &bc.metrics.PoWAttempts
+	nonce, _, err := pow.RunParallel(ctx, workers)
+	if err != nil {
+		return nil, err
+	}
+	return tmpl.SubmitSolution(nonce)
+}
 
-	newBlock := NewBlock(transactions, lastHash)
+// connectMinedBlock stores a block mined locally on top of the current tip and makes it
+// the new tip, failing with ErrStaleTip if the tip has moved since it was built.
+func (bc *Blockchain) connectMinedBlock(block *Block, height int) error {
+	bc.putMu.Lock()
+	defer bc.putMu.Unlock()
 
-	err = bc.db.Update(func(tx *bbolt.Tx) error {
+	if err := bc.checkCheckpoint(height, block.Hash); err != nil {
+		return err
+	}
+	err := bc.db.Update(func(tx *bbolt.Tx) error {
 		b := tx.Bucket([]byte(blocksBucket))
-		if putErr := b.Put(newBlock.Hash, newBlock.Serialize()); putErr != nil {
+		if !bytes.Equal(b.Get([]byte(lastHashKey)), block.PrevBlockHash) {
+			return ErrStaleTip
+		}
+		if putErr := b.Put(block.Hash, block.Serialize()); putErr != nil {
+			return putErr
+		}
+		if putErr := putBlockHeight(tx, block); putErr != nil {
 			return putErr
 		}
-		if putErr := b.Put([]byte(lastHashKey), newBlock.Hash); putErr != nil {
+		if putErr := b.Put([]byte(lastHashKey), block.Hash); putErr != nil {
 			return putErr
 		}
-		bc.tip = newBlock.Hash
+		if utxoErr := updateUTXOSet(tx, block, height); utxoErr != nil {
+			return utxoErr
+		}
 		return nil
 	})
 	if err != nil {
-		log.Panic(err)
+		return err
 	}
-	return newBlock.Hash
+	bc.setTip(block.Hash)
+	bc.metrics.BlocksProcessed.Inc()
+	bc.events.Publish(Event{Type: EventBlock, Hash: block.Hash, Height: height})
+	return nil
 }
 
+// ErrTransactionNotFound is returned when no block on the chain contains a transaction.
+var ErrTransactionNotFound = errors.New("transaction not found")
+
+// FindTransaction returns the main-chain transaction with the given ID. Transactions in
+// pruned blocks are rebuilt from their unspent outputs, which is all signing and fee
+// computation need. Otherwise it fails with ErrTransactionNotFound, also on a chain with
+// no blocks yet.
 func (bc *Blockchain) FindTransaction(ID []byte) (Transaction, error) {
+	tx, _, err := bc.FindTransactionBlock(ID)
+	if errors.Is(err, ErrTransactionNotFound) {
+		tx, _, err = bc.prunedTransaction(ID)
+	}
+	return tx, err
+}
+
+// FindTransactionBlock is FindTransaction that also returns the hash of the block containing the transaction.
+func (bc *Blockchain) FindTransactionBlock(ID []byte) (Transaction, []byte, error) {
 	it := bc.Iterator()
 	for {
 		block := it.Next()
+		if block == nil {
+			break
+		}
 		for _, tx := range block.Transactions {
 			if bytes.Equal(tx.ID, ID) {
-				return *tx, nil
+				return *tx, block.Hash, nil
 			}
 		}
 		if len(block.PrevBlockHash) == 0 {
 			break
 		}
 	}
-	return Transaction{}, errors.New("transaction not found")
+	return Transaction{}, nil, ErrTransactionNotFound
+}
+
+// pendingSet keys the transactions of txs, but for coinbases, by hex ID: the unconfirmed
+// parents that findPrevTx looks up before the chain. A coinbase is left out since its
+// outputs cannot be spent before they mature.
+func pendingSet(txs []*Transaction) map[string]*Transaction {
+	pending := make(map[string]*Transaction, len(txs))
+	for _, tx := range txs {
+		if !tx.IsCoinbase() {
+			pending[hex.EncodeToString(tx.ID)] = tx
+		}
+	}
+	return pending
+}
+
+// findPrevTx returns the transaction with ID, which an input spends, from pending
+// (unconfirmed transactions keyed by hex ID, such as the mempool or the earlier
+// transactions of a block) or else from the chain, with the height of its block: 0 for a
+// pending one.
+func (bc *Blockchain) findPrevTx(ID []byte, pending map[string]*Transaction) (Transaction, int, error) {
+	if tx, ok := pending[hex.EncodeToString(ID)]; ok {
+		return *tx, 0, nil
+	}
+	return bc.findTransactionHeight(ID)
 }
 
-func (bc *Blockchain) SignTransaction(tx *Transaction, privKey *ecdsa.PrivateKey) {
+// SignTransaction signs tx with privKey, looking up the transactions its inputs spend. An
+// input whose transaction is not on the chain makes it fail with ErrTransactionNotFound.
+func (bc *Blockchain) SignTransaction(tx *Transaction, privKey *ecdsa.PrivateKey) error {
+	return bc.signTransaction(tx, privKey, nil)
+}
+
+// signTransaction is SignTransaction also finding the spent transactions in pending (see
+// findPrevTx).
+func (bc *Blockchain) signTransaction(tx *Transaction, privKey *ecdsa.PrivateKey, pending map[string]*Transaction) error {
 	prevTXs := make(map[string]Transaction)
 	for _, vin := range tx.Vin {
-		prevTx, err := bc.FindTransaction(vin.Txid)
+		prevTx, _, err := bc.findPrevTx(vin.Txid, pending)
 		if err != nil {
-			log.Panic(err)
+			return fmt.Errorf("input %x:%d: %w", vin.Txid, vin.Vout, err)
 		}
 		prevTXs[hex.EncodeToString(prevTx.ID)] = prevTx
 	}
-	tx.Sign(privKey, prevTXs)
+	return tx.Sign(privKey, prevTXs)
 }
 
-func (bc *Blockchain) VerifyTransaction(tx *Transaction) bool {
+// VerifyTransaction checks tx's outputs, the outputs it spends and its signatures against
+// the chain, with the low-S rule mempools apply. The error wraps ErrInvalidTransaction and
+// says what is wrong; an input whose transaction is not on the chain also wraps
+// ErrTransactionNotFound.
+func (bc *Blockchain) VerifyTransaction(tx *Transaction) error {
+	return bc.verifyTransactionWith(tx, nil, true)
+}
+
+// verifyTransactionWith is VerifyTransaction also accepting inputs that spend the outputs
+// of pending transactions (see findPrevTx), and high-S signatures unless requireLowS is set.
+func (bc *Blockchain) verifyTransactionWith(tx *Transaction, pending map[string]*Transaction, requireLowS bool) error {
+	err := bc.verifyTransaction(tx, pending, requireLowS)
+	if err == nil {
+		bc.metrics.TxsValid.Inc()
+	} else {
+		bc.metrics.TxsInvalid.Inc()
+	}
+	return err
+}
+
+func (bc *Blockchain) verifyTransaction(tx *Transaction, pending map[string]*Transaction, requireLowS bool) error {
 	if tx.IsCoinbase() {
-		return true
+		return nil
+	}
+	if err := checkOutputValues(tx); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidTransaction, err)
+	}
+	for i, out := range tx.Vout {
+		if err := checkDataOutput(out); err != nil {
+			return fmt.Errorf("%w: %x: output %d: %w", ErrInvalidTransaction, tx.ID, i, err)
+		}
+		if err := out.checkPushes(); err != nil {
+			return fmt.Errorf("%w: %x: output %d: %w", ErrInvalidTransaction, tx.ID, i, err)
+		}
 	}
 	prevTXs := make(map[string]Transaction)
+	inputValue := 0
+	spendHeight := bc.BestHeight() + 1
 	for _, vin := range tx.Vin {
-		prevTx, err := bc.FindTransaction(vin.Txid)
+		if err := vin.checkPushes(); err != nil {
+			return fmt.Errorf("%w: %x: input %x:%d: %w", ErrInvalidTransaction, tx.ID, vin.Txid, vin.Vout, err)
+		}
+		prevTx, height, err := bc.findPrevTx(vin.Txid, pending)
 		if err != nil {
-			log.Panic(err)
+			return fmt.Errorf("%w: %x: input %x:%d: %w", ErrInvalidTransaction, tx.ID, vin.Txid, vin.Vout, err)
 		}
+		if vin.Vout < 0 || vin.Vout >= len(prevTx.Vout) || prevTx.Vout[vin.Vout].IsData() {
+			return fmt.Errorf("%w: %x: input %x:%d references a non-existent output", ErrInvalidTransaction, tx.ID, vin.Txid, vin.Vout)
+		}
+		if prevTx.IsCoinbase() && !bc.config.coinbaseMature(height, spendHeight) {
+			return fmt.Errorf("%w: %x: input %x:%d spends an immature coinbase", ErrInvalidTransaction, tx.ID, vin.Txid, vin.Vout)
+		}
+		inputValue += prevTx.Vout[vin.Vout].Value
 		prevTXs[hex.EncodeToString(prevTx.ID)] = prevTx
 	}
-	return tx.Verify(prevTXs)
+	// Outputs may not create value; whatever is left over is the miner's fee.
+	if tx.OutputValue() > inputValue {
+		return fmt.Errorf("%w: %x: outputs %d exceed inputs %d", ErrInvalidTransaction, tx.ID, tx.OutputValue(), inputValue)
+	}
+	if !tx.verify(prevTXs, requireLowS) {
+		return fmt.Errorf("%w: %x: bad signature", ErrInvalidTransaction, tx.ID)
+	}
+	return nil
+}
+
+// TransactionFee returns the value spent by tx's inputs minus the value of its outputs.
+// Coinbase transactions pay no fee.
+func (bc *Blockchain) TransactionFee(tx *Transaction) (int, error) {
+	return bc.transactionFee(tx, nil)
+}
+
+// transactionFee is TransactionFee also finding the spent transactions in pending (see
+// findPrevTx).
+func (bc *Blockchain) transactionFee(tx *Transaction, pending map[string]*Transaction) (int, error) {
+	if tx.IsCoinbase() {
+		return 0, nil
+	}
+	inputValue := 0
+	for _, vin := range tx.Vin {
+		prevTx, _, err := bc.findPrevTx(vin.Txid, pending)
+		if err != nil {
+			return 0, fmt.Errorf("input %x:%d: %w", vin.Txid, vin.Vout, err)
+		}
+		if vin.Vout < 0 || vin.Vout >= len(prevTx.Vout) {
+			return 0, fmt.Errorf("input %x:%d references a non-existent output", vin.Txid, vin.Vout)
+		}
+		inputValue += prevTx.Vout[vin.Vout].Value
+	}
+	return inputValue - tx.OutputValue(), nil
+}
+
+// TotalFees sums the fees of all non-coinbase transactions in txs, which, like those of a
+// block, may spend the outputs of earlier ones.
+func (bc *Blockchain) TotalFees(txs []*Transaction) (int, error) {
+	total := 0
+	for i, tx := range txs {
+		fee, err := bc.transactionFee(tx, pendingSet(txs[:i]))
+		if err != nil {
+			return 0, err
+		}
+		total += fee
+	}
+	return total, nil
 }
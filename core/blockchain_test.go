@@ -0,0 +1,217 @@
+package core
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"my-blockchain/wallet"
+)
+
+// newEmptyChain opens a regtest chain with no blocks yet, like a node's before it syncs.
+func newEmptyChain(t *testing.T) *Blockchain {
+	t.Helper()
+	chdirTemp(t)
+	bc, err := InitBlockchainForNode("empty", RegtestConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = bc.Close() })
+	return bc
+}
+
+func TestFindTransactionOnEmptyChain(t *testing.T) {
+	bc := newEmptyChain(t)
+	id := []byte("no such transaction")
+
+	if _, err := bc.FindTransaction(id); !errors.Is(err, ErrTransactionNotFound) {
+		t.Fatalf("FindTransaction: got %v, want ErrTransactionNotFound", err)
+	}
+	if _, _, err := bc.FindTransactionBlock(id); !errors.Is(err, ErrTransactionNotFound) {
+		t.Fatalf("FindTransactionBlock: got %v, want ErrTransactionNotFound", err)
+	}
+	if _, _, _, err := bc.FindTransactionLocation(id); !errors.Is(err, ErrTransactionNotFound) {
+		t.Fatalf("FindTransactionLocation: got %v, want ErrTransactionNotFound", err)
+	}
+}
+
+func TestEmptyChainEdgeCases(t *testing.T) {
+	bc := newEmptyChain(t)
+
+	if block := bc.Iterator().Next(); block != nil {
+		t.Fatalf("iterator returned block %x on an empty chain", block.Hash)
+	}
+	if got := bc.BestHeight(); got != 0 {
+		t.Fatalf("BestHeight = %d, want 0", got)
+	}
+	if got := bc.GenesisHash(); len(got) != 0 {
+		t.Fatalf("GenesisHash = %x, want none", got)
+	}
+	if got := bc.GetBlockHashes(); len(got) != 0 {
+		t.Fatalf("GetBlockHashes returned %d hashes, want none", len(got))
+	}
+	if got := bc.NextTargetBits(); got != RegtestConfig.TargetBits {
+		t.Fatalf("NextTargetBits = %d, want the genesis difficulty %d", got, RegtestConfig.TargetBits)
+	}
+	pubKeyHash := wallet.HashPubKey(wallet.NewWallet().PubKey())
+	if got := bc.FindUTXO(pubKeyHash); len(got) != 0 {
+		t.Fatalf("FindUTXO returned %d outputs, want none", len(got))
+	}
+	if got := bc.ListUTXOs(pubKeyHash); len(got) != 0 {
+		t.Fatalf("ListUTXOs returned %d outputs, want none", len(got))
+	}
+	if amount, _ := bc.FindSpendableOutputs(pubKeyHash, 1); amount != 0 {
+		t.Fatalf("FindSpendableOutputs found %d, want 0", amount)
+	}
+	if err := bc.VerifyChain(); err != nil {
+		t.Fatalf("VerifyChain: %v", err)
+	}
+}
+
+func TestFindTransactionNotInChain(t *testing.T) {
+	bc, _ := newTestChain(t)
+	genesis := mustBlock(t, bc, bc.Tip())
+
+	tx, err := bc.FindTransaction(genesis.Transactions[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tx.IsCoinbase() {
+		t.Fatal("found transaction is not the genesis coinbase")
+	}
+	if _, err := bc.FindTransaction([]byte("no such transaction")); !errors.Is(err, ErrTransactionNotFound) {
+		t.Fatalf("unknown ID: got %v, want ErrTransactionNotFound", err)
+	}
+	if _, err := bc.FindTransaction(nil); !errors.Is(err, ErrTransactionNotFound) {
+		t.Fatalf("nil ID: got %v, want ErrTransactionNotFound", err)
+	}
+}
+
+func TestFindTransactionLocationHeights(t *testing.T) {
+	bc, w := newTestChain(t)
+	genesis := mustBlock(t, bc, bc.Tip())
+	payment := spend(t, bc, w, genesis.Transactions[0], 0, 10)
+
+	block2 := mineOn(t, bc, genesis, 2)
+	block3 := mineOn(t, bc, block2, 3, payment)
+	block4 := mineOn(t, bc, block3, 4)
+	for _, b := range []*Block{block2, block3, block4} {
+		if err := bc.PutBlock(b.Serialize()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A shorter side branch stays off the main chain.
+	side2 := mineOn(t, bc, genesis, 2)
+	side3 := mineOn(t, bc, side2, 3)
+	for _, b := range []*Block{side2, side3} {
+		if err := bc.PutBlock(b.Serialize()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		view   *Blockchain
+		tx     *Transaction
+		block  *Block
+		height int
+	}{
+		{"genesis coinbase", bc, genesis.Transactions[0], genesis, 1},
+		{"payment", bc, payment, block3, 3},
+		{"tip coinbase", bc, block4.Transactions[0], block4, 4},
+		{"side branch coinbase", bc.at(side3.Hash), side2.Transactions[0], side2, 2},
+	}
+	for _, tt := range tests {
+		tx, blockHash, height, err := tt.view.FindTransactionLocation(tt.tx.ID)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !bytes.Equal(tx.ID, tt.tx.ID) || !bytes.Equal(blockHash, tt.block.Hash) || height != tt.height {
+			t.Fatalf("%s: found %x in %x at height %d, want block %x at height %d", tt.name, tx.ID, blockHash, height, tt.block.Hash, tt.height)
+		}
+	}
+}
+
+func TestGenesisCoinbasePaysCreatorAddress(t *testing.T) {
+	chdirTemp(t)
+	if _, err := CreateBlockchainForNode("not an address", "bad", RegtestConfig); !errors.Is(err, ErrInvalidAddress) {
+		t.Fatalf("creating a chain for an invalid address: got %v, want ErrInvalidAddress", err)
+	}
+
+	for _, cfg := range []ChainConfig{MainConfig, RegtestConfig} {
+		address := string(wallet.NewWallet().GetAddress())
+		bc, err := CreateBlockchainForNode(address, cfg.Name, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = bc.Close() })
+
+		genesis := mustBlock(t, bc, bc.Tip())
+		coinbase := genesis.Transactions[0]
+		if !coinbase.IsCoinbase() || len(coinbase.Vout) != 1 {
+			t.Fatalf("%s genesis transaction %+v, want a coinbase with one output", cfg.Name, coinbase)
+		}
+		if !coinbase.Vout[0].IsLockedWithKey(wallet.PubKeyHashFromAddress(address)) {
+			t.Fatalf("%s genesis coinbase is not locked to %s", cfg.Name, address)
+		}
+		if got := balance(bc, address); got != cfg.Subsidy {
+			t.Fatalf("%s initial balance %d, want the subsidy %d", cfg.Name, got, cfg.Subsidy)
+		}
+	}
+}
+
+func TestSignAndVerifyWithMissingInput(t *testing.T) {
+	bc, w := newTestChain(t)
+	missing := bytes.Repeat([]byte{0xab}, 32)
+	tx := &Transaction{
+		Vin:  []TxInput{{Txid: missing, Vout: 1, PubKey: w.PubKey()}},
+		Vout: []TxOutput{*NewTxOutput(5, string(w.GetAddress()))},
+	}
+	tx.ID = tx.Hash()
+
+	err := bc.SignTransaction(tx, w.PrivateECDSA())
+	if !errors.Is(err, ErrTransactionNotFound) {
+		t.Fatalf("SignTransaction: got %v, want ErrTransactionNotFound", err)
+	}
+	if want := hex.EncodeToString(missing) + ":1"; !strings.Contains(err.Error(), want) {
+		t.Fatalf("SignTransaction: %q does not name the input %s", err, want)
+	}
+
+	err = bc.VerifyTransaction(tx)
+	if !errors.Is(err, ErrTransactionNotFound) || !errors.Is(err, ErrInvalidTransaction) {
+		t.Fatalf("VerifyTransaction: got %v, want ErrInvalidTransaction and ErrTransactionNotFound", err)
+	}
+	if !strings.Contains(err.Error(), hex.EncodeToString(missing)) {
+		t.Fatalf("VerifyTransaction: %q does not name the missing transaction", err)
+	}
+}
+
+func TestOpeningHeldDBReturnsErrDBLocked(t *testing.T) {
+	newTestChain(t)
+	defer func(old time.Duration) { DBLockTimeout = old }(DBLockTimeout)
+	DBLockTimeout = 50 * time.Millisecond
+
+	for name, open := range map[string]func() (*Blockchain, error){
+		"OpenBlockchainForNode": func() (*Blockchain, error) { return OpenBlockchainForNode("test") },
+		"InitBlockchainForNode": func() (*Blockchain, error) { return InitBlockchainForNode("test", RegtestConfig) },
+	} {
+		bc, err := open()
+		if err == nil {
+			_ = bc.Close()
+			t.Fatalf("%s opened a DB held by another chain", name)
+		}
+		var locked *DBLockedError
+		if !errors.Is(err, ErrDBLocked) || !errors.As(err, &locked) {
+			t.Fatalf("%s: got %v, want a DBLockedError", name, err)
+		}
+		if locked.NodeID != "test" || locked.Path != "blockchain_test.db" || locked.Waited != DBLockTimeout {
+			t.Fatalf("%s: got %+v", name, locked)
+		}
+		if !strings.Contains(err.Error(), locked.Path) {
+			t.Fatalf("%s: %q does not name the file", name, err)
+		}
+	}
+}
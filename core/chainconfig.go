@@ -0,0 +1,195 @@
+package core
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+
+	"go.etcd.io/bbolt"
+)
+
+// configBucket holds the chain's ChainConfig under configKey.
+const (
+	configBucket = "config"
+	configKey    = "chain"
+)
+
+// ChainConfig holds the consensus parameters a chain is created with. It is stored in the
+// chain's DB and every Blockchain opened on that DB validates with it, so every command
+// and node working on the DB applies the same rules.
+type ChainConfig struct {
+	Name string
+	// Subsidy is the block reward before any halving.
+	Subsidy int
+	// TargetBits is the proof-of-work difficulty of the genesis block and the blocks after
+	// it until the first retarget.
+	TargetBits       int
+	GenesisMessage   string
+	HalvingInterval  int
+	CoinbaseMaturity int
+	// NoRetargeting keeps every block at TargetBits instead of adjusting the difficulty
+	// to the block rate.
+	NoRetargeting bool
+	// LowSHeight, when not 0, is the first block height whose transactions must sign in
+	// low-S form (see wallet.IsLowS). Mempools refuse high-S signatures regardless; chains
+	// created before the rule existed decode 0 and keep accepting them in blocks.
+	LowSHeight int
+	// Checkpoints pins the main chain's block hash at some heights. Blocks and branches
+	// that disagree are rejected, so peers cannot rewrite history before them.
+	Checkpoints map[int][]byte
+	// MaxBlockSize and MaxTxSize bound the serialized size in bytes of a block and of each
+	// transaction in it.
+	MaxBlockSize int
+	MaxTxSize    int
+	// DustThreshold is the smallest value a spendable output may hold; 0 disables the
+	// check, though outputs must still be worth at least 1 (see checkOutputValues).
+	DustThreshold int
+}
+
+// legacyDustThreshold is the dust threshold of chains stored before their config held
+// the size and dust limits: every block of theirs meets it.
+const legacyDustThreshold = 1
+
+// Built-in chain profiles, selected by name with ChainConfigByName.
+var (
+	MainConfig = ChainConfig{
+		Name:             "main",
+		Subsidy:          10,
+		TargetBits:       Difficulty,
+		GenesisMessage:   "Genesis",
+		HalvingInterval:  210,
+		CoinbaseMaturity: 100,
+		LowSHeight:       1,
+		MaxBlockSize:     DefaultMaxBlockSize,
+		MaxTxSize:        DefaultMaxTxSize,
+		DustThreshold:    DefaultDustThreshold,
+	}
+	TestConfig = ChainConfig{
+		Name:             "test",
+		Subsidy:          10,
+		TargetBits:       12,
+		GenesisMessage:   "Testnet genesis",
+		HalvingInterval:  210,
+		CoinbaseMaturity: 10,
+		LowSHeight:       1,
+		MaxBlockSize:     DefaultMaxBlockSize,
+		MaxTxSize:        DefaultMaxTxSize,
+		DustThreshold:    DefaultDustThreshold,
+	}
+	// RegtestConfig mines almost instantly, for tests and local experiments.
+	RegtestConfig = ChainConfig{
+		Name:             "regtest",
+		Subsidy:          10,
+		TargetBits:       1,
+		GenesisMessage:   "Regtest genesis",
+		HalvingInterval:  150,
+		CoinbaseMaturity: 100,
+		LowSHeight:       1,
+		NoRetargeting:    true,
+		MaxBlockSize:     DefaultMaxBlockSize,
+		MaxTxSize:        DefaultMaxTxSize,
+		DustThreshold:    DefaultDustThreshold,
+	}
+)
+
+// ChainConfigByName returns the built-in profile called name ("main", "test" or "regtest").
+func ChainConfigByName(name string) (ChainConfig, error) {
+	for _, cfg := range []ChainConfig{MainConfig, TestConfig, RegtestConfig} {
+		if cfg.Name == name {
+			return cfg, nil
+		}
+	}
+	return ChainConfig{}, fmt.Errorf("unknown chain %q (want main, test or regtest)", name)
+}
+
+// Validate rejects parameters the consensus code cannot work with.
+func (cfg ChainConfig) Validate() error {
+	switch {
+	case cfg.Subsidy < 1:
+		return fmt.Errorf("chain %s: subsidy must be at least 1", cfg.Name)
+	case checkTargetBitsRange(cfg.TargetBits) != nil:
+		return fmt.Errorf("chain %s: target bits must be between %d and %d", cfg.Name, minPowBits, maxPowBits)
+	case cfg.HalvingInterval < 1:
+		return fmt.Errorf("chain %s: halving interval must be at least 1", cfg.Name)
+	case cfg.CoinbaseMaturity < 0:
+		return fmt.Errorf("chain %s: coinbase maturity must not be negative", cfg.Name)
+	case cfg.LowSHeight < 0:
+		return fmt.Errorf("chain %s: low-S activation height must not be negative", cfg.Name)
+	case cfg.MaxBlockSize <= blockReserve:
+		return fmt.Errorf("chain %s: max block size must be more than %d bytes", cfg.Name, blockReserve)
+	case cfg.MaxTxSize < 1:
+		return fmt.Errorf("chain %s: max transaction size must be at least 1 byte", cfg.Name)
+	case cfg.DustThreshold < 0:
+		return fmt.Errorf("chain %s: dust threshold must not be negative", cfg.Name)
+	case len(cfg.GenesisMessage) < minCoinbaseData || len(cfg.GenesisMessage) > maxCoinbaseData:
+		return fmt.Errorf("chain %s: genesis message must be %d to %d bytes", cfg.Name, minCoinbaseData, maxCoinbaseData)
+	}
+	for height, hash := range cfg.Checkpoints {
+		if height < 1 || len(hash) == 0 {
+			return fmt.Errorf("chain %s: invalid checkpoint at height %d", cfg.Name, height)
+		}
+	}
+	return nil
+}
+
+// requiresLowS reports whether transactions in a block at height must sign in low-S form.
+func (cfg ChainConfig) requiresLowS(height int) bool {
+	return cfg.LowSHeight > 0 && height >= cfg.LowSHeight
+}
+
+// Config returns the consensus parameters of the chain.
+func (bc *Blockchain) Config() ChainConfig {
+	return bc.config
+}
+
+func putChainConfig(tx *bbolt.Tx, cfg ChainConfig) error {
+	b, err := tx.CreateBucketIfNotExists([]byte(configBucket))
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(cfg); err != nil {
+		return err
+	}
+	return b.Put([]byte(configKey), buf.Bytes())
+}
+
+// readChainConfig returns the stored config, or ok=false for a DB without one.
+func readChainConfig(tx *bbolt.Tx) (cfg ChainConfig, ok bool, err error) {
+	b := tx.Bucket([]byte(configBucket))
+	if b == nil {
+		return cfg, false, nil
+	}
+	data := b.Get([]byte(configKey))
+	if data == nil {
+		return cfg, false, nil
+	}
+	if err = gob.NewDecoder(bytes.NewReader(data)).Decode(&cfg); err != nil {
+		return cfg, false, err
+	}
+	// Configs stored before the limits were part of them decode 0 for all three.
+	if cfg.MaxBlockSize == 0 {
+		cfg.MaxBlockSize = DefaultMaxBlockSize
+		cfg.MaxTxSize = DefaultMaxTxSize
+		cfg.DustThreshold = legacyDustThreshold
+	}
+	return cfg, true, nil
+}
+
+// loadChainConfig reads the DB's config. Chains created before configs were stored run
+// under MainConfig, whose values they were created with, and the legacy dust threshold.
+func loadChainConfig(db *bbolt.DB) (ChainConfig, error) {
+	cfg := MainConfig
+	cfg.DustThreshold = legacyDustThreshold
+	err := db.View(func(tx *bbolt.Tx) error {
+		stored, ok, err := readChainConfig(tx)
+		if ok {
+			cfg = stored
+		}
+		return err
+	})
+	if err != nil {
+		return ChainConfig{}, fmt.Errorf("reading chain config: %w", err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,214 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"go.etcd.io/bbolt"
+
+	"my-blockchain/wallet"
+)
+
+func TestChainsKeepTheirOwnConfig(t *testing.T) {
+	small, _ := newTestChain(t)
+	cfg := RegtestConfig
+	cfg.Subsidy = 40
+	cfg.CoinbaseMaturity = 2
+	big, err := CreateBlockchainForNode(string(wallet.NewWallet().GetAddress()), "other", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = big.Close() })
+
+	if got := small.Config().BlockReward(2); got != RegtestConfig.Subsidy {
+		t.Fatalf("first chain's reward after opening a second: got %d, want %d", got, RegtestConfig.Subsidy)
+	}
+	if small.config.coinbaseMature(2, 4) {
+		t.Fatal("first chain took the second chain's coinbase maturity")
+	}
+
+	// A coinbase paying the second chain's reward is too much for the first.
+	smallGenesis := mustBlock(t, small, small.Tip())
+	overpaid := mineBits(t, big, smallGenesis, 2, small.config.TargetBits)
+	if err := small.PutBlock(overpaid.Serialize()); !errors.Is(err, ErrBadCoinbaseValue) {
+		t.Fatalf("block paying %d on a chain paying %d: got %v, want ErrBadCoinbaseValue", cfg.Subsidy, RegtestConfig.Subsidy, err)
+	}
+	block := mineOn(t, big, mustBlock(t, big, big.Tip()), 2)
+	if err := big.PutBlock(block.Serialize()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCustomSubsidyPaysGenesisAndLaterBlocks(t *testing.T) {
+	chdirTemp(t)
+	cfg := RegtestConfig
+	cfg.Name = "custom"
+	cfg.Subsidy = 25
+	cfg.HalvingInterval = 3
+	to := string(wallet.NewWallet().GetAddress())
+	bc, err := CreateBlockchainForNode(to, "custom", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The config is stored with the chain, so reopening it keeps the custom subsidy.
+	bc, err = OpenBlockchainForNode("custom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = bc.Close() })
+	if got := bc.Config(); got.Subsidy != 25 || got.HalvingInterval != 3 {
+		t.Fatalf("reopened chain has subsidy %d and halving interval %d, want 25 and 3", got.Subsidy, got.HalvingInterval)
+	}
+	for height := 2; height <= 3; height++ {
+		if _, err := bc.AddBlock([]*Transaction{bc.Config().CoinbaseTx(to, "", height)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Height 3 is the first past the halving interval.
+	for height, want := range map[int]int{1: 25, 2: 25, 3: 12} {
+		hash, err := bc.GetBlockHash(height)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := mustBlock(t, bc, hash).Transactions[0].Vout[0].Value; got != want {
+			t.Errorf("coinbase at height %d pays %d, want %d", height, got, want)
+		}
+	}
+	if got := balance(bc, to); got != 62 {
+		t.Fatalf("balance %d, want 62", got)
+	}
+}
+
+func TestChainsKeepTheirOwnLimits(t *testing.T) {
+	strict, w := newTestChain(t)
+	cfg := RegtestConfig
+	cfg.DustThreshold = 0
+	cfg.MaxTxSize = 200
+	loose, err := CreateBlockchainForNode(string(w.GetAddress()), "other", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := loose.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Reopening reads the limits stored with the chain.
+	loose, err = OpenBlockchainForNode("other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = loose.Close() })
+	if got := loose.Config(); got.DustThreshold != 0 || got.MaxTxSize != 200 || got.MaxBlockSize != DefaultMaxBlockSize {
+		t.Fatalf("reopened chain's limits: dust %d, tx %d, block %d", got.DustThreshold, got.MaxTxSize, got.MaxBlockSize)
+	}
+
+	// Both chains share the genesis key, so the same spend is offered to each.
+	tiny := spend(t, strict, w, mustBlock(t, strict, strict.Tip()).Transactions[0], 0, 1)
+	if _, err := strict.CheckPendingTx(tiny, NewMempool()); !errors.Is(err, ErrDustOutput) {
+		t.Fatalf("output of 1 on the default chain: got %v, want ErrDustOutput", err)
+	}
+	tiny = spend(t, loose, w, mustBlock(t, loose, loose.Tip()).Transactions[0], 0, 1)
+	if _, err := loose.CheckPendingTx(tiny, NewMempool()); !errors.Is(err, ErrTxTooLarge) {
+		t.Fatalf("%d-byte transaction under a 200-byte limit: got %v, want ErrTxTooLarge", len(tiny.Serialize()), err)
+	}
+}
+
+func TestConfigStoredWithoutLimitsGetsDefaults(t *testing.T) {
+	bc, _ := newTestChain(t)
+	old := RegtestConfig
+	old.MaxBlockSize, old.MaxTxSize, old.DustThreshold = 0, 0, 0
+	if err := bc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	rewriteDB(t, func(tx *bbolt.Tx) error { return putChainConfig(tx, old) })
+	bc, err := OpenBlockchainForNode("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = bc.Close() })
+	if got := bc.Config(); got.MaxBlockSize != DefaultMaxBlockSize || got.MaxTxSize != DefaultMaxTxSize || got.DustThreshold != legacyDustThreshold {
+		t.Fatalf("limits of a chain stored without them: block %d, tx %d, dust %d", got.MaxBlockSize, got.MaxTxSize, got.DustThreshold)
+	}
+}
+
+func TestCreateChainRejectsInvalidConfig(t *testing.T) {
+	chdirTemp(t)
+	to := string(wallet.NewWallet().GetAddress())
+	for name, change := range map[string]func(*ChainConfig){
+		"subsidy":              func(cfg *ChainConfig) { cfg.Subsidy = 0 },
+		"target bits":          func(cfg *ChainConfig) { cfg.TargetBits = 256 },
+		"halving interval":     func(cfg *ChainConfig) { cfg.HalvingInterval = 0 },
+		"maturity":             func(cfg *ChainConfig) { cfg.CoinbaseMaturity = -1 },
+		"max block size":       func(cfg *ChainConfig) { cfg.MaxBlockSize = 0 },
+		"max transaction size": func(cfg *ChainConfig) { cfg.MaxTxSize = 0 },
+		"dust threshold":       func(cfg *ChainConfig) { cfg.DustThreshold = -1 },
+	} {
+		cfg := RegtestConfig
+		change(&cfg)
+		bc, err := CreateBlockchainForNode(to, "invalid", cfg)
+		if err == nil {
+			_ = bc.Close()
+			t.Errorf("%s: created a chain with %+v", name, cfg)
+		} else if !strings.Contains(err.Error(), name) {
+			t.Errorf("%s: error %q does not name the parameter", name, err)
+		}
+	}
+}
+
+func TestRegtestMinesHundredBlocksInASecond(t *testing.T) {
+	bc, _ := newTestChain(t)
+	to := string(wallet.NewWallet().GetAddress())
+	start := time.Now()
+	for height := 2; height <= 101; height++ {
+		if _, err := bc.AddBlock([]*Transaction{bc.config.CoinbaseTx(to, "", height)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Second && !raceEnabled {
+		t.Fatalf("mining 100 regtest blocks took %v", elapsed)
+	}
+	if got := bc.BestHeight(); got != 101 {
+		t.Fatalf("height %d after mining 100 blocks, want 101", got)
+	}
+	for height := 2; height <= 101; height += 33 {
+		hash, err := bc.GetBlockHash(height)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bits := mustBlock(t, bc, hash).Bits(); bits != RegtestConfig.TargetBits {
+			t.Fatalf("block %d stores %d target bits, want %d", height, bits, RegtestConfig.TargetBits)
+		}
+	}
+}
+
+func BenchmarkRegtestAddBlock(b *testing.B) {
+	dir := b.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		b.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(wd) }()
+	to := string(wallet.NewWallet().GetAddress())
+	bc, err := CreateBlockchainForNode(to, "bench", RegtestConfig)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer func() { _ = bc.Close() }()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := bc.AddBlock([]*Transaction{bc.config.CoinbaseTx(to, "", bc.BestHeight()+1)}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// ErrCheckpointMismatch is returned for a block whose hash differs from the chain's
+// checkpoint at its height.
+var ErrCheckpointMismatch = errors.New("block conflicts with checkpoint")
+
+// checkCheckpoint rejects hash as the block at height if a checkpoint pins another block there.
+func (bc *Blockchain) checkCheckpoint(height int, hash []byte) error {
+	want, ok := bc.config.Checkpoints[height]
+	if !ok || bytes.Equal(want, hash) {
+		return nil
+	}
+	return fmt.Errorf("%w: height %d is %x, checkpoint is %x", ErrCheckpointMismatch, height, hash, want)
+}
+
+// lastCheckpoint returns the highest checkpoint height at or below height, or 0 if none.
+func (bc *Blockchain) lastCheckpoint(height int) int {
+	last := 0
+	for h := range bc.config.Checkpoints {
+		if h <= height && h > last {
+			last = h
+		}
+	}
+	return last
+}
+
+// branchHeight returns the height a block built on prevHash would have, whether or not
+// prevHash is on the main chain.
+func (bc *Blockchain) branchHeight(prevHash []byte) (int, error) {
+	if len(prevHash) == 0 {
+		return 1, nil
+	}
+	height, err := bc.GetBlockHeight(prevHash)
+	return height + 1, err
+}
+
+// VerifyCheckpoints checks the stored main chain against the chain's checkpoints, reporting
+// the first block that conflicts. Checkpoints above the tip are not checked.
+func (bc *Blockchain) VerifyCheckpoints() error {
+	if len(bc.config.Checkpoints) == 0 {
+		return nil
+	}
+	for i, hash := range bc.GetBlockHashes() {
+		if err := bc.checkCheckpoint(i+1, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestBlockConflictingWithCheckpointRejected(t *testing.T) {
+	bc, _ := newTestChain(t)
+	genesis := mustBlock(t, bc, bc.Tip())
+	pinned := mineOn(t, bc, genesis, 2)
+	bc.config.Checkpoints = map[int][]byte{2: pinned.Hash}
+
+	// Each mined block pays a new address, so this one differs from the pinned block.
+	other := mineOn(t, bc, genesis, 2)
+	if err := bc.PutBlock(other.Serialize()); !errors.Is(err, ErrCheckpointMismatch) {
+		t.Fatalf("other block at the checkpoint height: got %v, want ErrCheckpointMismatch", err)
+	}
+	if bc.HasBlock(other.Hash) {
+		t.Fatal("block conflicting with the checkpoint was stored")
+	}
+	putAll(t, bc, pinned)
+	if !bytes.Equal(bc.Tip(), pinned.Hash) {
+		t.Fatalf("tip %x, want the checkpointed block %x", bc.Tip(), pinned.Hash)
+	}
+}
+
+func TestReorgBelowCheckpointRefused(t *testing.T) {
+	bc, _ := newTestChain(t)
+	genesis := mustBlock(t, bc, bc.Tip())
+	m2 := mineOn(t, bc, genesis, 2)
+	m3 := mineOn(t, bc, m2, 3)
+	putAll(t, bc, m2, m3)
+	// A side branch stored before the checkpoint was known.
+	f2 := mineOn(t, bc, genesis, 2)
+	putAll(t, bc, f2)
+	bc.config.Checkpoints = map[int][]byte{2: m2.Hash}
+
+	// f3 and f4 pass the checkpoint, having none at their heights, but f4 would make the
+	// branch forking at genesis the longest.
+	f3 := mineOn(t, bc, f2, 3)
+	f4 := mineOn(t, bc, f3, 4)
+	var err error
+	for _, b := range []*Block{f3, f4} {
+		if err = bc.PutBlock(b.Serialize()); err != nil {
+			break
+		}
+	}
+	if !errors.Is(err, ErrCheckpointMismatch) {
+		t.Fatalf("reorg forking below the checkpoint: got %v, want ErrCheckpointMismatch", err)
+	}
+	if !bytes.Equal(bc.Tip(), m3.Hash) {
+		t.Fatalf("tip moved to %x, want %x", bc.Tip(), m3.Hash)
+	}
+	if err := bc.VerifyCheckpoints(); err != nil {
+		t.Fatalf("main chain after the refused reorg: %v", err)
+	}
+}
@@ -0,0 +1,178 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+)
+
+// CoinSelection picks which of a wallet's unspent outputs fund a new transaction.
+type CoinSelection string
+
+const (
+	// SelectFirstFit takes outputs in chainstate order until the amount is covered.
+	SelectFirstFit CoinSelection = ""
+	// SelectLargestFirst spends the fewest, largest outputs.
+	SelectLargestFirst CoinSelection = "largest"
+	// SelectSmallestFirst spends dust first, consolidating the wallet.
+	SelectSmallestFirst CoinSelection = "smallest"
+	// SelectBranchAndBound looks for outputs worth exactly the amount, so the
+	// transaction needs no change output, and falls back to SelectLargestFirst.
+	SelectBranchAndBound CoinSelection = "bnb"
+)
+
+// bnbMaxTries bounds the branch-and-bound search so large wallets fall back quickly.
+const bnbMaxTries = 100000
+
+// ParseCoinSelection accepts "" (first fit), "largest", "smallest" or "bnb".
+func ParseCoinSelection(s string) (CoinSelection, error) {
+	switch cs := CoinSelection(s); cs {
+	case SelectFirstFit, SelectLargestFirst, SelectSmallestFirst, SelectBranchAndBound:
+		return cs, nil
+	}
+	return "", fmt.Errorf("unknown coin selection %q (want largest, smallest or bnb)", s)
+}
+
+// coin is one spendable output.
+type coin struct {
+	txID  string
+	vout  int
+	value int
+}
+
+// selectCoins returns the coins that fund amount under strategy, or all of them
+// if they are not enough.
+func selectCoins(coins []coin, amount int, strategy CoinSelection) []coin {
+	switch strategy {
+	case SelectLargestFirst:
+		return firstFit(sortCoins(coins, true), amount)
+	case SelectSmallestFirst:
+		return firstFit(sortCoins(coins, false), amount)
+	case SelectBranchAndBound:
+		sorted := sortCoins(coins, true)
+		if exact := branchAndBound(sorted, amount); exact != nil {
+			return exact
+		}
+		return firstFit(sorted, amount)
+	}
+	return firstFit(coins, amount)
+}
+
+// sortCoins returns coins ordered by value, ties broken by outpoint so the choice is
+// the same on every run.
+func sortCoins(coins []coin, descending bool) []coin {
+	sorted := append([]coin(nil), coins...)
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.value != b.value {
+			return (a.value > b.value) == descending
+		}
+		if a.txID != b.txID {
+			return a.txID < b.txID
+		}
+		return a.vout < b.vout
+	})
+	return sorted
+}
+
+func firstFit(coins []coin, amount int) []coin {
+	accumulated := 0
+	for i, c := range coins {
+		accumulated += c.value
+		if accumulated >= amount {
+			return coins[:i+1]
+		}
+	}
+	return coins
+}
+
+// branchAndBound searches coins (largest first) depth-first for a subset worth exactly
+// amount, preferring to include larger coins. It returns nil if there is none or the
+// search runs out of tries.
+func branchAndBound(coins []coin, amount int) []coin {
+	// remaining[i] is the value of coins[i:], for pruning branches that cannot reach amount.
+	remaining := make([]int, len(coins)+1)
+	for i := len(coins) - 1; i >= 0; i-- {
+		remaining[i] = remaining[i+1] + coins[i].value
+	}
+
+	var picked []coin
+	tries := 0
+	var search func(i, sum int) bool
+	search = func(i, sum int) bool {
+		if sum == amount {
+			return true
+		}
+		tries++
+		if i == len(coins) || sum > amount || sum+remaining[i] < amount || tries > bnbMaxTries {
+			return false
+		}
+		picked = append(picked, coins[i])
+		if search(i+1, sum+coins[i].value) {
+			return true
+		}
+		picked = picked[:len(picked)-1]
+		return search(i+1, sum)
+	}
+	if !search(0, 0) {
+		return nil
+	}
+	return picked
+}
+
+// spendableCoins lists the outputs locked to pubKeyHash that can be spent in the next
+// block, in chainstate order, skipping those listed in exclude.
+func (u UTXOSet) spendableCoins(pubKeyHash []byte, exclude map[string][]int) []coin {
+	var coins []coin
+	spendHeight := u.Blockchain.BestHeight() + 1
+	u.forEachOrdered(func(txID string, outs TxOutputs) {
+		if !u.Blockchain.config.outputsMature(outs, spendHeight) {
+			return
+		}
+		for _, idx := range outs.sortedIndexes() {
+			if containsInt(exclude[txID], idx) {
+				continue
+			}
+			if out := outs.Outputs[idx]; out.IsLockedWithKey(pubKeyHash) {
+				coins = append(coins, coin{txID: txID, vout: idx, value: out.Value})
+			}
+		}
+	})
+	return coins
+}
+
+// selectSpendableOutputs is findSpendableOutputs choosing the outputs with strategy. Only
+// when the confirmed outputs fall short does it also draw on unconfirmed, outputs of
+// pending transactions: first fit after all the confirmed ones, the other strategies
+// choosing among both.
+func (u UTXOSet) selectSpendableOutputs(pubKeyHash []byte, amount int, exclude map[string][]int, unconfirmed []coin, strategy CoinSelection) (int, map[string][]int) {
+	if strategy == SelectFirstFit {
+		accumulated, unspentOutputs := u.findSpendableOutputs(pubKeyHash, amount, exclude)
+		for _, c := range unconfirmed {
+			if accumulated >= amount {
+				break
+			}
+			accumulated += c.value
+			unspentOutputs[c.txID] = append(unspentOutputs[c.txID], c.vout)
+		}
+		return accumulated, unspentOutputs
+	}
+	coins := u.spendableCoins(pubKeyHash, exclude)
+	if len(unconfirmed) > 0 && sumCoins(coins) < amount {
+		coins = append(coins, unconfirmed...)
+	}
+	accumulated := 0
+	unspentOutputs := make(map[string][]int)
+	for _, c := range selectCoins(coins, amount, strategy) {
+		accumulated += c.value
+		unspentOutputs[c.txID] = append(unspentOutputs[c.txID], c.vout)
+	}
+	return accumulated, unspentOutputs
+}
+
+func sumCoins(coins []coin) int {
+	total := 0
+	for _, c := range coins {
+		total += c.value
+	}
+	return total
+}
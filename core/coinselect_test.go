@@ -0,0 +1,45 @@
+package core
+
+import "testing"
+
+func TestCoinSelectionInputCounts(t *testing.T) {
+	var coins []coin
+	for i, value := range []int{1, 7, 2, 9, 3, 4} {
+		coins = append(coins, coin{txID: "tx", vout: i, value: value})
+	}
+	tests := []struct {
+		strategy CoinSelection
+		amount   int
+		inputs   int
+		change   int
+	}{
+		{SelectFirstFit, 10, 3, 0},
+		{SelectLargestFirst, 10, 2, 6},
+		{SelectSmallestFirst, 10, 4, 0},
+		{SelectBranchAndBound, 10, 2, 0},
+		{SelectFirstFit, 12, 4, 7},
+		{SelectLargestFirst, 12, 2, 4},
+		{SelectSmallestFirst, 12, 5, 5},
+		{SelectBranchAndBound, 12, 2, 0},
+	}
+	for _, tt := range tests {
+		picked := selectCoins(coins, tt.amount, tt.strategy)
+		total := 0
+		for _, c := range picked {
+			total += c.value
+		}
+		if len(picked) != tt.inputs || total-tt.amount != tt.change {
+			t.Errorf("strategy %q for %d: %d inputs with change %d, want %d with change %d",
+				tt.strategy, tt.amount, len(picked), total-tt.amount, tt.inputs, tt.change)
+		}
+	}
+}
+
+func TestCoinSelectionFallsShortWithAllCoins(t *testing.T) {
+	coins := []coin{{txID: "a", value: 2}, {txID: "b", value: 3}}
+	for _, strategy := range []CoinSelection{SelectFirstFit, SelectLargestFirst, SelectSmallestFirst, SelectBranchAndBound} {
+		if picked := selectCoins(coins, 6, strategy); len(picked) != 2 {
+			t.Errorf("strategy %q picked %d coins for an unfundable amount, want all 2", strategy, len(picked))
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MaxDataSize is the largest payload a data output may carry.
+const MaxDataSize = 80
+
+var ErrBadDataOutput = errors.New("invalid data output")
+
+// NewDataOutput returns a provably unspendable output embedding data in the chain.
+// It has no value and no lock, so it never enters the UTXO set.
+func NewDataOutput(data []byte) (*TxOutput, error) {
+	out := &TxOutput{ScriptType: ScriptData, Data: append([]byte(nil), data...)}
+	if err := checkDataOutput(*out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// IsData reports whether out is a data output.
+func (out *TxOutput) IsData() bool {
+	return out.ScriptType == ScriptData
+}
+
+// checkDataOutput accepts data outputs of zero value and at most MaxDataSize bytes, and
+// any other output that carries no data.
+func checkDataOutput(out TxOutput) error {
+	if !out.IsData() {
+		if len(out.Data) > 0 {
+			return fmt.Errorf("%w: data on a spendable output", ErrBadDataOutput)
+		}
+		return nil
+	}
+	switch {
+	case out.Value != 0:
+		return fmt.Errorf("%w: value %d, want 0", ErrBadDataOutput, out.Value)
+	case len(out.PubKeyHash) > 0:
+		return fmt.Errorf("%w: data outputs cannot be locked", ErrBadDataOutput)
+	case len(out.Data) == 0 || len(out.Data) > MaxDataSize:
+		return fmt.Errorf("%w: %d bytes, want 1 to %d", ErrBadDataOutput, len(out.Data), MaxDataSize)
+	}
+	return nil
+}
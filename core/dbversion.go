@@ -0,0 +1,79 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"go.etcd.io/bbolt"
+)
+
+// dbVersion is the format of the chain DB, stored under versionKey in configBucket.
+// Version 1 is the first with canonical transaction IDs (see Transaction.hashData). The
+// blocks of older DBs commit to IDs hashed from gob output, so they cannot be reindexed
+// into the new format: the chain has to be created or synced again.
+const (
+	dbVersion  = 1
+	versionKey = "version"
+)
+
+// ErrDBFormat is returned when opening a DB this build cannot read.
+var ErrDBFormat = errors.New("unsupported blockchain database format")
+
+func putDBVersion(tx *bbolt.Tx) error {
+	b, err := tx.CreateBucketIfNotExists([]byte(configBucket))
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(versionKey), binary.BigEndian.AppendUint32(nil, dbVersion))
+}
+
+// checkDBVersion fails with ErrDBFormat unless the DB of nodeID, whose stored tip is tip,
+// has this build's format. A DB without a version is accepted when its tip block's
+// transactions hash to their stored IDs, and writable ones are then stamped with it.
+func checkDBVersion(db *bbolt.DB, nodeID string, tip []byte, writable bool) error {
+	version, stamped := 0, false
+	var tipBlock *Block
+	err := db.View(func(tx *bbolt.Tx) error {
+		if b := tx.Bucket([]byte(configBucket)); b != nil {
+			if v := b.Get([]byte(versionKey)); v != nil {
+				stamped = true
+				if len(v) == 4 {
+					version = int(binary.BigEndian.Uint32(v))
+				}
+				return nil
+			}
+		}
+		if len(tip) == 0 {
+			return nil
+		}
+		data := tx.Bucket([]byte(blocksBucket)).Get(tip)
+		if data == nil {
+			return fmt.Errorf("tip block %x is missing", tip)
+		}
+		var err error
+		tipBlock, err = DecodeBlock(data)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("checking %s: %w", nodeDBFile(nodeID), err)
+	}
+	if stamped {
+		if version != dbVersion {
+			return fmt.Errorf("%s: %w: version %d, this build reads version %d", nodeDBFile(nodeID), ErrDBFormat, version, dbVersion)
+		}
+		return nil
+	}
+	if tipBlock != nil {
+		for _, tx := range tipBlock.Transactions {
+			if !bytes.Equal(tx.ID, tx.Hash()) {
+				return fmt.Errorf("%s: %w: transaction IDs were computed by an older version; delete the file and create the chain again, or let the node sync it from upgraded peers", nodeDBFile(nodeID), ErrDBFormat)
+			}
+		}
+	}
+	if !writable {
+		return nil
+	}
+	return db.Update(putDBVersion)
+}
@@ -0,0 +1,80 @@
+package core
+
+import (
+	"errors"
+	"testing"
+
+	"go.etcd.io/bbolt"
+)
+
+// rewriteDB reopens the closed chain DB of node "test" and applies fn to it.
+func rewriteDB(t *testing.T, fn func(tx *bbolt.Tx) error) {
+	t.Helper()
+	db, err := openDB("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+	if err := db.Update(fn); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func deleteDBVersion(tx *bbolt.Tx) error {
+	return tx.Bucket([]byte(configBucket)).Delete([]byte(versionKey))
+}
+
+func TestOpenStampsUnversionedDB(t *testing.T) {
+	bc, _ := newTestChain(t)
+	_ = bc.Close()
+	rewriteDB(t, deleteDBVersion)
+
+	reopened, err := OpenBlockchainForNode("test")
+	if err != nil {
+		t.Fatalf("unversioned DB with current transaction IDs: %v", err)
+	}
+	_ = reopened.Close()
+	rewriteDB(t, func(tx *bbolt.Tx) error {
+		if tx.Bucket([]byte(configBucket)).Get([]byte(versionKey)) == nil {
+			t.Error("opening did not stamp the DB version")
+		}
+		return nil
+	})
+}
+
+func TestOpenRejectsOldTransactionIDs(t *testing.T) {
+	bc, _ := newTestChain(t)
+	tip := mustBlock(t, bc, bc.Tip())
+	_ = bc.Close()
+
+	// An older build hashed transactions differently: their stored IDs no longer match.
+	tip.Transactions[0].ID = []byte("id from the old gob-based hash")
+	rewriteDB(t, func(tx *bbolt.Tx) error {
+		if err := deleteDBVersion(tx); err != nil {
+			return err
+		}
+		return tx.Bucket([]byte(blocksBucket)).Put(tip.Hash, tip.Serialize())
+	})
+
+	if _, err := OpenBlockchainForNode("test"); !errors.Is(err, ErrDBFormat) {
+		t.Fatalf("open: got %v, want ErrDBFormat", err)
+	}
+	if _, err := OpenBlockchainReadOnlyForNode("test"); !errors.Is(err, ErrDBFormat) {
+		t.Fatalf("read-only open: got %v, want ErrDBFormat", err)
+	}
+	if _, err := InitBlockchainForNode("test", RegtestConfig); !errors.Is(err, ErrDBFormat) {
+		t.Fatalf("init: got %v, want ErrDBFormat", err)
+	}
+}
+
+func TestOpenRejectsNewerDBVersion(t *testing.T) {
+	bc, _ := newTestChain(t)
+	_ = bc.Close()
+	rewriteDB(t, func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(configBucket)).Put([]byte(versionKey), []byte{0, 0, 0, dbVersion + 1})
+	})
+
+	if _, err := OpenBlockchainForNode("test"); !errors.Is(err, ErrDBFormat) {
+		t.Fatalf("open: got %v, want ErrDBFormat", err)
+	}
+}
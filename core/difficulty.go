@@ -0,0 +1,94 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+const (
+	// targetBlockTime is the desired number of seconds between blocks.
+	targetBlockTime = 10
+	// retargetInterval is how many blocks share a difficulty before it is recomputed.
+	retargetInterval = 10
+	// maxAdjustFactor bounds a single retarget to between 1/4 and 4 times the work.
+	maxAdjustFactor = 4
+
+	minTargetBits = 8
+	maxTargetBits = 32
+)
+
+// NextTargetBits returns the difficulty the next block on top of the current tip must meet.
+// Every retargetInterval blocks it compares how long the last window took against
+// retargetInterval*targetBlockTime; in between, and on chains configured with
+// NoRetargeting, blocks inherit the tip's difficulty.
+func (bc *Blockchain) NextTargetBits() int {
+	if len(bc.Tip()) == 0 {
+		return bc.config.TargetBits
+	}
+
+	// Collect the last retargetInterval+1 blocks (tip first).
+	var window []*Block
+	it := bc.Iterator()
+	for len(window) <= retargetInterval {
+		block := it.Next()
+		if block == nil {
+			break
+		}
+		window = append(window, block)
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+	tipBits := window[0].Bits()
+
+	nextHeight := bc.BestHeight()
+	if bc.config.NoRetargeting || nextHeight%retargetInterval != 0 || len(window) <= retargetInterval {
+		return tipBits
+	}
+	oldest := window[len(window)-1]
+	// The genesis timestamp is fixed at 0, so it cannot anchor a timespan.
+	if len(oldest.PrevBlockHash) == 0 {
+		return tipBits
+	}
+
+	return retarget(tipBits, window[0].Timestamp-oldest.Timestamp)
+}
+
+// ErrBadTargetBits is returned for a block whose difficulty is not the one NextTargetBits
+// requires after its parent.
+var ErrBadTargetBits = errors.New("block difficulty does not match the expected target")
+
+// checkTargetBits requires block to meet the difficulty NextTargetBits gives after its
+// parent, which must be stored. A genesis block sets its chain's difficulty.
+func (bc *Blockchain) checkTargetBits(block *Block) error {
+	if len(block.PrevBlockHash) == 0 {
+		return nil
+	}
+	if want := bc.at(block.PrevBlockHash).NextTargetBits(); block.Bits() != want {
+		return fmt.Errorf("%w: %d bits, want %d", ErrBadTargetBits, block.Bits(), want)
+	}
+	return nil
+}
+
+// retarget adjusts bits by the ratio of expected to actual window duration, clamped to
+// maxAdjustFactor either way. Each doubling of the ratio is one more leading zero bit.
+func retarget(bits int, actualTimespan int64) int {
+	expected := float64(retargetInterval * targetBlockTime)
+	if actualTimespan < 1 {
+		actualTimespan = 1
+	}
+
+	ratio := expected / float64(actualTimespan)
+	ratio = math.Max(ratio, 1.0/maxAdjustFactor)
+	ratio = math.Min(ratio, maxAdjustFactor)
+
+	bits += int(math.Round(math.Log2(ratio)))
+	if bits < minTargetBits {
+		bits = minTargetBits
+	}
+	if bits > maxTargetBits {
+		bits = maxTargetBits
+	}
+	return bits
+}
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"my-blockchain/wallet"
+)
+
+func TestBlockWithWrongTargetBitsRejected(t *testing.T) {
+	cfg := RegtestConfig
+	cfg.TargetBits = 16
+	bc, _ := newTestChainWith(t, cfg)
+	genesis := mustBlock(t, bc, bc.Tip())
+
+	easy := mineBits(t, bc, genesis, 2, 1)
+	if err := bc.PutBlock(easy.Serialize()); !errors.Is(err, ErrBadTargetBits) {
+		t.Fatalf("1-bit block on a 16-bit chain: got %v, want ErrBadTargetBits", err)
+	}
+	if bc.HasBlock(easy.Hash) {
+		t.Fatal("block with the wrong difficulty was stored")
+	}
+
+	block := mineOn(t, bc, genesis, 2)
+	if err := bc.PutBlock(block.Serialize()); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(bc.Tip(), block.Hash) {
+		t.Fatalf("tip is %x, want %x", bc.Tip(), block.Hash)
+	}
+}
+
+// mineWindow stores blocks 2 to 20 on bc's genesis, spacing seconds apart, at the chain's
+// difficulty; 20 is the first height whose successor is retargeted.
+func mineWindow(t *testing.T, bc *Blockchain, spacing int64) {
+	t.Helper()
+	parent := mustBlock(t, bc, bc.Tip())
+	for height := 2; height <= 20; height++ {
+		block := newBlockTemplate([]*Transaction{bc.config.CoinbaseTx(string(wallet.NewWallet().GetAddress()), "", height)}, parent.Hash, bc.NextTargetBits())
+		block.Timestamp = parent.Timestamp + spacing
+		block.Nonce, block.Hash = NewProofOfWork(block).Run()
+		putAll(t, bc, block)
+		parent = block
+	}
+}
+
+func TestRetargetFollowsBlockRate(t *testing.T) {
+	cfg := RegtestConfig
+	cfg.TargetBits = 10
+	cfg.NoRetargeting = false
+	// Ten blocks should take 100 seconds; one adjustment is clamped to a factor of 4.
+	for _, tc := range []struct {
+		name    string
+		spacing int64
+		want    int
+	}{
+		{"on target", 10, 10},
+		{"twice as fast", 5, 11},
+		{"ten times as fast, clamped", 1, 12},
+		{"twice as slow", 20, 9},
+		{"ten times as slow, clamped", 100, 8},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			bc, _ := newTestChainWith(t, cfg)
+			mineWindow(t, bc, tc.spacing)
+			for height := 2; height <= 20; height++ {
+				hash, err := bc.GetBlockHash(height)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if bits := mustBlock(t, bc, hash).Bits(); bits != cfg.TargetBits {
+					t.Fatalf("block %d has %d bits before the first retarget, want %d", height, bits, cfg.TargetBits)
+				}
+			}
+			if got := bc.NextTargetBits(); got != tc.want {
+				t.Fatalf("next target bits %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRetargetStaysWithinBounds(t *testing.T) {
+	if got := retarget(maxTargetBits, 1); got != maxTargetBits {
+		t.Errorf("fast blocks at the hardest difficulty: %d bits, want %d", got, maxTargetBits)
+	}
+	if got := retarget(minTargetBits, 1<<20); got != minTargetBits {
+		t.Errorf("slow blocks at the easiest difficulty: %d bits, want %d", got, minTargetBits)
+	}
+	if got := retarget(20, 0); got != 22 {
+		t.Errorf("a window of no time: %d bits, want the clamped 22", got)
+	}
+}
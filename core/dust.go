@@ -0,0 +1,33 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DefaultDustThreshold is the built-in profiles' dust threshold, the smallest value a
+// spendable output may hold (see ChainConfig.DustThreshold). Smaller outputs cost more to
+// spend than they are worth and only bloat the UTXO set. Data outputs and coinbases are
+// exempt. Amounts are whole coins and the subsidy starts at 10, so the default makes
+// outputs of 1 and 2 dust.
+const DefaultDustThreshold = 3
+
+var ErrDustOutput = errors.New("output below dust threshold")
+
+// IsDust reports whether out is a spendable output worth less than threshold.
+func (out *TxOutput) IsDust(threshold int) bool {
+	return !out.IsData() && out.Value < threshold
+}
+
+// checkDust rejects a transaction with a dust output, unless it is a coinbase.
+func (cfg ChainConfig) checkDust(tx *Transaction) error {
+	if tx.IsCoinbase() {
+		return nil
+	}
+	for i, out := range tx.Vout {
+		if out.IsDust(cfg.DustThreshold) {
+			return fmt.Errorf("%w: %x output %d is worth %d, threshold %d", ErrDustOutput, tx.ID, i, out.Value, cfg.DustThreshold)
+		}
+	}
+	return nil
+}
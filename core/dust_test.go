@@ -0,0 +1,98 @@
+package core
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"my-blockchain/wallet"
+)
+
+func TestSubDustPaymentRejected(t *testing.T) {
+	dir := chdirTemp(t)
+	ws, err := wallet.NewWalletsAt(filepath.Join(dir, "wallets.dat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	from, err := ws.CreateWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc, err := CreateBlockchainForNode(from, "test", RegtestConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = bc.Close() })
+	to := string(wallet.NewWallet().GetAddress())
+	threshold := bc.Config().DustThreshold
+
+	_, err = NewUTXOTransaction(from, to, threshold-1, bc, ws)
+	if !errors.Is(err, ErrDustOutput) {
+		t.Fatalf("paying %d: got %v, want ErrDustOutput", threshold-1, err)
+	}
+	if !strings.Contains(err.Error(), "threshold 3") {
+		t.Fatalf("error %q does not name the threshold", err)
+	}
+	if _, err := NewUTXOTransaction(from, to, threshold, bc, ws); err != nil {
+		t.Fatalf("paying exactly the threshold: %v", err)
+	}
+}
+
+func TestSubDustOutputRejectedByMempoolAndBlocks(t *testing.T) {
+	bc, w := newTestChain(t)
+	genesis := mustBlock(t, bc, bc.Tip())
+	dust := spend(t, bc, w, genesis.Transactions[0], 0, bc.config.DustThreshold-1)
+
+	if _, err := bc.CheckPendingTx(dust, NewMempool()); !errors.Is(err, ErrDustOutput) {
+		t.Fatalf("mempool: got %v, want ErrDustOutput", err)
+	}
+	block := mineOn(t, bc, genesis, 2, dust)
+	if err := bc.PutBlock(block.Serialize()); !errors.Is(err, ErrDustOutput) {
+		t.Fatalf("block: got %v, want ErrDustOutput", err)
+	}
+	if bc.HasBlock(block.Hash) {
+		t.Fatal("block with a dust output was stored")
+	}
+
+	ok := spend(t, bc, w, genesis.Transactions[0], 0, bc.config.DustThreshold)
+	if _, err := bc.CheckPendingTx(ok, NewMempool()); err != nil {
+		t.Fatalf("output at the threshold: %v", err)
+	}
+}
+
+func TestNoDustThresholdStillRejectsNegativeOutputs(t *testing.T) {
+	cfg := RegtestConfig
+	cfg.DustThreshold = 0
+	bc, w := newTestChainWith(t, cfg)
+	genesis := mustBlock(t, bc, bc.Tip())
+	to := string(wallet.NewWallet().GetAddress())
+
+	// Outputs of 20 and -10 sum to the 10 spent, creating 10 from nothing.
+	tx := &Transaction{
+		Vin:  []TxInput{{Txid: genesis.Transactions[0].ID, Vout: 0, PubKey: w.PubKey()}},
+		Vout: []TxOutput{*NewTxOutput(20, to), *NewTxOutput(-10, string(w.GetAddress()))},
+	}
+	tx.ID = tx.Hash()
+	if err := bc.SignTransaction(tx, w.PrivateECDSA()); err != nil {
+		t.Fatal(err)
+	}
+	tx.ID = tx.Hash()
+
+	if _, err := bc.CheckPendingTx(tx, NewMempool()); !errors.Is(err, ErrBadOutputValue) {
+		t.Fatalf("mempool: got %v, want ErrBadOutputValue", err)
+	}
+	block := mineOn(t, bc, genesis, 2, tx)
+	if err := bc.PutBlock(block.Serialize()); !errors.Is(err, ErrBadOutputValue) {
+		t.Fatalf("block: got %v, want ErrBadOutputValue", err)
+	}
+	if balance(bc, to) != 0 {
+		t.Fatalf("the inflated output was connected")
+	}
+
+	// Small outputs are fine without a threshold.
+	small := spend(t, bc, w, genesis.Transactions[0], 0, 1)
+	if _, err := bc.CheckPendingTx(small, NewMempool()); err != nil {
+		t.Fatalf("output of 1 with no dust threshold: %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package core
+
+import "sync"
+
+// Event types published on a chain's and a mempool's EventBus.
+const (
+	// EventBlock is published when a block becomes the new tip, with its hash and height.
+	EventBlock = "block"
+	// EventTx is published when a transaction enters a mempool, with its ID.
+	EventTx = "tx"
+	// EventSync is published as a node catching up stores blocks from peers, with its tip,
+	// height and the best height peers announced as Target.
+	EventSync = "sync"
+)
+
+// eventBuffer is how many undelivered events a subscriber may fall behind by before
+// further events to it are dropped.
+const eventBuffer = 64
+
+type Event struct {
+	Type   string
+	Hash   []byte
+	Height int
+	// Target is set on EventSync only.
+	Target int
+}
+
+// EventBus fans published events out to subscribers. Publishing never blocks: a
+// subscriber that does not keep up misses events instead of stalling the node.
+type EventBus struct {
+	mu     sync.Mutex
+	subs   map[int]*subscription
+	nextID int
+}
+
+type subscription struct {
+	ch    chan Event
+	types map[string]bool
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{subs: make(map[int]*subscription)}
+}
+
+// Subscribe returns a channel receiving events of the given types (all types if none are
+// given) and a function that cancels the subscription and closes the channel.
+func (eb *EventBus) Subscribe(types ...string) (<-chan Event, func()) {
+	sub := &subscription{ch: make(chan Event, eventBuffer), types: make(map[string]bool)}
+	for _, t := range types {
+		sub.types[t] = true
+	}
+
+	eb.mu.Lock()
+	id := eb.nextID
+	eb.nextID++
+	eb.subs[id] = sub
+	eb.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			eb.mu.Lock()
+			delete(eb.subs, id)
+			eb.mu.Unlock()
+			close(sub.ch)
+		})
+	}
+	return sub.ch, cancel
+}
+
+// Publish sends e to the subscribers for its type. Publishing on a nil bus does nothing.
+func (eb *EventBus) Publish(e Event) {
+	if eb == nil {
+		return
+	}
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+
+	for _, sub := range eb.subs {
+		if len(sub.types) > 0 && !sub.types[e.Type] {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+		}
+	}
+}
+
+// Events returns the bus bc publishes new tips to. Each Blockchain opened has its own
+// until SetEvents shares one.
+func (bc *Blockchain) Events() *EventBus {
+	return bc.events
+}
+
+// SetEvents makes bc publish to eb, e.g. the bus its node's mempool publishes to.
+func (bc *Blockchain) SetEvents(eb *EventBus) {
+	bc.events = eb
+}
+
+// SetEvents makes mp publish the transactions it accepts to eb. A new mempool publishes
+// nowhere.
+func (mp *Mempool) SetEvents(eb *EventBus) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	mp.events = eb
+}
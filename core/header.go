@@ -0,0 +1,103 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// BlockHeader is a block without its transactions: enough to check proof of work and
+// chain linkage before downloading bodies.
+type BlockHeader struct {
+	Timestamp     int64
+	PrevBlockHash []byte
+	Hash          []byte
+	Nonce         int
+	MerkleRoot    []byte
+	TargetBits    int
+}
+
+func (b *Block) Header() BlockHeader {
+	return BlockHeader{
+		Timestamp:     b.Timestamp,
+		PrevBlockHash: append([]byte(nil), b.PrevBlockHash...),
+		Hash:          append([]byte(nil), b.Hash...),
+		Nonce:         b.Nonce,
+		MerkleRoot:    append([]byte(nil), b.MerkleRoot...),
+		TargetBits:    b.TargetBits,
+	}
+}
+
+// Validate checks the header's target bits range and its proof of work, failing with
+// ErrTargetBitsRange or ErrBadProofOfWork. The PoW hash covers every header field, so this
+// also proves the fields were not altered.
+func (h BlockHeader) Validate() error {
+	block := &Block{
+		Timestamp:     h.Timestamp,
+		PrevBlockHash: h.PrevBlockHash,
+		Hash:          h.Hash,
+		Nonce:         h.Nonce,
+		MerkleRoot:    h.MerkleRoot,
+		TargetBits:    h.TargetBits,
+	}
+	if err := checkTargetBitsRange(block.Bits()); err != nil {
+		return err
+	}
+	if !NewProofOfWork(block).Validate() {
+		return ErrBadProofOfWork
+	}
+	return nil
+}
+
+// Headers returns the headers of the main chain in chain order (genesis -> tip).
+func (bc *Blockchain) Headers() []BlockHeader {
+	return bc.HeadersAfter(0)
+}
+
+// HeadersAfter returns the headers of the main-chain blocks above height, in chain order.
+// HeadersAfter(0) is the whole chain.
+func (bc *Blockchain) HeadersAfter(height int) []BlockHeader {
+	if len(bc.Tip()) == 0 {
+		return nil
+	}
+	var headers []BlockHeader
+	it := bc.Iterator()
+	for count := bc.BestHeight() - height; count > 0; count-- {
+		block := it.Next()
+		if block == nil {
+			break
+		}
+		headers = append(headers, block.Header())
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	// reverse (currently tip -> genesis)
+	for i, j := 0, len(headers)-1; i < j; i, j = i+1, j-1 {
+		headers[i], headers[j] = headers[j], headers[i]
+	}
+	return headers
+}
+
+// CheckHeaderChain verifies that headers each carry valid proof of work and link to the
+// header before them, the first to parent: a genesis header when parent is nil.
+func CheckHeaderChain(headers []BlockHeader, parent []byte) error {
+	for i, h := range headers {
+		if err := h.Validate(); err != nil {
+			return fmt.Errorf("header %d (%x): %w", i, h.Hash, err)
+		}
+		if i == 0 {
+			if len(parent) == 0 && len(h.PrevBlockHash) != 0 {
+				return fmt.Errorf("header chain does not start at genesis")
+			}
+			if !bytes.Equal(h.PrevBlockHash, parent) {
+				return fmt.Errorf("header chain starts at %x, not after %x", h.PrevBlockHash, parent)
+			}
+			continue
+		}
+		if !bytes.Equal(h.PrevBlockHash, headers[i-1].Hash) {
+			return fmt.Errorf("header %d (%x) does not link to %x", i, h.Hash, headers[i-1].Hash)
+		}
+	}
+	return nil
+}
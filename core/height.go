@@ -0,0 +1,146 @@
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"go.etcd.io/bbolt"
+)
+
+// blockHeightBucket maps the hash of every stored block, on any branch, to its height
+// (4 bytes, big-endian), so heights need no walk back to genesis.
+const blockHeightBucket = "blockheight"
+
+// ErrBlockNotFound is returned for a hash the DB holds no block for.
+var ErrBlockNotFound = errors.New("block not found")
+
+// putBlockHeight records the height of a block being stored, one above its parent's.
+// It runs in the write transaction that stores the block, after the parent's.
+func putBlockHeight(tx *bbolt.Tx, block *Block) error {
+	b, err := tx.CreateBucketIfNotExists([]byte(blockHeightBucket))
+	if err != nil {
+		return err
+	}
+	height := 1
+	if len(block.PrevBlockHash) > 0 {
+		parent, ok := blockHeight(tx, block.PrevBlockHash)
+		if !ok {
+			return fmt.Errorf("block %x: height of parent %x unknown", block.Hash, block.PrevBlockHash)
+		}
+		height = parent + 1
+	}
+	return b.Put(block.Hash, binary.BigEndian.AppendUint32(nil, uint32(height)))
+}
+
+func blockHeight(tx *bbolt.Tx, hash []byte) (int, bool) {
+	b := tx.Bucket([]byte(blockHeightBucket))
+	if b == nil {
+		return 0, false
+	}
+	v := b.Get(hash)
+	if len(v) != 4 {
+		return 0, false
+	}
+	return int(binary.BigEndian.Uint32(v)), true
+}
+
+// GetBlockHeight returns the height of a stored block, on the main chain or not. Genesis
+// has height 1.
+func (bc *Blockchain) GetBlockHeight(hash []byte) (int, error) {
+	height, indexed := 0, false
+	err := bc.db.View(func(tx *bbolt.Tx) error {
+		if tx.Bucket([]byte(blockHeightBucket)) == nil {
+			return nil
+		}
+		indexed = true
+		var ok bool
+		if height, ok = blockHeight(tx, hash); !ok {
+			return fmt.Errorf("%w: %x", ErrBlockNotFound, hash)
+		}
+		return nil
+	})
+	if err != nil || indexed {
+		return height, err
+	}
+	return bc.scanBlockHeight(hash)
+}
+
+// scanBlockHeight is GetBlockHeight for databases without the height index (older ones
+// opened read-only): it counts the block's stored ancestors.
+func (bc *Blockchain) scanBlockHeight(hash []byte) (int, error) {
+	height := 0
+	err := bc.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(blocksBucket))
+		if b == nil {
+			return fmt.Errorf("%w: %x", ErrBlockNotFound, hash)
+		}
+		for h := hash; len(h) > 0; height++ {
+			data := b.Get(h)
+			if data == nil {
+				if height == 0 {
+					return fmt.Errorf("%w: %x", ErrBlockNotFound, hash)
+				}
+				return fmt.Errorf("missing ancestor %x", h)
+			}
+			h = DeserializeBlock(data).PrevBlockHash
+		}
+		return nil
+	})
+	return height, err
+}
+
+// ReindexHeights rebuilds the height index from every stored block.
+func (bc *Blockchain) ReindexHeights() error {
+	return bc.db.Update(func(tx *bbolt.Tx) error {
+		if tx.Bucket([]byte(blockHeightBucket)) != nil {
+			if err := tx.DeleteBucket([]byte(blockHeightBucket)); err != nil {
+				return err
+			}
+		}
+		heights, err := tx.CreateBucket([]byte(blockHeightBucket))
+		if err != nil {
+			return err
+		}
+		blocks := tx.Bucket([]byte(blocksBucket))
+		if blocks == nil {
+			return nil
+		}
+
+		// Each block is indexed after its ancestors, walking back to the nearest indexed one.
+		return blocks.ForEach(func(k, _ []byte) error {
+			if string(k) == lastHashKey {
+				return nil
+			}
+			var pending []*Block
+			for hash := k; len(hash) > 0 && heights.Get(hash) == nil; {
+				data := blocks.Get(hash)
+				if data == nil {
+					return fmt.Errorf("block %x: missing ancestor %x", k, hash)
+				}
+				block := DeserializeBlock(data)
+				pending = append(pending, block)
+				hash = block.PrevBlockHash
+			}
+			for i := len(pending) - 1; i >= 0; i-- {
+				if err := putBlockHeight(tx, pending[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// ensureHeightIndex builds the height index for databases created before it existed.
+func (bc *Blockchain) ensureHeightIndex() error {
+	found := false
+	_ = bc.db.View(func(tx *bbolt.Tx) error {
+		found = tx.Bucket([]byte(blockHeightBucket)) != nil
+		return nil
+	})
+	if len(bc.Tip()) > 0 && !found {
+		return bc.ReindexHeights()
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+// scanHeight counts the blocks from hash back to genesis, the way heights were found
+// before they were stored.
+func scanHeight(t *testing.T, bc *Blockchain, hash []byte) int {
+	t.Helper()
+	height := 0
+	for len(hash) > 0 {
+		height++
+		hash = mustBlock(t, bc, hash).PrevBlockHash
+	}
+	return height
+}
+
+func TestStoredHeightsMatchScanAfterReorg(t *testing.T) {
+	bc, _ := newTestChain(t)
+	main := []*Block{mustBlock(t, bc, bc.Tip())}
+	for height := 2; height <= 20; height++ {
+		main = append(main, mineOn(t, bc, main[len(main)-1], height))
+		putAll(t, bc, main[len(main)-1])
+	}
+	if bc.BestHeight() != 20 {
+		t.Fatalf("best height %d, want 20", bc.BestHeight())
+	}
+
+	// A branch from height 16 overtakes the last five blocks.
+	fork := []*Block{main[15]}
+	for height := 17; height <= 22; height++ {
+		fork = append(fork, mineOn(t, bc, fork[len(fork)-1], height))
+	}
+	putAll(t, bc, fork[1:]...)
+	if bc.BestHeight() != 22 {
+		t.Fatalf("best height after the reorg %d, want 22", bc.BestHeight())
+	}
+	if got := scanHeight(t, bc, bc.Tip()); got != bc.BestHeight() {
+		t.Fatalf("scanned tip height %d, stored %d", got, bc.BestHeight())
+	}
+
+	for _, b := range append(main, fork[1:]...) {
+		got, err := bc.GetBlockHeight(b.Hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := scanHeight(t, bc, b.Hash); got != want {
+			t.Errorf("block %x: stored height %d, scanned %d", b.Hash, got, want)
+		}
+	}
+	if _, err := bc.GetBlockHeight([]byte("unknown")); !errors.Is(err, ErrBlockNotFound) {
+		t.Fatalf("height of an unknown block: got %v, want ErrBlockNotFound", err)
+	}
+}
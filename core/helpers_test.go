@@ -0,0 +1,125 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"my-blockchain/wallet"
+)
+
+// chdirTemp runs the rest of the test in a new temporary directory, where the chain DB
+// files, named after the node ID, are created.
+func chdirTemp(t testing.TB) string {
+	t.Helper()
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	return dir
+}
+
+// newTestChain creates a regtest chain whose genesis block pays a new wallet.
+func newTestChain(t testing.TB) (*Blockchain, *wallet.Wallet) {
+	t.Helper()
+	return newTestChainWith(t, RegtestConfig)
+}
+
+// newTestChainWith is newTestChain for a chain created with cfg.
+func newTestChainWith(t testing.TB, cfg ChainConfig) (*Blockchain, *wallet.Wallet) {
+	t.Helper()
+	chdirTemp(t)
+	w := wallet.NewWallet()
+	bc, err := CreateBlockchainForNode(string(w.GetAddress()), "test", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = bc.Close() })
+	return bc, w
+}
+
+// newWalletChain creates a regtest chain whose genesis block pays a new address in a
+// wallet file, for tests that build transactions through the wallet.
+func newWalletChain(t *testing.T) (*Blockchain, *wallet.Wallets, string) {
+	t.Helper()
+	dir := chdirTemp(t)
+	ws, err := wallet.NewWalletsAt(filepath.Join(dir, "wallets.dat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	from, err := ws.CreateWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc, err := CreateBlockchainForNode(from, "test", RegtestConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = bc.Close() })
+	return bc, ws, from
+}
+
+// mustBlock returns the stored block at hash.
+func mustBlock(t *testing.T, bc *Blockchain, hash []byte) *Block {
+	t.Helper()
+	block, err := bc.Block(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return block
+}
+
+// mineOn mines txs, after a coinbase for height, on top of parent at the chain's
+// difficulty, stamped a second after parent so the median time past never rejects it.
+func mineOn(t *testing.T, bc *Blockchain, parent *Block, height int, txs ...*Transaction) *Block {
+	t.Helper()
+	return mineBits(t, bc, parent, height, bc.config.TargetBits, txs...)
+}
+
+// mineBits is mineOn at difficulty bits.
+func mineBits(t *testing.T, bc *Blockchain, parent *Block, height, bits int, txs ...*Transaction) *Block {
+	t.Helper()
+	to := string(wallet.NewWallet().GetAddress())
+	txs = append([]*Transaction{bc.config.CoinbaseTx(to, "", height)}, txs...)
+	block := newBlockTemplate(txs, parent.Hash, bits)
+	block.Timestamp = parent.Timestamp + 1
+	block.Nonce, block.Hash = NewProofOfWork(block).Run()
+	return block
+}
+
+// putAll stores blocks in order with PutBlock.
+func putAll(t *testing.T, bc *Blockchain, blocks ...*Block) {
+	t.Helper()
+	for _, b := range blocks {
+		if err := bc.PutBlock(b.Serialize()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// spend returns a transaction paying value of output vout of prev, which w owns, to a new
+// address, signed by w.
+func spend(t *testing.T, bc *Blockchain, w *wallet.Wallet, prev *Transaction, vout, value int) *Transaction {
+	t.Helper()
+	return payTo(t, bc, w, prev, vout, value, string(wallet.NewWallet().GetAddress()))
+}
+
+// payTo is spend paying address to.
+func payTo(t *testing.T, bc *Blockchain, w *wallet.Wallet, prev *Transaction, vout, value int, to string) *Transaction {
+	t.Helper()
+	tx := &Transaction{
+		Vin:  []TxInput{{Txid: prev.ID, Vout: vout, PubKey: w.PubKey()}},
+		Vout: []TxOutput{*NewTxOutput(value, to)},
+	}
+	tx.ID = tx.Hash()
+	if err := bc.signTransaction(tx, w.PrivateECDSA(), pendingSet([]*Transaction{prev})); err != nil {
+		t.Fatal(err)
+	}
+	tx.ID = tx.Hash()
+	return tx
+}
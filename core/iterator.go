@@ -9,16 +9,24 @@ import (
 type BlockchainIterator struct {
 	currentHash []byte
 	db          *bbolt.DB
+	cache       *blockCache
 }
 
 func (bc *Blockchain) Iterator() *BlockchainIterator {
-	return &BlockchainIterator{currentHash: bc.tip, db: bc.db}
+	return &BlockchainIterator{currentHash: bc.Tip(), db: bc.db, cache: bc.blocks}
 }
 
+// Next returns the current block and steps to its parent, or nil past the genesis block
+// and at once on a chain with no blocks yet, so loops over it must check for nil.
+// The block may be shared with other readers through the block cache: do not modify it.
 func (it *BlockchainIterator) Next() *Block {
 	if len(it.currentHash) == 0 {
 		return nil
 	}
+	if block, ok := it.cache.get(it.currentHash); ok {
+		it.currentHash = block.PrevBlockHash
+		return block
+	}
 	var block *Block
 
 	err := it.db.View(func(tx *bbolt.Tx) error {
@@ -38,6 +46,7 @@ func (it *BlockchainIterator) Next() *Block {
 		return nil
 	}
 
+	it.cache.put(block)
 	it.currentHash = block.PrevBlockHash
 	return block
 }
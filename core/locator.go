@@ -0,0 +1,49 @@
+package core
+
+import "bytes"
+
+// locatorDense is how many of the most recent blocks a block locator lists one by one
+// before its steps start doubling.
+const locatorDense = 10
+
+// BlockLocator returns hashes of main-chain blocks, tip first, from which a peer can tell
+// where its chain and ours fork: the locatorDense most recent blocks, then blocks ever
+// further apart, the step doubling each time, and genesis last. So it stays short (about
+// 10 + log2(height) hashes) however long the chain. It is nil for an empty chain.
+func (bc *Blockchain) BlockLocator() [][]byte {
+	best := bc.BestHeight()
+	if best == 0 {
+		return nil
+	}
+	var locator [][]byte
+	step := 1
+	for height := best; height > 1; height -= step {
+		if hash, err := bc.GetBlockHash(height); err == nil {
+			locator = append(locator, hash)
+		}
+		if len(locator) >= locatorDense {
+			step *= 2
+		}
+	}
+	if genesis, err := bc.GetBlockHash(1); err == nil {
+		locator = append(locator, genesis)
+	}
+	return locator
+}
+
+// LocateFork returns the height of the first block in locator (see BlockLocator) that is
+// on our main chain: the last block the peer that sent it shares with us. It returns 0
+// when there is none, e.g. for an empty locator from a peer without one, so that peer is
+// sent everything from genesis.
+func (bc *Blockchain) LocateFork(locator [][]byte) int {
+	for _, hash := range locator {
+		height, err := bc.GetBlockHeight(hash)
+		if err != nil {
+			continue
+		}
+		if main, err := bc.GetBlockHash(height); err == nil && bytes.Equal(main, hash) {
+			return height
+		}
+	}
+	return 0
+}
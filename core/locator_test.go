@@ -0,0 +1,69 @@
+package core
+
+import (
+	"testing"
+
+	"my-blockchain/wallet"
+)
+
+func TestBlockLocatorSpacing(t *testing.T) {
+	bc, _ := newTestChain(t)
+	if got := len(bc.BlockLocator()); got != 1 {
+		t.Fatalf("locator of the genesis chain has %d hashes, want 1", got)
+	}
+	to := string(wallet.NewWallet().GetAddress())
+	for height := 2; height <= 100; height++ {
+		if _, err := bc.AddBlock([]*Transaction{bc.config.CoinbaseTx(to, "", height)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Ten blocks one by one from the tip, then steps of 2, 4, 8, 16 and 32, then genesis.
+	want := []int{100, 99, 98, 97, 96, 95, 94, 93, 92, 91, 89, 85, 77, 61, 29, 1}
+	locator := bc.BlockLocator()
+	if len(locator) != len(want) {
+		t.Fatalf("locator has %d hashes, want %d", len(locator), len(want))
+	}
+	for i, height := range want {
+		if got := scanHeight(t, bc, locator[i]); got != height {
+			t.Errorf("locator entry %d is at height %d, want %d", i, got, height)
+		}
+	}
+}
+
+func TestLocateForkFindsLastSharedBlock(t *testing.T) {
+	bc, _ := newTestChain(t)
+	to := string(wallet.NewWallet().GetAddress())
+	for height := 2; height <= 40; height++ {
+		if _, err := bc.AddBlock([]*Transaction{bc.config.CoinbaseTx(to, "", height)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	forkHash, err := bc.GetBlockHash(30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A stored side branch off block 30: its blocks have heights but are not on the main chain.
+	side1 := mineOn(t, bc, mustBlock(t, bc, forkHash), 31)
+	side2 := mineOn(t, bc, side1, 32)
+	putAll(t, bc, side1, side2)
+
+	genesis, err := bc.GetBlockHash(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, tc := range map[string]struct {
+		locator [][]byte
+		want    int
+	}{
+		"side branch":   {[][]byte{side2.Hash, side1.Hash, forkHash, genesis}, 30},
+		"unknown first": {[][]byte{[]byte("unknown"), forkHash}, 30},
+		"our own tip":   {[][]byte{bc.Tip(), forkHash}, 40},
+		"only unknown":  {[][]byte{[]byte("unknown")}, 0},
+		"empty":         {nil, 0},
+	} {
+		if got := bc.LocateFork(tc.locator); got != tc.want {
+			t.Errorf("%s: fork at %d, want %d", name, got, tc.want)
+		}
+	}
+}
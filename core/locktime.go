@@ -0,0 +1,41 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// lockTimeThreshold splits LockTime values, as in Bitcoin: below it they are block
+// heights, from it on Unix timestamps.
+const lockTimeThreshold = 500_000_000
+
+var ErrNonFinalTx = errors.New("transaction is locked")
+
+// IsFinal reports whether tx may be included in a block at height with timestamp
+// blockTime. Coinbases are never locked.
+func (tx *Transaction) IsFinal(height int, blockTime int64) bool {
+	if tx.LockTime <= 0 || tx.IsCoinbase() {
+		return true
+	}
+	if tx.LockTime < lockTimeThreshold {
+		return tx.LockTime <= height
+	}
+	return int64(tx.LockTime) <= blockTime
+}
+
+func checkFinal(tx *Transaction, height int, blockTime int64) error {
+	if tx.IsFinal(height, blockTime) {
+		return nil
+	}
+	if tx.LockTime < lockTimeThreshold {
+		return fmt.Errorf("%w: %x until height %d", ErrNonFinalTx, tx.ID, tx.LockTime)
+	}
+	return fmt.Errorf("%w: %x until %s", ErrNonFinalTx, tx.ID, time.Unix(int64(tx.LockTime), 0).UTC().Format(time.RFC3339))
+}
+
+// CheckFinal returns ErrNonFinalTx unless tx may go into the next block, judged by the
+// next height and the current time. Nodes only accept final transactions into the mempool.
+func (bc *Blockchain) CheckFinal(tx *Transaction) error {
+	return checkFinal(tx, bc.BestHeight()+1, time.Now().Unix())
+}
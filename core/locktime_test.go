@@ -0,0 +1,87 @@
+package core
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"my-blockchain/wallet"
+)
+
+// lockedSpend is spend with LockTime set to lockTime before signing.
+func lockedSpend(t *testing.T, bc *Blockchain, w *wallet.Wallet, prev *Transaction, value, lockTime int) *Transaction {
+	t.Helper()
+	tx := &Transaction{
+		Vin:      []TxInput{{Txid: prev.ID, Vout: 0, PubKey: w.PubKey()}},
+		Vout:     []TxOutput{*NewTxOutput(value, string(wallet.NewWallet().GetAddress()))},
+		LockTime: lockTime,
+	}
+	tx.ID = tx.Hash()
+	if err := bc.signTransaction(tx, w.PrivateECDSA(), pendingSet([]*Transaction{prev})); err != nil {
+		t.Fatal(err)
+	}
+	tx.ID = tx.Hash()
+	return tx
+}
+
+// mineAt is mineOn with the block stamped timestamp instead of its parent's time plus 1.
+func mineAt(t *testing.T, bc *Blockchain, parent *Block, height int, timestamp int64, txs ...*Transaction) *Block {
+	t.Helper()
+	to := string(wallet.NewWallet().GetAddress())
+	block := newBlockTemplate(append([]*Transaction{bc.config.CoinbaseTx(to, "", height)}, txs...), parent.Hash, bc.config.TargetBits)
+	block.Timestamp = timestamp
+	block.Nonce, block.Hash = NewProofOfWork(block).Run()
+	return block
+}
+
+func TestHeightLockedTransaction(t *testing.T) {
+	bc, w := newTestChain(t)
+	genesis := mustBlock(t, bc, bc.Tip())
+	tx := lockedSpend(t, bc, w, genesis.Transactions[0], 10, 3)
+
+	// The next block is 2, one short of the lock.
+	if _, err := bc.CheckPendingTx(tx, NewMempool()); !errors.Is(err, ErrNonFinalTx) {
+		t.Fatalf("mempool at next height 2: got %v, want ErrNonFinalTx", err)
+	}
+	early := mineOn(t, bc, genesis, 2, tx)
+	if err := bc.PutBlock(early.Serialize()); !errors.Is(err, ErrNonFinalTx) {
+		t.Fatalf("block 2: got %v, want ErrNonFinalTx", err)
+	}
+
+	empty := mineOn(t, bc, genesis, 2)
+	putAll(t, bc, empty)
+	if _, err := bc.CheckPendingTx(tx, NewMempool()); err != nil {
+		t.Fatalf("mempool at next height 3: %v", err)
+	}
+	putAll(t, bc, mineOn(t, bc, empty, 3, tx))
+	if bc.BestHeight() != 3 {
+		t.Fatalf("height %d, want 3 with the unlocked transaction mined", bc.BestHeight())
+	}
+}
+
+func TestTimeLockedTransaction(t *testing.T) {
+	bc, w := newTestChain(t)
+	genesis := mustBlock(t, bc, bc.Tip())
+	coinbase := genesis.Transactions[0]
+
+	// The mempool judges by the clock.
+	now := int(time.Now().Unix())
+	if _, err := bc.CheckPendingTx(lockedSpend(t, bc, w, coinbase, 10, now+3600), NewMempool()); !errors.Is(err, ErrNonFinalTx) {
+		t.Fatalf("mempool, locked for an hour: got %v, want ErrNonFinalTx", err)
+	}
+	if _, err := bc.CheckPendingTx(lockedSpend(t, bc, w, coinbase, 10, now), NewMempool()); err != nil {
+		t.Fatalf("mempool, locked until now: %v", err)
+	}
+
+	// Blocks judge by their own timestamp.
+	lock := lockTimeThreshold + 1000
+	tx := lockedSpend(t, bc, w, coinbase, 10, lock)
+	early := mineAt(t, bc, genesis, 2, int64(lock-1), tx)
+	if err := bc.PutBlock(early.Serialize()); !errors.Is(err, ErrNonFinalTx) {
+		t.Fatalf("block a second before the lock: got %v, want ErrNonFinalTx", err)
+	}
+	putAll(t, bc, mineAt(t, bc, genesis, 2, int64(lock), tx))
+	if bc.BestHeight() != 2 {
+		t.Fatalf("height %d, want 2 with the block at the lock time", bc.BestHeight())
+	}
+}
@@ -0,0 +1,39 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// Log is the process-wide logger: info and above, as text on stderr. The CLI replaces it to
+// change the level, and a node derives its own from it tagged with its node ID.
+var Log = NewLogger(os.Stderr, slog.LevelInfo)
+
+// NewLogger returns a logger writing text records of at least level to w. Pass io.Discard
+// to silence it.
+func NewLogger(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: level}))
+}
+
+// ParseLogLevel parses debug, info, warn or error, in any case.
+func ParseLogLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return level, fmt.Errorf("invalid log level %q (want debug, info, warn or error)", name)
+	}
+	return level, nil
+}
+
+// SetLogger makes bc log to l instead of Log.
+func (bc *Blockchain) SetLogger(l *slog.Logger) {
+	bc.log = l
+}
+
+func (bc *Blockchain) logger() *slog.Logger {
+	if bc.log == nil {
+		return Log
+	}
+	return bc.log
+}
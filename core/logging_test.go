@@ -0,0 +1,44 @@
+package core
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestLoggerFiltersBelowLevel(t *testing.T) {
+	var buf bytes.Buffer
+	level, err := ParseLogLevel("WARN")
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := NewLogger(&buf, level).With("node", "3000")
+	log.Debug("debug line")
+	log.Info("info line")
+	log.Warn("warn line")
+	log.Error("error line")
+
+	out := buf.String()
+	for _, suppressed := range []string{"debug line", "info line"} {
+		if strings.Contains(out, suppressed) {
+			t.Errorf("%q logged at level warn", suppressed)
+		}
+	}
+	for _, shown := range []string{"level=WARN msg=\"warn line\" node=3000", "level=ERROR msg=\"error line\" node=3000"} {
+		if !strings.Contains(out, shown) {
+			t.Errorf("output %q lacks %q", out, shown)
+		}
+	}
+}
+
+func TestParseLogLevel(t *testing.T) {
+	for name, want := range map[string]slog.Level{"debug": slog.LevelDebug, "Info": slog.LevelInfo, "warn": slog.LevelWarn, "ERROR": slog.LevelError} {
+		if got, err := ParseLogLevel(name); err != nil || got != want {
+			t.Errorf("ParseLogLevel(%q) = %v, %v, want %v", name, got, err, want)
+		}
+	}
+	if _, err := ParseLogLevel("verbose"); err == nil {
+		t.Error("parsed an unknown level")
+	}
+}
@@ -0,0 +1,107 @@
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"go.etcd.io/bbolt"
+)
+
+// mainChainBucket maps each main-chain height (4 bytes, big-endian) to the hash of the
+// block at that height. It moves with the tip, in the same write transaction.
+const mainChainBucket = "mainchain"
+
+// ErrHeightOutOfRange is returned for a height below genesis or above the tip.
+var ErrHeightOutOfRange = errors.New("block height out of range")
+
+func heightKey(height int) []byte {
+	return binary.BigEndian.AppendUint32(nil, uint32(height))
+}
+
+// putMainChainHash records hash as the main-chain block at height.
+func putMainChainHash(tx *bbolt.Tx, height int, hash []byte) error {
+	b, err := tx.CreateBucketIfNotExists([]byte(mainChainBucket))
+	if err != nil {
+		return err
+	}
+	return b.Put(heightKey(height), hash)
+}
+
+// deleteMainChainHash drops the main-chain entry of a disconnected block.
+func deleteMainChainHash(tx *bbolt.Tx, block *Block) error {
+	b := tx.Bucket([]byte(mainChainBucket))
+	if b == nil {
+		return nil
+	}
+	height, ok := blockHeight(tx, block.Hash)
+	if !ok {
+		return fmt.Errorf("block %x: height unknown", block.Hash)
+	}
+	return b.Delete(heightKey(height))
+}
+
+// GetBlockHash returns the hash of the main-chain block at height; genesis has height 1.
+// Databases without the index (older ones opened read-only) fall back to a chain walk.
+func (bc *Blockchain) GetBlockHash(height int) ([]byte, error) {
+	best := bc.BestHeight()
+	if height < 1 || height > best {
+		return nil, fmt.Errorf("%w: %d (the chain has %d blocks)", ErrHeightOutOfRange, height, best)
+	}
+	var hash []byte
+	indexed := false
+	err := bc.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(mainChainBucket))
+		if b == nil {
+			return nil
+		}
+		indexed = true
+		if v := b.Get(heightKey(height)); v != nil {
+			hash = append([]byte(nil), v...)
+			return nil
+		}
+		return fmt.Errorf("main-chain index has no block at height %d", height)
+	})
+	if err != nil || indexed {
+		return hash, err
+	}
+	hashes := bc.GetBlockHashes()
+	if height > len(hashes) {
+		return nil, fmt.Errorf("%w: %d (the chain has %d blocks)", ErrHeightOutOfRange, height, len(hashes))
+	}
+	return hashes[height-1], nil
+}
+
+// ReindexMainChain rebuilds the main-chain index by walking back from the tip.
+func (bc *Blockchain) ReindexMainChain() error {
+	hashes := bc.GetBlockHashes()
+	return bc.db.Update(func(tx *bbolt.Tx) error {
+		if tx.Bucket([]byte(mainChainBucket)) != nil {
+			if err := tx.DeleteBucket([]byte(mainChainBucket)); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.CreateBucket([]byte(mainChainBucket)); err != nil {
+			return err
+		}
+		for i, hash := range hashes {
+			if err := putMainChainHash(tx, i+1, hash); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ensureMainChainIndex builds the main-chain index for databases created before it existed.
+func (bc *Blockchain) ensureMainChainIndex() error {
+	found := false
+	_ = bc.db.View(func(tx *bbolt.Tx) error {
+		found = tx.Bucket([]byte(mainChainBucket)) != nil
+		return nil
+	})
+	if len(bc.Tip()) > 0 && !found {
+		return bc.ReindexMainChain()
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package core
+
+import "errors"
+
+// coinbaseMature reports whether a coinbase mined at height may be spent by a transaction
+// in a block at spendHeight: CoinbaseMaturity blocks must be built on top of its block,
+// counting that block itself. Heights count blocks from 1 (genesis), like BestHeight. The
+// genesis coinbase is exempt so a fresh chain has spendable coins.
+func (cfg ChainConfig) coinbaseMature(height, spendHeight int) bool {
+	return height <= 1 || spendHeight-height >= cfg.CoinbaseMaturity
+}
+
+// outputsMature reports whether outs may be spent in a block at spendHeight.
+func (cfg ChainConfig) outputsMature(outs TxOutputs, spendHeight int) bool {
+	return !outs.Coinbase || cfg.coinbaseMature(outs.Height, spendHeight)
+}
+
+// findTransactionHeight is FindTransaction that also returns the height of the block
+// containing the transaction.
+// Transactions in pruned blocks are rebuilt from their unspent outputs.
+func (bc *Blockchain) findTransactionHeight(ID []byte) (Transaction, int, error) {
+	tx, _, height, err := bc.FindTransactionLocation(ID)
+	if errors.Is(err, ErrTransactionNotFound) {
+		return bc.prunedTransaction(ID)
+	}
+	return tx, height, err
+}
+
+// FindTransactionLocation is FindTransaction that also returns the hash and height of the
+// block containing the transaction. The search stops at that block; its height comes from
+// the height index.
+func (bc *Blockchain) FindTransactionLocation(ID []byte) (Transaction, []byte, int, error) {
+	tx, blockHash, err := bc.FindTransactionBlock(ID)
+	if err != nil {
+		return Transaction{}, nil, 0, err
+	}
+	height, err := bc.GetBlockHeight(blockHash)
+	if err != nil {
+		return Transaction{}, nil, 0, err
+	}
+	return tx, blockHash, height, nil
+}
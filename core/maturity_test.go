@@ -0,0 +1,39 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCoinbaseSpendableOnlyAtMaturity(t *testing.T) {
+	cfg := RegtestConfig
+	cfg.CoinbaseMaturity = 3
+	bc, w := newTestChainWith(t, cfg)
+	genesis := mustBlock(t, bc, bc.Tip())
+
+	// Block 2's coinbase pays w, like the genesis block's, which is exempt from maturity.
+	reward := newBlockTemplate([]*Transaction{cfg.CoinbaseTx(string(w.GetAddress()), "", 2)}, genesis.Hash, cfg.TargetBits)
+	reward.Timestamp = genesis.Timestamp + 1
+	reward.Nonce, reward.Hash = NewProofOfWork(reward).Run()
+	block3 := mineOn(t, bc, reward, 3)
+	putAll(t, bc, reward, block3)
+	coinbase := reward.Transactions[0]
+
+	early := mineOn(t, bc, block3, 4, spend(t, bc, w, coinbase, 0, 10))
+	err := bc.PutBlock(early.Serialize())
+	if !errors.Is(err, ErrInvalidTransaction) || !strings.Contains(err.Error(), "immature coinbase") {
+		t.Fatalf("spending a height-2 coinbase at height 4: got %v, want an immature coinbase error", err)
+	}
+	if !bytes.Equal(bc.Tip(), block3.Hash) {
+		t.Fatalf("tip moved to %x", bc.Tip())
+	}
+
+	block4 := mineOn(t, bc, block3, 4)
+	mature := mineOn(t, bc, block4, 5, spend(t, bc, w, coinbase, 0, 10))
+	putAll(t, bc, block4, mature)
+	if !bytes.Equal(bc.Tip(), mature.Hash) {
+		t.Fatal("spending a height-2 coinbase at height 5 was not accepted")
+	}
+}
@@ -0,0 +1,345 @@
+package core
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ErrReplacementFee is returned when a transaction conflicting with replaceable pending
+// ones does not pay more than all of them together.
+var ErrReplacementFee = errors.New("replacement fee too low")
+
+type mempoolEntry struct {
+	tx  *Transaction
+	fee int
+	seq uint64
+}
+
+// Mempool holds validated transactions waiting to be mined, keyed by hex tx ID.
+// It is safe for concurrent use.
+type Mempool struct {
+	mu      sync.Mutex
+	txs     map[string]mempoolEntry
+	nextSeq uint64
+	// spent maps each output claimed by a pending transaction to that transaction's hex ID.
+	spent map[outpoint]string
+	// events is where accepted transactions are announced; nil means nowhere.
+	events *EventBus
+}
+
+func NewMempool() *Mempool {
+	return &Mempool{txs: make(map[string]mempoolEntry), spent: make(map[outpoint]string)}
+}
+
+// Add stores tx, which pays fee, in the pool. Adding a transaction that is already present
+// is a no-op. A transaction spending outputs already claimed by pending ones replaces them,
+// and every pending transaction descending from them, if they all signal Replaceable and
+// fee is higher than the fees of everything replaced combined; otherwise it fails.
+func (mp *Mempool) Add(tx *Transaction, fee int) error {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	id := hex.EncodeToString(tx.ID)
+	if _, ok := mp.txs[id]; ok {
+		return nil
+	}
+	if err := checkDoubleSpends([]*Transaction{tx}); err != nil {
+		return err
+	}
+	conflicts := make(map[string]bool)
+	for _, vin := range tx.Vin {
+		op := outpoint{txid: hex.EncodeToString(vin.Txid), vout: vin.Vout}
+		other, ok := mp.spent[op]
+		if !ok || conflicts[other] {
+			continue
+		}
+		if !mp.txs[other].tx.Replaceable {
+			return fmt.Errorf("%w: %s by pending %s", ErrDoubleSpend, op, other)
+		}
+		conflicts[other] = true
+	}
+	replaced := mp.withDescendants(conflicts)
+	replacedFees := 0
+	for other := range replaced {
+		replacedFees += mp.txs[other].fee
+	}
+	for _, vin := range tx.Vin {
+		if parent := hex.EncodeToString(vin.Txid); replaced[parent] {
+			return fmt.Errorf("%w: spends an output of pending %s, which it replaces", ErrDoubleSpend, parent)
+		}
+	}
+	if len(replaced) > 0 && fee <= replacedFees {
+		return fmt.Errorf("%w: pays %d, the pending transactions it replaces pay %d", ErrReplacementFee, fee, replacedFees)
+	}
+	for other := range replaced {
+		mp.remove(other)
+	}
+
+	mp.txs[id] = mempoolEntry{tx: tx, fee: fee, seq: mp.nextSeq}
+	mp.nextSeq++
+	for _, vin := range tx.Vin {
+		mp.spent[outpoint{txid: hex.EncodeToString(vin.Txid), vout: vin.Vout}] = id
+	}
+	mp.events.Publish(Event{Type: EventTx, Hash: tx.ID})
+	return nil
+}
+
+// withDescendants returns ids plus every pending transaction spending an output of one of
+// them, directly or through other pending transactions. mp.mu must be held.
+func (mp *Mempool) withDescendants(ids map[string]bool) map[string]bool {
+	all := make(map[string]bool, len(ids))
+	queue := make([]string, 0, len(ids))
+	for id := range ids {
+		all[id] = true
+		queue = append(queue, id)
+	}
+	for len(queue) > 0 {
+		parent := queue[0]
+		queue = queue[1:]
+		for op, child := range mp.spent {
+			if op.txid == parent && !all[child] {
+				all[child] = true
+				queue = append(queue, child)
+			}
+		}
+	}
+	return all
+}
+
+// Remove drops the transactions with the given IDs, e.g. after they were mined.
+func (mp *Mempool) Remove(ids [][]byte) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	for _, id := range ids {
+		mp.remove(hex.EncodeToString(id))
+	}
+}
+
+// remove drops one transaction and releases the outputs it claimed. mp.mu must be held.
+func (mp *Mempool) remove(id string) {
+	e, ok := mp.txs[id]
+	if !ok {
+		return
+	}
+	for _, vin := range e.tx.Vin {
+		delete(mp.spent, outpoint{txid: hex.EncodeToString(vin.Txid), vout: vin.Vout})
+	}
+	delete(mp.txs, id)
+}
+
+// Has reports whether a transaction with the given ID is pending.
+func (mp *Mempool) Has(id []byte) bool {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	_, ok := mp.txs[hex.EncodeToString(id)]
+	return ok
+}
+
+// Get returns the pending transaction with the given ID.
+func (mp *Mempool) Get(id []byte) (*Transaction, bool) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	e, ok := mp.txs[hex.EncodeToString(id)]
+	return e.tx, ok
+}
+
+func (mp *Mempool) Len() int {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	return len(mp.txs)
+}
+
+// PendingTx describes a pending transaction for listings.
+type PendingTx struct {
+	ID  []byte
+	Fee int
+	// Size is the transaction's serialized size in bytes.
+	Size int
+}
+
+// Pending lists the pending transactions in arrival order.
+func (mp *Mempool) Pending() []PendingTx {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	entries := make([]mempoolEntry, 0, len(mp.txs))
+	for _, e := range mp.txs {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].seq < entries[j].seq })
+	pending := make([]PendingTx, 0, len(entries))
+	for _, e := range entries {
+		pending = append(pending, PendingTx{ID: e.tx.ID, Fee: e.fee, Size: len(e.tx.Serialize())})
+	}
+	return pending
+}
+
+// Collect returns up to max pending transactions to mine, without removing them: highest
+// fee rate (fee per serialized byte) first, lowest ID first on a tie. A transaction spending
+// an output of another pending one comes after it, and is left out if it is. Transactions
+// that would no longer fit in a block of maxBlockSize bytes are skipped. A max <= 0 lifts
+// the count limit.
+func (mp *Mempool) Collect(max, maxBlockSize int) []*Transaction {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	type candidate struct {
+		id   string
+		tx   *Transaction
+		fee  int
+		size int
+	}
+	candidates := make([]candidate, 0, len(mp.txs))
+	for id, e := range mp.txs {
+		candidates = append(candidates, candidate{id: id, tx: e.tx, fee: e.fee, size: len(e.tx.Serialize())})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		// a.fee/a.size > b.fee/b.size, without rounding.
+		if ra, rb := a.fee*b.size, b.fee*a.size; ra != rb {
+			return ra > rb
+		}
+		return a.id < b.id
+	})
+
+	// included records each decided transaction: true if collected, false if left out.
+	included := make(map[string]bool, len(candidates))
+	// ready reports whether every pending parent of tx is collected; left is set when one
+	// was left out, so tx must be too.
+	ready := func(tx *Transaction) (ok, left bool) {
+		for _, vin := range tx.Vin {
+			parent := hex.EncodeToString(vin.Txid)
+			if _, pending := mp.txs[parent]; !pending {
+				continue
+			}
+			in, decided := included[parent]
+			if !decided {
+				return false, false
+			}
+			if !in {
+				return false, true
+			}
+		}
+		return true, false
+	}
+
+	var txs []*Transaction
+	size := blockReserve
+	// Each pass collects the best transaction whose parents are in, so a child follows
+	// its parent as soon as its own fee rate allows.
+	for max <= 0 || len(txs) < max {
+		picked := false
+		for _, c := range candidates {
+			if _, decided := included[c.id]; decided {
+				continue
+			}
+			ok, left := ready(c.tx)
+			if left || (ok && size+c.size > maxBlockSize) {
+				included[c.id] = false
+				continue
+			}
+			if !ok {
+				continue
+			}
+			size += c.size
+			included[c.id] = true
+			txs = append(txs, c.tx)
+			picked = true
+			break
+		}
+		if !picked {
+			break
+		}
+	}
+	return txs
+}
+
+// transactions returns the pending transactions keyed by hex ID, for findPrevTx. A nil
+// mp has none.
+func (mp *Mempool) transactions() map[string]*Transaction {
+	if mp == nil {
+		return nil
+	}
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	txs := make(map[string]*Transaction, len(mp.txs))
+	for id, e := range mp.txs {
+		txs[id] = e.tx
+	}
+	return txs
+}
+
+// unspentCoins lists the outputs of pending transactions locked to pubKeyHash that no
+// other pending transaction spends yet, in arrival order. A nil mp has none.
+func (mp *Mempool) unspentCoins(pubKeyHash []byte) []coin {
+	if mp == nil {
+		return nil
+	}
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	entries := make([]mempoolEntry, 0, len(mp.txs))
+	for _, e := range mp.txs {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].seq < entries[j].seq })
+	var coins []coin
+	for _, e := range entries {
+		txID := hex.EncodeToString(e.tx.ID)
+		for idx, out := range e.tx.Vout {
+			if _, spent := mp.spent[outpoint{txid: txID, vout: idx}]; spent || out.IsData() || !out.IsLockedWithKey(pubKeyHash) {
+				continue
+			}
+			coins = append(coins, coin{txID: txID, vout: idx, value: out.Value})
+		}
+	}
+	return coins
+}
+
+// SpentOutputs returns the outputs already claimed by pending transactions,
+// as a map of hex tx ID to output indexes.
+func (mp *Mempool) SpentOutputs() map[string][]int {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	spent := make(map[string][]int)
+	for op := range mp.spent {
+		spent[op.txid] = append(spent[op.txid], op.vout)
+	}
+	return spent
+}
+
+// EvictSpent removes pending transactions whose inputs have been spent by a block,
+// including the transactions that were mined themselves, and then those spending outputs
+// of an evicted transaction that did not make it into the chain. A transaction spending a
+// pending one stays as long as its parent does. It returns the number evicted.
+func (mp *Mempool) EvictSpent(bc *Blockchain) int {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	pending := make(map[string]*Transaction, len(mp.txs))
+	for id, e := range mp.txs {
+		pending[id] = e.tx
+	}
+	evicted := 0
+	for again := true; again; {
+		again = false
+		for id, e := range mp.txs {
+			if !bc.inputsUnspent(e.tx, pending) {
+				mp.remove(id)
+				delete(pending, id)
+				evicted++
+				again = true
+			}
+		}
+	}
+	return evicted
+}
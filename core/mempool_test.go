@@ -0,0 +1,184 @@
+package core
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"my-blockchain/wallet"
+)
+
+// pendingTx returns an unsigned transaction spending the given outputs of prev into one
+// output of value; the mempool does not check signatures.
+func pendingTx(prev []byte, vouts []int, value int, replaceable bool) *Transaction {
+	tx := &Transaction{Replaceable: replaceable}
+	for _, vout := range vouts {
+		tx.Vin = append(tx.Vin, TxInput{Txid: prev, Vout: vout})
+	}
+	tx.Vout = []TxOutput{*NewTxOutput(value, string(wallet.NewWallet().GetAddress()))}
+	tx.ID = tx.Hash()
+	return tx
+}
+
+func TestReplacementEvictsDescendants(t *testing.T) {
+	funding := []byte("funding transaction")
+	parent := pendingTx(funding, []int{0}, 50000, true)
+	child := pendingTx(parent.ID, []int{0}, 49000, false)
+	grandchild := pendingTx(child.ID, []int{0}, 48000, false)
+	unrelated := pendingTx(funding, []int{1}, 50000, false)
+
+	mp := NewMempool()
+	for _, tx := range []*Transaction{parent, child, grandchild, unrelated} {
+		if err := mp.Add(tx, 1000); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Outbidding the parent alone is not enough: its descendants go with it.
+	cheap := pendingTx(funding, []int{0}, 49000, true)
+	if err := mp.Add(cheap, 2000); !errors.Is(err, ErrReplacementFee) {
+		t.Fatalf("replacement paying less than the three it evicts: got %v, want ErrReplacementFee", err)
+	}
+
+	replacement := pendingTx(funding, []int{0}, 46000, true)
+	if err := mp.Add(replacement, 4000); err != nil {
+		t.Fatal(err)
+	}
+	for _, tx := range []*Transaction{parent, child, grandchild} {
+		if mp.Has(tx.ID) {
+			t.Fatalf("%x is still pending after its ancestor was replaced", tx.ID)
+		}
+	}
+	if !mp.Has(replacement.ID) || !mp.Has(unrelated.ID) || mp.Len() != 2 {
+		t.Fatalf("pool holds %d transactions, want the replacement and the unrelated one", mp.Len())
+	}
+	for _, tx := range mp.Collect(0, DefaultMaxBlockSize) {
+		if tx == child || tx == grandchild {
+			t.Fatalf("evicted descendant %x was collected for mining", tx.ID)
+		}
+	}
+}
+
+func TestReplacementCannotSpendWhatItReplaces(t *testing.T) {
+	funding := []byte("funding transaction")
+	parent := pendingTx(funding, []int{0, 1}, 50000, true)
+	mp := NewMempool()
+	if err := mp.Add(parent, 1000); err != nil {
+		t.Fatal(err)
+	}
+
+	// Spends funding:0, conflicting with parent, and parent's own output.
+	tx := &Transaction{Vin: []TxInput{{Txid: funding, Vout: 0}, {Txid: parent.ID, Vout: 0}}}
+	tx.Vout = []TxOutput{*NewTxOutput(40000, string(wallet.NewWallet().GetAddress()))}
+	tx.ID = tx.Hash()
+	if err := mp.Add(tx, 5000); !errors.Is(err, ErrDoubleSpend) {
+		t.Fatalf("replacement spending the transaction it replaces: got %v, want ErrDoubleSpend", err)
+	}
+	if !mp.Has(parent.ID) {
+		t.Fatal("rejected replacement still evicted the original")
+	}
+}
+
+func TestSecondSpendOfPendingOutputRejected(t *testing.T) {
+	funding := []byte("funding transaction")
+	first := pendingTx(funding, []int{0}, 50000, false)
+	second := pendingTx(funding, []int{0}, 40000, false)
+
+	mp := NewMempool()
+	if err := mp.Add(first, 1000); err != nil {
+		t.Fatal(err)
+	}
+	err := mp.Add(second, 10000)
+	if !errors.Is(err, ErrDoubleSpend) {
+		t.Fatalf("second spend of %x:0: got %v, want ErrDoubleSpend", funding, err)
+	}
+	if op := hex.EncodeToString(funding) + ":0"; !strings.Contains(err.Error(), op) {
+		t.Fatalf("error %q does not name the outpoint %s", err, op)
+	}
+	if !mp.Has(first.ID) || mp.Has(second.ID) {
+		t.Fatal("the second spend displaced the first")
+	}
+
+	twice := pendingTx(funding, []int{1, 1}, 50000, false)
+	if err := mp.Add(twice, 1000); !errors.Is(err, ErrDoubleSpend) {
+		t.Fatalf("transaction spending %x:1 twice: got %v, want ErrDoubleSpend", funding, err)
+	}
+}
+
+func TestCollectStopsAtBlockSizeLimit(t *testing.T) {
+	funding := []byte("funding transaction")
+	var txs []*Transaction
+	for vout := range 5 {
+		txs = append(txs, pendingTx(funding, []int{vout}, 1000, false))
+	}
+	// Room for three and a half: the sizes differ by a byte or two at most.
+	size := len(txs[0].Serialize())
+	cfg := RegtestConfig
+	cfg.MaxBlockSize = blockReserve + 3*size + size/2
+
+	mp := NewMempool()
+	for _, tx := range txs {
+		if err := mp.Add(tx, 100); err != nil {
+			t.Fatal(err)
+		}
+	}
+	collected := mp.Collect(0, cfg.MaxBlockSize)
+	if len(collected) != 3 {
+		t.Fatalf("collected %d transactions of about %d bytes under a %d-byte block limit, want 3", len(collected), size, cfg.MaxBlockSize)
+	}
+
+	to := string(wallet.NewWallet().GetAddress())
+	block := newBlockTemplate(append([]*Transaction{RegtestConfig.CoinbaseTx(to, "", 2)}, collected...), nil, 1)
+	if err := cfg.checkBlockSize(block); err != nil {
+		t.Fatalf("block of the collected transactions: %v", err)
+	}
+	cfg.MaxBlockSize = len(block.Serialize()) - 1
+	if err := cfg.checkBlockSize(block); !errors.Is(err, ErrBlockTooLarge) {
+		t.Fatalf("block a byte over the limit: got %v, want ErrBlockTooLarge", err)
+	}
+}
+
+func TestCollectOrdersByFeeRateParentsFirst(t *testing.T) {
+	funding := []byte("funding transaction")
+	high := pendingTx(funding, []int{0}, 1000, false)
+	mid := pendingTx(funding, []int{1}, 1000, false)
+	tieA := pendingTx(funding, []int{2}, 1000, false)
+	tieB := pendingTx(funding, []int{3}, 1000, false)
+	low := pendingTx(funding, []int{4}, 1000, false)
+	parent := pendingTx(funding, []int{5}, 1000, false)
+	child := pendingTx(parent.ID, []int{0}, 900, false)
+	if hex.EncodeToString(tieB.ID) < hex.EncodeToString(tieA.ID) {
+		tieA, tieB = tieB, tieA
+	}
+
+	mp := NewMempool()
+	// Added in random order. The child pays the best rate but must wait for its parent,
+	// which pays little.
+	for tx, fee := range map[*Transaction]int{high: 900, mid: 500, tieA: 300, tieB: 300, low: 50, parent: 100, child: 5000} {
+		if err := mp.Add(tx, fee); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []*Transaction{high, mid, tieA, tieB, parent, child, low}
+	names := map[*Transaction]string{high: "high", mid: "mid", tieA: "tieA", tieB: "tieB", low: "low", parent: "parent", child: "child"}
+	got := mp.Collect(0, DefaultMaxBlockSize)
+	if len(got) != len(want) {
+		t.Fatalf("collected %d transactions, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			var order []string
+			for _, tx := range got {
+				order = append(order, names[tx])
+			}
+			t.Fatalf("collected %v", order)
+		}
+	}
+
+	// A count limit keeps the best of that order.
+	if got := mp.Collect(2, DefaultMaxBlockSize); len(got) != 2 || got[0] != high || got[1] != mid {
+		t.Fatal("Collect(2) did not take the two best ready transactions")
+	}
+}
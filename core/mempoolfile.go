@@ -0,0 +1,103 @@
+package core
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// MempoolFile returns the file a node saves its pending transactions to on shutdown.
+func MempoolFile(nodeID string) string {
+	if nodeID == "" {
+		nodeID = "3000"
+	}
+	return fmt.Sprintf("mempool_%s.dat", nodeID)
+}
+
+// savedMempool is the gob layout of a mempool file: serialized transactions in arrival
+// order. Fees are not saved; Load recomputes them from the chain.
+type savedMempool struct {
+	Txs [][]byte
+}
+
+// Save writes the pending transactions to path, replacing it atomically.
+func (mp *Mempool) Save(path string) error {
+	mp.mu.Lock()
+	entries := make([]mempoolEntry, 0, len(mp.txs))
+	for _, e := range mp.txs {
+		entries = append(entries, e)
+	}
+	mp.mu.Unlock()
+	sort.Slice(entries, func(i, j int) bool { return entries[i].seq < entries[j].seq })
+
+	var saved savedMempool
+	for _, e := range entries {
+		saved.Txs = append(saved.Txs, e.tx.Serialize())
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(saved); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, buf.Bytes())
+}
+
+// Load adds the transactions saved at path back to the pool, in their original order,
+// after checking each again against bc as a relayed one would be. It returns how many
+// were kept and how many dropped, e.g. because a block mined while the node was down
+// spent their inputs. A missing file loads nothing.
+func (mp *Mempool) Load(path string, bc *Blockchain) (kept, dropped int, err error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	var saved savedMempool
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&saved); err != nil {
+		return 0, 0, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for _, raw := range saved.Txs {
+		tx, err := DecodeTransaction(raw)
+		if err != nil {
+			dropped++
+			continue
+		}
+		fee, err := bc.CheckPendingTx(tx, mp)
+		if err == nil {
+			err = mp.Add(tx, fee)
+		}
+		if err != nil {
+			dropped++
+			continue
+		}
+		kept++
+	}
+	return kept, dropped, nil
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	// Once renamed, the temporary name no longer exists and Remove is a no-op.
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"path/filepath"
+	"testing"
+
+	"my-blockchain/wallet"
+)
+
+func TestMempoolReloadDropsSpentTransactions(t *testing.T) {
+	bc, ws, from := newWalletChain(t)
+	kept, err := ws.CreateWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	spent, err := ws.CreateWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	miner := string(wallet.NewWallet().GetAddress())
+	split, err := NewUTXOTransactionMulti(from, map[string]int{kept: 5, spent: 5}, bc, ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bc.AddBlock([]*Transaction{bc.config.CoinbaseTx(miner, "", 2), split}); err != nil {
+		t.Fatal(err)
+	}
+
+	mp := NewMempool()
+	var pending []*Transaction
+	for _, sender := range []string{kept, spent} {
+		tx, err := NewPendingUTXOTransaction(sender, miner, 5, TxOptions{}, bc, ws, mp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fee, err := bc.CheckPendingTx(tx, mp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := mp.Add(tx, fee); err != nil {
+			t.Fatal(err)
+		}
+		pending = append(pending, tx)
+	}
+	path := filepath.Join(t.TempDir(), MempoolFile("test"))
+	if err := mp.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// While the node is down, a block spends the second transaction's input another way.
+	conflict, err := NewUTXOTransaction(spent, from, 5, bc, ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bc.AddBlock([]*Transaction{bc.config.CoinbaseTx(miner, "", 3), conflict}); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := NewMempool()
+	k, d, err := reloaded.Load(path, bc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k != 1 || d != 1 || reloaded.Len() != 1 {
+		t.Fatalf("kept %d and dropped %d (%d pending), want 1 and 1", k, d, reloaded.Len())
+	}
+	if !reloaded.Has(pending[0].ID) || reloaded.Has(pending[1].ID) {
+		t.Fatal("reloaded the wrong transaction")
+	}
+
+	if k, d, err := NewMempool().Load(filepath.Join(t.TempDir(), "missing.dat"), bc); err != nil || k != 0 || d != 0 {
+		t.Fatalf("loading a missing file: kept %d, dropped %d, %v", k, d, err)
+	}
+}
@@ -1,9 +1,17 @@
 package core
 
-import "crypto/sha256"
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+)
 
 type MerkleTree struct {
 	RootNode *MerkleNode
+	// Mutated is set when two real sibling nodes are identical. Odd levels are padded by
+	// duplicating the last node, so e.g. [a b c] and [a b c c] share a root (CVE-2012-2459);
+	// a tree whose root depends on such a pair must not be trusted.
+	Mutated bool
 }
 
 type MerkleNode struct {
@@ -40,7 +48,13 @@ func NewMerkleTree(data [][]byte) *MerkleTree {
 		nodes = append(nodes, *node)
 	}
 
+	mutated := false
 	for len(nodes) > 1 {
+		for i := 0; i+1 < len(nodes); i += 2 {
+			if bytes.Equal(nodes[i].Data, nodes[i+1].Data) {
+				mutated = true
+			}
+		}
 		if len(nodes)%2 != 0 {
 			nodes = append(nodes, nodes[len(nodes)-1])
 		}
@@ -52,5 +66,50 @@ func NewMerkleTree(data [][]byte) *MerkleTree {
 		nodes = newLevel
 	}
 
-	return &MerkleTree{RootNode: &nodes[0]}
+	return &MerkleTree{RootNode: &nodes[0], Mutated: mutated}
+}
+
+// ErrNotInTree is returned by Proof for data that is not a leaf of the tree.
+var ErrNotInTree = errors.New("data is not a leaf of the merkle tree")
+
+// Proof returns the sibling hashes on the path from the leaf for data (e.g. a tx ID)
+// to the root, bottom-up. left[i] reports whether proof[i] is the left operand when
+// hashing level i.
+func (t *MerkleTree) Proof(data []byte) ([][]byte, []bool, error) {
+	leaf := sha256.Sum256(data)
+	proof, left, ok := t.RootNode.path(leaf[:])
+	if !ok {
+		return nil, nil, ErrNotInTree
+	}
+	return proof, left, nil
+}
+
+// path searches the subtree for the leaf hash and returns its proof (bottom-up).
+func (n *MerkleNode) path(leaf []byte) ([][]byte, []bool, bool) {
+	if n.Left == nil && n.Right == nil {
+		return nil, nil, bytes.Equal(n.Data, leaf)
+	}
+	if proof, left, ok := n.Left.path(leaf); ok {
+		return append(proof, n.Right.Data), append(left, false), true
+	}
+	if proof, left, ok := n.Right.path(leaf); ok {
+		return append(proof, n.Left.Data), append(left, true), true
+	}
+	return nil, nil, false
+}
+
+// VerifyMerkleProof reports whether proof and left (as returned by Proof) link data to root.
+func VerifyMerkleProof(root, data []byte, proof [][]byte, left []bool) bool {
+	if len(proof) != len(left) {
+		return false
+	}
+	hash := sha256.Sum256(data)
+	for i, sibling := range proof {
+		if left[i] {
+			hash = sha256.Sum256(append(append([]byte{}, sibling...), hash[:]...))
+		} else {
+			hash = sha256.Sum256(append(hash[:], sibling...))
+		}
+	}
+	return bytes.Equal(hash[:], root)
 }
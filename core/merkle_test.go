@@ -0,0 +1,96 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestDuplicatedLastLeafCollidesButIsFlagged(t *testing.T) {
+	a, b, c := []byte("a"), []byte("b"), []byte("c")
+	honest := NewMerkleTree([][]byte{a, b, c})
+	padded := NewMerkleTree([][]byte{a, b, c, c})
+	if !bytes.Equal(honest.RootNode.Data, padded.RootNode.Data) {
+		t.Fatal("[a b c] and [a b c c] no longer share a root; the test no longer demonstrates the collision")
+	}
+	if honest.Mutated {
+		t.Error("odd-length tree padded by the construction itself flagged as mutated")
+	}
+	if !padded.Mutated {
+		t.Error("tree with a duplicated real leaf not flagged as mutated")
+	}
+	if NewMerkleTree([][]byte{a, b, c, a}).Mutated {
+		t.Error("tree with a repeated but not adjacent leaf flagged as mutated")
+	}
+}
+
+func TestMutatedBlockRejectedWithoutPoisoningItsHash(t *testing.T) {
+	bc, w := newTestChain(t)
+	genesis := mustBlock(t, bc, bc.Tip())
+	first := payTo(t, bc, w, genesis.Transactions[0], 0, 10, string(w.GetAddress()))
+	second := spend(t, bc, w, first, 0, 10)
+	block := mineOn(t, bc, genesis, 2, first, second)
+
+	// Repeating the last transaction keeps the merkle root, so the header, its proof of
+	// work and the block hash are all unchanged.
+	mutated := *block
+	mutated.Transactions = append(append([]*Transaction{}, block.Transactions...), second)
+	if !bytes.Equal(mutated.merkleTree().RootNode.Data, block.MerkleRoot) {
+		t.Fatal("mutated block has a different merkle root")
+	}
+	if err := bc.PutBlock(mutated.Serialize()); !errors.Is(err, ErrMutatedMerkleTree) {
+		t.Fatalf("mutated block: got %v, want ErrMutatedMerkleTree", err)
+	}
+	if err := bc.PutBlock(block.Serialize()); err != nil {
+		t.Fatalf("honest block with the same hash after the mutated one: %v", err)
+	}
+	if !bytes.Equal(bc.Tip(), block.Hash) {
+		t.Fatalf("tip is %x, want the honest block", bc.Tip())
+	}
+}
+
+func TestMerkleProofVerifiesEveryLeaf(t *testing.T) {
+	for n := 1; n <= 7; n++ {
+		var leaves [][]byte
+		for i := 0; i < n; i++ {
+			leaves = append(leaves, []byte(fmt.Sprintf("tx%d", i)))
+		}
+		tree := NewMerkleTree(leaves)
+		root := tree.RootNode.Data
+		for i, leaf := range leaves {
+			proof, left, err := tree.Proof(leaf)
+			if err != nil {
+				t.Fatalf("%d leaves, leaf %d: %v", n, i, err)
+			}
+			if !VerifyMerkleProof(root, leaf, proof, left) {
+				t.Errorf("%d leaves: proof for leaf %d does not verify", n, i)
+			}
+			if VerifyMerkleProof(root, []byte("other"), proof, left) {
+				t.Errorf("%d leaves: proof for leaf %d verifies other data", n, i)
+			}
+		}
+	}
+}
+
+func TestMerkleProofRejectsNonMember(t *testing.T) {
+	leaves := [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+	tree := NewMerkleTree(leaves)
+	if _, _, err := tree.Proof([]byte("d")); !errors.Is(err, ErrNotInTree) {
+		t.Fatalf("proof for a non-member: got %v, want ErrNotInTree", err)
+	}
+
+	// A proof taken from another tree does not link the leaf to this root.
+	other := NewMerkleTree([][]byte{[]byte("a"), []byte("d")})
+	proof, left, err := other.Proof([]byte("d"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if VerifyMerkleProof(tree.RootNode.Data, []byte("d"), proof, left) {
+		t.Fatal("non-member verified against the root")
+	}
+	proof, left, _ = tree.Proof([]byte("c"))
+	if VerifyMerkleProof(tree.RootNode.Data, []byte("c"), proof, left[:len(left)-1]) {
+		t.Fatal("proof with mismatched flags verified")
+	}
+}
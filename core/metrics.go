@@ -0,0 +1,39 @@
+package core
+
+import "sync/atomic"
+
+// Counter counts events since the process started. It is safe for concurrent use.
+type Counter struct {
+	n atomic.Uint64
+}
+
+func (c *Counter) Inc() {
+	c.n.Add(1)
+}
+
+func (c *Counter) Add(n uint64) {
+	c.n.Add(n)
+}
+
+func (c *Counter) Value() uint64 {
+	return c.n.Load()
+}
+
+// Metrics are one chain's counters, exported by its node's /metrics endpoint. Each
+// Blockchain opened has its own, starting at zero.
+type Metrics struct {
+	// BlocksProcessed counts blocks stored, whether mined here or received, on any branch.
+	BlocksProcessed Counter
+	// TxsValid and TxsInvalid count VerifyTransaction calls by outcome.
+	TxsValid   Counter
+	TxsInvalid Counter
+	// PoWAttempts counts the nonces tried by proof of work mining on the chain.
+	PoWAttempts Counter
+	// DeepReorgs counts reorganizations refused for exceeding MaxReorgDepth.
+	DeepReorgs Counter
+}
+
+// Metrics returns bc's counters.
+func (bc *Blockchain) Metrics() *Metrics {
+	return bc.metrics
+}
@@ -0,0 +1,72 @@
+package core
+
+import (
+	"bytes"
+	"encoding/hex"
+)
+
+// maxOrphans caps how many parentless blocks are kept in memory at once.
+const maxOrphans = 100
+
+// AddOrphan holds a block whose parent is not stored yet. It reports whether the block
+// was kept; when the pool is full the block is dropped and must be fetched again.
+func (bc *Blockchain) AddOrphan(block *Block) bool {
+	bc.orphansMu.Lock()
+	defer bc.orphansMu.Unlock()
+
+	if bc.orphanCount >= maxOrphans {
+		return false
+	}
+	if bc.orphans == nil {
+		bc.orphans = make(map[string][]*Block)
+	}
+	key := hex.EncodeToString(block.PrevBlockHash)
+	for _, o := range bc.orphans[key] {
+		if bytes.Equal(o.Hash, block.Hash) {
+			return true
+		}
+	}
+	bc.orphans[key] = append(bc.orphans[key], block)
+	bc.orphanCount++
+	return true
+}
+
+// ConnectOrphans stores every orphan waiting on parent, then their own waiting children,
+// and so on. It returns how many blocks were connected.
+func (bc *Blockchain) ConnectOrphans(parent []byte) int {
+	connected := 0
+	queue := [][]byte{parent}
+	for len(queue) > 0 {
+		hash := queue[0]
+		queue = queue[1:]
+
+		for _, child := range bc.takeOrphans(hash) {
+			if err := bc.putBlock(child, child.Serialize()); err != nil {
+				bc.logger().Warn("dropped orphan block", "block", hex.EncodeToString(child.Hash), "err", err)
+				continue
+			}
+			connected++
+			queue = append(queue, child.Hash)
+		}
+	}
+	return connected
+}
+
+// OrphanCount returns the number of blocks waiting for their parent.
+func (bc *Blockchain) OrphanCount() int {
+	bc.orphansMu.Lock()
+	defer bc.orphansMu.Unlock()
+
+	return bc.orphanCount
+}
+
+func (bc *Blockchain) takeOrphans(parent []byte) []*Block {
+	bc.orphansMu.Lock()
+	defer bc.orphansMu.Unlock()
+
+	key := hex.EncodeToString(parent)
+	children := bc.orphans[key]
+	delete(bc.orphans, key)
+	bc.orphanCount -= len(children)
+	return children
+}
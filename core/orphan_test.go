@@ -0,0 +1,52 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestReversedBatchConnectsOnceGenesisArrives(t *testing.T) {
+	source, _ := newTestChain(t)
+	genesis := mustBlock(t, source, source.Tip())
+	b2 := mineOn(t, source, genesis, 2)
+	b3 := mineOn(t, source, b2, 3)
+	b4 := mineOn(t, source, b3, 4)
+
+	bc := newEmptyChain(t)
+	putAll(t, bc, b4, b3, b2)
+	if len(bc.Tip()) != 0 {
+		t.Fatalf("tip moved to %x before the genesis block arrived", bc.Tip())
+	}
+	if got := bc.OrphanCount(); got != 3 {
+		t.Fatalf("%d orphans held, want 3", got)
+	}
+
+	putAll(t, bc, genesis)
+	if !bytes.Equal(bc.Tip(), b4.Hash) || bc.BestHeight() != 4 {
+		t.Fatalf("tip %x at height %d, want %x at 4", bc.Tip(), bc.BestHeight(), b4.Hash)
+	}
+	if got := bc.OrphanCount(); got != 0 {
+		t.Fatalf("%d orphans left after connecting", got)
+	}
+}
+
+func TestConnectOrphansDirectly(t *testing.T) {
+	bc, _ := newTestChain(t)
+	genesis := mustBlock(t, bc, bc.Tip())
+	b2 := mineOn(t, bc, genesis, 2)
+	b3 := mineOn(t, bc, b2, 3)
+
+	if !bc.AddOrphan(b3) || !bc.AddOrphan(b3) {
+		t.Fatal("orphan not kept")
+	}
+	if got := bc.OrphanCount(); got != 1 {
+		t.Fatalf("adding the same orphan twice holds %d, want 1", got)
+	}
+	if got := bc.ConnectOrphans(genesis.Hash); got != 0 {
+		t.Fatalf("connected %d blocks to a parent nothing waits on", got)
+	}
+	putAll(t, bc, b2)
+	if !bytes.Equal(bc.Tip(), b3.Hash) {
+		t.Fatalf("tip %x, want %x", bc.Tip(), b3.Hash)
+	}
+}
@@ -2,38 +2,79 @@ package core
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
+	"errors"
+	"fmt"
 	"math"
 	"math/big"
+	"sync"
 )
 
+// Difficulty is the initial proof-of-work difficulty, in leading zero bits.
 const Difficulty = 16
 
+// Bounds on a block's difficulty in leading zero bits. Blocks come from peers, so their
+// bits are checked against these (see checkTargetBitsRange) before any target is derived.
+const (
+	minPowBits = 1
+	maxPowBits = 255
+)
+
+// checkTargetBitsRange rejects difficulties outside [minPowBits, maxPowBits].
+func checkTargetBitsRange(bits int) error {
+	if bits < minPowBits || bits > maxPowBits {
+		return fmt.Errorf("%w: %d, want %d to %d", ErrTargetBitsRange, bits, minPowBits, maxPowBits)
+	}
+	return nil
+}
+
 type ProofOfWork struct {
 	block  *Block
 	target *big.Int
+	// attempts, when set, counts the nonces tried.
+	attempts *Counter
 }
 
 func NewProofOfWork(b *Block) *ProofOfWork {
-	target := big.NewInt(1)
-	target.Lsh(target, uint(256-Difficulty))
+	return &ProofOfWork{block: b, target: powTarget(b.Bits())}
+}
 
-	return &ProofOfWork{block: b, target: target}
+// powTarget returns the value a hash must stay below for bits leading zero bits. Bits out
+// of range get a target of 0, which no hash meets.
+func powTarget(bits int) *big.Int {
+	if checkTargetBitsRange(bits) != nil {
+		return new(big.Int)
+	}
+	target := big.NewInt(1)
+	target.Lsh(target, uint(256-bits))
+	return target
 }
 
 func (pow *ProofOfWork) prepareData(nonce int) []byte {
+	return PowData(pow.block.PrevBlockHash, pow.block.MerkleRoot, pow.block.Timestamp, pow.block.Bits(), nonce)
+}
+
+// PowData returns the bytes whose SHA-256 is a block's hash. External miners hash this
+// for each nonce they try.
+func PowData(prevBlockHash, merkleRoot []byte, timestamp int64, bits, nonce int) []byte {
 	return bytes.Join(
 		[][]byte{
-			pow.block.PrevBlockHash,
-			pow.block.MerkleRoot,
-			IntToHex(pow.block.Timestamp),
-			IntToHex(int64(Difficulty)),
+			prevBlockHash,
+			merkleRoot,
+			IntToHex(timestamp),
+			IntToHex(int64(bits)),
 			IntToHex(int64(nonce)),
 		},
 		[]byte{},
 	)
 }
 
+// MeetsTarget reports whether hash is below the target for bits leading zero bits.
+func MeetsTarget(hash []byte, bits int) bool {
+	return new(big.Int).SetBytes(hash).Cmp(powTarget(bits)) == -1
+}
+
 func (pow *ProofOfWork) Run() (int, []byte) {
 	var hashInt big.Int
 	var hash [32]byte
@@ -49,14 +90,77 @@ func (pow *ProofOfWork) Run() (int, []byte) {
 		}
 		nonce++
 	}
+	pow.countAttempts(uint64(nonce) + 1)
 
 	return nonce, hash[:]
 }
 
+func (pow *ProofOfWork) countAttempts(n uint64) {
+	if pow.attempts != nil {
+		pow.attempts.Add(n)
+	}
+}
+
+// cancelCheckInterval is how many nonces a worker tries between checks for cancellation.
+const cancelCheckInterval = 1 << 12
+
+// RunParallel is Run split across workers goroutines: worker i tries nonces i, i+workers,
+// i+2*workers, ... All workers stop as soon as one finds a solution or ctx is cancelled,
+// in which case it returns ctx's error.
+func (pow *ProofOfWork) RunParallel(ctx context.Context, workers int) (int, []byte, error) {
+	if workers < 1 {
+		workers = 1
+	}
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	type solution struct {
+		nonce int
+		hash  []byte
+	}
+	found := make(chan solution, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(start int) {
+			defer wg.Done()
+			var hashInt big.Int
+			tries := 0
+			defer func() { pow.countAttempts(uint64(tries)) }()
+			for nonce := start; nonce >= 0; nonce += workers {
+				if tries%cancelCheckInterval == 0 && ctx.Err() != nil {
+					return
+				}
+				tries++
+				hash := sha256.Sum256(pow.prepareData(nonce))
+				hashInt.SetBytes(hash[:])
+				if hashInt.Cmp(pow.target) == -1 {
+					found <- solution{nonce, hash[:]}
+					stop()
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	select {
+	case s := <-found:
+		return s.nonce, s.hash, nil
+	default:
+		if err := ctx.Err(); err != nil {
+			return 0, nil, err
+		}
+		return 0, nil, errors.New("proof of work: nonce space exhausted")
+	}
+}
+
+// Validate reports whether the block's nonce meets its target and its stored Hash
+// is the hash the nonce actually produces.
 func (pow *ProofOfWork) Validate() bool {
 	var hashInt big.Int
 	data := pow.prepareData(pow.block.Nonce)
 	hash := sha256.Sum256(data)
 	hashInt.SetBytes(hash[:])
-	return hashInt.Cmp(pow.target) == -1
+	return hashInt.Cmp(pow.target) == -1 && bytes.Equal(hash[:], pow.block.Hash)
 }
@@ -0,0 +1,78 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"testing"
+	"time"
+
+	"my-blockchain/wallet"
+)
+
+func TestRunParallelFindsValidNonce(t *testing.T) {
+	coinbase := RegtestConfig.CoinbaseTx(string(wallet.NewWallet().GetAddress()), "", 1)
+	for _, workers := range []int{0, 1, 4} {
+		block := newBlockTemplate([]*Transaction{coinbase}, nil, 12)
+		nonce, hash, err := NewProofOfWork(block).RunParallel(context.Background(), workers)
+		if err != nil {
+			t.Fatalf("%d workers: %v", workers, err)
+		}
+		block.Nonce, block.Hash = nonce, hash
+		if !NewProofOfWork(block).Validate() {
+			t.Fatalf("%d workers: nonce %d does not solve the block", workers, nonce)
+		}
+	}
+}
+
+func TestRunParallelStopsOnCancel(t *testing.T) {
+	coinbase := RegtestConfig.CoinbaseTx(string(wallet.NewWallet().GetAddress()), "", 1)
+	// No hash has 255 leading zero bits, so only cancellation ends the search.
+	block := newBlockTemplate([]*Transaction{coinbase}, nil, 255)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := NewProofOfWork(block).RunParallel(ctx, 4)
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("got %v, want context.Canceled", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("workers still running 2s after cancellation")
+	}
+}
+
+func TestBlockWithOutOfRangeBitsRejected(t *testing.T) {
+	bc, _ := newTestChain(t)
+	genesis := mustBlock(t, bc, bc.Tip())
+	to := string(wallet.NewWallet().GetAddress())
+
+	// Deriving a target from these used to panic; no nonce can solve them, so any will do.
+	for _, bits := range []int{300, 256, -5} {
+		block := newBlockTemplate([]*Transaction{bc.config.CoinbaseTx(to, "", 2)}, genesis.Hash, bits)
+		block.Timestamp = genesis.Timestamp + 1
+		hash := sha256.Sum256(NewProofOfWork(block).prepareData(0))
+		block.Hash = hash[:]
+		if err := bc.config.CheckBlock(block); !errors.Is(err, ErrTargetBitsRange) {
+			t.Errorf("CheckBlock with %d bits: got %v, want ErrTargetBitsRange", bits, err)
+		}
+		if err := bc.PutBlock(block.Serialize()); !errors.Is(err, ErrTargetBitsRange) {
+			t.Errorf("PutBlock with %d bits: got %v, want ErrTargetBitsRange", bits, err)
+		}
+		if err := CheckHeaderChain([]BlockHeader{block.Header()}, genesis.Hash); !errors.Is(err, ErrTargetBitsRange) {
+			t.Errorf("header with %d bits: got %v, want ErrTargetBitsRange", bits, err)
+		}
+		if MeetsTarget(make([]byte, 32), bits) {
+			t.Errorf("a zero hash meets %d bits", bits)
+		}
+	}
+	if bc.BestHeight() != 1 {
+		t.Fatalf("height %d after rejecting every block", bc.BestHeight())
+	}
+}
@@ -0,0 +1,138 @@
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"go.etcd.io/bbolt"
+)
+
+// pruneHeightKey stores, in the config bucket, the height of the highest pruned block.
+const pruneHeightKey = "pruneheight"
+
+// MinPruneDepth is the fewest most recent blocks Prune keeps whole, so ordinary reorgs
+// always find the block bodies and undo records they need.
+const MinPruneDepth = 10
+
+// ErrBlockPruned is returned when a block's transactions are needed but were pruned.
+var ErrBlockPruned = errors.New("block body was pruned")
+
+// Prune discards the transactions and undo records of main-chain blocks more than depth
+// blocks below the tip, keeping their headers. Spending the outputs they created still
+// works through the chainstate, but a reorg that would disconnect a pruned block is
+// refused and the UTXO set can no longer be rebuilt. It returns how many blocks it pruned.
+func (bc *Blockchain) Prune(depth int) (int, error) {
+	if depth < MinPruneDepth {
+		return 0, fmt.Errorf("prune depth must be at least %d", MinPruneDepth)
+	}
+	// Hold off blocks arriving from peers, so no reorg is reading the bodies we delete.
+	bc.putMu.Lock()
+	defer bc.putMu.Unlock()
+
+	hashes := bc.GetBlockHashes()
+	keep := len(hashes) - depth
+	if keep <= 0 {
+		return 0, nil
+	}
+	pruned := 0
+	err := bc.db.Update(func(tx *bbolt.Tx) error {
+		from := pruneHeight(tx)
+		if from >= keep {
+			return nil
+		}
+		blocks := tx.Bucket([]byte(blocksBucket))
+		undo := tx.Bucket([]byte(blockUndoBucket))
+		for _, hash := range hashes[from:keep] {
+			block := DeserializeBlock(blocks.Get(hash))
+			if block.Pruned {
+				continue
+			}
+			block.Transactions = nil
+			block.Pruned = true
+			if err := blocks.Put(hash, block.Serialize()); err != nil {
+				return err
+			}
+			if undo != nil {
+				if err := undo.Delete(hash); err != nil {
+					return err
+				}
+			}
+			pruned++
+		}
+		return putPruneHeight(tx, keep)
+	})
+	if pruned > 0 {
+		bc.blocks.reset()
+	}
+	return pruned, err
+}
+
+// PruneHeight returns the height of the highest pruned block, or 0 if nothing was pruned.
+func (bc *Blockchain) PruneHeight() int {
+	height := 0
+	_ = bc.db.View(func(tx *bbolt.Tx) error {
+		height = pruneHeight(tx)
+		return nil
+	})
+	return height
+}
+
+func pruneHeight(tx *bbolt.Tx) int {
+	b := tx.Bucket([]byte(configBucket))
+	if b == nil {
+		return 0
+	}
+	data := b.Get([]byte(pruneHeightKey))
+	if len(data) != 4 {
+		return 0
+	}
+	return int(binary.BigEndian.Uint32(data))
+}
+
+func putPruneHeight(tx *bbolt.Tx, height int) error {
+	b, err := tx.CreateBucketIfNotExists([]byte(configBucket))
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(pruneHeightKey), binary.BigEndian.AppendUint32(nil, uint32(height)))
+}
+
+// prunedTransaction stands in for a transaction whose block was pruned, rebuilt from its
+// unspent outputs in the chainstate. Spent outputs are left zero, so they cannot verify.
+// Only outputs created at or below the prune height are considered: anything newer is
+// still in a block, and the chainstate follows the main chain, which may not be the
+// branch being checked.
+func (bc *Blockchain) prunedTransaction(ID []byte) (Transaction, int, error) {
+	var outs TxOutputs
+	found := false
+	err := bc.db.View(func(tx *bbolt.Tx) error {
+		limit := pruneHeight(tx)
+		b := tx.Bucket([]byte(utxoBucket))
+		if limit == 0 || b == nil {
+			return nil
+		}
+		if data := b.Get(ID); data != nil {
+			outs = DeserializeOutputs(data)
+			found = outs.Height <= limit
+		}
+		return nil
+	})
+	if err != nil || !found {
+		return Transaction{}, 0, ErrTransactionNotFound
+	}
+
+	n := 0
+	for i := range outs.Outputs {
+		n = max(n, i+1)
+	}
+	prevTx := Transaction{ID: ID, Vout: make([]TxOutput, n)}
+	for i, out := range outs.Outputs {
+		prevTx.Vout[i] = out
+	}
+	if outs.Coinbase {
+		// Shaped like a coinbase input so IsCoinbase holds for maturity checks.
+		prevTx.Vin = []TxInput{{Vout: -1}}
+	}
+	return prevTx, outs.Height, nil
+}
@@ -0,0 +1,92 @@
+package core
+
+import (
+	"errors"
+	"testing"
+
+	"my-blockchain/wallet"
+)
+
+// balance sums the unspent outputs locked to address.
+func balance(bc *Blockchain, address string) int {
+	total := 0
+	for _, out := range (UTXOSet{Blockchain: bc}).FindUTXO(wallet.PubKeyHashFromAddress(address)) {
+		total += out.Value
+	}
+	return total
+}
+
+func TestPrunedChainKeepsBalancesAndSpends(t *testing.T) {
+	bc, ws, from := newWalletChain(t)
+	genesisHash := bc.Tip()
+	to := string(wallet.NewWallet().GetAddress())
+	miner := string(wallet.NewWallet().GetAddress())
+
+	payment, err := NewUTXOTransaction(from, to, 4, bc, ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bc.AddBlock([]*Transaction{bc.config.CoinbaseTx(miner, "", 2), payment}); err != nil {
+		t.Fatal(err)
+	}
+	for height := 3; height <= 14; height++ {
+		if _, err := bc.AddBlock([]*Transaction{bc.config.CoinbaseTx(miner, "", height)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := bc.Prune(MinPruneDepth - 1); err == nil {
+		t.Fatalf("pruned to a depth below %d", MinPruneDepth)
+	}
+	pruned, err := bc.Prune(MinPruneDepth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pruned != 4 || bc.PruneHeight() != 4 {
+		t.Fatalf("pruned %d blocks up to height %d, want 4 up to 4", pruned, bc.PruneHeight())
+	}
+	if again, err := bc.Prune(MinPruneDepth); err != nil || again != 0 {
+		t.Fatalf("pruning again: %d, %v", again, err)
+	}
+
+	if got := balance(bc, from); got != 6 {
+		t.Errorf("sender balance after pruning %d, want 6", got)
+	}
+	if got := balance(bc, to); got != 4 {
+		t.Errorf("recipient balance after pruning %d, want 4", got)
+	}
+
+	genesis := mustBlock(t, bc, genesisHash)
+	if !genesis.Pruned || len(genesis.Transactions) != 0 {
+		t.Fatal("genesis block body still stored")
+	}
+	if _, err := bc.GetBlock(genesisHash); !errors.Is(err, ErrBlockPruned) {
+		t.Fatalf("reading the pruned genesis block: got %v, want ErrBlockPruned", err)
+	}
+	if _, _, err := bc.FindTransactionBlock(payment.ID); !errors.Is(err, ErrTransactionNotFound) {
+		t.Fatalf("finding the block of a pruned transaction: got %v, want ErrTransactionNotFound", err)
+	}
+	// FindTransaction rebuilds a pruned transaction from its unspent outputs.
+	rebuilt, err := bc.FindTransaction(payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rebuilt.Vin) != 0 || len(rebuilt.Vout) != len(payment.Vout) || rebuilt.OutputValue() != payment.OutputValue() {
+		t.Fatalf("rebuilt pruned transaction %v, want the outputs of %v", &rebuilt, payment)
+	}
+	if err := (UTXOSet{Blockchain: bc}).Reindex(); !errors.Is(err, ErrBlockPruned) {
+		t.Fatalf("reindexing a pruned chain: got %v, want ErrBlockPruned", err)
+	}
+
+	// The change from the pruned block is still spendable.
+	change, err := NewUTXOTransaction(from, to, 6, bc, ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bc.AddBlock([]*Transaction{bc.config.CoinbaseTx(miner, "", 15), change}); err != nil {
+		t.Fatalf("spending an output of a pruned block: %v", err)
+	}
+	if got := balance(bc, to); got != 10 {
+		t.Errorf("recipient balance %d, want 10", got)
+	}
+}
@@ -0,0 +1,6 @@
+//go:build race
+
+package core
+
+// raceEnabled is set when tests run under the race detector, which slows them severalfold.
+const raceEnabled = true
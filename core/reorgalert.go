@@ -0,0 +1,48 @@
+package core
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MaxReorgDepth is the most main-chain blocks a reorganization may disconnect. An honest
+// fork is resolved within a few blocks, so a deeper one more likely means an attack or a
+// bug: it is refused and raises a ReorgAlert. 0 lifts the limit.
+var MaxReorgDepth = 100
+
+var ErrReorgTooDeep = errors.New("reorg exceeds maximum depth")
+
+// ReorgAlert describes the last reorganization refused for being too deep.
+type ReorgAlert struct {
+	Time       int64 // Unix seconds
+	From       []byte
+	To         []byte
+	ForkHeight int
+	// Depth is how many main-chain blocks the reorg would have disconnected.
+	Depth int
+}
+
+// LastReorgAlert returns the most recent refused deep reorg, if there has been one since
+// the chain was opened.
+func (bc *Blockchain) LastReorgAlert() (ReorgAlert, bool) {
+	alert := bc.reorgAlert.Load()
+	if alert == nil {
+		return ReorgAlert{}, false
+	}
+	return *alert, true
+}
+
+// checkReorgDepth refuses a reorg to newTip that would disconnect depth blocks above
+// forkHeight, if that is more than MaxReorgDepth, and raises the alert.
+func (bc *Blockchain) checkReorgDepth(newTip []byte, forkHeight, depth int) error {
+	if MaxReorgDepth == 0 || depth <= MaxReorgDepth {
+		return nil
+	}
+	alert := &ReorgAlert{Time: time.Now().Unix(), From: bc.Tip(), To: newTip, ForkHeight: forkHeight, Depth: depth}
+	bc.reorgAlert.Store(alert)
+	bc.metrics.DeepReorgs.Inc()
+	bc.logger().Error("ALERT: deep reorg refused", "from", hex.EncodeToString(alert.From), "to", hex.EncodeToString(newTip), "fork_height", forkHeight, "depth", depth, "max", MaxReorgDepth)
+	return fmt.Errorf("reorg to %x rejected, it would disconnect %d blocks: %w of %d", newTip, depth, ErrReorgTooDeep, MaxReorgDepth)
+}
@@ -0,0 +1,65 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestReorgDepthLimit(t *testing.T) {
+	saved := MaxReorgDepth
+	MaxReorgDepth = 2
+	t.Cleanup(func() { MaxReorgDepth = saved })
+	bc, _ := newTestChain(t)
+	var logged bytes.Buffer
+	bc.SetLogger(NewLogger(&logged, slog.LevelInfo))
+	genesis := mustBlock(t, bc, bc.Tip())
+
+	m2 := mineOn(t, bc, genesis, 2)
+	m3 := mineOn(t, bc, m2, 3)
+	m4 := mineOn(t, bc, m3, 4)
+	putAll(t, bc, m2, m3, m4)
+
+	// Within the limit: a branch from m2 disconnects m3 and m4.
+	f3 := mineOn(t, bc, m2, 3)
+	f4 := mineOn(t, bc, f3, 4)
+	f5 := mineOn(t, bc, f4, 5)
+	putAll(t, bc, f3, f4, f5)
+	if !bytes.Equal(bc.Tip(), f5.Hash) {
+		t.Fatalf("tip %x, want the longer branch %x", bc.Tip(), f5.Hash)
+	}
+	if _, ok := bc.LastReorgAlert(); ok {
+		t.Fatal("alert raised for a reorg within the limit")
+	}
+
+	// Beyond it: a branch from genesis would disconnect m2, f3, f4 and f5.
+	refused := bc.Metrics().DeepReorgs.Value()
+	deep := []*Block{genesis}
+	for height := 2; height <= 6; height++ {
+		deep = append(deep, mineOn(t, bc, deep[len(deep)-1], height))
+	}
+	var err error
+	for _, b := range deep[1:] {
+		if err = bc.PutBlock(b.Serialize()); err != nil {
+			break
+		}
+	}
+	if !errors.Is(err, ErrReorgTooDeep) {
+		t.Fatalf("deep reorg: got %v, want ErrReorgTooDeep", err)
+	}
+	if !bytes.Equal(bc.Tip(), f5.Hash) {
+		t.Fatalf("tip moved to %x", bc.Tip())
+	}
+	alert, ok := bc.LastReorgAlert()
+	if !ok || alert.Depth != 4 || alert.ForkHeight != 1 || !bytes.Equal(alert.From, f5.Hash) || !bytes.Equal(alert.To, deep[5].Hash) {
+		t.Fatalf("alert %+v (%v), want depth 4 from %x to %x at fork height 1", alert, ok, f5.Hash, deep[5].Hash)
+	}
+	if bc.Metrics().DeepReorgs.Value() != refused+1 {
+		t.Fatalf("deep reorg counter moved by %d, want 1", bc.Metrics().DeepReorgs.Value()-refused)
+	}
+	if !strings.Contains(logged.String(), "level=ERROR msg=\"ALERT: deep reorg refused\"") {
+		t.Fatalf("no alert logged: %s", logged.String())
+	}
+}
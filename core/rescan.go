@@ -0,0 +1,66 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// RescanResult reports a walk of the main chain made by Rescan.
+type RescanResult struct {
+	// StartHeight and StopHeight are the first and last block scanned.
+	StartHeight int
+	StopHeight  int
+	// Transactions counts the transactions paying to or spending from the addresses.
+	Transactions int
+	// Pruned counts the blocks whose transactions were no longer stored.
+	Pruned int
+}
+
+// Rescan walks the main-chain blocks from height start to stop (the tip when 0) and counts
+// the transactions paying to or spending from any of pubKeyHashes. Balances and histories
+// never need it: the chainstate and address index cover every address, including those a
+// wallet imports later. It confirms what the chain holds for them.
+func (bc *Blockchain) Rescan(pubKeyHashes [][]byte, start, stop int) (RescanResult, error) {
+	best := bc.BestHeight()
+	if stop == 0 {
+		stop = best
+	}
+	if start < 1 || start > stop || stop > best {
+		return RescanResult{}, fmt.Errorf("%w: %d to %d (the chain has %d blocks)", ErrHeightOutOfRange, start, stop, best)
+	}
+
+	res := RescanResult{StartHeight: start, StopHeight: stop}
+	for height := start; height <= stop; height++ {
+		hash, err := bc.GetBlockHash(height)
+		if err != nil {
+			return res, err
+		}
+		data, err := bc.GetBlock(hash)
+		if err != nil {
+			return res, err
+		}
+		block := DeserializeBlock(data)
+		if block.Pruned {
+			res.Pruned++
+			continue
+		}
+		for _, tx := range block.Transactions {
+			if touchesAny(tx, pubKeyHashes) {
+				res.Transactions++
+			}
+		}
+	}
+	return res, nil
+}
+
+// touchesAny reports whether tx pays to or spends from any of pubKeyHashes.
+func touchesAny(tx *Transaction, pubKeyHashes [][]byte) bool {
+	for _, h := range txAddresses(tx) {
+		for _, want := range pubKeyHashes {
+			if bytes.Equal(h, want) {
+				return true
+			}
+		}
+	}
+	return false
+}
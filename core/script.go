@@ -0,0 +1,328 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"my-blockchain/wallet"
+)
+
+// Opcode is one instruction of the small stack language outputs are locked with. Bytes
+// 0x01-0x4b are not opcodes: like in Bitcoin, they push that many following bytes.
+type Opcode byte
+
+const (
+	// OpFalse pushes an empty item, which counts as false.
+	OpFalse Opcode = 0x00
+	// OpPushData1 and OpPushData2 push data whose length follows in 1 or 2 (little-endian) bytes.
+	OpPushData1 Opcode = 0x4c
+	OpPushData2 Opcode = 0x4d
+	// OpTrue pushes 1.
+	OpTrue Opcode = 0x51
+	// OpVerify fails the script unless the top item, which it pops, is true.
+	OpVerify Opcode = 0x69
+	// OpReturn fails the script at once: the output can never be spent.
+	OpReturn Opcode = 0x6a
+	// OpDup duplicates the top item.
+	OpDup Opcode = 0x76
+	// OpEqual pops two items and pushes whether they are equal; OpEqualVerify fails unless they are.
+	OpEqual       Opcode = 0x87
+	OpEqualVerify Opcode = 0x88
+	// OpHash160 replaces the top item with its SHA-256 then RIPEMD-160 hash.
+	OpHash160 Opcode = 0xa9
+	// OpCheckSig pops a public key and a signature and pushes whether the signature is
+	// valid for the input being spent.
+	OpCheckSig Opcode = 0xac
+	// OpCheckMultisig pops a multisig script (see wallet.NewMultisigScript), a count k and
+	// k signature/public key pairs, and pushes whether at least m signatures are valid,
+	// each by a distinct key the script lists. Empty signatures are skipped.
+	OpCheckMultisig Opcode = 0xae
+)
+
+// maxScriptPush is the largest item a script can push (with OpPushData2).
+const maxScriptPush = 0xffff
+
+var (
+	// ErrScriptFailed is returned by EvalScript when a script does not end with true on the stack.
+	ErrScriptFailed = errors.New("script failed")
+	// ErrPushTooLarge is returned for a transaction field that would be pushed onto the
+	// script stack but is larger than maxScriptPush.
+	ErrPushTooLarge = errors.New("script push too large")
+)
+
+// Script is a sequence of opcodes and pushes. Outputs still store their lock as a
+// ScriptType with PubKeyHash or Data; ScriptPubKey and ScriptSig turn those into scripts,
+// so a new output type is a new case there and, if needed, a new opcode.
+type Script []byte
+
+// Op appends the opcode op.
+func (s Script) Op(op Opcode) Script {
+	return append(s, byte(op))
+}
+
+// Push appends a push of data, in the shortest encoding. It panics for more than
+// maxScriptPush bytes: fields from peers are checked with checkPushes first.
+func (s Script) Push(data []byte) Script {
+	switch n := len(data); {
+	case n == 0:
+		return append(s, byte(OpFalse))
+	case n < int(OpPushData1):
+		s = append(s, byte(n))
+	case n <= 0xff:
+		s = append(s, byte(OpPushData1), byte(n))
+	default:
+		if n > maxScriptPush {
+			panic(fmt.Sprintf("script push of %d bytes", n))
+		}
+		s = append(s, byte(OpPushData2), byte(n), byte(n>>8))
+	}
+	return append(s, data...)
+}
+
+// pushInt appends a push of n as a 4-byte big-endian number.
+func (s Script) pushInt(n int) Script {
+	return s.Push(binary.BigEndian.AppendUint32(nil, uint32(n)))
+}
+
+// ScriptPubKey returns the script locking out. Pay-to-pubkey-hash outputs (and, as before,
+// outputs of an unknown type) lock to a key hash; multisig outputs to a multisig script hash;
+// data outputs can never be spent.
+func (out *TxOutput) ScriptPubKey() Script {
+	switch out.ScriptType {
+	case ScriptData:
+		return Script{}.Op(OpReturn).Push(out.Data)
+	case ScriptMultisig:
+		return Script{}.Op(OpDup).Op(OpHash160).Push(out.PubKeyHash).Op(OpEqualVerify).Op(OpCheckMultisig)
+	default:
+		return Script{}.Op(OpDup).Op(OpHash160).Push(out.PubKeyHash).Op(OpEqualVerify).Op(OpCheckSig)
+	}
+}
+
+// ScriptSig returns the script unlocking the output in spends: the signature and public
+// key, or for a multisig input each signature with its key, their count and the multisig
+// script.
+func (in *TxInput) ScriptSig() Script {
+	if len(in.PubKeys) == 0 && len(in.Signatures) == 0 {
+		return Script{}.Push(in.Signature).Push(in.PubKey)
+	}
+	var s Script
+	pairs := max(len(in.Signatures), len(in.PubKeys))
+	for i := 0; i < pairs; i++ {
+		var sig, pub []byte
+		if i < len(in.Signatures) {
+			sig = in.Signatures[i]
+		}
+		if i < len(in.PubKeys) {
+			pub = in.PubKeys[i]
+		}
+		s = s.Push(sig).Push(pub)
+	}
+	return s.pushInt(pairs).Push(in.PubKey)
+}
+
+// checkPushes returns ErrPushTooLarge if an item ScriptSig would push is too large.
+func (in *TxInput) checkPushes() error {
+	items := append([][]byte{in.Signature, in.PubKey}, in.Signatures...)
+	for _, item := range append(items, in.PubKeys...) {
+		if len(item) > maxScriptPush {
+			return fmt.Errorf("%w: %d bytes in the unlocking script", ErrPushTooLarge, len(item))
+		}
+	}
+	return nil
+}
+
+// checkPushes returns ErrPushTooLarge if an item ScriptPubKey would push is too large.
+func (out *TxOutput) checkPushes() error {
+	if n := max(len(out.PubKeyHash), len(out.Data)); n > maxScriptPush {
+		return fmt.Errorf("%w: %d bytes in the locking script", ErrPushTooLarge, n)
+	}
+	return nil
+}
+
+// EvalScript runs scriptSig and then scriptPubKey on one stack and succeeds when the top
+// item is true at the end. checkSig decides OpCheckSig and OpCheckMultisig for each
+// signature and public key; nil fails every signature.
+func EvalScript(scriptSig, scriptPubKey Script, checkSig func(sig, pubKey []byte) bool) error {
+	if checkSig == nil {
+		checkSig = func([]byte, []byte) bool { return false }
+	}
+	var stack [][]byte
+	for _, script := range []Script{scriptSig, scriptPubKey} {
+		var err error
+		if stack, err = script.run(stack, checkSig); err != nil {
+			return err
+		}
+	}
+	if len(stack) == 0 || !scriptBool(stack[len(stack)-1]) {
+		return fmt.Errorf("%w: false at the end", ErrScriptFailed)
+	}
+	return nil
+}
+
+// run executes s on stack and returns the resulting stack.
+func (s Script) run(stack [][]byte, checkSig func(sig, pubKey []byte) bool) ([][]byte, error) {
+	pop := func() ([]byte, error) {
+		if len(stack) == 0 {
+			return nil, fmt.Errorf("%w: stack empty", ErrScriptFailed)
+		}
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		return top, nil
+	}
+	push := func(b bool) {
+		if b {
+			stack = append(stack, []byte{1})
+		} else {
+			stack = append(stack, nil)
+		}
+	}
+
+	for pc := 0; pc < len(s); {
+		op := Opcode(s[pc])
+		pc++
+		if op <= OpPushData2 {
+			n := int(op)
+			switch op {
+			case OpPushData1:
+				if pc+1 > len(s) {
+					return nil, fmt.Errorf("%w: truncated push", ErrScriptFailed)
+				}
+				n = int(s[pc])
+				pc++
+			case OpPushData2:
+				if pc+2 > len(s) {
+					return nil, fmt.Errorf("%w: truncated push", ErrScriptFailed)
+				}
+				n = int(binary.LittleEndian.Uint16(s[pc:]))
+				pc += 2
+			}
+			if pc+n > len(s) {
+				return nil, fmt.Errorf("%w: truncated push", ErrScriptFailed)
+			}
+			stack = append(stack, s[pc:pc+n])
+			pc += n
+			continue
+		}
+
+		switch op {
+		case OpTrue:
+			push(true)
+		case OpReturn:
+			return nil, fmt.Errorf("%w: OP_RETURN", ErrScriptFailed)
+		case OpVerify:
+			top, err := pop()
+			if err != nil {
+				return nil, err
+			}
+			if !scriptBool(top) {
+				return nil, fmt.Errorf("%w: OP_VERIFY", ErrScriptFailed)
+			}
+		case OpDup:
+			top, err := pop()
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, top, top)
+		case OpEqual, OpEqualVerify:
+			a, err := pop()
+			if err != nil {
+				return nil, err
+			}
+			b, err := pop()
+			if err != nil {
+				return nil, err
+			}
+			if op == OpEqual {
+				push(bytes.Equal(a, b))
+			} else if !bytes.Equal(a, b) {
+				return nil, fmt.Errorf("%w: OP_EQUALVERIFY", ErrScriptFailed)
+			}
+		case OpHash160:
+			top, err := pop()
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, wallet.HashPubKey(top))
+		case OpCheckSig:
+			pub, err := pop()
+			if err != nil {
+				return nil, err
+			}
+			sig, err := pop()
+			if err != nil {
+				return nil, err
+			}
+			push(checkSig(sig, pub))
+		case OpCheckMultisig:
+			ok, err := checkMultisig(pop, checkSig)
+			if err != nil {
+				return nil, err
+			}
+			push(ok)
+		default:
+			return nil, fmt.Errorf("%w: unknown opcode %#x", ErrScriptFailed, byte(op))
+		}
+	}
+	return stack, nil
+}
+
+// checkMultisig does OpCheckMultisig's work, taking its operands with pop.
+func checkMultisig(pop func() ([]byte, error), checkSig func(sig, pubKey []byte) bool) (bool, error) {
+	script, err := pop()
+	if err != nil {
+		return false, err
+	}
+	m, pubKeyHashes, err := wallet.ParseMultisigScript(script)
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", ErrScriptFailed, err)
+	}
+	count, err := pop()
+	if err != nil {
+		return false, err
+	}
+	if len(count) != 4 {
+		return false, fmt.Errorf("%w: bad multisig count", ErrScriptFailed)
+	}
+
+	used := make([]bool, len(pubKeyHashes))
+	valid := 0
+	for k := binary.BigEndian.Uint32(count); k > 0; k-- {
+		pub, err := pop()
+		if err != nil {
+			return false, err
+		}
+		sig, err := pop()
+		if err != nil {
+			return false, err
+		}
+		if len(sig) == 0 {
+			continue
+		}
+		pkh := wallet.HashPubKey(pub)
+		slot := -1
+		for j, candidate := range pubKeyHashes {
+			if !used[j] && bytes.Equal(candidate, pkh) {
+				slot = j
+				break
+			}
+		}
+		if slot < 0 || !checkSig(sig, pub) {
+			return false, nil
+		}
+		used[slot] = true
+		valid++
+	}
+	return valid >= m, nil
+}
+
+// scriptBool reports whether a stack item counts as true: any non-zero byte.
+func scriptBool(item []byte) bool {
+	for _, b := range item {
+		if b != 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package core
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"my-blockchain/wallet"
+)
+
+func TestOversizedSignatureRejected(t *testing.T) {
+	bc, w := newTestChain(t)
+	coinbase := mustBlock(t, bc, bc.Tip()).Transactions[0]
+
+	tx := spend(t, bc, w, coinbase, 0, 10)
+	tx.Vin[0].Signature = make([]byte, maxScriptPush+1)
+	tx.ID = tx.Hash()
+	if _, err := bc.CheckPendingTx(tx, nil); !errors.Is(err, ErrPushTooLarge) {
+		t.Fatalf("70000-byte signature: got %v, want ErrPushTooLarge", err)
+	}
+	prevTXs := map[string]Transaction{hex.EncodeToString(coinbase.ID): *coinbase}
+	if tx.Verify(prevTXs) {
+		t.Fatal("Verify accepted an oversized signature")
+	}
+}
+
+func TestOversizedMultisigPubKeyRejected(t *testing.T) {
+	bc, w := newTestChain(t)
+	coinbase := mustBlock(t, bc, bc.Tip()).Transactions[0]
+
+	tx := spend(t, bc, w, coinbase, 0, 10)
+	tx.Vin[0].PubKeys = [][]byte{make([]byte, 70000)}
+	tx.ID = tx.Hash()
+	if _, err := bc.CheckPendingTx(tx, nil); !errors.Is(err, ErrPushTooLarge) {
+		t.Fatalf("70000-byte multisig key: got %v, want ErrPushTooLarge", err)
+	}
+}
+
+func TestEvalP2PKHScript(t *testing.T) {
+	w := wallet.NewWallet()
+	out := NewTxOutput(10, string(w.GetAddress()))
+	sig := []byte("signature")
+	checkSig := func(gotSig, pubKey []byte) bool {
+		return bytes.Equal(gotSig, sig) && bytes.Equal(pubKey, w.PubKey())
+	}
+
+	in := TxInput{Signature: sig, PubKey: w.PubKey()}
+	if err := EvalScript(in.ScriptSig(), out.ScriptPubKey(), checkSig); err != nil {
+		t.Fatalf("valid spend: %v", err)
+	}
+	tests := map[string]TxInput{
+		"wrong signature": {Signature: []byte("forged"), PubKey: w.PubKey()},
+		"other key":       {Signature: sig, PubKey: wallet.NewWallet().PubKey()},
+		"no signature":    {PubKey: w.PubKey()},
+	}
+	for name, in := range tests {
+		if err := EvalScript(in.ScriptSig(), out.ScriptPubKey(), checkSig); !errors.Is(err, ErrScriptFailed) {
+			t.Errorf("%s: got %v, want ErrScriptFailed", name, err)
+		}
+	}
+}
+
+func TestEvalAlwaysAndNeverTrueScripts(t *testing.T) {
+	always := Script{}.Op(OpTrue)
+	if err := EvalScript(nil, always, nil); err != nil {
+		t.Fatalf("OP_TRUE: %v", err)
+	}
+	for name, never := range map[string]Script{
+		"OP_FALSE":           Script{}.Op(OpFalse),
+		"OP_RETURN":          Script{}.Op(OpTrue).Op(OpReturn),
+		"empty":              nil,
+		"OP_FALSE OP_VERIFY": Script{}.Op(OpTrue).Op(OpFalse).Op(OpVerify),
+	} {
+		if err := EvalScript(nil, never, nil); !errors.Is(err, ErrScriptFailed) {
+			t.Errorf("%s: got %v, want ErrScriptFailed", name, err)
+		}
+	}
+	data, err := NewDataOutput([]byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := EvalScript(Script{}.Op(OpTrue), data.ScriptPubKey(), nil); !errors.Is(err, ErrScriptFailed) {
+		t.Errorf("spending a data output: got %v, want ErrScriptFailed", err)
+	}
+}
+
+func TestTwoOfThreeMultisigSpend(t *testing.T) {
+	bc, w := newTestChain(t)
+	genesis := mustBlock(t, bc, bc.Tip())
+	keys := []*wallet.Wallet{wallet.NewWallet(), wallet.NewWallet(), wallet.NewWallet()}
+	var pubKeys, hashes [][]byte
+	for _, k := range keys {
+		pubKeys = append(pubKeys, k.PubKey())
+		hashes = append(hashes, wallet.HashPubKey(k.PubKey()))
+	}
+	script, err := wallet.NewMultisigScript(2, hashes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fund := payTo(t, bc, w, genesis.Transactions[0], 0, 10, wallet.MultisigAddress(script))
+	putAll(t, bc, mineOn(t, bc, genesis, 2, fund))
+	if got := balance(bc, wallet.MultisigAddress(script)); got != 10 {
+		t.Fatalf("multisig address holds %d, want 10", got)
+	}
+
+	// spendSignedBy spends the multisig output, signed by the keys at the given indexes.
+	spendSignedBy := func(signers ...int) *Transaction {
+		t.Helper()
+		tx := &Transaction{
+			Vin:  []TxInput{{Txid: fund.ID, Vout: 0, PubKey: script, PubKeys: pubKeys}},
+			Vout: []TxOutput{*NewTxOutput(10, string(wallet.NewWallet().GetAddress()))},
+		}
+		tx.ID = tx.Hash()
+		for _, i := range signers {
+			if err := bc.SignTransaction(tx, keys[i].PrivateECDSA()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		tx.ID = tx.Hash()
+		return tx
+	}
+
+	if _, err := bc.CheckPendingTx(spendSignedBy(0), NewMempool()); !errors.Is(err, ErrInvalidTransaction) {
+		t.Fatalf("one signature of two: got %v, want ErrInvalidTransaction", err)
+	}
+	// The first key's signature offered twice, as if from two keys.
+	repeated := spendSignedBy(0)
+	repeated.Vin[0].PubKeys = [][]byte{pubKeys[0], pubKeys[0], pubKeys[2]}
+	repeated.Vin[0].Signatures = [][]byte{repeated.Vin[0].Signatures[0], repeated.Vin[0].Signatures[0], nil}
+	repeated.ID = repeated.Hash()
+	if _, err := bc.CheckPendingTx(repeated, NewMempool()); !errors.Is(err, ErrInvalidTransaction) {
+		t.Fatalf("one signature repeated: got %v, want ErrInvalidTransaction", err)
+	}
+
+	tx := spendSignedBy(0, 2)
+	if _, err := bc.CheckPendingTx(tx, NewMempool()); err != nil {
+		t.Fatalf("two signatures of three: %v", err)
+	}
+	putAll(t, bc, mineOn(t, bc, mustBlock(t, bc, bc.Tip()), 3, tx))
+	if balance(bc, wallet.MultisigAddress(script)) != 0 {
+		t.Fatal("the multisig output is still unspent")
+	}
+}
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+)
+
+// SigHashType is an input's signature hash flag: which parts of the transaction the
+// signature on that input commits to. A signer sets it on TxInput.SigHash before Sign.
+//
+// The base type says which outputs are covered. SigHashAnyoneCanPay, or-ed in, drops every
+// other input from the signed digest, so inputs can be added later, e.g. when several
+// parties each contribute coins to one transaction.
+type SigHashType byte
+
+const (
+	// SigHashAll covers every input and output. It is the zero value, so transactions from
+	// before the flags keep their signatures and IDs.
+	SigHashAll SigHashType = 0
+	// SigHashNone covers no outputs: anyone may redirect the coins.
+	SigHashNone SigHashType = 1
+	// SigHashSingle covers only the output with the same index as the input.
+	SigHashSingle SigHashType = 2
+	// SigHashAnyoneCanPay covers only the input being signed.
+	SigHashAnyoneCanPay SigHashType = 0x80
+)
+
+// ErrInvalidSigHash is returned for an unknown flag, or SigHashSingle on an input with no
+// output of the same index.
+var ErrInvalidSigHash = errors.New("invalid signature hash flag")
+
+func (t SigHashType) String() string {
+	var name string
+	switch t &^ SigHashAnyoneCanPay {
+	case SigHashAll:
+		name = "ALL"
+	case SigHashNone:
+		name = "NONE"
+	case SigHashSingle:
+		name = "SINGLE"
+	default:
+		return fmt.Sprintf("%#x", byte(t))
+	}
+	if t&SigHashAnyoneCanPay != 0 {
+		name += "|ANYONECANPAY"
+	}
+	return name
+}
+
+// signatureHash returns the digest the signature on input inID covers, given the output it
+// spends, as chosen by the input's SigHash flag. Other inputs' flags are left out: each is
+// committed to by that input's own signature.
+func (tx *Transaction) signatureHash(inID int, prevOut TxOutput) ([]byte, error) {
+	flag := tx.Vin[inID].SigHash
+	txCopy := tx.TrimmedCopy()
+	txCopy.Vin[inID].PubKey = prevOut.PubKeyHash
+	txCopy.Vin[inID].SigHash = flag
+
+	switch flag &^ SigHashAnyoneCanPay {
+	case SigHashAll:
+	case SigHashNone:
+		txCopy.Vout = nil
+	case SigHashSingle:
+		if inID >= len(txCopy.Vout) {
+			return nil, fmt.Errorf("%w: SINGLE on input %d, which has no matching output", ErrInvalidSigHash, inID)
+		}
+		// Earlier outputs are blanked rather than dropped, so the index stays committed.
+		outputs := make([]TxOutput, inID+1)
+		for i := range outputs[:inID] {
+			outputs[i] = TxOutput{Value: -1}
+		}
+		outputs[inID] = txCopy.Vout[inID]
+		txCopy.Vout = outputs
+	default:
+		return nil, fmt.Errorf("%w: %s on input %d", ErrInvalidSigHash, flag, inID)
+	}
+	if flag&SigHashAnyoneCanPay != 0 {
+		txCopy.Vin = txCopy.Vin[inID : inID+1]
+	}
+	return txCopy.Hash(), nil
+}
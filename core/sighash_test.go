@@ -0,0 +1,85 @@
+package core
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"my-blockchain/wallet"
+)
+
+func TestSigHashFlagsAllowOnlyUncoveredChanges(t *testing.T) {
+	w1, w2 := wallet.NewWallet(), wallet.NewWallet()
+	prev1 := RegtestConfig.CoinbaseTx(string(w1.GetAddress()), "", 1)
+	prev2 := RegtestConfig.CoinbaseTx(string(w2.GetAddress()), "", 2)
+	prev3 := RegtestConfig.CoinbaseTx(string(w2.GetAddress()), "", 3)
+	prevTXs := make(map[string]Transaction)
+	for _, tx := range []*Transaction{prev1, prev2, prev3} {
+		prevTXs[hex.EncodeToString(tx.ID)] = *tx
+	}
+	a, b := string(wallet.NewWallet().GetAddress()), string(wallet.NewWallet().GetAddress())
+
+	changeOutput := func(i int) func(*Transaction) {
+		return func(tx *Transaction) { tx.Vout[i] = *NewTxOutput(10, string(wallet.NewWallet().GetAddress())) }
+	}
+	swapOtherInput := func(tx *Transaction) { tx.Vin[1].Txid = prev3.ID }
+	tests := []struct {
+		name   string
+		flag   SigHashType
+		modify func(*Transaction)
+		valid  bool
+	}{
+		{"ALL unchanged", SigHashAll, func(*Transaction) {}, true},
+		{"ALL other output changed", SigHashAll, changeOutput(1), false},
+		{"ALL other input changed", SigHashAll, swapOtherInput, false},
+		{"NONE outputs changed", SigHashNone, func(tx *Transaction) { changeOutput(0)(tx); changeOutput(1)(tx) }, true},
+		{"NONE other input changed", SigHashNone, swapOtherInput, false},
+		{"SINGLE other output changed", SigHashSingle, changeOutput(1), true},
+		{"SINGLE own output changed", SigHashSingle, changeOutput(0), false},
+		{"ALL|ANYONECANPAY other input changed", SigHashAll | SigHashAnyoneCanPay, swapOtherInput, true},
+		{"ALL|ANYONECANPAY output changed", SigHashAll | SigHashAnyoneCanPay, changeOutput(1), false},
+		{"NONE|ANYONECANPAY input and outputs changed", SigHashNone | SigHashAnyoneCanPay, func(tx *Transaction) { swapOtherInput(tx); changeOutput(0)(tx) }, true},
+		{"SINGLE|ANYONECANPAY own output changed", SigHashSingle | SigHashAnyoneCanPay, changeOutput(0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := &Transaction{
+				Vin: []TxInput{
+					{Txid: prev1.ID, Vout: 0, PubKey: w1.PubKey(), SigHash: tt.flag},
+					{Txid: prev2.ID, Vout: 0, PubKey: w2.PubKey()},
+				},
+				Vout: []TxOutput{*NewTxOutput(10, a), *NewTxOutput(10, b)},
+			}
+			// The first party signs under the flag, then the transaction changes, then the
+			// second party signs all of it.
+			if err := tx.Sign(w1.PrivateECDSA(), prevTXs); err != nil {
+				t.Fatal(err)
+			}
+			tt.modify(tx)
+			if err := tx.Sign(w2.PrivateECDSA(), prevTXs); err != nil {
+				t.Fatal(err)
+			}
+			tx.ID = tx.Hash()
+			if got := tx.Verify(prevTXs); got != tt.valid {
+				t.Fatalf("Verify = %v, want %v", got, tt.valid)
+			}
+		})
+	}
+}
+
+func TestSigHashSingleNeedsMatchingOutput(t *testing.T) {
+	w, other := wallet.NewWallet(), wallet.NewWallet()
+	prev := RegtestConfig.CoinbaseTx(string(w.GetAddress()), "", 1)
+	otherPrev := RegtestConfig.CoinbaseTx(string(other.GetAddress()), "", 2)
+	prevTXs := map[string]Transaction{hex.EncodeToString(prev.ID): *prev, hex.EncodeToString(otherPrev.ID): *otherPrev}
+	tx := &Transaction{
+		Vin: []TxInput{
+			{Txid: otherPrev.ID, Vout: 0, PubKey: other.PubKey()},
+			{Txid: prev.ID, Vout: 0, PubKey: w.PubKey(), SigHash: SigHashSingle},
+		},
+		Vout: []TxOutput{*NewTxOutput(10, string(w.GetAddress()))},
+	}
+	if err := tx.Sign(w.PrivateECDSA(), prevTXs); !errors.Is(err, ErrInvalidSigHash) {
+		t.Fatalf("signing SINGLE on an input without a matching output: got %v, want ErrInvalidSigHash", err)
+	}
+}
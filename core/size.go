@@ -0,0 +1,42 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DefaultMaxBlockSize and DefaultMaxTxSize are the built-in profiles' bounds on the
+// serialized size in bytes of a block and of each transaction in it (see ChainConfig).
+const (
+	DefaultMaxBlockSize = 1 << 20
+	DefaultMaxTxSize    = 100 << 10
+)
+
+// blockReserve is the part of a block's size limit kept free for the block header and coinbase
+// when packing pending transactions into a block.
+const blockReserve = 2 << 10
+
+var (
+	ErrBlockTooLarge = errors.New("block exceeds maximum size")
+	ErrTxTooLarge    = errors.New("transaction exceeds maximum size")
+)
+
+func (cfg ChainConfig) checkTxSize(tx *Transaction) error {
+	if size := len(tx.Serialize()); size > cfg.MaxTxSize {
+		return fmt.Errorf("%w: %x is %d bytes, limit %d", ErrTxTooLarge, tx.ID, size, cfg.MaxTxSize)
+	}
+	return nil
+}
+
+// checkBlockSize checks the block as a whole and every transaction in it.
+func (cfg ChainConfig) checkBlockSize(block *Block) error {
+	if size := len(block.Serialize()); size > cfg.MaxBlockSize {
+		return fmt.Errorf("%w: %d bytes, limit %d", ErrBlockTooLarge, size, cfg.MaxBlockSize)
+	}
+	for _, tx := range block.Transactions {
+		if err := cfg.checkTxSize(tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
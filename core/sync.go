@@ -2,34 +2,44 @@ package core
 
 import (
 	"bytes"
+	"encoding/hex"
 	"errors"
-	"log"
+	"fmt"
+	"math/big"
 
 	"go.etcd.io/bbolt"
 )
 
+// BestHeight returns the height of the tip, or 0 for an empty chain.
 func (bc *Blockchain) BestHeight() int {
-	if bc.tip == nil {
+	tip := bc.Tip()
+	if len(tip) == 0 {
 		return 0
 	}
+	height, _ := bc.GetBlockHeight(tip)
+	return height
+}
+
+// GenesisHash returns the hash of the first block of the main chain, or nil for an empty chain.
+func (bc *Blockchain) GenesisHash() []byte {
+	if len(bc.Tip()) == 0 {
+		return nil
+	}
 	it := bc.Iterator()
-	height := 0
 	for {
 		block := it.Next()
 		if block == nil {
-			break
+			return nil
 		}
-		height++
 		if len(block.PrevBlockHash) == 0 {
-			break
+			return block.Hash
 		}
 	}
-	return height
 }
 
 // GetBlockHashes returns all known block hashes in chain order (genesis -> tip).
 func (bc *Blockchain) GetBlockHashes() [][]byte {
-	if bc.tip == nil {
+	if len(bc.Tip()) == 0 {
 		return nil
 	}
 	it := bc.Iterator()
@@ -76,16 +86,80 @@ func (bc *Blockchain) GetBlock(hash []byte) ([]byte, error) {
 		if v == nil {
 			return errors.New("block not found")
 		}
+		if DeserializeBlock(v).Pruned {
+			return fmt.Errorf("%w: %x", ErrBlockPruned, hash)
+		}
 		data = append([]byte(nil), v...)
 		return nil
 	})
 	return data, err
 }
 
-// PutBlock stores a serialized block in the DB. It updates the tip if the block extends the current tip.
-func (bc *Blockchain) PutBlock(blockData []byte) {
-	block := DeserializeBlock(blockData)
+// Block returns the stored block with hash, on any branch. A pruned block comes back with
+// Pruned set and no transactions. It may be shared through the block cache: do not modify it.
+func (bc *Blockchain) Block(hash []byte) (*Block, error) {
+	if block := bc.at(hash).Iterator().Next(); block != nil {
+		return block, nil
+	}
+	return nil, fmt.Errorf("%w: %x", ErrBlockNotFound, hash)
+}
+
+// PutBlock validates and stores a serialized block received from a peer. It updates the tip if
+// the block extends the current tip. Invalid blocks, including blocks that conflict with a
+// checkpoint on any branch, are rejected without touching the DB.
+// Blocks whose parent is unknown are held in the orphan pool until the parent arrives.
+func (bc *Blockchain) PutBlock(blockData []byte) error {
+	block, err := DecodeBlock(blockData)
+	if err != nil {
+		return fmt.Errorf("decode block: %w", err)
+	}
+	if err := bc.config.CheckBlock(block); err != nil {
+		return fmt.Errorf("block %x: %w", block.Hash, err)
+	}
+
+	bc.putMu.Lock()
+	defer bc.putMu.Unlock()
+
+	if bc.HasBlock(block.Hash) {
+		return nil
+	}
+	if len(block.PrevBlockHash) > 0 && !bc.HasBlock(block.PrevBlockHash) {
+		bc.AddOrphan(block)
+		return nil
+	}
+	if err := bc.putBlock(block, blockData); err != nil {
+		return err
+	}
+	bc.ConnectOrphans(block.Hash)
+	return nil
+}
+
+func (bc *Blockchain) putBlock(block *Block, blockData []byte) error {
+	if err := bc.checkTimestamp(block); err != nil {
+		return fmt.Errorf("block %x: %w", block.Hash, err)
+	}
+	if err := bc.checkTargetBits(block); err != nil {
+		return fmt.Errorf("block %x: %w", block.Hash, err)
+	}
+	// A branch through a conflicting block can never be reorganized to: it is never stored.
+	if len(bc.config.Checkpoints) > 0 {
+		branchHeight, err := bc.branchHeight(block.PrevBlockHash)
+		if err != nil {
+			return fmt.Errorf("block %x: %w", block.Hash, err)
+		}
+		if err := bc.checkCheckpoint(branchHeight, block.Hash); err != nil {
+			return err
+		}
+	}
+	// Transactions can only be checked against the chain the block builds on.
+	if tip := bc.Tip(); len(tip) == 0 || bytes.Equal(block.PrevBlockHash, tip) {
+		if err := bc.checkBlockTransactions(block); err != nil {
+			return fmt.Errorf("block %x: %w", block.Hash, err)
+		}
+	}
 
+	height := bc.BestHeight() + 1
+	reorg, connected := false, false
 	err := bc.db.Update(func(tx *bbolt.Tx) error {
 		b := tx.Bucket([]byte(blocksBucket))
 		if b == nil {
@@ -100,6 +174,9 @@ func (bc *Blockchain) PutBlock(blockData []byte) {
 			if err := b.Put(block.Hash, blockData); err != nil {
 				return err
 			}
+			if err := putBlockHeight(tx, block); err != nil {
+				return err
+			}
 		}
 
 		currentTip := b.Get([]byte(lastHashKey))
@@ -108,7 +185,11 @@ func (bc *Blockchain) PutBlock(blockData []byte) {
 			if err := b.Put([]byte(lastHashKey), block.Hash); err != nil {
 				return err
 			}
-			bc.tip = block.Hash
+			if err := updateUTXOSet(tx, block, 1); err != nil {
+				return err
+			}
+			height = 1
+			connected = true
 			return nil
 		}
 
@@ -117,11 +198,147 @@ func (bc *Blockchain) PutBlock(blockData []byte) {
 			if err := b.Put([]byte(lastHashKey), block.Hash); err != nil {
 				return err
 			}
-			bc.tip = block.Hash
+			if err := updateUTXOSet(tx, block, height); err != nil {
+				return err
+			}
+			connected = true
+			return nil
 		}
+
+		// Fork choice: a side branch with more cumulative work than the main chain wins.
+		// The block itself stays stored either way, so the losing branch can win later.
+		newWork := branchWork(b, block.Hash)
+		reorg = newWork != nil && newWork.Cmp(branchWork(b, currentTip)) > 0
 		return nil
 	})
 	if err != nil {
-		log.Panic(err)
+		return fmt.Errorf("block %x: %w", block.Hash, err)
+	}
+	bc.metrics.BlocksProcessed.Inc()
+	if connected {
+		// Readers go from the tip to its block, so it moves only once the block is committed.
+		bc.setTip(block.Hash)
+		bc.events.Publish(Event{Type: EventBlock, Hash: block.Hash, Height: height})
+	}
+	if reorg {
+		return bc.reorganize(block.Hash)
+	}
+	return nil
+}
+
+// branchWork returns the total proof-of-work of the chain ending at hash,
+// or nil if one of its ancestors has not been stored yet.
+func branchWork(b *bbolt.Bucket, hash []byte) *big.Int {
+	work := new(big.Int)
+	for len(hash) > 0 {
+		data := b.Get(hash)
+		if data == nil {
+			return nil
+		}
+		block := DeserializeBlock(data)
+		// Stored blocks passed CheckBlock; a bad difficulty would count for nothing.
+		if checkTargetBitsRange(block.Bits()) == nil {
+			work.Add(work, new(big.Int).Lsh(big.NewInt(1), uint(block.Bits())))
+		}
+		hash = block.PrevBlockHash
+	}
+	return work
+}
+
+// reorganize makes the stored branch ending at newTip the main chain. The blocks that
+// are new to the main chain get their difficulty and transactions verified first; if any
+// fails, the old tip is kept, as it is when the branch forks below a checkpoint the main
+// chain has reached or would disconnect more than MaxReorgDepth blocks. The UTXO set is
+// then moved to the new chain by rolling back the old branch and applying the new one, or
+// rebuilt if the old branch has no undo records.
+func (bc *Blockchain) reorganize(newTip []byte) error {
+	oldTip := bc.Tip()
+
+	mainChain := bc.GetBlockHashes()
+	heights := make(map[string]int, len(mainChain))
+	for i, h := range mainChain {
+		heights[hex.EncodeToString(h)] = i + 1
+	}
+
+	it := bc.at(newTip).Iterator()
+	var attached []*Block // tip first
+	forkHeight := 0
+	for {
+		block := it.Next()
+		if block == nil {
+			break
+		}
+		if h, ok := heights[hex.EncodeToString(block.Hash)]; ok {
+			forkHeight = h
+			break
+		}
+		attached = append(attached, block)
+	}
+
+	if cp := bc.lastCheckpoint(len(mainChain)); cp > forkHeight {
+		return fmt.Errorf("reorg to %x rejected, it forks at height %d below the checkpoint at %d: %w", newTip, forkHeight, cp, ErrCheckpointMismatch)
+	}
+	if err := bc.checkReorgDepth(newTip, forkHeight, len(mainChain)-forkHeight); err != nil {
+		return err
+	}
+	for _, block := range attached {
+		// Check each block against its own ancestors only, as if it extended the tip.
+		if err := bc.checkTargetBits(block); err != nil {
+			return fmt.Errorf("reorg to %x rejected, block %x: %w", newTip, block.Hash, err)
+		}
+		if err := bc.at(block.PrevBlockHash).checkBlockTransactions(block); err != nil {
+			return fmt.Errorf("reorg to %x rejected, block %x: %w", newTip, block.Hash, err)
+		}
+	}
+
+	var detached []*Block // tip first
+	for _, h := range mainChain[forkHeight:] {
+		data, err := bc.GetBlock(h)
+		if err != nil {
+			return err
+		}
+		detached = append([]*Block{DeserializeBlock(data)}, detached...)
+	}
+
+	err := bc.db.Update(func(tx *bbolt.Tx) error {
+		for _, block := range detached {
+			if err := rollbackUTXOSet(tx, block); err != nil {
+				return err
+			}
+		}
+		for i := len(attached) - 1; i >= 0; i-- {
+			height := forkHeight + len(attached) - i
+			if err := updateUTXOSet(tx, attached[i], height); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket([]byte(blocksBucket)).Put([]byte(lastHashKey), newTip)
+	})
+	if err == nil {
+		bc.setTip(newTip)
+	}
+	if errors.Is(err, ErrNoUndoData) {
+		err = bc.db.Update(func(tx *bbolt.Tx) error {
+			return tx.Bucket([]byte(blocksBucket)).Put([]byte(lastHashKey), newTip)
+		})
+		if err == nil {
+			bc.setTip(newTip)
+			if err := (UTXOSet{Blockchain: bc}).Reindex(); err != nil {
+				return fmt.Errorf("reorg to %x: rebuilding UTXO set: %w", newTip, err)
+			}
+			if err := bc.ReindexAddresses(); err != nil {
+				return fmt.Errorf("reorg to %x: rebuilding address index: %w", newTip, err)
+			}
+			if err := bc.ReindexMainChain(); err != nil {
+				return fmt.Errorf("reorg to %x: rebuilding main-chain index: %w", newTip, err)
+			}
+		}
+	}
+	if err != nil {
+		return err
 	}
+
+	bc.logger().Info("chain reorganized", "from", hex.EncodeToString(oldTip), "to", hex.EncodeToString(newTip), "disconnected", len(detached), "connected", len(attached))
+	bc.events.Publish(Event{Type: EventBlock, Hash: newTip, Height: forkHeight + len(attached)})
+	return nil
 }
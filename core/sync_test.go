@@ -0,0 +1,158 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"my-blockchain/wallet"
+)
+
+func TestPutBlockReturnsStoreFailure(t *testing.T) {
+	bc, _ := newTestChain(t)
+	block := mineOn(t, bc, mustBlock(t, bc, bc.Tip()), 2)
+	if err := bc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every check passes on a read-only DB; only storing the block fails.
+	ro, err := OpenBlockchainReadOnlyForNode("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ro.Close() }()
+	if err := ro.PutBlock(block.Serialize()); err == nil {
+		t.Fatal("PutBlock on a read-only DB succeeded")
+	}
+}
+
+func TestConvergesOnLongerBranch(t *testing.T) {
+	bc, w := newTestChain(t)
+	genesis := mustBlock(t, bc, bc.Tip())
+	coinbase := genesis.Transactions[0]
+
+	// Branch A spends the genesis output; branch B does not and grows longer.
+	a2 := mineOn(t, bc, genesis, 2, spend(t, bc, w, coinbase, 0, 10))
+	a3 := mineOn(t, bc, a2, 3)
+	b2 := mineOn(t, bc, genesis, 2)
+	b3 := mineOn(t, bc, b2, 3)
+	b4 := mineOn(t, bc, b3, 4)
+
+	putAll(t, bc, a2, a3, b2, b3)
+	if !bytes.Equal(bc.Tip(), a3.Hash) {
+		t.Fatalf("tip %x, want the first branch seen at equal height %x", bc.Tip(), a3.Hash)
+	}
+	utxo := UTXOSet{Blockchain: bc}
+	if utxo.IsUnspent(coinbase.ID, 0) {
+		t.Fatal("genesis output unspent on branch A")
+	}
+
+	putAll(t, bc, b4)
+	if !bytes.Equal(bc.Tip(), b4.Hash) || bc.BestHeight() != 4 {
+		t.Fatalf("tip %x at height %d, want the longer branch %x at 4", bc.Tip(), bc.BestHeight(), b4.Hash)
+	}
+	if !utxo.IsUnspent(coinbase.ID, 0) {
+		t.Fatal("genesis output still spent after reorganizing away from branch A")
+	}
+	for _, b := range []*Block{a2, a3} {
+		if !bc.HasBlock(b.Hash) {
+			t.Fatalf("block %x of the abandoned branch was dropped", b.Hash)
+		}
+	}
+
+	// Branch A takes over again once it is the longer one.
+	a4 := mineOn(t, bc, a3, 4)
+	a5 := mineOn(t, bc, a4, 5)
+	putAll(t, bc, a4, a5)
+	if !bytes.Equal(bc.Tip(), a5.Hash) {
+		t.Fatalf("tip %x, want %x", bc.Tip(), a5.Hash)
+	}
+	if utxo.IsUnspent(coinbase.ID, 0) {
+		t.Fatal("genesis output unspent after reorganizing back to branch A")
+	}
+}
+
+func TestConcurrentBlocksAndTransactionsKeepChainConsistent(t *testing.T) {
+	bc, w := newTestChain(t)
+	genesis := mustBlock(t, bc, bc.Tip())
+
+	// A peer's branch arrives while the node mines its own blocks and accepts transactions.
+	var branch []*Block
+	parent := genesis
+	for height := 2; height <= 9; height++ {
+		parent = mineOn(t, bc, parent, height)
+		branch = append(branch, parent)
+	}
+	payment := spend(t, bc, w, genesis.Transactions[0], 0, 10)
+	mp := NewMempool()
+	to := string(wallet.NewWallet().GetAddress())
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	wg.Add(4)
+	go func() {
+		defer wg.Done()
+		for _, b := range branch {
+			if err := bc.PutBlock(b.Serialize()); err != nil {
+				errs <- err
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for range 8 {
+			_, err := bc.AddBlock([]*Transaction{bc.config.CoinbaseTx(to, "", bc.BestHeight()+1)})
+			// Losing a race against a peer's block is expected; the block is just dropped.
+			if err != nil && !errors.Is(err, ErrStaleTip) && !errors.Is(err, ErrDuplicateTxID) {
+				errs <- err
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for range 50 {
+			if err := bc.VerifyTransaction(payment); err == nil {
+				_ = mp.Add(payment, 0)
+			}
+			mp.EvictSpent(bc)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		u := UTXOSet{Blockchain: bc}
+		for range 50 {
+			_ = bc.BestHeight()
+			_ = u.FindUTXO(wallet.HashPubKey(w.PubKey()))
+		}
+	}()
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	height := bc.BestHeight()
+	if height < 9 {
+		t.Fatalf("height %d, want at least the peer's 9", height)
+	}
+	hash, err := bc.GetBlockHash(height)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(hash, bc.Tip()) {
+		t.Fatalf("height index has %x at the best height, but the tip is %x", hash, bc.Tip())
+	}
+	if scanned, err := bc.scanBlockHeight(bc.Tip()); err != nil || scanned != height {
+		t.Fatalf("tip is %d blocks above genesis (%v), want %d", scanned, err, height)
+	}
+	u := UTXOSet{Blockchain: bc}
+	before := utxoSnapshot(u)
+	if err := u.Reindex(); err != nil {
+		t.Fatal(err)
+	}
+	if after := utxoSnapshot(u); !reflect.DeepEqual(after, before) {
+		t.Fatal("UTXO set differs from a rebuild from the main chain")
+	}
+}
@@ -0,0 +1,107 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+
+	"go.etcd.io/bbolt"
+)
+
+// BlockTemplate is a validated block on top of the current tip waiting for its proof of
+// work. Whoever mines it, in-process or an external miner, hashes
+// PowData(PrevBlockHash, MerkleRoot, Timestamp, TargetBits, nonce) until the hash
+// MeetsTarget, then hands the nonce to SubmitSolution.
+type BlockTemplate struct {
+	Height        int
+	PrevBlockHash []byte
+	MerkleRoot    []byte
+	Timestamp     int64
+	TargetBits    int
+
+	bc    *Blockchain
+	block *Block
+}
+
+// NewBlockTemplate checks transactions the way AddBlock does and returns a template for
+// a block containing them on top of the current tip. Like mempools, it requires low-S
+// signatures whatever the chain's LowSHeight.
+func (bc *Blockchain) NewBlockTemplate(transactions []*Transaction) (*BlockTemplate, error) {
+	if err := checkCoinbase(transactions); err != nil {
+		return nil, err
+	}
+	if err := checkDoubleSpends(transactions); err != nil {
+		return nil, err
+	}
+	for i, tx := range transactions {
+		if err := bc.verifyTransactionWith(tx, pendingSet(transactions[:i]), true); err != nil {
+			return nil, err
+		}
+	}
+
+	var lastHash []byte
+	height := bc.BestHeight() + 1
+
+	err := bc.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(blocksBucket))
+		lastHash = bytes.Clone(b.Get([]byte(lastHashKey)))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	block := newBlockTemplate(transactions, lastHash, bc.NextTargetBits())
+	// Blocks mined within the same second (or against a clock running behind) still have
+	// to move past the median time past.
+	median, err := bc.medianTimePast(lastHash)
+	if err != nil {
+		return nil, err
+	}
+	block.Timestamp = max(block.Timestamp, median+1)
+	for _, tx := range transactions {
+		if err := checkFinal(tx, height, block.Timestamp); err != nil {
+			return nil, err
+		}
+	}
+	if err := bc.config.checkBlockSize(block); err != nil {
+		return nil, err
+	}
+	if err := bc.checkDuplicateTxIDs(block); err != nil {
+		return nil, err
+	}
+	return &BlockTemplate{
+		Height:        height,
+		PrevBlockHash: block.PrevBlockHash,
+		MerkleRoot:    block.MerkleRoot,
+		Timestamp:     block.Timestamp,
+		TargetBits:    block.Bits(),
+		bc:            bc,
+		block:         block,
+	}, nil
+}
+
+// Solves reports whether nonce is a valid proof of work for the template.
+func (t *BlockTemplate) Solves(nonce int) bool {
+	return MeetsTarget(t.hash(nonce), t.TargetBits)
+}
+
+func (t *BlockTemplate) hash(nonce int) []byte {
+	hash := sha256.Sum256(PowData(t.PrevBlockHash, t.MerkleRoot, t.Timestamp, t.TargetBits, nonce))
+	return hash[:]
+}
+
+// SubmitSolution completes the block with nonce and adds it to the chain, returning its
+// hash. It fails with ErrBadProofOfWork if nonce does not solve the template and with
+// ErrStaleTip if another block became the tip in the meantime.
+func (t *BlockTemplate) SubmitSolution(nonce int) ([]byte, error) {
+	if !t.Solves(nonce) {
+		return nil, ErrBadProofOfWork
+	}
+	block := *t.block
+	block.Nonce = nonce
+	block.Hash = t.hash(nonce)
+	if err := t.bc.connectMinedBlock(&block, t.Height); err != nil {
+		return nil, err
+	}
+	return block.Hash, nil
+}
@@ -0,0 +1,69 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// MaxFutureBlockTime is how far ahead of the local clock a block's timestamp may be.
+const MaxFutureBlockTime = 2 * time.Hour
+
+// medianTimeSpan is how many blocks, ending at the parent, the median time past covers.
+const medianTimeSpan = 11
+
+var (
+	ErrTimestampTooNew = errors.New("block timestamp too far in the future")
+	ErrTimestampTooOld = errors.New("block timestamp not after median time past")
+)
+
+// checkBlockTime rejects blocks stamped more than MaxFutureBlockTime ahead of now.
+func checkBlockTime(block *Block) error {
+	limit := time.Now().Add(MaxFutureBlockTime).Unix()
+	if block.Timestamp > limit {
+		return fmt.Errorf("%w: %d is more than %v ahead", ErrTimestampTooNew, block.Timestamp, MaxFutureBlockTime)
+	}
+	return nil
+}
+
+// medianTimePast returns the median timestamp of the block at hash and up to
+// medianTimeSpan-1 of its ancestors.
+func (bc *Blockchain) medianTimePast(hash []byte) (int64, error) {
+	var times []int64
+	err := bc.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(blocksBucket))
+		for len(hash) > 0 && len(times) < medianTimeSpan {
+			data := b.Get(hash)
+			if data == nil {
+				return fmt.Errorf("block %x not found", hash)
+			}
+			block := DeserializeBlock(data)
+			times = append(times, block.Timestamp)
+			hash = block.PrevBlockHash
+		}
+		return nil
+	})
+	if err != nil || len(times) == 0 {
+		return 0, err
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	return times[len(times)/2], nil
+}
+
+// checkTimestamp requires a block to be stamped after the median time past of its parent.
+func (bc *Blockchain) checkTimestamp(block *Block) error {
+	if len(block.PrevBlockHash) == 0 {
+		return nil
+	}
+	median, err := bc.medianTimePast(block.PrevBlockHash)
+	if err != nil {
+		return err
+	}
+	if block.Timestamp <= median {
+		return fmt.Errorf("%w: %d <= %d", ErrTimestampTooOld, block.Timestamp, median)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBlockTimestampBounds(t *testing.T) {
+	bc, _ := newTestChain(t)
+	genesis := mustBlock(t, bc, bc.Tip())
+
+	// restamp mines block again with timestamp ts.
+	restamp := func(block *Block, ts int64) *Block {
+		b := *block
+		b.Timestamp = ts
+		b.Nonce, b.Hash = NewProofOfWork(&b).Run()
+		return &b
+	}
+	block := mineOn(t, bc, genesis, 2)
+
+	future := restamp(block, time.Now().Add(MaxFutureBlockTime+time.Minute).Unix())
+	if err := bc.PutBlock(future.Serialize()); !errors.Is(err, ErrTimestampTooNew) {
+		t.Fatalf("block %v ahead: got %v, want ErrTimestampTooNew", MaxFutureBlockTime+time.Minute, err)
+	}
+	stale := restamp(block, genesis.Timestamp)
+	if err := bc.PutBlock(stale.Serialize()); !errors.Is(err, ErrTimestampTooOld) {
+		t.Fatalf("block stamped like its parent: got %v, want ErrTimestampTooOld", err)
+	}
+	if !bytes.Equal(bc.Tip(), genesis.Hash) {
+		t.Fatalf("a badly stamped block moved the tip to %x", bc.Tip())
+	}
+
+	ahead := restamp(block, time.Now().Add(MaxFutureBlockTime-time.Minute).Unix())
+	if err := bc.PutBlock(ahead.Serialize()); err != nil {
+		t.Fatalf("block within the allowed drift: %v", err)
+	}
+	if !bytes.Equal(bc.Tip(), ahead.Hash) {
+		t.Fatal("block within the allowed drift did not become the tip")
+	}
+}
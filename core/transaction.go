@@ -3,37 +3,72 @@ package core
 import (
 	"bytes"
 	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
 	"crypto/sha256"
 	"encoding/gob"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"strings"
 
 	"my-blockchain/wallet"
 )
 
-const subsidy = 10
+// BlockReward returns the subsidy for the block at height (genesis is 1, like BestHeight):
+// Subsidy >> (height / HalvingInterval), which reaches zero after a few halvings.
+func (cfg ChainConfig) BlockReward(height int) int {
+	halvings := height / cfg.HalvingInterval
+	if halvings >= 63 {
+		return 0
+	}
+	return cfg.Subsidy >> uint(halvings)
+}
+
+// Output script types. The zero value keeps plain pay-to-pubkey-hash outputs unchanged.
+const (
+	ScriptPubKeyHash = 0
+	// ScriptMultisig outputs lock to the hash of an m-of-n multisig script
+	// (see wallet.NewMultisigScript) instead of a single public key hash.
+	ScriptMultisig = 1
+	// ScriptData outputs carry Data instead of a lock; they have no value and can never
+	// be spent (see NewDataOutput).
+	ScriptData = 2
+)
 
 type Transaction struct {
 	ID   []byte
 	Vin  []TxInput
 	Vout []TxOutput
+	// LockTime is the earliest block height (below lockTimeThreshold) or Unix time (at or
+	// above it) of a block that may include the transaction. Zero means no lock.
+	LockTime int
+	// Replaceable opts in to replace-by-fee: while pending, the transaction may be replaced
+	// by one spending the same outputs with a higher fee (see Mempool.Add).
+	Replaceable bool
 }
 
 type TxInput struct {
 	Txid      []byte
 	Vout      int
 	Signature []byte
-	PubKey    []byte
+	// PubKey is the spender's public key, or the multisig script when spending a multisig output.
+	PubKey []byte
+	// Signatures and PubKeys are used by multisig inputs only: Signatures[i] is made
+	// with the key PubKeys[i], whose hash must appear in the script.
+	Signatures [][]byte
+	PubKeys    [][]byte
+	// SigHash chooses what the input's signatures commit to; see SigHashType.
+	SigHash SigHashType
 }
 
 type TxOutput struct {
-	Value      int
+	Value int
+	// PubKeyHash is the recipient's public key hash, or the multisig script hash.
 	PubKeyHash []byte
+	ScriptType int
+	// Data is the payload of a ScriptData output.
+	Data []byte
 }
 
 func (in *TxInput) UsesKey(pubKeyHash []byte) bool {
@@ -42,7 +77,7 @@ func (in *TxInput) UsesKey(pubKeyHash []byte) bool {
 }
 
 func (out *TxOutput) IsLockedWithKey(pubKeyHash []byte) bool {
-	return bytes.Equal(out.PubKeyHash, pubKeyHash)
+	return !out.IsData() && bytes.Equal(out.PubKeyHash, pubKeyHash)
 }
 
 func (out *TxOutput) Lock(address string) error {
@@ -51,6 +86,10 @@ func (out *TxOutput) Lock(address string) error {
 		return errors.New("invalid address")
 	}
 	out.PubKeyHash = pubKeyHash
+	out.ScriptType = ScriptPubKeyHash
+	if wallet.IsMultisigAddress(address) {
+		out.ScriptType = ScriptMultisig
+	}
 	return nil
 }
 
@@ -66,19 +105,59 @@ func (tx *Transaction) IsCoinbase() bool {
 	return len(tx.Vin) == 1 && len(tx.Vin[0].Txid) == 0 && tx.Vin[0].Vout == -1
 }
 
-func CoinbaseTx(to, data string) *Transaction {
+// CoinbaseTx creates a coinbase for the block at height paying the block reward of cfg.
+func (cfg ChainConfig) CoinbaseTx(to, data string, height int) *Transaction {
+	return cfg.CoinbaseTxWithFees(to, data, height, 0)
+}
+
+// CoinbaseTxWithFees creates a coinbase for the block at height paying the block reward
+// plus the fees collected from the other transactions in the block.
+func (cfg ChainConfig) CoinbaseTxWithFees(to, data string, height, fees int) *Transaction {
 	if data == "" {
-		data = fmt.Sprintf("Coinbase to %s", to)
+		// The height keeps coinbases to the same address from sharing a tx ID.
+		data = fmt.Sprintf("Coinbase to %s at height %d", to, height)
+	}
+	if fees < 0 {
+		log.Panic("fees must be non-negative")
 	}
 
-	txin := TxInput{Txid: []byte{}, Vout: -1, Signature: nil, PubKey: []byte(data)}
-	txout := *NewTxOutput(subsidy, to)
-
-	tx := &Transaction{ID: nil, Vin: []TxInput{txin}, Vout: []TxOutput{txout}}
+	// A coinbase has nothing to sign, so its Signature carries the height instead: it makes
+	// every coinbase ID unique, custom data or not.
+	txin := TxInput{Txid: []byte{}, Vout: -1, Signature: IntToHex(int64(height)), PubKey: []byte(data)}
+	tx := &Transaction{ID: nil, Vin: []TxInput{txin}}
+	// Once the subsidy has run out, a block without fees pays nothing, and outputs worth
+	// nothing are invalid.
+	if value := cfg.BlockReward(height) + fees; value > 0 {
+		tx.Vout = []TxOutput{*NewTxOutput(value, to)}
+	}
 	tx.ID = tx.Hash()
 	return tx
 }
 
+// checkOutputValues rejects a transaction, coinbases included, with a negative output, a
+// spendable output worth nothing, or outputs whose sum overflows. Balance checks compare
+// sums, so without it an output of -X would pay for another of X. Data outputs are worth
+// exactly 0 (see checkDataOutput).
+func checkOutputValues(tx *Transaction) error {
+	total := 0
+	for i, out := range tx.Vout {
+		if out.Value < 0 || (out.Value == 0 && !out.IsData()) || total > math.MaxInt-out.Value {
+			return fmt.Errorf("%w: %x output %d is worth %d", ErrBadOutputValue, tx.ID, i, out.Value)
+		}
+		total += out.Value
+	}
+	return nil
+}
+
+// OutputValue returns the sum of all output values.
+func (tx *Transaction) OutputValue() int {
+	total := 0
+	for _, out := range tx.Vout {
+		total += out.Value
+	}
+	return total
+}
+
 func (tx *Transaction) Serialize() []byte {
 	var encoded bytes.Buffer
 	enc := gob.NewEncoder(&encoded)
@@ -88,14 +167,75 @@ func (tx *Transaction) Serialize() []byte {
 	return encoded.Bytes()
 }
 
-func (tx *Transaction) Hash() []byte {
-	txCopy := *tx
-	txCopy.ID = nil
+// DecodeTransaction deserializes a gob-encoded transaction, e.g. one relayed by a peer.
+func DecodeTransaction(data []byte) (*Transaction, error) {
+	var tx Transaction
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(&tx); err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
 
-	hash := sha256.Sum256(txCopy.Serialize())
+func (tx *Transaction) Hash() []byte {
+	hash := sha256.Sum256(tx.hashData())
 	return hash[:]
 }
 
+// hashData is a canonical encoding of everything but the ID. gob output embeds
+// per-process type IDs, so the same transaction can serialize differently on two nodes.
+func (tx *Transaction) hashData() []byte {
+	var buf bytes.Buffer
+	writeBytes := func(b []byte) {
+		buf.Write(IntToHex(int64(len(b))))
+		buf.Write(b)
+	}
+
+	buf.Write(IntToHex(int64(len(tx.Vin))))
+	for _, in := range tx.Vin {
+		writeBytes(in.Txid)
+		buf.Write(IntToHex(int64(in.Vout)))
+		writeBytes(in.Signature)
+		writeBytes(in.PubKey)
+		buf.Write(IntToHex(int64(len(in.Signatures))))
+		for _, sig := range in.Signatures {
+			writeBytes(sig)
+		}
+		buf.Write(IntToHex(int64(len(in.PubKeys))))
+		for _, pub := range in.PubKeys {
+			writeBytes(pub)
+		}
+	}
+	buf.Write(IntToHex(int64(len(tx.Vout))))
+	for _, out := range tx.Vout {
+		buf.Write(IntToHex(int64(out.Value)))
+		writeBytes(out.PubKeyHash)
+		buf.Write(IntToHex(int64(out.ScriptType)))
+		if out.IsData() {
+			writeBytes(out.Data)
+		}
+	}
+	// Left out when zero so transactions from before locktimes keep their IDs.
+	if tx.LockTime != 0 {
+		buf.Write(IntToHex(int64(tx.LockTime)))
+	}
+	// Likewise the flag only enters the hash when set.
+	if tx.Replaceable {
+		buf.WriteString("rbf")
+	}
+	// And the inputs' sighash flags, when any is not SigHashAll.
+	for _, in := range tx.Vin {
+		if in.SigHash != SigHashAll {
+			buf.WriteString("sighash")
+			for _, in := range tx.Vin {
+				buf.WriteByte(byte(in.SigHash))
+			}
+			break
+		}
+	}
+	return buf.Bytes()
+}
+
 func (tx *Transaction) TrimmedCopy() Transaction {
 	inputs := make([]TxInput, 0, len(tx.Vin))
 	for _, vin := range tx.Vin {
@@ -103,65 +243,110 @@ func (tx *Transaction) TrimmedCopy() Transaction {
 	}
 	outputs := make([]TxOutput, 0, len(tx.Vout))
 	for _, vout := range tx.Vout {
-		outputs = append(outputs, TxOutput{Value: vout.Value, PubKeyHash: vout.PubKeyHash})
+		outputs = append(outputs, TxOutput{Value: vout.Value, PubKeyHash: vout.PubKeyHash, ScriptType: vout.ScriptType, Data: vout.Data})
 	}
-	return Transaction{ID: tx.ID, Vin: inputs, Vout: outputs}
+	return Transaction{ID: tx.ID, Vin: inputs, Vout: outputs, LockTime: tx.LockTime, Replaceable: tx.Replaceable}
 }
 
-func (tx *Transaction) Sign(privKey *ecdsa.PrivateKey, prevTXs map[string]Transaction) {
+// Sign signs the inputs privKey can spend, each over the parts of the transaction its
+// SigHash flag selects. Single-key inputs are signed when their PubKey is privKey's. For
+// multisig inputs it fills the signature slot of the matching entry in PubKeys. Other
+// inputs are skipped, so each co-signer, or each party contributing inputs, calls Sign in
+// turn.
+func (tx *Transaction) Sign(privKey *ecdsa.PrivateKey, prevTXs map[string]Transaction) error {
 	if tx.IsCoinbase() {
-		return
+		return nil
 	}
 
 	for _, vin := range tx.Vin {
-		if prevTXs[hex.EncodeToString(vin.Txid)].ID == nil {
-			log.Panic("previous transaction is not correct")
+		prevTx := prevTXs[hex.EncodeToString(vin.Txid)]
+		if prevTx.ID == nil || vin.Vout < 0 || vin.Vout >= len(prevTx.Vout) {
+			return fmt.Errorf("input %x:%d: %w", vin.Txid, vin.Vout, ErrTransactionNotFound)
 		}
 	}
 
-	txCopy := tx.TrimmedCopy()
-	for inID, vin := range txCopy.Vin {
-		prevTx := prevTXs[hex.EncodeToString(vin.Txid)]
-		txCopy.Vin[inID].Signature = nil
-		txCopy.Vin[inID].PubKey = prevTx.Vout[vin.Vout].PubKeyHash
-		txCopy.ID = txCopy.Hash()
-		txCopy.Vin[inID].PubKey = nil
+	for inID, vin := range tx.Vin {
+		prevOut := prevTXs[hex.EncodeToString(vin.Txid)].Vout[vin.Vout]
+		slot := -1
+		if prevOut.ScriptType == ScriptMultisig {
+			if slot = multisigKeyIndex(vin.PubKeys, &privKey.PublicKey); slot < 0 {
+				continue
+			}
+		} else if multisigKeyIndex([][]byte{vin.PubKey}, &privKey.PublicKey) < 0 {
+			continue
+		}
 
-		sig, err := ecdsa.SignASN1(rand.Reader, privKey, txCopy.ID)
+		hash, err := tx.signatureHash(inID, prevOut)
+		if err != nil {
+			return err
+		}
+		sig, err := wallet.SignLowS(privKey, hash)
 		if err != nil {
-			log.Panic(err)
+			return err
+		}
+		if slot >= 0 {
+			for len(tx.Vin[inID].Signatures) < len(tx.Vin[inID].PubKeys) {
+				tx.Vin[inID].Signatures = append(tx.Vin[inID].Signatures, nil)
+			}
+			tx.Vin[inID].Signatures[slot] = sig
+			continue
 		}
 		tx.Vin[inID].Signature = sig
 	}
+	return nil
+}
+
+// multisigKeyIndex returns the position of pub in pubKeys, in either encoding, or -1.
+func multisigKeyIndex(pubKeys [][]byte, pub *ecdsa.PublicKey) int {
+	for i, candidate := range pubKeys {
+		parsed, err := wallet.ParsePubKey(candidate)
+		if err == nil && parsed.Equal(pub) {
+			return i
+		}
+	}
+	return -1
 }
 
+// Verify checks tx's signatures against the outputs they spend, found in prevTXs by hex
+// tx ID. Like mempools, it requires every signature in low-S form (see wallet.IsLowS).
 func (tx *Transaction) Verify(prevTXs map[string]Transaction) bool {
+	return tx.verify(prevTXs, true)
+}
+
+// verify is Verify that also accepts high-S signatures unless requireLowS is set, as
+// blocks below the chain's LowSHeight do.
+func (tx *Transaction) verify(prevTXs map[string]Transaction, requireLowS bool) bool {
 	if tx.IsCoinbase() {
 		return true
 	}
 
 	for _, vin := range tx.Vin {
-		if prevTXs[hex.EncodeToString(vin.Txid)].ID == nil {
-			log.Panic("previous transaction is not correct")
+		prevTx := prevTXs[hex.EncodeToString(vin.Txid)]
+		if prevTx.ID == nil || vin.Vout < 0 || vin.Vout >= len(prevTx.Vout) {
+			return false
 		}
 	}
 
-	txCopy := tx.TrimmedCopy()
-	curve := elliptic.P256()
-
 	for inID, vin := range tx.Vin {
-		prevTx := prevTXs[hex.EncodeToString(vin.Txid)]
-		txCopy.Vin[inID].Signature = nil
-		txCopy.Vin[inID].PubKey = prevTx.Vout[vin.Vout].PubKeyHash
-		txCopy.ID = txCopy.Hash()
-		txCopy.Vin[inID].PubKey = nil
-
-		x, y := elliptic.Unmarshal(curve, vin.PubKey)
-		if x == nil {
+		prevOut := prevTXs[hex.EncodeToString(vin.Txid)].Vout[vin.Vout]
+		if vin.checkPushes() != nil || prevOut.checkPushes() != nil {
 			return false
 		}
-		pubKey := ecdsa.PublicKey{Curve: curve, X: x, Y: y}
-		if !ecdsa.VerifyASN1(&pubKey, txCopy.ID, vin.Signature) {
+		hash, err := tx.signatureHash(inID, prevOut)
+		if err != nil {
+			return false
+		}
+		checkSig := func(sig, pubKey []byte) bool {
+			parsed, err := wallet.ParsePubKey(pubKey)
+			if err != nil {
+				return false
+			}
+			if requireLowS {
+				return wallet.VerifyLowS(parsed, hash, sig)
+			}
+			return ecdsa.VerifyASN1(parsed, hash, sig)
+		}
+		if EvalScript(vin.ScriptSig(), prevOut.ScriptPubKey(), checkSig) != nil {
 			return false
 		}
 	}
@@ -172,6 +357,12 @@ func (tx *Transaction) Verify(prevTXs map[string]Transaction) bool {
 func (tx *Transaction) String() string {
 	var lines []string
 	lines = append(lines, fmt.Sprintf("--- Transaction %x", tx.ID))
+	if tx.LockTime != 0 {
+		lines = append(lines, fmt.Sprintf("  LockTime: %d", tx.LockTime))
+	}
+	if tx.Replaceable {
+		lines = append(lines, "  Replaceable: yes")
+	}
 
 	for i, input := range tx.Vin {
 		lines = append(lines, fmt.Sprintf("  Input %d:", i))
@@ -179,12 +370,22 @@ func (tx *Transaction) String() string {
 		lines = append(lines, fmt.Sprintf("    Out:  %d", input.Vout))
 		lines = append(lines, fmt.Sprintf("    Sig:  %x", input.Signature))
 		lines = append(lines, fmt.Sprintf("    Pub:  %x", input.PubKey))
+		for j, sig := range input.Signatures {
+			lines = append(lines, fmt.Sprintf("    Sig %d: %x", j, sig))
+		}
 	}
 
 	for i, output := range tx.Vout {
 		lines = append(lines, fmt.Sprintf("  Output %d:", i))
+		if output.IsData() {
+			lines = append(lines, fmt.Sprintf("    Data:   %x", output.Data))
+			continue
+		}
 		lines = append(lines, fmt.Sprintf("    Value:  %d", output.Value))
 		lines = append(lines, fmt.Sprintf("    Script: %x", output.PubKeyHash))
+		if output.ScriptType == ScriptMultisig {
+			lines = append(lines, "    Type:   multisig")
+		}
 	}
 
 	return strings.Join(lines, "\n")
@@ -0,0 +1,162 @@
+package core
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"testing"
+
+	"my-blockchain/wallet"
+)
+
+// highS returns a copy of tx, whose single input is signed, with the signature's S
+// replaced by n-S: still valid ECDSA, but a different transaction ID.
+func highS(t *testing.T, tx *Transaction) *Transaction {
+	t.Helper()
+	var sig struct{ R, S *big.Int }
+	if _, err := asn1.Unmarshal(tx.Vin[0].Signature, &sig); err != nil {
+		t.Fatal(err)
+	}
+	sig.S.Sub(elliptic.P256().Params().N, sig.S)
+	flipped, err := asn1.Marshal(sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	malleated := *tx
+	malleated.Vin = append([]TxInput(nil), tx.Vin...)
+	malleated.Vin[0].Signature = flipped
+	malleated.ID = malleated.Hash()
+	return &malleated
+}
+
+func TestHighSRejectedFromMempool(t *testing.T) {
+	bc, w := newTestChain(t)
+	coinbase := mustBlock(t, bc, bc.Tip()).Transactions[0]
+	tx := spend(t, bc, w, coinbase, 0, 9)
+
+	if _, err := bc.CheckPendingTx(highS(t, tx), NewMempool()); !errors.Is(err, ErrInvalidTransaction) {
+		t.Fatalf("high-S transaction offered for the mempool: got %v, want ErrInvalidTransaction", err)
+	}
+	if _, err := bc.CheckPendingTx(tx, NewMempool()); err != nil {
+		t.Fatalf("low-S original: %v", err)
+	}
+}
+
+func TestLowSEnforcedInBlocksFromActivationHeight(t *testing.T) {
+	cfg := RegtestConfig
+	cfg.LowSHeight = 3
+	bc, w := newTestChainWith(t, cfg)
+	genesis := mustBlock(t, bc, bc.Tip())
+	coinbase := genesis.Transactions[0]
+	first := payTo(t, bc, w, coinbase, 0, 10, string(w.GetAddress()))
+
+	// Below the activation height a high-S signature is still valid in a block.
+	block2 := mineOn(t, bc, genesis, 2, highS(t, first))
+	if err := bc.PutBlock(block2.Serialize()); err != nil {
+		t.Fatalf("high-S transaction below LowSHeight: %v", err)
+	}
+
+	prev := block2.Transactions[1]
+	high := mineOn(t, bc, block2, 3, highS(t, spend(t, bc, w, prev, 0, 9)))
+	if err := bc.PutBlock(high.Serialize()); !errors.Is(err, ErrInvalidTransaction) {
+		t.Fatalf("high-S transaction at LowSHeight: got %v, want ErrInvalidTransaction", err)
+	}
+	low := mineOn(t, bc, block2, 3, spend(t, bc, w, prev, 0, 9))
+	if err := bc.PutBlock(low.Serialize()); err != nil {
+		t.Fatalf("low-S transaction at LowSHeight: %v", err)
+	}
+}
+
+func TestCompressedAndUncompressedKeysBothSpend(t *testing.T) {
+	bc, uncompressed := newTestChain(t)
+	compressed := *uncompressed
+	compressed.Compressed = true
+	if len(compressed.PubKey()) != 33 || len(uncompressed.PubKey()) != 65 {
+		t.Fatalf("public keys are %d and %d bytes, want 33 and 65", len(compressed.PubKey()), len(uncompressed.PubKey()))
+	}
+	if string(compressed.GetAddress()) == string(uncompressed.GetAddress()) {
+		t.Fatal("compressed and uncompressed keys share an address")
+	}
+
+	prev := &Transaction{
+		Vin: []TxInput{{Txid: []byte("funding"), Vout: 0}},
+		Vout: []TxOutput{
+			*NewTxOutput(5, string(uncompressed.GetAddress())),
+			*NewTxOutput(5, string(compressed.GetAddress())),
+		},
+	}
+	prev.ID = prev.Hash()
+	prevTXs := map[string]Transaction{hex.EncodeToString(prev.ID): *prev}
+
+	for vout, w := range []*wallet.Wallet{uncompressed, &compressed} {
+		tx := spend(t, bc, w, prev, vout, 5)
+		if !tx.Verify(prevTXs) {
+			t.Errorf("spend of output %d by its own key form does not verify", vout)
+		}
+	}
+
+	wrongForm := spend(t, bc, &compressed, prev, 0, 5)
+	if wrongForm.Verify(prevTXs) {
+		t.Error("compressed key spent an output locked to the uncompressed address")
+	}
+}
+
+func TestBlockRewardHalves(t *testing.T) {
+	cfg := RegtestConfig
+	cfg.Subsidy = 10
+	cfg.HalvingInterval = 100
+	tests := []struct{ height, want int }{
+		{1, 10},
+		{99, 10},
+		{100, 5},
+		{199, 5},
+		{200, 2},
+		{300, 1},
+		{399, 1},
+		{400, 0},
+		{1 << 40, 0},
+	}
+	for _, tt := range tests {
+		if got := cfg.BlockReward(tt.height); got != tt.want {
+			t.Errorf("BlockReward(%d) = %d, want %d", tt.height, got, tt.want)
+		}
+	}
+	if got := cfg.CoinbaseTx(string(wallet.NewWallet().GetAddress()), "", 100).Vout[0].Value; got != 5 {
+		t.Errorf("coinbase at the first halving pays %d, want 5", got)
+	}
+}
+
+func TestDecodeTransactionRoundTrip(t *testing.T) {
+	bc, w := newTestChain(t)
+	genesis := mustBlock(t, bc, bc.Tip())
+	for _, tx := range []*Transaction{genesis.Transactions[0], spend(t, bc, w, genesis.Transactions[0], 0, 10)} {
+		raw := tx.Serialize()
+		decoded, err := DecodeTransaction(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(decoded.Hash(), tx.ID) || !bytes.Equal(decoded.Serialize(), raw) {
+			t.Fatalf("decoded %x does not match the serialized transaction", decoded.Hash())
+		}
+		if decoded.IsCoinbase() != tx.IsCoinbase() {
+			t.Fatalf("decoded coinbase flag %v, want %v", decoded.IsCoinbase(), tx.IsCoinbase())
+		}
+	}
+}
+
+func TestDecodeTransactionRejectsGarbage(t *testing.T) {
+	bc, _ := newTestChain(t)
+	raw := mustBlock(t, bc, bc.Tip()).Transactions[0].Serialize()
+	for name, data := range map[string][]byte{
+		"empty":     nil,
+		"not gob":   []byte("not a transaction"),
+		"truncated": raw[:len(raw)/2],
+	} {
+		if tx, err := DecodeTransaction(data); err == nil {
+			t.Errorf("%s: decoded %+v, want an error", name, tx)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package core
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"log"
+
+	"go.etcd.io/bbolt"
+)
+
+// blockUndoBucket maps a block hash to the outputs that block spent, so its effect on the
+// chainstate can be reversed when it leaves the main chain.
+const blockUndoBucket = "blockundo"
+
+// ErrNoUndoData is returned when rolling back a block connected before undo records existed.
+var ErrNoUndoData = errors.New("no undo data for block")
+
+// spentOutput is one output a block consumed, with the metadata needed to put it back.
+type spentOutput struct {
+	Txid     []byte
+	Vout     int
+	Output   TxOutput
+	Height   int
+	Coinbase bool
+}
+
+type blockUndo struct {
+	Spent []spentOutput
+}
+
+func (u blockUndo) serialize() []byte {
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	if err := enc.Encode(u); err != nil {
+		log.Panic(err)
+	}
+	return buf.Bytes()
+}
+
+func deserializeUndo(data []byte) (blockUndo, error) {
+	var u blockUndo
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&u)
+	return u, err
+}
+
+// Rollback reverses a main-chain block's effect on the chainstate: the outputs it created
+// are removed and the outputs it spent are restored. It must be called on the current
+// tip block, one block at a time.
+func (u UTXOSet) Rollback(block *Block) error {
+	return u.Blockchain.db.Update(func(tx *bbolt.Tx) error {
+		return rollbackUTXOSet(tx, block)
+	})
+}
+
+// rollbackUTXOSet is Rollback inside the caller's write transaction. It also drops the
+// block from the address and main-chain indexes.
+func rollbackUTXOSet(tx *bbolt.Tx, block *Block) error {
+	undoBucket := tx.Bucket([]byte(blockUndoBucket))
+	var data []byte
+	if undoBucket != nil {
+		data = undoBucket.Get(block.Hash)
+	}
+	if data == nil {
+		return fmt.Errorf("%w %x", ErrNoUndoData, block.Hash)
+	}
+	undo, err := deserializeUndo(data)
+	if err != nil {
+		return err
+	}
+
+	b, err := tx.CreateBucketIfNotExists([]byte(utxoBucket))
+	if err != nil {
+		return err
+	}
+	created := make(map[string]bool, len(block.Transactions))
+	for _, t := range block.Transactions {
+		created[string(t.ID)] = true
+		if err := b.Delete(t.ID); err != nil {
+			return err
+		}
+	}
+	for _, s := range undo.Spent {
+		// Outputs created and spent within the block are already gone.
+		if created[string(s.Txid)] {
+			continue
+		}
+		outs := TxOutputs{Outputs: make(map[int]TxOutput), Height: s.Height, Coinbase: s.Coinbase}
+		if existing := b.Get(s.Txid); existing != nil {
+			outs = DeserializeOutputs(existing)
+		}
+		outs.Outputs[s.Vout] = s.Output
+		if err := b.Put(s.Txid, outs.Serialize()); err != nil {
+			return err
+		}
+	}
+	if err := unindexBlockAddresses(tx, block); err != nil {
+		return err
+	}
+	if err := deleteMainChainHash(tx, block); err != nil {
+		return err
+	}
+	return undoBucket.Delete(block.Hash)
+}
@@ -0,0 +1,43 @@
+package core
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// utxoSnapshot returns a copy of the whole UTXO set.
+func utxoSnapshot(u UTXOSet) map[string]TxOutputs {
+	set := make(map[string]TxOutputs)
+	u.forEach(func(txID string, outs TxOutputs) bool {
+		set[txID] = outs
+		return true
+	})
+	return set
+}
+
+func TestRollbackRestoresUTXOSet(t *testing.T) {
+	bc, w := newTestChain(t)
+	u := UTXOSet{Blockchain: bc}
+	genesis := mustBlock(t, bc, bc.Tip())
+	before := utxoSnapshot(u)
+
+	// The block spends the genesis output, and also an output it creates itself.
+	first := payTo(t, bc, w, genesis.Transactions[0], 0, 10, string(w.GetAddress()))
+	second := spend(t, bc, w, first, 0, 10)
+	block := mineOn(t, bc, genesis, 2, first, second)
+	putAll(t, bc, block)
+	if u.IsUnspent(genesis.Transactions[0].ID, 0) || !u.IsUnspent(second.ID, 0) {
+		t.Fatal("block not applied to the UTXO set")
+	}
+
+	if err := u.Rollback(block); err != nil {
+		t.Fatal(err)
+	}
+	if after := utxoSnapshot(u); !reflect.DeepEqual(after, before) {
+		t.Fatalf("UTXO set after rollback:\n%v\nwant:\n%v", after, before)
+	}
+	if err := u.Rollback(block); !errors.Is(err, ErrNoUndoData) {
+		t.Fatalf("rolling back the same block twice: got %v, want ErrNoUndoData", err)
+	}
+}
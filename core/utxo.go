@@ -2,12 +2,431 @@ package core
 
 import (
 	"bytes"
+	"encoding/gob"
 	"encoding/hex"
+	"errors"
+	"fmt"
 	"log"
+	"math"
+	"sort"
+
+	"go.etcd.io/bbolt"
 
 	"my-blockchain/wallet"
 )
 
+// utxoBucket is the chainstate index: tx ID -> serialized TxOutputs still unspent.
+const utxoBucket = "chainstate"
+
+// TxOutputs holds the unspent outputs of one transaction keyed by output index,
+// so partially spent transactions keep their original indexes.
+type TxOutputs struct {
+	Outputs map[int]TxOutput
+	// Height is the height of the block that created the outputs, for coinbase maturity.
+	Height   int
+	Coinbase bool
+}
+
+func (outs TxOutputs) Serialize() []byte {
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	if err := enc.Encode(outs); err != nil {
+		log.Panic(err)
+	}
+	return buf.Bytes()
+}
+
+func DeserializeOutputs(data []byte) TxOutputs {
+	var outs TxOutputs
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(&outs); err != nil {
+		log.Panic(err)
+	}
+	if outs.Outputs == nil {
+		outs.Outputs = make(map[int]TxOutput)
+	}
+	return outs
+}
+
+// sortedIndexes returns the output indexes of outs in ascending order.
+func (outs TxOutputs) sortedIndexes() []int {
+	idxs := make([]int, 0, len(outs.Outputs))
+	for idx := range outs.Outputs {
+		idxs = append(idxs, idx)
+	}
+	sort.Ints(idxs)
+	return idxs
+}
+
+// UTXOSet answers balance and coin-selection queries from the chainstate bucket
+// instead of scanning every block.
+type UTXOSet struct {
+	Blockchain *Blockchain
+}
+
+// Reindex rebuilds the chainstate bucket from a full scan of the main chain. It fails with
+// ErrBlockPruned on a pruned chain, whose chainstate cannot be recovered.
+func (u UTXOSet) Reindex() error {
+	if u.Blockchain.PruneHeight() > 0 {
+		return fmt.Errorf("cannot rebuild the UTXO set: %w", ErrBlockPruned)
+	}
+	utxo := u.Blockchain.FindAllUTXO()
+	defer u.Blockchain.utxoCache.reset()
+
+	err := u.Blockchain.db.Update(func(tx *bbolt.Tx) error {
+		if tx.Bucket([]byte(utxoBucket)) != nil {
+			if err := tx.DeleteBucket([]byte(utxoBucket)); err != nil {
+				return err
+			}
+		}
+		b, err := tx.CreateBucket([]byte(utxoBucket))
+		if err != nil {
+			return err
+		}
+		for txID, outs := range utxo {
+			key, err := hex.DecodeString(txID)
+			if err != nil {
+				return err
+			}
+			if err := b.Put(key, outs.Serialize()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return err
+}
+
+// Update applies a newly connected main-chain block at height to the chainstate bucket.
+func (u UTXOSet) Update(block *Block, height int) error {
+	return u.Blockchain.db.Update(func(tx *bbolt.Tx) error {
+		return updateUTXOSet(tx, block, height)
+	})
+}
+
+// updateUTXOSet removes the outputs block spends and adds the outputs it creates, saving
+// the spent outputs as the block's undo record, and adds the block to the address and
+// main-chain indexes. Spending an output that is not in the set is an error. It runs inside the caller's write transaction so the indexes move together with the tip.
+func updateUTXOSet(tx *bbolt.Tx, block *Block, height int) error {
+	b, err := tx.CreateBucketIfNotExists([]byte(utxoBucket))
+	if err != nil {
+		return err
+	}
+	undoBucket, err := tx.CreateBucketIfNotExists([]byte(blockUndoBucket))
+	if err != nil {
+		return err
+	}
+
+	var undo blockUndo
+	for _, t := range block.Transactions {
+		if !t.IsCoinbase() {
+			for _, vin := range t.Vin {
+				// A missing output was spent before or never existed: the block is
+				// invalid, and returning aborts the caller's transaction.
+				data := b.Get(vin.Txid)
+				if data == nil {
+					return fmt.Errorf("%w: %x spends %x:%d, which is not in the UTXO set", ErrDoubleSpend, t.ID, vin.Txid, vin.Vout)
+				}
+				outs := DeserializeOutputs(data)
+				out, ok := outs.Outputs[vin.Vout]
+				if !ok {
+					return fmt.Errorf("%w: %x spends %x:%d, which is not in the UTXO set", ErrDoubleSpend, t.ID, vin.Txid, vin.Vout)
+				}
+				undo.Spent = append(undo.Spent, spentOutput{
+					Txid:     bytes.Clone(vin.Txid),
+					Vout:     vin.Vout,
+					Output:   out,
+					Height:   outs.Height,
+					Coinbase: outs.Coinbase,
+				})
+				delete(outs.Outputs, vin.Vout)
+				if len(outs.Outputs) == 0 {
+					err = b.Delete(vin.Txid)
+				} else {
+					err = b.Put(vin.Txid, outs.Serialize())
+				}
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		newOutputs := TxOutputs{Outputs: make(map[int]TxOutput, len(t.Vout)), Height: height, Coinbase: t.IsCoinbase()}
+		for idx, out := range t.Vout {
+			if !out.IsData() {
+				newOutputs.Outputs[idx] = out
+			}
+		}
+		if len(newOutputs.Outputs) == 0 {
+			continue
+		}
+		if err := b.Put(t.ID, newOutputs.Serialize()); err != nil {
+			return err
+		}
+	}
+	if err := undoBucket.Put(block.Hash, undo.serialize()); err != nil {
+		return err
+	}
+	if err := putMainChainHash(tx, height, block.Hash); err != nil {
+		return err
+	}
+	return indexBlockAddresses(tx, block, height)
+}
+
+// indexed reports whether the chainstate bucket exists. Databases created before the
+// index existed (and opened read-only) fall back to full chain scans.
+func (u UTXOSet) indexed() bool {
+	found := false
+	_ = u.Blockchain.db.View(func(tx *bbolt.Tx) error {
+		found = tx.Bucket([]byte(utxoBucket)) != nil
+		return nil
+	})
+	return found
+}
+
+// forEach calls fn for every transaction in the chainstate bucket.
+func (u UTXOSet) forEach(fn func(txID string, outs TxOutputs) bool) {
+	err := u.Blockchain.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(utxoBucket))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if !fn(hex.EncodeToString(k), DeserializeOutputs(v)) {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// forEachOrdered calls fn for every transaction with unspent outputs in tx ID order,
+// from the chainstate bucket or, without one, a full chain scan.
+func (u UTXOSet) forEachOrdered(fn func(txID string, outs TxOutputs)) {
+	if u.indexed() {
+		u.forEach(func(txID string, outs TxOutputs) bool {
+			fn(txID, outs)
+			return true
+		})
+		return
+	}
+	utxo := u.Blockchain.FindAllUTXO()
+	txIDs := make([]string, 0, len(utxo))
+	for txID := range utxo {
+		txIDs = append(txIDs, txID)
+	}
+	sort.Strings(txIDs)
+	for _, txID := range txIDs {
+		fn(txID, utxo[txID])
+	}
+}
+
+// UnspentOutput is one coin of a wallet: an output that has not been spent on the main chain.
+type UnspentOutput struct {
+	TxID  []byte
+	Vout  int
+	Value int
+	// Height is the height of the block that created the output.
+	Height   int
+	Coinbase bool
+	// Mature is false for coinbase outputs that cannot be spent in the next block yet.
+	Mature bool
+}
+
+// ListUTXOs returns every unspent output locked to pubKeyHash, ordered by outpoint.
+func (bc *Blockchain) ListUTXOs(pubKeyHash []byte) []UnspentOutput {
+	var list []UnspentOutput
+	spendHeight := bc.BestHeight() + 1
+	UTXOSet{Blockchain: bc}.forEachOrdered(func(txID string, outs TxOutputs) {
+		for _, idx := range outs.sortedIndexes() {
+			out := outs.Outputs[idx]
+			if !out.IsLockedWithKey(pubKeyHash) {
+				continue
+			}
+			id, err := hex.DecodeString(txID)
+			if err != nil {
+				log.Panic(err)
+			}
+			list = append(list, UnspentOutput{
+				TxID:     id,
+				Vout:     idx,
+				Value:    out.Value,
+				Height:   outs.Height,
+				Coinbase: outs.Coinbase,
+				Mature:   bc.config.outputsMature(outs, spendHeight),
+			})
+		}
+	})
+	return list
+}
+
+// FindUTXO returns the unspent outputs locked to pubKeyHash. Results are cached until the
+// tip moves, so repeated balance queries on an unchanged chain skip the scan.
+func (u UTXOSet) FindUTXO(pubKeyHash []byte) []TxOutput {
+	bc := u.Blockchain
+	if UTXOs, ok := bc.utxoCache.get(bc.Tip(), pubKeyHash); ok {
+		return UTXOs
+	}
+	tip, UTXOs := u.findUTXO(pubKeyHash)
+	bc.utxoCache.put(tip, pubKeyHash, UTXOs)
+	return UTXOs
+}
+
+// findUTXO scans for FindUTXO and returns the tip the outputs were read at.
+func (u UTXOSet) findUTXO(pubKeyHash []byte) ([]byte, []TxOutput) {
+	if !u.indexed() {
+		tip := u.Blockchain.Tip()
+		return tip, u.Blockchain.FindUTXO(pubKeyHash)
+	}
+	var tip []byte
+	var UTXOs []TxOutput
+	err := u.Blockchain.db.View(func(tx *bbolt.Tx) error {
+		tip = bytes.Clone(tx.Bucket([]byte(blocksBucket)).Get([]byte(lastHashKey)))
+		return tx.Bucket([]byte(utxoBucket)).ForEach(func(_, v []byte) error {
+			outs := DeserializeOutputs(v)
+			for _, idx := range outs.sortedIndexes() {
+				if out := outs.Outputs[idx]; out.IsLockedWithKey(pubKeyHash) {
+					UTXOs = append(UTXOs, out)
+				}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+	return tip, UTXOs
+}
+
+func (u UTXOSet) FindSpendableOutputs(pubKeyHash []byte, amount int) (int, map[string][]int) {
+	return u.findSpendableOutputs(pubKeyHash, amount, nil)
+}
+
+// findSpendableOutputs is FindSpendableOutputs skipping any output listed in exclude
+// (hex tx ID -> output indexes), e.g. outputs already claimed by pending transactions.
+// Coinbase outputs that would still be immature in the next block are never selected.
+func (u UTXOSet) findSpendableOutputs(pubKeyHash []byte, amount int, exclude map[string][]int) (int, map[string][]int) {
+	if !u.indexed() {
+		return u.Blockchain.findSpendableOutputs(pubKeyHash, amount, exclude)
+	}
+	unspentOutputs := make(map[string][]int)
+	accumulated := 0
+	spendHeight := u.Blockchain.BestHeight() + 1
+	u.forEach(func(txID string, outs TxOutputs) bool {
+		if !u.Blockchain.config.outputsMature(outs, spendHeight) {
+			return true
+		}
+		for _, idx := range outs.sortedIndexes() {
+			if containsInt(exclude[txID], idx) {
+				continue
+			}
+			if out := outs.Outputs[idx]; out.IsLockedWithKey(pubKeyHash) {
+				accumulated += out.Value
+				unspentOutputs[txID] = append(unspentOutputs[txID], idx)
+				if accumulated >= amount {
+					return false
+				}
+			}
+		}
+		return true
+	})
+	return accumulated, unspentOutputs
+}
+
+// IsUnspent reports whether output vout of transaction txID is still unspent on the main chain.
+func (u UTXOSet) IsUnspent(txID []byte, vout int) bool {
+	if !u.indexed() {
+		unspent := u.Blockchain.FindAllUTXO()
+		_, ok := unspent[hex.EncodeToString(txID)].Outputs[vout]
+		return ok
+	}
+	found := false
+	_ = u.Blockchain.db.View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket([]byte(utxoBucket)).Get(txID)
+		if data == nil {
+			return nil
+		}
+		_, found = DeserializeOutputs(data).Outputs[vout]
+		return nil
+	})
+	return found
+}
+
+// InputsUnspent reports whether none of tx's inputs has already been spent in the chain.
+func (bc *Blockchain) InputsUnspent(tx *Transaction) bool {
+	return bc.inputsUnspent(tx, nil)
+}
+
+// inputsUnspent is InputsUnspent where inputs spending outputs of pending transactions
+// (see findPrevTx), which are not on the chain yet, count as unspent.
+func (bc *Blockchain) inputsUnspent(tx *Transaction, pending map[string]*Transaction) bool {
+	if tx.IsCoinbase() {
+		return true
+	}
+	u := UTXOSet{Blockchain: bc}
+	for _, in := range tx.Vin {
+		if _, ok := pending[hex.EncodeToString(in.Txid)]; ok {
+			continue
+		}
+		if !u.IsUnspent(in.Txid, in.Vout) {
+			return false
+		}
+	}
+	return true
+}
+
+// FindAllUTXO scans the main chain and returns every unspent output keyed by hex tx ID.
+func (bc *Blockchain) FindAllUTXO() map[string]TxOutputs {
+	utxo := make(map[string]TxOutputs)
+	spentTXOs := make(map[string][]int)
+	if len(bc.Tip()) == 0 {
+		return utxo
+	}
+
+	// Walking from the tip, heights count down to 1 at genesis.
+	height := bc.BestHeight()
+	it := bc.Iterator()
+	for {
+		block := it.Next()
+		if block == nil {
+			break
+		}
+
+		for _, tx := range block.Transactions {
+			txID := hex.EncodeToString(tx.ID)
+
+			for outIdx, out := range tx.Vout {
+				if out.IsData() || containsInt(spentTXOs[txID], outIdx) {
+					continue
+				}
+				outs, ok := utxo[txID]
+				if !ok {
+					outs = TxOutputs{Outputs: make(map[int]TxOutput), Height: height, Coinbase: tx.IsCoinbase()}
+					utxo[txID] = outs
+				}
+				outs.Outputs[outIdx] = out
+			}
+
+			if !tx.IsCoinbase() {
+				for _, in := range tx.Vin {
+					inTxID := hex.EncodeToString(in.Txid)
+					spentTXOs[inTxID] = append(spentTXOs[inTxID], in.Vout)
+				}
+			}
+		}
+
+		height--
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+	return utxo
+}
+
 func (bc *Blockchain) FindUnspentTransactions(pubKeyHash []byte) []Transaction {
 	var unspentTXs []Transaction
 	spentTXOs := make(map[string][]int)
@@ -15,6 +434,9 @@ func (bc *Blockchain) FindUnspentTransactions(pubKeyHash []byte) []Transaction {
 	it := bc.Iterator()
 	for {
 		block := it.Next()
+		if block == nil {
+			break
+		}
 
 		for _, tx := range block.Transactions {
 			txID := hex.EncodeToString(tx.ID)
@@ -66,19 +488,37 @@ func (bc *Blockchain) FindUTXO(pubKeyHash []byte) []TxOutput {
 }
 
 func (bc *Blockchain) FindSpendableOutputs(pubKeyHash []byte, amount int) (int, map[string][]int) {
+	return bc.findSpendableOutputs(pubKeyHash, amount, nil)
+}
+
+// findSpendableOutputs is FindSpendableOutputs skipping any output listed in exclude
+// (hex tx ID -> output indexes), e.g. outputs already claimed by pending transactions.
+func (bc *Blockchain) findSpendableOutputs(pubKeyHash []byte, amount int, exclude map[string][]int) (int, map[string][]int) {
 	unspentOutputs := make(map[string][]int)
-	unspentTxs := bc.FindUnspentTransactions(pubKeyHash)
 	accumulated := 0
+	spendHeight := bc.BestHeight() + 1
 
-Work:
-	for _, tx := range unspentTxs {
-		txID := hex.EncodeToString(tx.ID)
-		for outIdx, out := range tx.Vout {
-			if out.IsLockedWithKey(pubKeyHash) && accumulated < amount {
+	utxo := bc.FindAllUTXO()
+	txIDs := make([]string, 0, len(utxo))
+	for txID := range utxo {
+		txIDs = append(txIDs, txID)
+	}
+	sort.Strings(txIDs)
+
+	for _, txID := range txIDs {
+		outs := utxo[txID]
+		if !bc.config.outputsMature(outs, spendHeight) {
+			continue
+		}
+		for _, outIdx := range outs.sortedIndexes() {
+			if containsInt(exclude[txID], outIdx) {
+				continue
+			}
+			if out := outs.Outputs[outIdx]; out.IsLockedWithKey(pubKeyHash) {
 				accumulated += out.Value
 				unspentOutputs[txID] = append(unspentOutputs[txID], outIdx)
 				if accumulated >= amount {
-					break Work
+					return accumulated, unspentOutputs
 				}
 			}
 		}
@@ -87,58 +527,319 @@ Work:
 	return accumulated, unspentOutputs
 }
 
-func NewUTXOTransaction(from, to string, amount int, bc *Blockchain, ws *wallet.Wallets) *Transaction {
-	if !wallet.ValidateAddress(from) || !wallet.ValidateAddress(to) {
-		log.Panic("invalid from/to address")
+func containsInt(values []int, v int) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
 	}
+	return false
+}
 
-	w, ok := ws.GetWallet(from)
-	if !ok {
-		log.Panic("sender wallet not found; createwallet first")
+var (
+	ErrInsufficientFunds = errors.New("not enough funds")
+	// ErrWalletNotFound means the local wallets cannot sign for the sender address.
+	ErrWalletNotFound = errors.New("sender wallet not found; createwallet first")
+	// ErrValueMismatch means a built transaction does not balance its inputs; it indicates a bug.
+	ErrValueMismatch = errors.New("transaction value mismatch")
+)
+
+func NewUTXOTransaction(from, to string, amount int, bc *Blockchain, ws *wallet.Wallets) (*Transaction, error) {
+	return NewUTXOTransactionWithFee(from, to, amount, 0, bc, ws)
+}
+
+// NewUTXOTransactionWithFee builds and signs a transaction that leaves fee unclaimed
+// for the miner; only inputs - (amount + fee) is returned to the sender as change.
+func NewUTXOTransactionWithFee(from, to string, amount, fee int, bc *Blockchain, ws *wallet.Wallets) (*Transaction, error) {
+	return newUTXOTransaction(from, map[string]int{to: amount}, TxOptions{Fee: fee}, bc, ws, nil)
+}
+
+// TxOptions are the optional settings of a new transaction.
+type TxOptions struct {
+	// Fee is left unclaimed for the miner.
+	Fee int
+	// LockTime keeps the transaction out of blocks below that height (or, from
+	// lockTimeThreshold on, Unix time).
+	LockTime int
+	// Replaceable lets a higher-fee transaction replace it while it is pending.
+	Replaceable bool
+	// Data, when set, is embedded in an extra data output (see NewDataOutput).
+	Data []byte
+	// CoinSelection chooses the inputs; the zero value is SelectFirstFit.
+	CoinSelection CoinSelection
+}
+
+// NewUTXOTransactionWithOptions is NewUTXOTransactionWithFee with every option.
+func NewUTXOTransactionWithOptions(from, to string, amount int, opts TxOptions, bc *Blockchain, ws *wallet.Wallets) (*Transaction, error) {
+	return newUTXOTransaction(from, map[string]int{to: amount}, opts, bc, ws, nil)
+}
+
+// NewUTXOTransactionMulti builds and signs one transaction paying every address in
+// outputs its amount, with a single change output back to from. Payments are
+// ordered by address.
+func NewUTXOTransactionMulti(from string, outputs map[string]int, bc *Blockchain, ws *wallet.Wallets) (*Transaction, error) {
+	return newUTXOTransaction(from, outputs, TxOptions{}, bc, ws, nil)
+}
+
+// NewUTXOTransactionMultiWithOptions is NewUTXOTransactionMulti with every option.
+func NewUTXOTransactionMultiWithOptions(from string, outputs map[string]int, opts TxOptions, bc *Blockchain, ws *wallet.Wallets) (*Transaction, error) {
+	return newUTXOTransaction(from, outputs, opts, bc, ws, nil)
+}
+
+// NewPendingUTXOTransaction is NewUTXOTransactionWithOptions for a node with a mempool:
+// it never selects outputs that a pending transaction in mp already spends, and when the
+// confirmed outputs are not enough it also spends outputs of pending transactions, such
+// as the change of an earlier send. Such a transaction is mined after those it spends.
+func NewPendingUTXOTransaction(from, to string, amount int, opts TxOptions, bc *Blockchain, ws *wallet.Wallets, mp *Mempool) (*Transaction, error) {
+	return NewPendingUTXOTransactionMulti(from, map[string]int{to: amount}, opts, bc, ws, mp)
+}
+
+// NewPendingUTXOTransactionMulti is NewPendingUTXOTransaction paying several addresses.
+func NewPendingUTXOTransactionMulti(from string, outputs map[string]int, opts TxOptions, bc *Blockchain, ws *wallet.Wallets, mp *Mempool) (*Transaction, error) {
+	return newUTXOTransaction(from, outputs, opts, bc, ws, mp)
+}
+
+// Funding is how a transaction would be paid for: the outputs it would spend, the change
+// back to the sender and the fee, as FundTransaction works them out.
+type Funding struct {
+	// Inputs are the outputs spent, in the order the transaction lists them.
+	Inputs []FundingInput
+	// InputValue is what Inputs hold together.
+	InputValue int
+	// Amount is paid to the recipients.
+	Amount int
+	// Change goes back to the sender. It is 0 when the remainder is below the chain's
+	// DustThreshold, which is then left to the miner as part of Fee.
+	Change int
+	Fee    int
+}
+
+// FundingInput is one output a transaction would spend.
+type FundingInput struct {
+	TxID  []byte
+	Vout  int
+	Value int
+	// Unconfirmed is set for an output of a pending transaction in the mempool.
+	Unconfirmed bool
+}
+
+// FundTransaction runs the checks and coin selection of NewPendingUTXOTransactionMulti
+// without building or signing anything: it reports which outputs the send would spend,
+// its change and its fee, or why it would fail. ws, when not nil, must be able to sign
+// for from, as for the send itself; mp may be nil.
+func FundTransaction(from string, payments map[string]int, opts TxOptions, bc *Blockchain, ws *wallet.Wallets, mp *Mempool) (*Funding, error) {
+	if _, _, err := checkPayments(from, payments, opts, bc.config.DustThreshold); err != nil {
+		return nil, err
+	}
+	if ws != nil {
+		if _, _, err := senderSigners(from, ws); err != nil {
+			return nil, err
+		}
 	}
+	return fundTransaction(from, payments, opts, bc, mp)
+}
 
-	fromPubKeyHash := wallet.PubKeyHashFromAddress(from)
-	toPubKeyHash := wallet.PubKeyHashFromAddress(to)
-	if fromPubKeyHash == nil || toPubKeyHash == nil {
-		log.Panic("invalid address")
+// checkPayments validates a send before any coins are looked at, refusing payments below
+// dustThreshold, and returns the recipients, ordered by address, and the data output if
+// opts carries data.
+func checkPayments(from string, payments map[string]int, opts TxOptions, dustThreshold int) ([]string, *TxOutput, error) {
+	var dataOutput *TxOutput
+	if len(opts.Data) > 0 {
+		var err error
+		if dataOutput, err = NewDataOutput(opts.Data); err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(payments) == 0 {
+		return nil, nil, errors.New("no outputs to pay")
 	}
+	recipients := make([]string, 0, len(payments))
+	amount := 0
+	for to, value := range payments {
+		if value <= 0 {
+			return nil, nil, errors.New("amount must be positive")
+		}
+		if value < dustThreshold {
+			return nil, nil, fmt.Errorf("%w: paying %d to %s, threshold %d", ErrDustOutput, value, to, dustThreshold)
+		}
+		if !wallet.ValidateAddress(to) {
+			return nil, nil, ErrInvalidAddress
+		}
+		if amount > math.MaxInt-value {
+			return nil, nil, errors.New("total amount overflows")
+		}
+		recipients = append(recipients, to)
+		amount += value
+	}
+	sort.Strings(recipients)
+	if opts.Fee < 0 {
+		return nil, nil, errors.New("fee must be non-negative")
+	}
+	if amount > math.MaxInt-opts.Fee {
+		return nil, nil, errors.New("amount plus fee overflows")
+	}
+	if opts.LockTime < 0 {
+		return nil, nil, errors.New("locktime must be non-negative")
+	}
+	if _, err := ParseCoinSelection(string(opts.CoinSelection)); err != nil {
+		return nil, nil, err
+	}
+	if !wallet.ValidateAddress(from) {
+		return nil, nil, ErrInvalidAddress
+	}
+	return recipients, dataOutput, nil
+}
 
-	acc, validOutputs := bc.FindSpendableOutputs(fromPubKeyHash, amount)
-	if acc < amount {
-		log.Panic("not enough funds")
+// fundTransaction selects the outputs of from that pay for payments, which checkPayments
+// has accepted.
+func fundTransaction(from string, payments map[string]int, opts TxOptions, bc *Blockchain, mp *Mempool) (*Funding, error) {
+	amount := 0
+	for _, value := range payments {
+		amount += value
 	}
+	fee := opts.Fee
+	fromPubKeyHash := wallet.PubKeyHashFromAddress(from)
 
-	var inputs []TxInput
-	var outputs []TxOutput
+	var exclude map[string][]int
+	if mp != nil {
+		exclude = mp.SpentOutputs()
+	}
+	acc, validOutputs := UTXOSet{Blockchain: bc}.selectSpendableOutputs(fromPubKeyHash, amount+fee, exclude, mp.unspentCoins(fromPubKeyHash), opts.CoinSelection)
+	if acc < amount+fee {
+		return nil, fmt.Errorf("%w: have %d, need %d", ErrInsufficientFunds, acc, amount+fee)
+	}
 
-	for txidStr, outs := range validOutputs {
+	f := &Funding{InputValue: acc, Amount: amount}
+	pending := mp.transactions()
+	// Inputs go in (txid, vout) order, not map order, so the same spend always builds the
+	// same unsigned transaction. Lowercase hex sorts like the bytes it encodes.
+	txIDs := make([]string, 0, len(validOutputs))
+	for txidStr := range validOutputs {
+		txIDs = append(txIDs, txidStr)
+	}
+	sort.Strings(txIDs)
+	for _, txidStr := range txIDs {
 		txIDBytes, err := hex.DecodeString(txidStr)
 		if err != nil {
-			log.Panic(err)
+			return nil, err
+		}
+		prev, _, err := bc.findPrevTx(txIDBytes, pending)
+		if err != nil {
+			return nil, err
 		}
+		_, unconfirmed := pending[txidStr]
+		outs := append([]int(nil), validOutputs[txidStr]...)
+		sort.Ints(outs)
 		for _, outIdx := range outs {
-			input := TxInput{Txid: txIDBytes, Vout: outIdx, Signature: nil, PubKey: w.PublicKey}
-			inputs = append(inputs, input)
+			f.Inputs = append(f.Inputs, FundingInput{TxID: txIDBytes, Vout: outIdx, Value: prev.Vout[outIdx].Value, Unconfirmed: unconfirmed})
 		}
 	}
 
+	// Change too small to be worth spending is left to the miner instead.
+	if change := acc - amount - fee; change >= bc.config.DustThreshold && change > 0 {
+		f.Change = change
+	} else {
+		fee += change
+	}
+	f.Fee = fee
+	return f, nil
+}
+
+// senderSigners returns the wallets in ws holding the keys for from's inputs and, for a
+// multisig sender, which needs m of them locally, its script.
+func senderSigners(from string, ws *wallet.Wallets) ([]*wallet.Wallet, []byte, error) {
+	// Encrypted wallets can only sign while unlocked.
+	if ws.Locked() {
+		return nil, nil, wallet.ErrWalletLocked
+	}
+	if wallet.IsMultisigAddress(from) {
+		script, ok := ws.GetMultisigScript(from)
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: multisig script unknown; createmultisig first", ErrWalletNotFound)
+		}
+		signers, err := ws.MultisigSigners(script)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrWalletNotFound, err)
+		}
+		return signers, script, nil
+	}
+	w, ok := ws.GetWallet(from)
+	if !ok && ws.IsWatchOnly(from) {
+		return nil, nil, fmt.Errorf("%w: cannot send from %s without its key", wallet.ErrWatchOnly, from)
+	}
+	if !ok {
+		return nil, nil, ErrWalletNotFound
+	}
+	return []*wallet.Wallet{w}, nil, nil
+}
+
+// newUTXOTransaction builds and signs the transaction for the exported constructors. mp,
+// when not nil, is the mempool whose spent outputs are skipped and whose unspent ones
+// may be spent.
+func newUTXOTransaction(from string, payments map[string]int, opts TxOptions, bc *Blockchain, ws *wallet.Wallets, mp *Mempool) (*Transaction, error) {
+	recipients, dataOutput, err := checkPayments(from, payments, opts, bc.config.DustThreshold)
+	if err != nil {
+		return nil, err
+	}
+
+	signers, script, err := senderSigners(from, ws)
+	if err != nil {
+		return nil, err
+	}
+	fromPubKeyHash := wallet.PubKeyHashFromAddress(from)
+
+	funding, err := fundTransaction(from, payments, opts, bc, mp)
+	if err != nil {
+		return nil, err
+	}
+
+	var inputs []TxInput
+	var outputs []TxOutput
+
+	for _, in := range funding.Inputs {
+		input := TxInput{Txid: in.TxID, Vout: in.Vout, Signature: nil, PubKey: signers[0].PubKey()}
+		if script != nil {
+			input.PubKey = script
+			for _, signer := range signers {
+				input.PubKeys = append(input.PubKeys, signer.PubKey())
+			}
+		}
+		inputs = append(inputs, input)
+	}
+
 	// outputs
-	outputs = append(outputs, TxOutput{Value: amount, PubKeyHash: append([]byte(nil), toPubKeyHash...)})
-	if acc > amount {
-		outputs = append(outputs, TxOutput{Value: acc - amount, PubKeyHash: append([]byte(nil), fromPubKeyHash...)})
+	for _, to := range recipients {
+		outputs = append(outputs, *NewTxOutput(payments[to], to))
+	}
+	if funding.Change > 0 {
+		outputs = append(outputs, *NewTxOutput(funding.Change, from))
+	}
+	if dataOutput != nil {
+		outputs = append(outputs, *dataOutput)
 	}
 
-	tx := &Transaction{ID: nil, Vin: inputs, Vout: outputs}
+	tx := &Transaction{ID: nil, Vin: inputs, Vout: outputs, LockTime: opts.LockTime, Replaceable: opts.Replaceable}
+	// Whatever the selected inputs hold must be paid out, returned as change or left as the fee.
+	if paid := tx.OutputValue(); paid+funding.Fee != funding.InputValue {
+		return nil, fmt.Errorf("%w: inputs %d != outputs %d + fee %d", ErrValueMismatch, funding.InputValue, paid, funding.Fee)
+	}
 	tx.ID = tx.Hash()
 
-	bc.SignTransaction(tx, w.PrivateECDSA())
+	pending := mp.transactions()
+	for _, signer := range signers {
+		if err := bc.signTransaction(tx, signer.PrivateECDSA(), pending); err != nil {
+			return nil, err
+		}
+	}
+	// The ID commits to the signatures too, so it can only be final once they exist.
+	tx.ID = tx.Hash()
 
 	// Basic sanity: ensure each input matches the sender key.
 	for _, vin := range tx.Vin {
 		if !bytes.Equal(wallet.HashPubKey(vin.PubKey), fromPubKeyHash) {
-			log.Panic("input pubkey does not match sender")
+			return nil, errors.New("input pubkey does not match sender")
 		}
 	}
 
-	return tx
+	return tx, nil
 }
@@ -0,0 +1,316 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+
+	"my-blockchain/wallet"
+)
+
+func TestSendFailuresReturnErrors(t *testing.T) {
+	bc, ws, from := newWalletChain(t)
+	to := string(wallet.NewWallet().GetAddress())
+
+	if _, err := NewUTXOTransaction(from, to, 11, bc, ws); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("spending 11 of 10: got %v, want ErrInsufficientFunds", err)
+	}
+	if _, err := NewUTXOTransaction(to, from, 5, bc, ws); !errors.Is(err, ErrWalletNotFound) {
+		t.Errorf("sending from an address not in the wallet file: got %v, want ErrWalletNotFound", err)
+	}
+	if _, err := NewUTXOTransaction(from, "not an address", 5, bc, ws); !errors.Is(err, ErrInvalidAddress) {
+		t.Errorf("paying an invalid address: got %v, want ErrInvalidAddress", err)
+	}
+
+	tip := bc.Tip()
+	tx, err := NewUTXOTransaction(from, to, 5, bc, ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.Vout[0].Value++
+	tx.ID = tx.Hash()
+	if _, err := bc.AddBlock([]*Transaction{bc.config.CoinbaseTx(to, "", 2), tx}); !errors.Is(err, ErrInvalidTransaction) {
+		t.Fatalf("mining a transaction altered after signing: got %v, want ErrInvalidTransaction", err)
+	}
+	if !bytes.Equal(bc.Tip(), tip) {
+		t.Fatalf("tip moved to %x", bc.Tip())
+	}
+}
+
+func TestSendManyPaysEveryRecipientAndChange(t *testing.T) {
+	bc, ws, from := newWalletChain(t)
+	a := string(wallet.NewWallet().GetAddress())
+	b := string(wallet.NewWallet().GetAddress())
+	miner := string(wallet.NewWallet().GetAddress())
+
+	tx, err := NewUTXOTransactionMulti(from, map[string]int{a: 4, b: 3}, bc, ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tx.Vin) != 1 || len(tx.Vout) != 3 {
+		t.Fatalf("got %d inputs and %d outputs, want 1 and 3", len(tx.Vin), len(tx.Vout))
+	}
+	change := tx.Vout[len(tx.Vout)-1]
+	if !change.IsLockedWithKey(wallet.PubKeyHashFromAddress(from)) || change.Value != 3 {
+		t.Fatalf("last output pays %d to %x, want change of 3 to the sender", change.Value, change.PubKeyHash)
+	}
+	if _, err := bc.AddBlock([]*Transaction{bc.config.CoinbaseTx(miner, "", 2), tx}); err != nil {
+		t.Fatal(err)
+	}
+
+	for addr, want := range map[string]int{a: 4, b: 3, from: 3} {
+		if got := balance(bc, addr); got != want {
+			t.Errorf("balance of %s is %d, want %d", addr, got, want)
+		}
+	}
+}
+
+func TestSendBalancesInputsAndOutputs(t *testing.T) {
+	tests := []struct {
+		name    string
+		amount  int
+		outputs []int
+		err     error
+	}{
+		{"exact funds", 10, []int{10}, nil},
+		{"surplus funds", 6, []int{6, 4}, nil},
+		{"insufficient funds", 11, nil, ErrInsufficientFunds},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bc, ws, from := newWalletChain(t)
+			to := string(wallet.NewWallet().GetAddress())
+
+			tx, err := NewUTXOTransaction(from, to, tt.amount, bc, ws)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			var values []int
+			total := 0
+			for _, out := range tx.Vout {
+				values = append(values, out.Value)
+				total += out.Value
+			}
+			if !slices.Equal(values, tt.outputs) {
+				t.Fatalf("outputs %v, want %v", values, tt.outputs)
+			}
+			if total != 10 {
+				t.Fatalf("outputs total %d, want the 10 spent", total)
+			}
+		})
+	}
+}
+
+func TestListUTXOsMatchesCreatedOutpoints(t *testing.T) {
+	bc, ws, from := newWalletChain(t)
+	to := string(wallet.NewWallet().GetAddress())
+	miner := string(wallet.NewWallet().GetAddress())
+	genesis := mustBlock(t, bc, bc.Tip())
+
+	got := bc.ListUTXOs(wallet.PubKeyHashFromAddress(from))
+	want := []UnspentOutput{{TxID: genesis.Transactions[0].ID, Vout: 0, Value: 10, Height: 1, Coinbase: true, Mature: true}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("before spending: %+v, want %+v", got, want)
+	}
+
+	tx, err := NewUTXOTransaction(from, to, 4, bc, ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bc.AddBlock([]*Transaction{bc.config.CoinbaseTx(miner, "", 2), tx}); err != nil {
+		t.Fatal(err)
+	}
+	for addr, want := range map[string][]UnspentOutput{
+		to:   {{TxID: tx.ID, Vout: 0, Value: 4, Height: 2, Mature: true}},
+		from: {{TxID: tx.ID, Vout: 1, Value: 6, Height: 2, Mature: true}},
+	} {
+		if got := bc.ListUTXOs(wallet.PubKeyHashFromAddress(addr)); !reflect.DeepEqual(got, want) {
+			t.Errorf("unspent outputs of %s: %+v, want %+v", addr, got, want)
+		}
+	}
+}
+
+func TestWatchOnlyAddressCountsButCannotSpend(t *testing.T) {
+	bc, ws, from := newWalletChain(t)
+	watched := string(wallet.NewWallet().GetAddress())
+	miner := string(wallet.NewWallet().GetAddress())
+	if err := ws.ImportAddress(watched); err != nil {
+		t.Fatal(err)
+	}
+	tx, err := NewUTXOTransaction(from, watched, 4, bc, ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bc.AddBlock([]*Transaction{bc.config.CoinbaseTx(miner, "", 2), tx}); err != nil {
+		t.Fatal(err)
+	}
+
+	owned, watchOnly := 0, 0
+	for _, addr := range ws.GetAddresses() {
+		owned += balance(bc, addr)
+	}
+	for _, addr := range ws.WatchedAddresses() {
+		watchOnly += balance(bc, addr)
+	}
+	if owned != 6 || watchOnly != 4 {
+		t.Fatalf("owned %d and watch-only %d, want 6 and 4", owned, watchOnly)
+	}
+
+	if _, err := NewUTXOTransaction(watched, from, 4, bc, ws); !errors.Is(err, wallet.ErrWatchOnly) {
+		t.Fatalf("sending from a watch-only address: got %v, want ErrWatchOnly", err)
+	}
+}
+
+func TestSameSpendBuildsSameUnsignedTransaction(t *testing.T) {
+	bc, ws, from := newWalletChain(t)
+	owner, err := ws.CreateWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	miner := string(wallet.NewWallet().GetAddress())
+	// owner gets two outputs, in different transactions, that a spend of 5 needs both of.
+	for height := 2; height <= 3; height++ {
+		tx, err := NewUTXOTransaction(from, owner, 3, bc, ws)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := bc.AddBlock([]*Transaction{bc.config.CoinbaseTx(miner, "", height), tx}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var first []byte
+	for range 5 {
+		tx, err := NewUTXOTransaction(owner, miner, 5, bc, ws)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := bc.VerifyTransaction(tx); err != nil {
+			t.Fatalf("signature does not verify: %v", err)
+		}
+		if len(tx.Vin) != 2 {
+			t.Fatalf("spend has %d inputs, want 2", len(tx.Vin))
+		}
+		if a, b := tx.Vin[0], tx.Vin[1]; bytes.Compare(a.Txid, b.Txid) > 0 || bytes.Equal(a.Txid, b.Txid) && a.Vout > b.Vout {
+			t.Fatalf("inputs %x:%d and %x:%d are not in outpoint order", a.Txid, a.Vout, b.Txid, b.Vout)
+		}
+		// Signatures are randomized; everything they cover must be the same every time.
+		trimmed := tx.TrimmedCopy()
+		unsigned := trimmed.Hash()
+		if first == nil {
+			first = unsigned
+		} else if !bytes.Equal(unsigned, first) {
+			t.Fatalf("unsigned transaction %x, first built as %x", unsigned, first)
+		}
+	}
+}
+
+func TestChainedUnconfirmedSendsMineInOrder(t *testing.T) {
+	bc, ws, from := newWalletChain(t)
+	mp := NewMempool()
+	to := []string{string(wallet.NewWallet().GetAddress()), string(wallet.NewWallet().GetAddress())}
+	miner := string(wallet.NewWallet().GetAddress())
+
+	// B can only be funded by the change of A, which is still pending when B is built.
+	var sent []*Transaction
+	fees := 0
+	for _, addr := range to {
+		tx, err := NewPendingUTXOTransaction(from, addr, 3, TxOptions{}, bc, ws, mp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fee, err := bc.CheckPendingTx(tx, mp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := mp.Add(tx, fee); err != nil {
+			t.Fatal(err)
+		}
+		sent = append(sent, tx)
+		fees += fee
+	}
+	a, b := sent[0], sent[1]
+	if len(b.Vin) != 1 || !bytes.Equal(b.Vin[0].Txid, a.ID) {
+		t.Fatalf("B spends %x, want A's change in %x", b.Vin[0].Txid, a.ID)
+	}
+
+	tip := bc.Tip()
+	if _, err := bc.AddBlock([]*Transaction{bc.config.CoinbaseTx(miner, "", 2), b}); !errors.Is(err, ErrInvalidTransaction) {
+		t.Fatalf("mining B without A: got %v, want ErrInvalidTransaction", err)
+	}
+	if !bytes.Equal(bc.Tip(), tip) {
+		t.Fatalf("tip moved to %x", bc.Tip())
+	}
+
+	collected := mp.Collect(0, DefaultMaxBlockSize)
+	if len(collected) != 2 || collected[0] != a || collected[1] != b {
+		t.Fatal("the mempool did not collect A before B")
+	}
+	if _, err := bc.AddBlock(append([]*Transaction{bc.config.CoinbaseTx(miner, "", 2)}, collected...)); err != nil {
+		t.Fatal(err)
+	}
+	if evicted := mp.EvictSpent(bc); evicted != 2 || mp.Len() != 0 {
+		t.Fatalf("evicted %d mined transactions, %d left pending", evicted, mp.Len())
+	}
+	for addr, want := range map[string]int{to[0]: 3, to[1]: 3, from: 10 - 6 - fees} {
+		if got := balance(bc, addr); got != want {
+			t.Errorf("balance of %s is %d, want %d", addr, got, want)
+		}
+	}
+}
+
+func TestIndexedBalanceMatchesFullScan(t *testing.T) {
+	bc, ws, from := newWalletChain(t)
+	to, err := ws.CreateWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := string(wallet.NewWallet().GetAddress())
+	miner := string(wallet.NewWallet().GetAddress())
+	u := UTXOSet{Blockchain: bc}
+	if !u.indexed() {
+		t.Fatal("new chain has no UTXO index")
+	}
+
+	sum := func(outs []TxOutput) int {
+		total := 0
+		for _, out := range outs {
+			total += out.Value
+		}
+		return total
+	}
+	check := func(want map[string]int) {
+		t.Helper()
+		for addr, value := range want {
+			pubKeyHash := wallet.PubKeyHashFromAddress(addr)
+			indexed, scanned := sum(u.FindUTXO(pubKeyHash)), sum(bc.FindUTXO(pubKeyHash))
+			if indexed != scanned || indexed != value {
+				t.Errorf("height %d, %s: indexed balance %d, full scan %d, want %d", bc.BestHeight(), addr, indexed, scanned, value)
+			}
+		}
+	}
+
+	// Mine a payment from the genesis coinbase, then spend part of it.
+	pay, err := NewUTXOTransaction(from, to, 6, bc, ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bc.AddBlock([]*Transaction{bc.config.CoinbaseTx(miner, "", 2), pay}); err != nil {
+		t.Fatal(err)
+	}
+	check(map[string]int{from: 4, to: 6, other: 0, miner: 10})
+
+	spent, err := NewUTXOTransaction(to, other, 3, bc, ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bc.AddBlock([]*Transaction{bc.config.CoinbaseTx(miner, "", 3), spent}); err != nil {
+		t.Fatal(err)
+	}
+	check(map[string]int{from: 4, to: 3, other: 3, miner: 20})
+}
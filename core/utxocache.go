@@ -0,0 +1,49 @@
+package core
+
+import (
+	"bytes"
+	"slices"
+	"sync"
+)
+
+// maxUTXOCacheEntries bounds how many addresses' outputs are cached for one tip, so
+// queries for arbitrary addresses cannot grow it without limit.
+const maxUTXOCacheEntries = 1024
+
+// utxoCache remembers FindUTXO results for one tip. Any other tip is a miss and a result
+// for a new tip replaces everything, so nothing survives a block being connected, a
+// reorganization or a UTXO reindex. The zero value is ready to use.
+type utxoCache struct {
+	mu      sync.Mutex
+	tip     []byte
+	outputs map[string][]TxOutput
+}
+
+// get returns a copy of the outputs cached for pubKeyHash at tip.
+func (c *utxoCache) get(tip, pubKeyHash []byte) ([]TxOutput, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.outputs == nil || !bytes.Equal(c.tip, tip) {
+		return nil, false
+	}
+	outs, ok := c.outputs[string(pubKeyHash)]
+	return slices.Clone(outs), ok
+}
+
+// put caches outs for pubKeyHash. tip must be the tip the outputs were read at, in the
+// same read transaction: the in-memory tip moves before the block's chainstate commits.
+func (c *utxoCache) put(tip, pubKeyHash []byte, outs []TxOutput) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.outputs == nil || !bytes.Equal(c.tip, tip) || len(c.outputs) >= maxUTXOCacheEntries {
+		c.tip = tip
+		c.outputs = make(map[string][]TxOutput)
+	}
+	c.outputs[string(pubKeyHash)] = slices.Clone(outs)
+}
+
+func (c *utxoCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tip, c.outputs = nil, nil
+}
@@ -0,0 +1,60 @@
+package core
+
+import (
+	"testing"
+
+	"my-blockchain/wallet"
+)
+
+func TestUTXOCacheInvalidatedByNewBlock(t *testing.T) {
+	bc, w := newTestChain(t)
+	genesis := mustBlock(t, bc, bc.Tip())
+	pubKeyHash := wallet.PubKeyHashFromAddress(string(w.GetAddress()))
+	utxo := UTXOSet{Blockchain: bc}
+
+	if outs := utxo.FindUTXO(pubKeyHash); len(outs) != 1 || outs[0].Value != 10 {
+		t.Fatalf("outputs %+v, want the genesis coinbase", outs)
+	}
+	if _, ok := bc.utxoCache.get(genesis.Hash, pubKeyHash); !ok {
+		t.Fatal("result not cached for the tip")
+	}
+
+	putAll(t, bc, mineOn(t, bc, genesis, 2, spend(t, bc, w, genesis.Transactions[0], 0, 10)))
+	if _, ok := bc.utxoCache.get(bc.Tip(), pubKeyHash); ok {
+		t.Fatal("cache hit for the new tip before any query")
+	}
+	if outs := utxo.FindUTXO(pubKeyHash); len(outs) != 0 {
+		t.Fatalf("outputs after spending them %+v, want none", outs)
+	}
+
+	// Results handed out are copies: changing one does not change the cache.
+	other := wallet.PubKeyHashFromAddress(string(wallet.NewWallet().GetAddress()))
+	bc.utxoCache.put(bc.Tip(), other, []TxOutput{{Value: 5}})
+	utxo.FindUTXO(other)[0].Value = 6
+	if outs := utxo.FindUTXO(other); outs[0].Value != 5 {
+		t.Fatalf("cached value changed to %d through a returned result", outs[0].Value)
+	}
+}
+
+func BenchmarkFindUTXO(b *testing.B) {
+	bc, w := newTestChain(b)
+	for height := 2; height <= 50; height++ {
+		if _, err := bc.AddBlock([]*Transaction{bc.config.CoinbaseTx(string(w.GetAddress()), "", height)}); err != nil {
+			b.Fatal(err)
+		}
+	}
+	pubKeyHash := wallet.PubKeyHashFromAddress(string(w.GetAddress()))
+	utxo := UTXOSet{Blockchain: bc}
+
+	b.Run("cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			utxo.FindUTXO(pubKeyHash)
+		}
+	})
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			bc.utxoCache.reset()
+			utxo.FindUTXO(pubKeyHash)
+		}
+	})
+}
@@ -0,0 +1,232 @@
+package core
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"go.etcd.io/bbolt"
+)
+
+var (
+	ErrBadProofOfWork     = errors.New("proof of work does not meet target")
+	ErrBadMerkleRoot      = errors.New("merkle root does not match transactions")
+	ErrMutatedMerkleTree  = errors.New("merkle tree contains duplicated nodes")
+	ErrBadTransactionID   = errors.New("transaction ID does not match its contents")
+	ErrInvalidTransaction = errors.New("invalid transaction")
+	ErrBadCoinbaseValue   = errors.New("coinbase pays more than block reward plus fees")
+	ErrDoubleSpend        = errors.New("output already spent")
+	ErrBadCoinbase        = errors.New("invalid coinbase")
+	ErrDuplicateTxID      = errors.New("transaction ID already in the chain")
+	ErrBadOutputValue     = errors.New("output value out of range")
+	ErrTargetBitsRange    = errors.New("target bits out of range")
+)
+
+// Bounds on the length of a coinbase's free-form data (its input's PubKey field).
+const (
+	minCoinbaseData = 2
+	maxCoinbaseData = 100
+)
+
+// CheckBlock runs the validation that needs no chain context beyond cfg's limits: the
+// range of its target bits, checked first, size limits, proof of work, timestamp drift,
+// coinbase placement, transaction IDs, output values, data and dust outputs and the
+// Merkle root.
+func (cfg ChainConfig) CheckBlock(block *Block) error {
+	if err := checkTargetBitsRange(block.Bits()); err != nil {
+		return err
+	}
+	if err := cfg.checkBlockSize(block); err != nil {
+		return err
+	}
+	if !NewProofOfWork(block).Validate() {
+		return ErrBadProofOfWork
+	}
+	if err := checkBlockTime(block); err != nil {
+		return err
+	}
+	if err := checkCoinbase(block.Transactions); err != nil {
+		return err
+	}
+	for _, tx := range block.Transactions {
+		if !bytes.Equal(tx.ID, tx.Hash()) {
+			return fmt.Errorf("%w: %x", ErrBadTransactionID, tx.ID)
+		}
+		if err := checkOutputValues(tx); err != nil {
+			return err
+		}
+		for _, out := range tx.Vout {
+			if err := checkDataOutput(out); err != nil {
+				return fmt.Errorf("%x: %w", tx.ID, err)
+			}
+		}
+		if err := cfg.checkDust(tx); err != nil {
+			return err
+		}
+	}
+	tree := block.merkleTree()
+	if !bytes.Equal(tree.RootNode.Data, block.MerkleRoot) {
+		return ErrBadMerkleRoot
+	}
+	if tree.Mutated {
+		return ErrMutatedMerkleTree
+	}
+	if err := checkDoubleSpends(block.Transactions); err != nil {
+		return err
+	}
+	return nil
+}
+
+// checkCoinbase requires exactly one coinbase, at index 0, with data of a sane length.
+func checkCoinbase(txs []*Transaction) error {
+	if len(txs) == 0 || !txs[0].IsCoinbase() {
+		return fmt.Errorf("%w: first transaction is not a coinbase", ErrBadCoinbase)
+	}
+	for i, tx := range txs[1:] {
+		if tx.IsCoinbase() {
+			return fmt.Errorf("%w: second coinbase %x at index %d", ErrBadCoinbase, tx.ID, i+1)
+		}
+	}
+	if n := len(txs[0].Vin[0].PubKey); n < minCoinbaseData || n > maxCoinbaseData {
+		return fmt.Errorf("%w: data is %d bytes, want %d to %d", ErrBadCoinbase, n, minCoinbaseData, maxCoinbaseData)
+	}
+	return nil
+}
+
+// outpoint identifies a transaction output as (hex tx ID, output index).
+type outpoint struct {
+	txid string
+	vout int
+}
+
+func (op outpoint) String() string {
+	return fmt.Sprintf("%s:%d", op.txid, op.vout)
+}
+
+// checkDoubleSpends rejects a transaction list in which two inputs spend the same output.
+func checkDoubleSpends(txs []*Transaction) error {
+	spentBy := make(map[outpoint][]byte)
+	for _, tx := range txs {
+		if tx.IsCoinbase() {
+			continue
+		}
+		for _, vin := range tx.Vin {
+			op := outpoint{txid: hex.EncodeToString(vin.Txid), vout: vin.Vout}
+			if first, ok := spentBy[op]; ok {
+				return fmt.Errorf("%w: %s by %x and %x", ErrDoubleSpend, op, first, tx.ID)
+			}
+			spentBy[op] = tx.ID
+		}
+	}
+	return nil
+}
+
+// CheckPendingTx checks a transaction offered for mp against the current tip: its ID, size
+// and dust, signatures, which must be low-S whatever the chain's LowSHeight, unspent
+// inputs and finality. Its inputs may spend outputs of transactions
+// pending in mp (which may be nil); whether another pending one spends them too is left to
+// Mempool.Add. Coinbases are refused. It returns the fee the transaction pays.
+func (bc *Blockchain) CheckPendingTx(tx *Transaction, mp *Mempool) (int, error) {
+	if !bytes.Equal(tx.ID, tx.Hash()) {
+		return 0, fmt.Errorf("%w: %x", ErrBadTransactionID, tx.ID)
+	}
+	if tx.IsCoinbase() {
+		return 0, fmt.Errorf("%w: %x: coinbase", ErrInvalidTransaction, tx.ID)
+	}
+	if err := bc.config.checkTxSize(tx); err != nil {
+		return 0, err
+	}
+	pending := mp.transactions()
+	if err := bc.verifyTransactionWith(tx, pending, true); err != nil {
+		return 0, err
+	}
+	if err := bc.config.checkDust(tx); err != nil {
+		return 0, err
+	}
+	if !bc.inputsUnspent(tx, pending) {
+		return 0, fmt.Errorf("%w: %x: inputs already spent", ErrInvalidTransaction, tx.ID)
+	}
+	if err := bc.CheckFinal(tx); err != nil {
+		return 0, err
+	}
+	fee, err := bc.transactionFee(tx, pending)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %x: %v", ErrInvalidTransaction, tx.ID, err)
+	}
+	return fee, nil
+}
+
+// checkBlockTransactions verifies every transaction in a block that is about to extend
+// the main chain: signatures, referenced outputs, locktimes, and the coinbase claim. A
+// transaction may spend outputs of one before it in the block; every other input must be
+// in the chainstate when that is the UTXO set of the block's parent. Side-branch blocks
+// are checked against it when reorganize connects them, by updateUTXOSet.
+func (bc *Blockchain) checkBlockTransactions(block *Block) error {
+	if err := bc.checkDuplicateTxIDs(block); err != nil {
+		return err
+	}
+	checkUnspent := bc.chainstateAt(block.PrevBlockHash)
+	coinbaseValue := 0
+	fees := 0
+	height := bc.BestHeight() + 1
+	requireLowS := bc.config.requiresLowS(height)
+	for i, tx := range block.Transactions {
+		if tx.IsCoinbase() {
+			coinbaseValue += tx.OutputValue()
+			continue
+		}
+		earlier := pendingSet(block.Transactions[:i])
+		if err := bc.verifyTransactionWith(tx, earlier, requireLowS); err != nil {
+			return err
+		}
+		if checkUnspent && !bc.inputsUnspent(tx, earlier) {
+			return fmt.Errorf("%w: %x spends an output not in the UTXO set", ErrDoubleSpend, tx.ID)
+		}
+		if err := checkFinal(tx, height, block.Timestamp); err != nil {
+			return err
+		}
+		fee, err := bc.transactionFee(tx, earlier)
+		if err != nil {
+			return fmt.Errorf("%w: %x: %v", ErrInvalidTransaction, tx.ID, err)
+		}
+		fees += fee
+	}
+	// Chains synced from peers start with a genesis block we cannot price.
+	if len(block.PrevBlockHash) > 0 && coinbaseValue > bc.config.BlockReward(height)+fees {
+		return ErrBadCoinbaseValue
+	}
+	return nil
+}
+
+// chainstateAt reports whether the chainstate bucket holds the UTXO set of the chain
+// ending at hash, that is whether hash is the stored tip.
+func (bc *Blockchain) chainstateAt(hash []byte) bool {
+	at := false
+	_ = bc.db.View(func(tx *bbolt.Tx) error {
+		if b := tx.Bucket([]byte(blocksBucket)); b != nil {
+			at = bytes.Equal(b.Get([]byte(lastHashKey)), hash)
+		}
+		return nil
+	})
+	return at
+}
+
+// checkDuplicateTxIDs rejects a block repeating the ID of a transaction already on the
+// chain it extends (BIP30): the second copy would overwrite the first one's outputs in the
+// UTXO set. Transactions of pruned blocks are gone and cannot be compared.
+func (bc *Blockchain) checkDuplicateTxIDs(block *Block) error {
+	ids := make(map[string]bool, len(block.Transactions))
+	for _, tx := range block.Transactions {
+		ids[hex.EncodeToString(tx.ID)] = true
+	}
+	it := bc.Iterator()
+	for b := it.Next(); b != nil; b = it.Next() {
+		for _, tx := range b.Transactions {
+			if ids[hex.EncodeToString(tx.ID)] {
+				return fmt.Errorf("%w: %x (in block %x)", ErrDuplicateTxID, tx.ID, b.Hash)
+			}
+		}
+	}
+	return nil
+}
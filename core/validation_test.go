@@ -0,0 +1,280 @@
+package core
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"my-blockchain/wallet"
+)
+
+func TestCrossBlockDoubleSpendRejected(t *testing.T) {
+	bc, w := newTestChain(t)
+	genesis := mustBlock(t, bc, bc.Tip())
+	coinbase := genesis.Transactions[0]
+
+	first := mineOn(t, bc, genesis, 2, spend(t, bc, w, coinbase, 0, 10))
+	if err := bc.PutBlock(first.Serialize()); err != nil {
+		t.Fatal(err)
+	}
+	again := mineOn(t, bc, first, 3, spend(t, bc, w, coinbase, 0, 9))
+	if err := bc.PutBlock(again.Serialize()); !errors.Is(err, ErrDoubleSpend) {
+		t.Fatalf("block spending the genesis output again: got %v, want ErrDoubleSpend", err)
+	}
+	if !bytes.Equal(bc.Tip(), first.Hash) {
+		t.Fatalf("tip moved to %x", bc.Tip())
+	}
+}
+
+func TestReorgToCrossBlockDoubleSpendRejected(t *testing.T) {
+	bc, w := newTestChain(t)
+	genesis := mustBlock(t, bc, bc.Tip())
+	coinbase := genesis.Transactions[0]
+
+	main2 := mineOn(t, bc, genesis, 2)
+	main3 := mineOn(t, bc, main2, 3)
+	for _, b := range []*Block{main2, main3} {
+		if err := bc.PutBlock(b.Serialize()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The side branch spends the genesis output twice, in different blocks, and outgrows
+	// the main chain with its last block.
+	side2 := mineOn(t, bc, genesis, 2, spend(t, bc, w, coinbase, 0, 10))
+	side3 := mineOn(t, bc, side2, 3, spend(t, bc, w, coinbase, 0, 9))
+	side4 := mineOn(t, bc, side3, 4)
+	for _, b := range []*Block{side2, side3} {
+		if err := bc.PutBlock(b.Serialize()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := bc.PutBlock(side4.Serialize()); !errors.Is(err, ErrDoubleSpend) {
+		t.Fatalf("reorg to the double-spending branch: got %v, want ErrDoubleSpend", err)
+	}
+	if !bytes.Equal(bc.Tip(), main3.Hash) {
+		t.Fatalf("tip moved to %x", bc.Tip())
+	}
+	if !(UTXOSet{Blockchain: bc}).IsUnspent(coinbase.ID, 0) {
+		t.Fatal("genesis output spent after the rejected reorg")
+	}
+}
+
+func TestTamperedBlocksRejected(t *testing.T) {
+	bc, w := newTestChain(t)
+	genesis := mustBlock(t, bc, bc.Tip())
+	coinbase := genesis.Transactions[0]
+
+	t.Run("bad nonce", func(t *testing.T) {
+		block := mineOn(t, bc, genesis, 2)
+		block.Nonce++
+		if err := bc.PutBlock(block.Serialize()); !errors.Is(err, ErrBadProofOfWork) {
+			t.Fatalf("got %v, want ErrBadProofOfWork", err)
+		}
+	})
+
+	t.Run("bad merkle root", func(t *testing.T) {
+		block := mineOn(t, bc, genesis, 2, spend(t, bc, w, coinbase, 0, 10))
+		// Swap in another valid transaction, then redo the proof of work over the old root.
+		block.Transactions[1] = spend(t, bc, w, coinbase, 0, 9)
+		block.Nonce, block.Hash = NewProofOfWork(block).Run()
+		if err := bc.PutBlock(block.Serialize()); !errors.Is(err, ErrBadMerkleRoot) {
+			t.Fatalf("got %v, want ErrBadMerkleRoot", err)
+		}
+	})
+
+	t.Run("forged signature", func(t *testing.T) {
+		// The genesis output is locked to w; a thief signs the spend with its own key.
+		forged := spend(t, bc, w, coinbase, 0, 10)
+		hash, err := forged.signatureHash(0, coinbase.Vout[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if forged.Vin[0].Signature, err = wallet.SignLowS(wallet.NewWallet().PrivateECDSA(), hash); err != nil {
+			t.Fatal(err)
+		}
+		forged.ID = forged.Hash()
+		block := mineOn(t, bc, genesis, 2, forged)
+		if err := bc.PutBlock(block.Serialize()); !errors.Is(err, ErrInvalidTransaction) {
+			t.Fatalf("got %v, want ErrInvalidTransaction", err)
+		}
+	})
+
+	if !bytes.Equal(bc.Tip(), genesis.Hash) {
+		t.Fatalf("a tampered block moved the tip to %x", bc.Tip())
+	}
+}
+
+func TestInBlockDoubleSpendRejected(t *testing.T) {
+	bc, w := newTestChain(t)
+	genesis := mustBlock(t, bc, bc.Tip())
+	coinbase := genesis.Transactions[0]
+
+	block := mineOn(t, bc, genesis, 2, spend(t, bc, w, coinbase, 0, 10), spend(t, bc, w, coinbase, 0, 9))
+	err := bc.PutBlock(block.Serialize())
+	if !errors.Is(err, ErrDoubleSpend) {
+		t.Fatalf("block spending the genesis output twice: got %v, want ErrDoubleSpend", err)
+	}
+	if op := hex.EncodeToString(coinbase.ID) + ":0"; !strings.Contains(err.Error(), op) {
+		t.Fatalf("error %q does not name the outpoint %s", err, op)
+	}
+	if !bytes.Equal(bc.Tip(), genesis.Hash) {
+		t.Fatalf("tip moved to %x", bc.Tip())
+	}
+}
+
+func TestBlocksWithoutOneLeadingCoinbaseRejected(t *testing.T) {
+	bc, w := newTestChain(t)
+	genesis := mustBlock(t, bc, bc.Tip())
+	to := string(wallet.NewWallet().GetAddress())
+	coinbase := bc.config.CoinbaseTx(to, "", 2)
+	payment := spend(t, bc, w, genesis.Transactions[0], 0, 10)
+
+	tests := []struct {
+		name string
+		txs  []*Transaction
+	}{
+		{"no transactions", nil},
+		{"no coinbase", []*Transaction{payment}},
+		{"misplaced coinbase", []*Transaction{payment, coinbase}},
+		{"two coinbases", []*Transaction{coinbase, bc.config.CoinbaseTx(to, "second", 2)}},
+		{"coinbase data too short", []*Transaction{bc.config.CoinbaseTx(to, "x", 2)}},
+		{"coinbase data too long", []*Transaction{bc.config.CoinbaseTx(to, strings.Repeat("x", maxCoinbaseData+1), 2)}},
+	}
+	for _, tt := range tests {
+		block := newBlockTemplate(tt.txs, genesis.Hash, bc.config.TargetBits)
+		block.Timestamp = genesis.Timestamp + 1
+		block.Nonce, block.Hash = NewProofOfWork(block).Run()
+		if err := bc.PutBlock(block.Serialize()); !errors.Is(err, ErrBadCoinbase) {
+			t.Errorf("%s: PutBlock got %v, want ErrBadCoinbase", tt.name, err)
+		}
+		if _, err := bc.AddBlock(tt.txs); !errors.Is(err, ErrBadCoinbase) {
+			t.Errorf("%s: AddBlock got %v, want ErrBadCoinbase", tt.name, err)
+		}
+	}
+	if !bytes.Equal(bc.Tip(), genesis.Hash) {
+		t.Fatalf("a block without one leading coinbase moved the tip to %x", bc.Tip())
+	}
+}
+
+func TestDuplicateCoinbaseIDsPrevented(t *testing.T) {
+	bc, _ := newTestChain(t)
+	to := string(wallet.NewWallet().GetAddress())
+
+	// Identical coinbases but for the height get distinct IDs, custom data or not.
+	for _, data := range []string{"", "same data"} {
+		if a, b := bc.config.CoinbaseTx(to, data, 2), bc.config.CoinbaseTx(to, data, 3); bytes.Equal(a.ID, b.ID) {
+			t.Fatalf("coinbases with data %q at heights 2 and 3 share ID %x", data, a.ID)
+		}
+	}
+
+	coinbase := bc.config.CoinbaseTx(to, "", 2)
+	if _, err := bc.AddBlock([]*Transaction{coinbase}); err != nil {
+		t.Fatal(err)
+	}
+	tip := bc.Tip()
+	if _, err := bc.AddBlock([]*Transaction{coinbase}); !errors.Is(err, ErrDuplicateTxID) {
+		t.Fatalf("mining the same coinbase again: got %v, want ErrDuplicateTxID", err)
+	}
+	if !bytes.Equal(bc.Tip(), tip) {
+		t.Fatalf("tip moved to %x", bc.Tip())
+	}
+	if balance(bc, to) != 10 {
+		t.Fatalf("balance %d, want the one coinbase of 10", balance(bc, to))
+	}
+}
+
+func TestNegativeAndZeroOutputsRejected(t *testing.T) {
+	bc, w := newTestChain(t)
+	genesis := mustBlock(t, bc, bc.Tip())
+	to := string(wallet.NewWallet().GetAddress())
+	other := string(wallet.NewWallet().GetAddress())
+
+	// The outputs sum to the reward, so only the negative one gives the inflation away.
+	for name, vout := range map[string][]TxOutput{
+		"negative output": {*NewTxOutput(1000000, to), *NewTxOutput(-1000000+10, other)},
+		"zero output":     {*NewTxOutput(10, to), *NewTxOutput(0, other)},
+	} {
+		coinbase := bc.config.CoinbaseTx(to, "", 2)
+		coinbase.Vout = vout
+		coinbase.ID = coinbase.Hash()
+		block := newBlockTemplate([]*Transaction{coinbase}, genesis.Hash, bc.config.TargetBits)
+		block.Timestamp = genesis.Timestamp + 1
+		block.Nonce, block.Hash = NewProofOfWork(block).Run()
+		if err := bc.PutBlock(block.Serialize()); !errors.Is(err, ErrBadOutputValue) {
+			t.Errorf("coinbase with a %s: got %v, want ErrBadOutputValue", name, err)
+		}
+	}
+	if !bytes.Equal(bc.Tip(), genesis.Hash) || balance(bc, to) != 0 {
+		t.Fatalf("a coinbase with a bad output was connected")
+	}
+
+	// The same trick in a payment: 1000 and -990 spend the 10 of the genesis coinbase.
+	tx := &Transaction{
+		Vin:  []TxInput{{Txid: genesis.Transactions[0].ID, Vout: 0, PubKey: w.PubKey()}},
+		Vout: []TxOutput{*NewTxOutput(1000, to), *NewTxOutput(-990, other)},
+	}
+	tx.ID = tx.Hash()
+	if err := bc.SignTransaction(tx, w.PrivateECDSA()); err != nil {
+		t.Fatal(err)
+	}
+	tx.ID = tx.Hash()
+	if _, err := bc.CheckPendingTx(tx, NewMempool()); !errors.Is(err, ErrBadOutputValue) || !errors.Is(err, ErrInvalidTransaction) {
+		t.Fatalf("pending payment with a negative output: got %v, want ErrInvalidTransaction and ErrBadOutputValue", err)
+	}
+	block := mineOn(t, bc, genesis, 2, tx)
+	if err := bc.PutBlock(block.Serialize()); !errors.Is(err, ErrBadOutputValue) {
+		t.Fatalf("block with a negative payment output: got %v, want ErrBadOutputValue", err)
+	}
+}
+
+func TestCoinbaseAfterSubsidyHasNoOutput(t *testing.T) {
+	bc, _ := newTestChain(t)
+	to := string(wallet.NewWallet().GetAddress())
+	height := 64 * bc.config.HalvingInterval
+	if got := bc.config.BlockReward(height); got != 0 {
+		t.Fatalf("reward at height %d is %d, want 0", height, got)
+	}
+	if coinbase := bc.config.CoinbaseTx(to, "", height); len(coinbase.Vout) != 0 {
+		t.Fatalf("coinbase paying nothing has %d outputs", len(coinbase.Vout))
+	}
+	if coinbase := bc.config.CoinbaseTxWithFees(to, "", height, 3); len(coinbase.Vout) != 1 || coinbase.Vout[0].Value != 3 {
+		t.Fatalf("coinbase paying fees of 3 has outputs %+v", coinbase.Vout)
+	}
+	block := newBlockTemplate([]*Transaction{bc.config.CoinbaseTx(to, "", height)}, bc.Tip(), bc.config.TargetBits)
+	block.Nonce, block.Hash = NewProofOfWork(block).Run()
+	if err := bc.config.CheckBlock(block); err != nil {
+		t.Fatalf("block whose coinbase pays nothing: %v", err)
+	}
+}
+
+func TestCoinbaseMayClaimExactlyTheSubsidyPlusFees(t *testing.T) {
+	bc, w := newTestChain(t)
+	genesis := mustBlock(t, bc, bc.Tip())
+	// Fees of 3 and 2, the second spending the first's output within the block.
+	first := payTo(t, bc, w, genesis.Transactions[0], 0, 7, string(w.GetAddress()))
+	second := spend(t, bc, w, first, 0, 5)
+	fees := 3 + 2
+	to := string(wallet.NewWallet().GetAddress())
+
+	withCoinbaseValue := func(value int) *Block {
+		coinbase := bc.config.CoinbaseTxWithFees(to, "", 2, fees)
+		coinbase.Vout[0].Value = value
+		coinbase.ID = coinbase.Hash()
+		block := newBlockTemplate([]*Transaction{coinbase, first, second}, genesis.Hash, bc.config.TargetBits)
+		block.Timestamp = genesis.Timestamp + 1
+		block.Nonce, block.Hash = NewProofOfWork(block).Run()
+		return block
+	}
+
+	want := bc.config.BlockReward(2) + fees
+	if err := bc.PutBlock(withCoinbaseValue(want + 1).Serialize()); !errors.Is(err, ErrBadCoinbaseValue) {
+		t.Fatalf("coinbase claiming %d, one more than subsidy and fees: got %v, want ErrBadCoinbaseValue", want+1, err)
+	}
+	putAll(t, bc, withCoinbaseValue(want))
+	if got := balance(bc, to); got != want {
+		t.Fatalf("miner holds %d, want %d", got, want)
+	}
+}
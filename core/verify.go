@@ -0,0 +1,78 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"go.etcd.io/bbolt"
+)
+
+// ErrCorruptChain is wrapped by every VerifyChain failure.
+var ErrCorruptChain = errors.New("stored chain is corrupt")
+
+// VerifyChain re-validates the stored main chain from the tip back to genesis: that every
+// block is stored under its own hash and links to a stored parent, its proof of work, its
+// Merkle root and transaction IDs, every transaction's signatures and fee, and the
+// checkpoints. It returns an error naming the first failing block, counting from the tip.
+// Blocks whose transactions were pruned, and on a pruned chain all transactions, cannot be
+// re-checked; only their headers are.
+func (bc *Blockchain) VerifyChain() error {
+	blocks, err := bc.readMainChain()
+	if err != nil {
+		return err
+	}
+	checkTxs := bc.PruneHeight() == 0
+	for i, block := range blocks {
+		height := len(blocks) - i
+		fail := func(err error) error {
+			return fmt.Errorf("%w: block %d (%x): %w", ErrCorruptChain, height, block.Hash, err)
+		}
+
+		if block.Pruned {
+			if !NewProofOfWork(block).Validate() {
+				return fail(ErrBadProofOfWork)
+			}
+		} else if err := bc.config.CheckBlock(block); err != nil {
+			return fail(err)
+		}
+		if err := bc.checkCheckpoint(height, block.Hash); err != nil {
+			return fail(err)
+		}
+		if checkTxs && !block.Pruned {
+			if err := bc.at(block.PrevBlockHash).checkBlockTransactions(block); err != nil {
+				return fail(err)
+			}
+		}
+	}
+	return nil
+}
+
+// readMainChain decodes the main chain, tip first, without the panics of Iterator: a block
+// that is missing, cannot be decoded or is stored under another hash is an error.
+func (bc *Blockchain) readMainChain() ([]*Block, error) {
+	var blocks []*Block
+	err := bc.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(blocksBucket))
+		if b == nil {
+			return nil
+		}
+		for hash := bc.Tip(); len(hash) > 0; {
+			data := b.Get(hash)
+			if data == nil {
+				return fmt.Errorf("%w: block %x, %d below the tip, is missing", ErrCorruptChain, hash, len(blocks))
+			}
+			block, err := DecodeBlock(data)
+			if err != nil {
+				return fmt.Errorf("%w: block %x, %d below the tip, cannot be decoded: %v", ErrCorruptChain, hash, len(blocks), err)
+			}
+			if !bytes.Equal(block.Hash, hash) {
+				return fmt.Errorf("%w: block stored as %x, %d below the tip, has hash %x", ErrCorruptChain, hash, len(blocks), block.Hash)
+			}
+			blocks = append(blocks, block)
+			hash = block.PrevBlockHash
+		}
+		return nil
+	})
+	return blocks, err
+}
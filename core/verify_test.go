@@ -0,0 +1,53 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"go.etcd.io/bbolt"
+
+	"my-blockchain/wallet"
+)
+
+func TestVerifyChainPinpointsCorruptBlock(t *testing.T) {
+	bc, ws, from := newWalletChain(t)
+	to := string(wallet.NewWallet().GetAddress())
+	miner := string(wallet.NewWallet().GetAddress())
+	payment, err := NewUTXOTransaction(from, to, 4, bc, ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paid, err := bc.AddBlock([]*Transaction{bc.config.CoinbaseTx(miner, "", 2), payment})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bc.AddBlock([]*Transaction{bc.config.CoinbaseTx(miner, "", 3)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.VerifyChain(); err != nil {
+		t.Fatalf("healthy chain: %v", err)
+	}
+
+	// Raise the payment, keeping its ID consistent, so only the Merkle root gives it away.
+	block, err := DecodeBlock(mustBlock(t, bc, paid).Serialize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	block.Transactions[1].Vout[0].Value = 10
+	block.Transactions[1].ID = block.Transactions[1].Hash()
+	if err := bc.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(blocksBucket)).Put(paid, block.Serialize())
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	err = bc.VerifyChain()
+	if !errors.Is(err, ErrCorruptChain) || !errors.Is(err, ErrBadMerkleRoot) {
+		t.Fatalf("corrupt chain: got %v, want ErrCorruptChain and ErrBadMerkleRoot", err)
+	}
+	if want := fmt.Sprintf("block 2 (%x)", paid); !strings.Contains(err.Error(), want) {
+		t.Fatalf("error %q does not name %s", err, want)
+	}
+}
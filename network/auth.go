@@ -0,0 +1,86 @@
+package network
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ErrListenUnauthenticated means a node was asked to listen beyond loopback, where other
+// hosts could reach its wallet commands and JSON API, without requiring a token on both.
+var ErrListenUnauthenticated = errors.New("listening beyond loopback needs an RPC token and TCP auth")
+
+// clientCommands are the TCP commands the CLI sends to its own node, as opposed to the
+// messages peers exchange. With TCPAuth they need the node's RPCToken.
+var clientCommands = map[string]bool{
+	"sendtx":           true,
+	"getbalance":       true,
+	"getchain":         true,
+	"getrawtx":         true,
+	"getmerkleproof":   true,
+	"getblockhash":     true,
+	"getblock":         true,
+	"getwork":          true,
+	"submitwork":       true,
+	"status":           true,
+	"listtxs":          true,
+	"listunspent":      true,
+	"mine":             true,
+	"faucet":           true,
+	"getmempool":       true,
+	"walletpassphrase": true,
+	"walletlock":       true,
+	"testsend":         true,
+	"rescan":           true,
+}
+
+// CheckListenAuth reports whether a node may listen on addr with token and tcpAuth: a
+// loopback host (localhost or a loopback IP) needs nothing, any other host needs both, as
+// the TCP client commands and the JSON API are then reachable from other machines.
+func CheckListenAuth(addr, token string, tcpAuth bool) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid listen address %q: %w", addr, err)
+	}
+	if ip := net.ParseIP(host); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+		return nil
+	}
+	if token == "" || !tcpAuth {
+		return fmt.Errorf("%w: %s", ErrListenUnauthenticated, addr)
+	}
+	return nil
+}
+
+// tokenMatches compares a presented token with the node's in constant time.
+func (n *Node) tokenMatches(token string) bool {
+	return subtle.ConstantTimeCompare([]byte(token), []byte(n.RPCToken)) == 1
+}
+
+// authorized reports whether the node may handle msg: peer messages always, client
+// commands when TCPAuth is off or msg carries the node's token.
+func (n *Node) authorized(msg Message) bool {
+	if !n.TCPAuth || n.RPCToken == "" || !clientCommands[msg.Command] {
+		return true
+	}
+	return n.tokenMatches(msg.Token)
+}
+
+// requireToken wraps the JSON API so every request must present the node's token, as
+// "Authorization: Bearer TOKEN" or as the password of HTTP basic auth (any user name).
+func (n *Node) requireToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			_, token, ok = r.BasicAuth()
+		}
+		if !ok || !n.tokenMatches(token) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="node", Basic realm="node"`)
+			writeJSONError(w, http.StatusUnauthorized, "missing or invalid token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,96 @@
+package network
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRPCRequiresToken(t *testing.T) {
+	chdirTemp(t)
+	n := newTestNode(t)
+	from := fundedChain(t, n)
+	n.RPCToken = "secret"
+	startNode(t, n)
+	srv := httptest.NewServer(n.RPCHandler())
+	t.Cleanup(srv.Close)
+
+	for _, tc := range []struct {
+		name string
+		auth func(*http.Request)
+		want int
+	}{
+		{"no credentials", func(*http.Request) {}, http.StatusUnauthorized},
+		{"wrong bearer token", func(r *http.Request) { r.Header.Set("Authorization", "Bearer guess") }, http.StatusUnauthorized},
+		{"wrong basic password", func(r *http.Request) { r.SetBasicAuth("secret", "guess") }, http.StatusUnauthorized},
+		{"bearer token", func(r *http.Request) { r.Header.Set("Authorization", "Bearer secret") }, http.StatusOK},
+		{"basic password", func(r *http.Request) { r.SetBasicAuth("anyone", "secret") }, http.StatusOK},
+	} {
+		req, err := http.NewRequest("GET", srv.URL+"/balance/"+from, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tc.auth(req)
+		res, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = res.Body.Close()
+		if res.StatusCode != tc.want {
+			t.Errorf("%s: status %d, want %d", tc.name, res.StatusCode, tc.want)
+		}
+		if tc.want == http.StatusUnauthorized && res.Header.Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: 401 without a WWW-Authenticate header", tc.name)
+		}
+	}
+}
+
+func TestTCPAuthGatesClientCommands(t *testing.T) {
+	chdirTemp(t)
+	n := newTestNode(t)
+	from := fundedChain(t, n)
+	n.RPCToken = "secret"
+	n.TCPAuth = true
+	startNode(t, n)
+	t.Cleanup(func() { RPCToken = "" })
+
+	if _, err := GetBalanceRequest(n.id, from); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("request without a token: got %v, want ErrUnauthorized", err)
+	}
+	RPCToken = "guess"
+	if _, err := GetBalanceRequest(n.id, from); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("request with a wrong token: got %v, want ErrUnauthorized", err)
+	}
+	RPCToken = "secret"
+	if got, err := GetBalanceRequest(n.id, from); err != nil || got != 10 {
+		t.Fatalf("request with the token: got %d, %v, want 10", got, err)
+	}
+}
+
+func TestCheckListenAuth(t *testing.T) {
+	for _, tc := range []struct {
+		addr    string
+		token   string
+		tcpAuth bool
+		ok      bool
+	}{
+		{"localhost:3000", "", false, true},
+		{"127.0.0.1:3000", "", false, true},
+		{"[::1]:3000", "", false, true},
+		{"192.168.1.20:3000", "", false, false},
+		{"192.168.1.20:3000", "secret", false, false},
+		{"192.168.1.20:3000", "", true, false},
+		{"node-b.lan:3000", "secret", false, false},
+		{"192.168.1.20:3000", "secret", true, true},
+		{"node-b.lan:3000", "secret", true, true},
+	} {
+		err := CheckListenAuth(tc.addr, tc.token, tc.tcpAuth)
+		if tc.ok && err != nil {
+			t.Errorf("%s, token %q, tcpauth %v: %v", tc.addr, tc.token, tc.tcpAuth, err)
+		}
+		if !tc.ok && !errors.Is(err, ErrListenUnauthenticated) {
+			t.Errorf("%s, token %q, tcpauth %v: got %v, want ErrListenUnauthenticated", tc.addr, tc.token, tc.tcpAuth, err)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package network
+
+import (
+	"bytes"
+	"net"
+
+	"my-blockchain/core"
+)
+
+// BlockHashRequest asks the node for the hash of the main-chain block at Height.
+type BlockHashRequest struct {
+	AddrFrom string
+	Height   int
+}
+
+type BlockHashResponse struct {
+	OK      bool
+	Message string
+	Code    string
+	Hash    []byte
+}
+
+// BlockRequest asks the node for one stored block by hash.
+type BlockRequest struct {
+	AddrFrom string
+	Hash     []byte
+}
+
+// BlockInfo is a block with its transactions decoded. Block.Index is its height.
+type BlockInfo struct {
+	Block ChainBlock
+	// MainChain is false for a stored block on a side branch; it then has no confirmations.
+	MainChain bool
+	// Txs is empty when the block is pruned.
+	Txs []RawTx
+}
+
+type BlockResponse struct {
+	OK      bool
+	Message string
+	Code    string
+	Info    BlockInfo
+}
+
+// GetBlockHashRequest asks the running node at nodeAddr(nodeID) for the hash of the
+// main-chain block at height.
+func GetBlockHashRequest(nodeID string, height int) ([]byte, error) {
+	addr := nodeAddr(nodeID)
+	payload := BlockHashRequest{AddrFrom: addr, Height: height}
+	var res BlockHashResponse
+	if err := request(addr, "getblockhash", payload, "blockhash", &res); err != nil {
+		return nil, err
+	}
+	if !res.OK {
+		return nil, &RemoteError{Message: res.Message, Code: res.Code}
+	}
+	return res.Hash, nil
+}
+
+// GetBlockRequest asks the running node at nodeAddr(nodeID) for the block with hash.
+func GetBlockRequest(nodeID string, hash []byte) (BlockInfo, error) {
+	addr := nodeAddr(nodeID)
+	payload := BlockRequest{AddrFrom: addr, Hash: hash}
+	var res BlockResponse
+	if err := request(addr, "getblock", payload, "blockinfo", &res); err != nil {
+		return BlockInfo{}, err
+	}
+	if !res.OK {
+		return BlockInfo{}, &RemoteError{Message: res.Message, Code: res.Code}
+	}
+	return res.Info, nil
+}
+
+func handleGetBlockHash(conn net.Conn, payloadBytes []byte, bc *core.Blockchain) error {
+	var payload BlockHashRequest
+	if err := decodePayload(payloadBytes, &payload); err != nil {
+		return err
+	}
+
+	res := BlockHashResponse{OK: true}
+	hash, err := bc.GetBlockHash(payload.Height)
+	if err != nil {
+		res = BlockHashResponse{OK: false, Message: err.Error(), Code: errorCode(err)}
+	}
+	res.Hash = hash
+	return replyWith(conn, "blockhash", res)
+}
+
+func handleGetBlock(conn net.Conn, payloadBytes []byte, bc *core.Blockchain) error {
+	var payload BlockRequest
+	if err := decodePayload(payloadBytes, &payload); err != nil {
+		return err
+	}
+
+	res := BlockResponse{OK: true}
+	info, err := BlockInfoFor(bc, payload.Hash)
+	if err != nil {
+		res = BlockResponse{OK: false, Message: err.Error(), Code: errorCode(err)}
+	}
+	res.Info = info
+	return replyWith(conn, "blockinfo", res)
+}
+
+// BlockInfoFor decodes the stored block with hash, on the main chain or a side branch.
+func BlockInfoFor(bc *core.Blockchain, hash []byte) (BlockInfo, error) {
+	b, err := bc.Block(hash)
+	if err != nil {
+		return BlockInfo{}, err
+	}
+	height, err := bc.GetBlockHeight(hash)
+	if err != nil {
+		return BlockInfo{}, err
+	}
+	info := BlockInfo{Block: ChainBlock{
+		Index:     height,
+		Timestamp: b.Timestamp,
+		PrevHash:  b.PrevBlockHash,
+		Hash:      b.Hash,
+		Nonce:     b.Nonce,
+		Merkle:    b.MerkleRoot,
+		Bits:      b.Bits(),
+		Pruned:    b.Pruned,
+	}}
+	if mainHash, err := bc.GetBlockHash(height); err == nil && bytes.Equal(mainHash, hash) {
+		info.MainChain = true
+		info.Block.Confirmations = bc.BestHeight() - height + 1
+	}
+	for _, tx := range b.Transactions {
+		info.Block.TxIDs = append(info.Block.TxIDs, tx.ID)
+		raw := NewRawTx(tx, hash)
+		raw.Confirmations = info.Block.Confirmations
+		info.Txs = append(info.Txs, raw)
+	}
+	return info, nil
+}
@@ -0,0 +1,53 @@
+package network
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"my-blockchain/core"
+)
+
+func TestGetBlockByHeightAndHash(t *testing.T) {
+	chdirTemp(t)
+	n := newTestNode(t)
+	fundedChain(t, n)
+	extendChain(t, n, 2)
+	startNode(t, n)
+
+	for _, height := range []int{1, 3} {
+		hash, err := GetBlockHashRequest(n.id, height)
+		if err != nil {
+			t.Fatalf("hash at height %d: %v", height, err)
+		}
+		info, err := GetBlockRequest(n.id, hash)
+		if err != nil {
+			t.Fatalf("block %x: %v", hash, err)
+		}
+		if !bytes.Equal(info.Block.Hash, hash) || info.Block.Index != height || !info.MainChain {
+			t.Fatalf("block at height %d: %+v", height, info.Block)
+		}
+		if want := 3 - height + 1; info.Block.Confirmations != want {
+			t.Errorf("block at height %d has %d confirmations, want %d", height, info.Block.Confirmations, want)
+		}
+		if len(info.Txs) != 1 || !info.Txs[0].Coinbase {
+			t.Errorf("block at height %d has %d transactions, want its coinbase", height, len(info.Txs))
+		}
+	}
+	genesis, err := GetBlockHashRequest(n.id, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := GetBlockRequest(n.id, genesis); err != nil || len(info.Block.PrevHash) != 0 {
+		t.Fatalf("genesis %+v (%v) has a parent", info.Block, err)
+	}
+
+	for _, height := range []int{0, 4, -1} {
+		if _, err := GetBlockHashRequest(n.id, height); !errors.Is(err, core.ErrHeightOutOfRange) {
+			t.Errorf("hash at height %d: got %v, want ErrHeightOutOfRange", height, err)
+		}
+	}
+	if _, err := GetBlockRequest(n.id, bytes.Repeat([]byte{0xab}, 32)); !errors.Is(err, core.ErrBlockNotFound) {
+		t.Errorf("unknown block: got %v, want ErrBlockNotFound", err)
+	}
+}
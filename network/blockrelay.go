@@ -0,0 +1,59 @@
+package network
+
+import (
+	"encoding/hex"
+
+	"my-blockchain/core"
+)
+
+// relayBlock pushes a whole block to every peer except ourselves and skip (the peer that
+// sent it), or to every peer when skip is empty.
+func (n *Node) relayBlock(blockBytes []byte, skip string) {
+	payload := BlockData{AddrFrom: n.address, Block: blockBytes}
+	for _, peer := range n.ListPeers() {
+		if peer == n.address || peer == skip {
+			continue
+		}
+		n.sendPayload(peer, "sendblock", payload)
+	}
+}
+
+// handleSendBlock stores a block a peer pushed unasked and passes it on. A block we
+// already have or have recently seen is dropped without relaying, so each block crosses
+// each link at most about once. A block whose parent we lack is kept as an orphan while we ask the sender for the
+// headers in between; it is not relayed.
+func (n *Node) handleSendBlock(payloadBytes []byte) error {
+	var payload BlockData
+	if err := decodePayload(payloadBytes, &payload); err != nil {
+		return err
+	}
+
+	block, err := core.DecodeBlock(payload.Block)
+	if err != nil || n.bc.HasBlock(block.Hash) || n.seenBlocks.has(block.Hash) {
+		return nil
+	}
+	if err := n.bc.PutBlock(payload.Block); err != nil {
+		n.logger.Warn("rejected block", "from", payload.AddrFrom, "err", err)
+		return nil
+	}
+	if !n.bc.HasBlock(block.Hash) {
+		n.logger.Debug("pushed block is an orphan, syncing", "block", hex.EncodeToString(block.Hash), "from", payload.AddrFrom)
+		n.sendGetHeaders(payload.AddrFrom)
+		return nil
+	}
+	n.mempool.EvictSpent(n.bc)
+	if !n.seenBlocks.add(block.Hash) {
+		return nil
+	}
+
+	if n.DirectBlockRelay {
+		n.relayBlock(payload.Block, payload.AddrFrom)
+	} else {
+		for _, peer := range n.ListPeers() {
+			if peer != n.address && peer != payload.AddrFrom {
+				n.sendInv(peer, "block", [][]byte{block.Hash})
+			}
+		}
+	}
+	return nil
+}
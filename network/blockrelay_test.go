@@ -0,0 +1,63 @@
+package network
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"my-blockchain/core"
+	"my-blockchain/wallet"
+)
+
+// relaySteps mines a block on one node and returns the block-carrying messages the two
+// nodes exchanged until its peer had it, with block relay direct or by inv and getdata.
+func relaySteps(t *testing.T, direct bool) []string {
+	t.Helper()
+	var received lockedBuffer
+	logger := core.NewLogger(&received, slog.LevelDebug)
+	a := newTestNode(t)
+	fundedChain(t, a)
+	b := newTestNode(t, a.address)
+	copyChain(t, a, b)
+	for _, n := range []*Node{a, b} {
+		n.DirectBlockRelay = direct
+		n.logger = logger
+		startNode(t, n)
+	}
+	waitFor(t, 5*time.Second, "A to learn B", func() bool {
+		return containsPeer(a.ListPeers(), b.address)
+	})
+	// Leave out the handshake, which both modes share.
+	start := len(received.String())
+
+	hashes, err := GenerateRequest(a.id, 1, string(wallet.NewWallet().GetAddress()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, 5*time.Second, "the block to reach B", func() bool {
+		return b.bc.HasBlock(hashes[0])
+	})
+
+	var steps []string
+	for _, line := range strings.Split(received.String()[start:], "\n") {
+		for _, command := range []string{"inv", "getdata", "block", "sendblock"} {
+			if strings.Contains(line, "msg=\"received message\" command="+command+" ") {
+				steps = append(steps, command)
+			}
+		}
+	}
+	return steps
+}
+
+func TestDirectBlockRelayTakesOneStep(t *testing.T) {
+	chdirTemp(t)
+	viaInv := relaySteps(t, false)
+	if want := "inv getdata block"; strings.Join(viaInv, " ") != want {
+		t.Fatalf("relay by inv took %q, want %q", viaInv, want)
+	}
+	direct := relaySteps(t, true)
+	if want := "sendblock"; strings.Join(direct, " ") != want {
+		t.Fatalf("direct relay took %q, want %q", direct, want)
+	}
+}
@@ -0,0 +1,99 @@
+package network
+
+import (
+	"errors"
+
+	"my-blockchain/core"
+	"my-blockchain/wallet"
+)
+
+// Errors the request functions return, so callers can tell why a request failed.
+// Failures reported by the node come as a *RemoteError that unwraps to one of them.
+var (
+	// ErrNodeUnreachable means the node could not be reached or did not answer.
+	ErrNodeUnreachable = errors.New("node unreachable")
+	// ErrBadRequest marks failures caused by the request itself rather than the chain.
+	ErrBadRequest        = errors.New("bad request")
+	ErrInvalidAddress    = errors.New("invalid address")
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	// ErrUnknownSender means the node's wallet cannot sign for the sender address.
+	ErrUnknownSender = errors.New("sender not in the node's wallet")
+	// ErrTxRejected means the node built the transaction but would not accept it, e.g.
+	// because it double-spends a pending one or is not final yet.
+	ErrTxRejected = errors.New("transaction rejected")
+	// ErrFaucetDisabled means the node's chain is not regtest, so it has no faucet.
+	ErrFaucetDisabled = errors.New("faucet is only available on regtest chains")
+	// ErrWalletLocked means the node's wallet file is encrypted and not unlocked (see
+	// WalletPassphraseRequest).
+	ErrWalletLocked = errors.New("wallet locked")
+	// ErrWrongPassphrase means the passphrase does not unlock the node's wallet file.
+	ErrWrongPassphrase = errors.New("wrong passphrase")
+	// ErrUnauthorized means the node requires a token ($RPC_TOKEN) and the request had
+	// none or the wrong one.
+	ErrUnauthorized = errors.New("unauthorized: missing or wrong RPC token")
+	// ErrMalformedPayload means a message's payload did not decode into the type its
+	// command carries.
+	ErrMalformedPayload = errors.New("malformed payload")
+)
+
+// Error codes sent in replies, mapping to the errors above or to core's.
+var remoteErrors = map[string]error{
+	"bad_request":         ErrBadRequest,
+	"invalid_address":     ErrInvalidAddress,
+	"insufficient_funds":  ErrInsufficientFunds,
+	"unknown_sender":      ErrUnknownSender,
+	"tx_rejected":         ErrTxRejected,
+	"faucet_disabled":     ErrFaucetDisabled,
+	"wallet_locked":       ErrWalletLocked,
+	"wrong_passphrase":    ErrWrongPassphrase,
+	"block_not_found":     core.ErrBlockNotFound,
+	"height_out_of_range": core.ErrHeightOutOfRange,
+}
+
+// RemoteError is an error reported by the node itself, as opposed to a failure to reach it.
+type RemoteError struct {
+	Message string
+	// Code classifies the error; see remoteErrors. It is empty for other failures.
+	Code string
+}
+
+func (e *RemoteError) Error() string {
+	return e.Message
+}
+
+// Unwrap returns the error kind named by Code, so errors.Is works on remote errors.
+func (e *RemoteError) Unwrap() error {
+	return remoteErrors[e.Code]
+}
+
+// errorCode classifies a failure on the node for the reply to the client.
+func errorCode(err error) string {
+	switch {
+	case errors.Is(err, ErrInvalidAddress), errors.Is(err, core.ErrInvalidAddress):
+		return "invalid_address"
+	case errors.Is(err, ErrBadRequest):
+		return "bad_request"
+	case errors.Is(err, ErrFaucetDisabled):
+		return "faucet_disabled"
+	case errors.Is(err, wallet.ErrWalletLocked):
+		return "wallet_locked"
+	case errors.Is(err, wallet.ErrWrongPassphrase):
+		return "wrong_passphrase"
+	case errors.Is(err, wallet.ErrNotEncrypted):
+		return "bad_request"
+	case errors.Is(err, core.ErrBlockNotFound):
+		return "block_not_found"
+	case errors.Is(err, core.ErrHeightOutOfRange):
+		return "height_out_of_range"
+	case errors.Is(err, core.ErrInsufficientFunds):
+		return "insufficient_funds"
+	case errors.Is(err, core.ErrWalletNotFound), errors.Is(err, wallet.ErrWatchOnly):
+		return "unknown_sender"
+	case errors.Is(err, core.ErrDoubleSpend), errors.Is(err, core.ErrReplacementFee),
+		errors.Is(err, core.ErrNonFinalTx), errors.Is(err, core.ErrInvalidTransaction),
+		errors.Is(err, core.ErrTxTooLarge), errors.Is(err, core.ErrBadDataOutput),
+		errors.Is(err, core.ErrDustOutput):
+		return "tx_rejected"
+	}
+	return ""
+}
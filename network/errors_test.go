@@ -0,0 +1,60 @@
+package network
+
+import (
+	"errors"
+	"testing"
+
+	"my-blockchain/core"
+	"my-blockchain/wallet"
+)
+
+func TestRequestErrorsAreTyped(t *testing.T) {
+	chdirTemp(t)
+	n := newTestNode(t)
+	from := fundedChain(t, n)
+	startNode(t, n)
+	to := string(wallet.NewWallet().GetAddress())
+
+	tests := []struct {
+		name string
+		call func() error
+		want error
+	}{
+		{"send to invalid address", func() error {
+			_, err := SendTxRequest(n.id, from, "not-an-address", 5, core.TxOptions{})
+			return err
+		}, ErrInvalidAddress},
+		{"send more than the balance", func() error {
+			_, err := SendTxRequest(n.id, from, to, 1000, core.TxOptions{})
+			return err
+		}, ErrInsufficientFunds},
+		{"send from a foreign address", func() error {
+			_, err := SendTxRequest(n.id, to, from, 5, core.TxOptions{})
+			return err
+		}, ErrUnknownSender},
+		{"balance of invalid address", func() error {
+			_, err := GetBalanceRequest(n.id, "not-an-address")
+			return err
+		}, ErrInvalidAddress},
+		{"send to a node that is down", func() error {
+			_, err := SendTxRequest(freePort(t), from, to, 5, core.TxOptions{})
+			return err
+		}, ErrNodeUnreachable},
+		{"balance from a node that is down", func() error {
+			_, err := GetBalanceRequest(freePort(t), from)
+			return err
+		}, ErrNodeUnreachable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+			var remote *RemoteError
+			if isRemote := errors.As(err, &remote); isRemote == errors.Is(tt.want, ErrNodeUnreachable) {
+				t.Fatalf("%v: RemoteError %v, want it only for failures reported by the node", err, isRemote)
+			}
+		})
+	}
+}
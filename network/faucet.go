@@ -0,0 +1,99 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"my-blockchain/core"
+	"my-blockchain/wallet"
+)
+
+// maxFaucetBlocks bounds how many blocks one faucet request may mine to fund itself.
+const maxFaucetBlocks = 300
+
+// FundRequest asks a regtest node to credit Amount to Address.
+type FundRequest struct {
+	AddrFrom string
+	Address  string
+	Amount   int
+}
+
+// FundResponse names the transaction paying the address and the blocks mined to fund
+// and confirm it, in order.
+type FundResponse struct {
+	OK      bool
+	Message string
+	Code    string
+	TxID    []byte
+	Hashes  [][]byte
+}
+
+// FaucetRequest asks the running node at nodeAddr(nodeID) to credit amount to address.
+// It returns the paying transaction's ID and the hashes of the blocks mined.
+func FaucetRequest(nodeID, address string, amount int) ([]byte, [][]byte, error) {
+	addr := nodeAddr(nodeID)
+	payload := FundRequest{AddrFrom: addr, Address: address, Amount: amount}
+	var res FundResponse
+	if err := requestTimeout(addr, "faucet", payload, "funded", &res, generateTimeout); err != nil {
+		return nil, nil, err
+	}
+	if !res.OK {
+		return nil, res.Hashes, &RemoteError{Message: res.Message, Code: res.Code}
+	}
+	return res.TxID, res.Hashes, nil
+}
+
+func (n *Node) handleFaucet(conn net.Conn, payloadBytes []byte) error {
+	var payload FundRequest
+	if err := decodePayload(payloadBytes, &payload); err != nil {
+		return err
+	}
+
+	res := FundResponse{OK: true}
+	txID, hashes, err := n.faucet(payload.Address, payload.Amount)
+	if err != nil {
+		res = FundResponse{OK: false, Message: err.Error(), Code: errorCode(err)}
+	}
+	res.TxID, res.Hashes = txID, hashes
+	return replyWith(conn, "funded", res)
+}
+
+// faucet pays amount to address from the node's miner address and mines a block
+// confirming it. When the miner has too few mature coins, it first mines blocks paying
+// itself until it can. Only regtest chains have a faucet.
+func (n *Node) faucet(address string, amount int) ([]byte, [][]byte, error) {
+	if n.bc.Config().Name != core.RegtestConfig.Name {
+		return nil, nil, ErrFaucetDisabled
+	}
+	if amount <= 0 {
+		return nil, nil, fmt.Errorf("%w: amount must be > 0", ErrBadRequest)
+	}
+	if !wallet.ValidateAddress(address) {
+		return nil, nil, ErrInvalidAddress
+	}
+	if n.miner == "" {
+		return nil, nil, fmt.Errorf("%w: the node has no miner address to fund the faucet", ErrBadRequest)
+	}
+
+	var hashes [][]byte
+	for {
+		tx, err := n.submitTx(n.miner, address, amount, core.TxOptions{})
+		if err == nil {
+			mined, err := n.generate(1, n.miner)
+			return tx.ID, append(hashes, mined...), err
+		}
+		if !errors.Is(err, core.ErrInsufficientFunds) {
+			return nil, hashes, err
+		}
+		if len(hashes) >= maxFaucetBlocks {
+			return nil, hashes, fmt.Errorf("%w: %d blocks mined without raising %d", err, len(hashes), amount)
+		}
+		// Mine one more coinbase to the miner; it takes CoinbaseMaturity blocks to mature.
+		mined, err := n.generate(1, n.miner)
+		hashes = append(hashes, mined...)
+		if err != nil {
+			return nil, hashes, err
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package network
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"my-blockchain/core"
+	"my-blockchain/wallet"
+)
+
+func TestFaucetFundsAddressOnRegtest(t *testing.T) {
+	chdirTemp(t)
+	n := newTestNode(t)
+	n.miner = fundedChain(t, n)
+	startNode(t, n)
+	to := string(wallet.NewWallet().GetAddress())
+
+	txID, hashes, err := FaucetRequest(n.id, to, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hashes) != 1 || n.bc.BestHeight() != 2 {
+		t.Fatalf("faucet mined %d blocks to height %d, want 1 to height 2", len(hashes), n.bc.BestHeight())
+	}
+	if _, block, err := n.bc.FindTransactionBlock(txID); err != nil || !bytes.Equal(block, hashes[0]) {
+		t.Fatalf("faucet transaction %x in block %x (%v), want %x", txID, block, err, hashes[0])
+	}
+	if got, err := GetBalanceRequest(n.id, to); err != nil || got != 5 {
+		t.Fatalf("funded balance %d (%v), want 5", got, err)
+	}
+
+	if _, _, err := FaucetRequest(n.id, "not an address", 5); !errors.Is(err, ErrInvalidAddress) {
+		t.Fatalf("funding an invalid address: got %v, want ErrInvalidAddress", err)
+	}
+}
+
+func TestFaucetRefusedOnStandardChain(t *testing.T) {
+	chdirTemp(t)
+	n := newTestNode(t)
+	n.Chain = core.MainConfig
+	n.miner = fundedChain(t, n)
+	startNode(t, n)
+
+	_, hashes, err := FaucetRequest(n.id, string(wallet.NewWallet().GetAddress()), 5)
+	if !errors.Is(err, ErrFaucetDisabled) {
+		t.Fatalf("faucet on the main chain: got %v, want ErrFaucetDisabled", err)
+	}
+	if len(hashes) != 0 || n.bc.BestHeight() != 1 {
+		t.Fatalf("refused faucet mined %d blocks to height %d", len(hashes), n.bc.BestHeight())
+	}
+}
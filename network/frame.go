@@ -0,0 +1,112 @@
+package network
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Every message on the wire is one frame:
+//
+//	magic (4 bytes) | version (1 byte) | length (4 bytes, big-endian) | payload (length bytes)
+//
+// The payload is a Message encoded with codec.
+
+var frameMagic = [4]byte{0xfa, 0xbf, 0xb5, 0xda}
+
+const (
+	// frameVersion is the framing/codec version, bumped whenever the payload encoding changes.
+	frameVersion    = 1
+	frameHeaderSize = 9
+)
+
+var (
+	ErrBadMagic      = errors.New("bad frame magic")
+	ErrFrameVersion  = errors.New("unsupported frame version")
+	ErrFrameTooLarge = errors.New("frame too large")
+)
+
+// messageCodec turns Messages into frame payloads and back. Changing the codec only
+// changes the payload; bump frameVersion with it so old peers fail cleanly.
+type messageCodec interface {
+	Marshal(msg Message) ([]byte, error)
+	Unmarshal(data []byte, msg *Message) error
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(msg Message) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(msg); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, msg *Message) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(msg)
+}
+
+var codec messageCodec = gobCodec{}
+
+// WriteFrame writes payload as a single frame.
+func WriteFrame(w io.Writer, payload []byte) error {
+	if len(payload) > maxMessageSize {
+		return fmt.Errorf("%w: %d bytes", ErrFrameTooLarge, len(payload))
+	}
+	frame := make([]byte, frameHeaderSize, frameHeaderSize+len(payload))
+	copy(frame, frameMagic[:])
+	frame[4] = frameVersion
+	binary.BigEndian.PutUint32(frame[5:], uint32(len(payload)))
+	_, err := w.Write(append(frame, payload...))
+	return err
+}
+
+// ReadFrame reads a single frame and returns its payload. A frame cut short returns
+// io.ErrUnexpectedEOF; payloads over maxMessageSize are rejected before being read.
+func ReadFrame(r io.Reader) ([]byte, error) {
+	var header [frameHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(header[:4], frameMagic[:]) {
+		return nil, fmt.Errorf("%w: %x", ErrBadMagic, header[:4])
+	}
+	if header[4] != frameVersion {
+		return nil, fmt.Errorf("%w: got %d, want %d", ErrFrameVersion, header[4], frameVersion)
+	}
+	length := binary.BigEndian.Uint32(header[5:])
+	if length > maxMessageSize {
+		return nil, fmt.Errorf("%w: %d bytes", ErrFrameTooLarge, length)
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return payload, nil
+}
+
+func writeMessage(w io.Writer, msg Message) error {
+	data, err := codec.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return WriteFrame(w, data)
+}
+
+func readMessage(r io.Reader) (Message, error) {
+	var msg Message
+	data, err := ReadFrame(r)
+	if err != nil {
+		return msg, err
+	}
+	err = codec.Unmarshal(data, &msg)
+	return msg, err
+}
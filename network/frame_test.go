@@ -0,0 +1,68 @@
+package network
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestFrameRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	msg := Message{Command: "ping", Payload: []byte("payload")}
+	if err := writeMessage(&buf, msg); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readMessage(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Command != msg.Command || !bytes.Equal(got.Payload, msg.Payload) {
+		t.Fatalf("read back %+v, want %+v", got, msg)
+	}
+}
+
+func TestReadFrameRejectsBadFrames(t *testing.T) {
+	var good bytes.Buffer
+	if err := WriteFrame(&good, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	frame := good.Bytes()
+	with := func(i int, b byte) []byte {
+		f := bytes.Clone(frame)
+		f[i] = b
+		return f
+	}
+
+	tests := []struct {
+		name  string
+		frame []byte
+		want  error
+	}{
+		{"empty", nil, io.EOF},
+		{"truncated header", frame[:frameHeaderSize-1], io.ErrUnexpectedEOF},
+		{"truncated payload", frame[:len(frame)-1], io.ErrUnexpectedEOF},
+		{"header only", frame[:frameHeaderSize], io.ErrUnexpectedEOF},
+		{"bad magic", with(0, frameMagic[0]^0xff), ErrBadMagic},
+		{"version mismatch", with(4, frameVersion+1), ErrFrameVersion},
+		{"too large", append(bytes.Clone(frame[:5]), 0xff, 0xff, 0xff, 0xff), ErrFrameTooLarge},
+	}
+	for _, tt := range tests {
+		if _, err := ReadFrame(bytes.NewReader(tt.frame)); !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+	if payload, err := ReadFrame(bytes.NewReader(frame)); err != nil || string(payload) != "hello" {
+		t.Fatalf("intact frame: got %q, %v", payload, err)
+	}
+}
+
+func TestWriteFrameRejectsOversizedPayload(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteFrame(&buf, make([]byte, maxMessageSize+1)); !errors.Is(err, ErrFrameTooLarge) {
+		t.Fatalf("got %v, want ErrFrameTooLarge", err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("wrote %d bytes of a rejected frame", buf.Len())
+	}
+}
@@ -0,0 +1,102 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"my-blockchain/core"
+	"my-blockchain/wallet"
+)
+
+// maxGenerate caps how many blocks one mine request may ask for.
+const maxGenerate = 100
+
+// generateTimeout is how long GenerateRequest waits for the node to mine the blocks.
+const generateTimeout = 10 * time.Minute
+
+// maxStaleRetries bounds how often generate restarts a block because a peer's became the
+// tip first.
+const maxStaleRetries = 10
+
+// MineRequest asks the node to mine Count blocks now, each draining what it can of the
+// mempool, with their coinbase paid to Address (the node's miner address when empty).
+type MineRequest struct {
+	AddrFrom string
+	Count    int
+	Address  string
+}
+
+// MineResponse lists the hashes of the blocks mined, in order.
+type MineResponse struct {
+	OK      bool
+	Message string
+	Code    string
+	Hashes  [][]byte
+}
+
+// GenerateRequest asks the running node at nodeAddr(nodeID) to mine count blocks paying
+// address ("" for its miner address) and returns their hashes.
+func GenerateRequest(nodeID string, count int, address string) ([][]byte, error) {
+	addr := nodeAddr(nodeID)
+	payload := MineRequest{AddrFrom: addr, Count: count, Address: address}
+	var res MineResponse
+	if err := requestTimeout(addr, "mine", payload, "mined", &res, generateTimeout); err != nil {
+		return nil, err
+	}
+	if !res.OK {
+		return res.Hashes, &RemoteError{Message: res.Message, Code: res.Code}
+	}
+	return res.Hashes, nil
+}
+
+func (n *Node) handleMine(conn net.Conn, payloadBytes []byte) error {
+	var payload MineRequest
+	if err := decodePayload(payloadBytes, &payload); err != nil {
+		return err
+	}
+
+	res := MineResponse{OK: true}
+	hashes, err := n.generate(payload.Count, payload.Address)
+	if err != nil {
+		res = MineResponse{OK: false, Message: err.Error(), Code: errorCode(err)}
+	}
+	res.Hashes = hashes
+	return replyWith(conn, "mined", res)
+}
+
+// generate mines count blocks on demand, empty ones included, paying address or, when it
+// is empty, the node's miner address. It returns the blocks mined before any failure.
+func (n *Node) generate(count int, address string) ([][]byte, error) {
+	if count < 1 || count > maxGenerate {
+		return nil, fmt.Errorf("%w: count must be between 1 and %d", ErrBadRequest, maxGenerate)
+	}
+	if address == "" {
+		address = n.miner
+	}
+	if address == "" {
+		return nil, fmt.Errorf("%w: the node has no miner address; give one to pay", ErrBadRequest)
+	}
+	if !wallet.ValidateAddress(address) {
+		return nil, ErrInvalidAddress
+	}
+
+	var hashes [][]byte
+	for stale := 0; len(hashes) < count; {
+		hash, err := n.mineBlock(context.Background(), address, true)
+		if errors.Is(err, context.Canceled) || errors.Is(err, core.ErrStaleTip) {
+			// A peer's block won the race: mine again on top of it.
+			if stale++; stale > maxStaleRetries {
+				return hashes, err
+			}
+			continue
+		}
+		if err != nil {
+			return hashes, err
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, nil
+}
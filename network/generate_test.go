@@ -0,0 +1,99 @@
+package network
+
+import (
+	"bytes"
+	"testing"
+
+	"my-blockchain/core"
+	"my-blockchain/wallet"
+)
+
+func TestGenerateConfirmsQueuedTransactions(t *testing.T) {
+	chdirTemp(t)
+	n := newTestNode(t)
+	from := fundedChain(t, n)
+	startNode(t, n)
+	miner := string(wallet.NewWallet().GetAddress())
+
+	// Each send after the first spends the pending change of the one before.
+	recipients := []string{
+		string(wallet.NewWallet().GetAddress()),
+		string(wallet.NewWallet().GetAddress()),
+		string(wallet.NewWallet().GetAddress()),
+	}
+	for _, to := range recipients {
+		if _, err := SendTxRequest(n.id, from, to, 3, core.TxOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if queued := n.mempool.Len(); queued != 3 {
+		t.Fatalf("%d transactions queued, want 3", queued)
+	}
+
+	hashes, err := GenerateRequest(n.id, 2, miner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hashes) != 2 || n.bc.BestHeight() != 3 {
+		t.Fatalf("generated %d blocks to height %d, want 2 to height 3", len(hashes), n.bc.BestHeight())
+	}
+	if left := n.mempool.Len(); left != 0 {
+		t.Fatalf("%d transactions left in the mempool", left)
+	}
+
+	// The last change, 1, is dust and goes to the miner as a fee.
+	want := map[string]int{recipients[0]: 3, recipients[1]: 3, recipients[2]: 3, from: 0, miner: 21}
+	for addr, value := range want {
+		got, err := GetBalanceRequest(n.id, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != value {
+			t.Errorf("balance of %s is %d, want %d", addr, got, value)
+		}
+	}
+}
+
+func TestPendingTransactionsMinedIntoOneBlock(t *testing.T) {
+	chdirTemp(t)
+	n := newTestNode(t)
+	from := fundedChain(t, n)
+	startNode(t, n)
+
+	for i := 0; i < 2; i++ {
+		if _, err := SendTxRequest(n.id, from, string(wallet.NewWallet().GetAddress()), 3, core.TxOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var pending [][]byte
+	for _, tx := range n.mempool.Pending() {
+		pending = append(pending, tx.ID)
+	}
+	if len(pending) != 2 {
+		t.Fatalf("%d transactions pending, want 2", len(pending))
+	}
+
+	hashes, err := GenerateRequest(n.id, 1, string(wallet.NewWallet().GetAddress()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := GetBlockRequest(n.id, hashes[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(block.Txs) != 3 || !block.Txs[0].Coinbase {
+		t.Fatalf("block holds %d transactions, want a coinbase and both pending ones", len(block.Txs))
+	}
+	for _, id := range pending {
+		found := false
+		for _, tx := range block.Txs[1:] {
+			found = found || bytes.Equal(tx.ID, id)
+		}
+		if !found {
+			t.Errorf("pending transaction %x not in the block", id)
+		}
+	}
+	if left := n.mempool.Len(); left != 0 {
+		t.Fatalf("%d transactions left in the mempool", left)
+	}
+}
@@ -0,0 +1,163 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"my-blockchain/core"
+	"my-blockchain/wallet"
+)
+
+// chdirTemp runs the rest of the test in a new temporary directory, where the chain DB
+// files, named after the node ID, are created.
+func chdirTemp(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	return dir
+}
+
+// freePort returns a TCP port on localhost nothing listens on right now.
+func freePort(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ln.Close() }()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	return port
+}
+
+// dialWithin waits until something accepts connections on addr.
+func dialWithin(t *testing.T, addr string, timeout time.Duration) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := net.DialTimeout("tcp", addr, 100*time.Millisecond)
+		if err == nil {
+			_ = conn.Close()
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("nothing listening on %s: %v", addr, err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// waitFor polls cond until it holds, failing the test with what after timeout.
+func waitFor(t *testing.T, timeout time.Duration, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// newTestNode returns a regtest node on a free port of localhost, in the current
+// directory, that knows only peers. It is not started.
+func newTestNode(t *testing.T, peers ...string) *Node {
+	t.Helper()
+	id := freePort(t)
+	peersFile := filepath.Join(".", PeersFile(id))
+	content, err := json.Marshal(append([]string{}, peers...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(peersFile, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	n := NewNode(id, "", peersFile, "")
+	n.Chain = core.RegtestConfig
+	return n
+}
+
+// startNode starts n and waits until it accepts connections, after which the test may use
+// n.bc. The node is stopped, and Start's error checked, when the test ends.
+func startNode(t *testing.T, n *Node) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- n.Start(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	})
+	select {
+	case <-n.listening:
+	case err := <-done:
+		done <- err
+		t.Fatalf("node %s stopped before listening: %v", n.id, err)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("node %s not listening after 5s", n.id)
+	}
+}
+
+// fundedChain creates n's regtest chain, whose genesis block pays a new address kept in
+// a wallet file n signs with, and returns that address.
+func fundedChain(t *testing.T, n *Node) string {
+	t.Helper()
+	n.WalletFile = filepath.Join(".", "wallets_"+n.id+".dat")
+	ws, err := wallet.NewWalletsAt(n.WalletFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	from, err := ws.CreateWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc, err := core.CreateBlockchainForNode(from, n.id, n.Chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return from
+}
+
+// copyChain gives dst, which must not be running, a copy of src's chain DB.
+func copyChain(t *testing.T, src, dst *Node) {
+	t.Helper()
+	content, err := os.ReadFile("blockchain_" + src.id + ".db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("blockchain_"+dst.id+".db", content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// extendChain mines count empty blocks onto n's chain, which must not be running.
+func extendChain(t *testing.T, n *Node, count int) {
+	t.Helper()
+	bc, err := core.OpenBlockchainForNode(n.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = bc.Close() }()
+	to := string(wallet.NewWallet().GetAddress())
+	for range count {
+		if _, err := bc.AddBlock([]*core.Transaction{bc.Config().CoinbaseTx(to, "", bc.BestHeight()+1)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package network
+
+import (
+	"net"
+
+	"my-blockchain/core"
+	"my-blockchain/wallet"
+)
+
+// HistoryRequest asks the node for every main-chain transaction involving Address.
+type HistoryRequest struct {
+	AddrFrom string
+	Address  string
+}
+
+// HistoryResponse lists an address's transactions, oldest first.
+type HistoryResponse struct {
+	OK      bool
+	Message string
+	Entries []core.AddressTx
+}
+
+// GetHistoryRequest asks the running node at nodeAddr(nodeID) for an address's transactions.
+func GetHistoryRequest(nodeID string, address string) ([]core.AddressTx, error) {
+	addr := nodeAddr(nodeID)
+	payload := HistoryRequest{AddrFrom: addr, Address: address}
+	var res HistoryResponse
+	if err := request(addr, "listtxs", payload, "history", &res); err != nil {
+		return nil, err
+	}
+	if !res.OK {
+		return nil, &RemoteError{Message: res.Message}
+	}
+	return res.Entries, nil
+}
+
+func handleListTxs(conn net.Conn, payloadBytes []byte, bc *core.Blockchain) error {
+	var payload HistoryRequest
+	if err := decodePayload(payloadBytes, &payload); err != nil {
+		return err
+	}
+
+	res := HistoryResponse{OK: true}
+	if !wallet.ValidateAddress(payload.Address) {
+		res = HistoryResponse{OK: false, Message: "invalid address"}
+	} else if entries, err := bc.FindTransactionsForAddress(wallet.PubKeyHashFromAddress(payload.Address)); err != nil {
+		res = HistoryResponse{OK: false, Message: err.Error()}
+	} else {
+		res.Entries = entries
+	}
+	return replyWith(conn, "history", res)
+}
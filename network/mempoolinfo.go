@@ -0,0 +1,60 @@
+package network
+
+import (
+	"net"
+
+	"my-blockchain/core"
+)
+
+// MempoolRequest asks the node to list its pending transactions.
+type MempoolRequest struct {
+	AddrFrom string
+}
+
+// MempoolInfo lists a node's pending transactions in arrival order, with their total
+// serialized size in bytes and total fees.
+type MempoolInfo struct {
+	Txs   []core.PendingTx
+	Bytes int
+	Fees  int
+}
+
+type MempoolResponse struct {
+	OK      bool
+	Message string
+	Code    string
+	Info    MempoolInfo
+}
+
+// GetMempoolRequest asks the running node at nodeAddr(nodeID) for its pending transactions.
+func GetMempoolRequest(nodeID string) (MempoolInfo, error) {
+	addr := nodeAddr(nodeID)
+	payload := MempoolRequest{AddrFrom: addr}
+	var res MempoolResponse
+	if err := request(addr, "getmempool", payload, "mempool", &res); err != nil {
+		return MempoolInfo{}, err
+	}
+	if !res.OK {
+		return MempoolInfo{}, &RemoteError{Message: res.Message, Code: res.Code}
+	}
+	return res.Info, nil
+}
+
+func (n *Node) handleGetMempool(conn net.Conn, payloadBytes []byte) error {
+	var payload MempoolRequest
+	if err := decodePayload(payloadBytes, &payload); err != nil {
+		return err
+	}
+
+	res := MempoolResponse{OK: true, Info: n.mempoolInfo()}
+	return replyWith(conn, "mempool", res)
+}
+
+func (n *Node) mempoolInfo() MempoolInfo {
+	info := MempoolInfo{Txs: n.mempool.Pending()}
+	for _, tx := range info.Txs {
+		info.Bytes += tx.Size
+		info.Fees += tx.Fee
+	}
+	return info
+}
@@ -0,0 +1,68 @@
+package network
+
+import (
+	"testing"
+
+	"my-blockchain/core"
+	"my-blockchain/wallet"
+)
+
+func TestGetMempoolListsPendingTransactions(t *testing.T) {
+	chdirTemp(t)
+	n := newTestNode(t)
+	from := fundedChain(t, n)
+	startNode(t, n)
+
+	info, err := GetMempoolRequest(n.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Txs) != 0 || info.Bytes != 0 || info.Fees != 0 {
+		t.Fatalf("empty mempool listed as %+v", info)
+	}
+
+	for _, fee := range []int{1, 2} {
+		to := string(wallet.NewWallet().GetAddress())
+		if _, err := SendTxRequest(n.id, from, to, 3, core.TxOptions{Fee: fee}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The second send spends the first's change of 6, and its own change of 1 is dust that
+	// goes to the fee.
+	fees := []int{1, 3}
+
+	info, err = GetMempoolRequest(n.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Txs) != len(fees) {
+		t.Fatalf("%d transactions listed, want %d", len(info.Txs), len(fees))
+	}
+	size := 0
+	for i, tx := range info.Txs {
+		if !n.mempool.Has(tx.ID) {
+			t.Errorf("transaction %d, %x, is not in the mempool", i, tx.ID)
+		}
+		if tx.Fee != fees[i] {
+			t.Errorf("transaction %d pays %d, want %d", i, tx.Fee, fees[i])
+		}
+		if tx.Size <= 0 {
+			t.Errorf("transaction %d has size %d", i, tx.Size)
+		}
+		size += tx.Size
+	}
+	if info.Bytes != size || info.Fees != 4 {
+		t.Fatalf("totals are %d bytes and %d fees, want %d and 4", info.Bytes, info.Fees, size)
+	}
+
+	if _, err := GenerateRequest(n.id, 1, string(wallet.NewWallet().GetAddress())); err != nil {
+		t.Fatal(err)
+	}
+	info, err = GetMempoolRequest(n.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Txs) != 0 || info.Bytes != 0 || info.Fees != 0 {
+		t.Fatalf("mempool after mining listed as %+v", info)
+	}
+}
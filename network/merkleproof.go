@@ -0,0 +1,72 @@
+package network
+
+import (
+	"fmt"
+	"net"
+
+	"my-blockchain/core"
+)
+
+// MerkleProofRequest asks the node to prove that a mined transaction is in its block.
+type MerkleProofRequest struct {
+	AddrFrom string
+	TxID     []byte
+}
+
+// MerkleProofResponse carries an SPV proof: with the block header's MerkleRoot, Proof and
+// Left are enough to check inclusion via core.VerifyMerkleProof without the full block.
+type MerkleProofResponse struct {
+	OK         bool
+	Message    string
+	BlockHash  []byte
+	MerkleRoot []byte
+	Proof      [][]byte
+	Left       []bool
+}
+
+// GetMerkleProofRequest asks the running node at nodeAddr(nodeID) for a transaction's Merkle proof.
+func GetMerkleProofRequest(nodeID string, txID []byte) (MerkleProofResponse, error) {
+	addr := nodeAddr(nodeID)
+	payload := MerkleProofRequest{AddrFrom: addr, TxID: txID}
+	var res MerkleProofResponse
+	if err := request(addr, "getmerkleproof", payload, "merkleproof", &res); err != nil {
+		return MerkleProofResponse{}, err
+	}
+	if !res.OK {
+		return res, &RemoteError{Message: res.Message}
+	}
+	return res, nil
+}
+
+func handleGetMerkleProof(conn net.Conn, payloadBytes []byte, bc *core.Blockchain) error {
+	var payload MerkleProofRequest
+	if err := decodePayload(payloadBytes, &payload); err != nil {
+		return err
+	}
+
+	res, err := merkleProof(bc, payload.TxID)
+	if err != nil {
+		res = MerkleProofResponse{OK: false, Message: err.Error()}
+	}
+	return replyWith(conn, "merkleproof", res)
+}
+
+func merkleProof(bc *core.Blockchain, txID []byte) (MerkleProofResponse, error) {
+	_, blockHash, err := bc.FindTransactionBlock(txID)
+	if err != nil {
+		return MerkleProofResponse{}, fmt.Errorf("transaction %x: %w", txID, err)
+	}
+	data, err := bc.GetBlock(blockHash)
+	if err != nil {
+		return MerkleProofResponse{}, err
+	}
+	block, err := core.DecodeBlock(data)
+	if err != nil {
+		return MerkleProofResponse{}, err
+	}
+	proof, left, err := block.MerkleProof(txID)
+	if err != nil {
+		return MerkleProofResponse{}, err
+	}
+	return MerkleProofResponse{OK: true, BlockHash: block.Hash, MerkleRoot: block.MerkleRoot, Proof: proof, Left: left}, nil
+}
@@ -0,0 +1,43 @@
+package network
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// metric is one sample of the /metrics endpoint. Its value is read at scrape time.
+type metric struct {
+	name  string
+	help  string
+	kind  string // "counter" or "gauge"
+	value func() uint64
+}
+
+// metrics lists what GET /metrics exports, counters first.
+func (n *Node) metrics() []metric {
+	bc := n.bc
+	counters := bc.Metrics()
+	return []metric{
+		{"blockchain_blocks_processed_total", "Blocks stored, mined here or received, on any branch.", "counter", counters.BlocksProcessed.Value},
+		{"blockchain_transactions_valid_total", "Transaction verifications that passed; a transaction is checked again in each block it is mined into.", "counter", counters.TxsValid.Value},
+		{"blockchain_transactions_invalid_total", "Transaction verifications that failed.", "counter", counters.TxsInvalid.Value},
+		{"blockchain_pow_attempts_total", "Nonces tried by proof of work.", "counter", counters.PoWAttempts.Value},
+		{"blockchain_deep_reorgs_refused_total", "Reorganizations refused for disconnecting more than the maximum reorg depth.", "counter", counters.DeepReorgs.Value},
+		{"blockchain_height", "Height of the chain tip.", "gauge", func() uint64 { return uint64(bc.BestHeight()) }},
+		{"blockchain_mempool_transactions", "Transactions waiting in the mempool.", "gauge", func() uint64 { return uint64(n.mempool.Len()) }},
+		{"blockchain_peers", "Known peers, not counting this node.", "gauge", func() uint64 { return uint64(n.peerCount()) }},
+	}
+}
+
+// handleMetrics serves metrics in the Prometheus text exposition format.
+func (n *Node) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	writeMetrics(w, n.metrics())
+}
+
+func writeMetrics(w io.Writer, metrics []metric) {
+	for _, m := range metrics {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %d\n", m.name, m.help, m.name, m.kind, m.name, m.value())
+	}
+}
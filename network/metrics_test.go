@@ -0,0 +1,139 @@
+package network
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"my-blockchain/core"
+	"my-blockchain/wallet"
+)
+
+// scrape fetches srv's /metrics and returns each sample's value by name.
+func scrape(t *testing.T, srv *httptest.Server) map[string]uint64 {
+	t.Helper()
+	res, err := srv.Client().Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("GET /metrics: status %d", res.StatusCode)
+	}
+	samples := make(map[string]uint64)
+	scanner := bufio.NewScanner(res.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(line, " ")
+		if !ok {
+			t.Fatalf("malformed sample %q", line)
+		}
+		v, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			t.Fatalf("sample %q: %v", line, err)
+		}
+		samples[name] = v
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return samples
+}
+
+func TestMetricsFollowActivity(t *testing.T) {
+	chdirTemp(t)
+	n := newTestNode(t, nodeAddr(freePort(t)))
+	from := fundedChain(t, n)
+	startNode(t, n)
+	srv := httptest.NewServer(n.RPCHandler())
+	t.Cleanup(srv.Close)
+	to := string(wallet.NewWallet().GetAddress())
+
+	before := scrape(t, srv)
+	if before["blockchain_height"] != 1 || before["blockchain_mempool_transactions"] != 0 || before["blockchain_peers"] != 1 {
+		t.Fatalf("initial metrics %v, want height 1, an empty mempool and one peer", before)
+	}
+
+	if _, err := SendTxRequest(n.id, from, to, 5, core.TxOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	queued := scrape(t, srv)
+	if queued["blockchain_mempool_transactions"] != 1 {
+		t.Errorf("mempool gauge %d after a send, want 1", queued["blockchain_mempool_transactions"])
+	}
+	if queued["blockchain_transactions_valid_total"] <= before["blockchain_transactions_valid_total"] {
+		t.Error("valid transaction counter did not move after a send")
+	}
+
+	if _, err := GenerateRequest(n.id, 1, to); err != nil {
+		t.Fatal(err)
+	}
+	mined := scrape(t, srv)
+	if mined["blockchain_height"] != 2 || mined["blockchain_mempool_transactions"] != 0 {
+		t.Errorf("after mining: height %d and mempool %d, want 2 and 0", mined["blockchain_height"], mined["blockchain_mempool_transactions"])
+	}
+	for _, name := range []string{"blockchain_blocks_processed_total", "blockchain_pow_attempts_total"} {
+		if mined[name] <= queued[name] {
+			t.Errorf("%s did not move after mining: %d", name, mined[name])
+		}
+	}
+}
+
+func TestNodesInOneProcessKeepTheirOwnMetricsAndEvents(t *testing.T) {
+	chdirTemp(t)
+	a := newTestNode(t)
+	from := fundedChain(t, a)
+	b := newTestNode(t)
+	fundedChain(t, b)
+	aEvents, unsubscribeA := a.events.Subscribe()
+	defer unsubscribeA()
+	bEvents, unsubscribeB := b.events.Subscribe()
+	defer unsubscribeB()
+	startNode(t, a)
+	startNode(t, b)
+	aSrv := httptest.NewServer(a.RPCHandler())
+	t.Cleanup(aSrv.Close)
+	bSrv := httptest.NewServer(b.RPCHandler())
+	t.Cleanup(bSrv.Close)
+
+	to := string(wallet.NewWallet().GetAddress())
+	if _, err := SendTxRequest(a.id, from, to, 5, core.TxOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GenerateRequest(a.id, 1, to); err != nil {
+		t.Fatal(err)
+	}
+
+	mined := scrape(t, aSrv)
+	if mined["blockchain_blocks_processed_total"] != 1 || mined["blockchain_pow_attempts_total"] == 0 || mined["blockchain_transactions_valid_total"] == 0 {
+		t.Errorf("A's metrics after a send and a block: %v", mined)
+	}
+	idle := scrape(t, bSrv)
+	for _, name := range []string{"blockchain_blocks_processed_total", "blockchain_pow_attempts_total", "blockchain_transactions_valid_total"} {
+		if idle[name] != 0 {
+			t.Errorf("B's %s is %d after only A worked, want 0", name, idle[name])
+		}
+	}
+
+	seen := make(map[string]bool)
+	for len(seen) < 2 {
+		select {
+		case e := <-aEvents:
+			seen[e.Type] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("A published only %v", seen)
+		}
+	}
+	select {
+	case e := <-bEvents:
+		t.Fatalf("B published A's %s event", e.Type)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
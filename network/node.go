@@ -0,0 +1,281 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"my-blockchain/core"
+)
+
+// Node is one blockchain node: its chain, mempool, peers and sync state. Several can run
+// in one process as long as their IDs, and so their ports and DB files, differ. Each has
+// its own event bus and counters, and its chain's own size and dust limits; they still
+// share the process-wide logger.
+type Node struct {
+	// MinerThreads, PruneDepth, WalletFile, Chain, ListenAddr, DirectBlockRelay, RPCToken,
+	// TCPAuth and MaxPeers start as the package defaults of the same name and may be
+	// changed before Start.
+	MinerThreads     int
+	PruneDepth       int
+	WalletFile       string
+	Chain            core.ChainConfig
+	ListenAddr       string
+	DirectBlockRelay bool
+	RPCToken         string
+	TCPAuth          bool
+	MaxPeers         int
+
+	id        string
+	address   string
+	miner     string
+	peersFile string
+	rpcPort   string
+	logger    *slog.Logger
+
+	bc      *core.Blockchain
+	mempool *core.Mempool
+	// events carries the node's chain, mempool and sync events, for /ws and its loops.
+	events *core.EventBus
+
+	peers   *peerSet
+	health  *peerHealth
+	bodies  *bodiesInFlight
+	transit *blocksInTransit
+	work    *workTemplates
+	// seenTxs and seenBlocks hold recently handled IDs, so each is relayed at most once.
+	seenTxs    *seenSet
+	seenBlocks *seenSet
+	// unlock holds the wallet key while walletpassphrase has the wallet file unlocked.
+	unlock walletUnlock
+
+	// syncTarget is the best height a peer announced, for SyncStatus.
+	syncTarget atomic.Int64
+
+	// miningMu keeps the mining loop and generate requests from mining on the same tip.
+	miningMu sync.Mutex
+	// handlerSlots is a semaphore bounding concurrent handleConnection calls to maxConnections.
+	handlerSlots chan struct{}
+	// listening is closed once Start has opened the chain and bound its address.
+	listening chan struct{}
+	// inflight counts the goroutines that may touch the DB, which Start closes once none are left.
+	inflight sync.WaitGroup
+}
+
+// NewNode returns the node listening on localhost:<nodeID>, or on its ListenAddr when that
+// is not empty, mining to minerAddress when it is not empty, keeping its peers in peersFile
+// and serving the JSON API on rpcPort of the same host when it is not empty. Nothing is
+// opened until Start.
+func NewNode(nodeID string, minerAddress string, peersFile string, rpcPort string) *Node {
+	n := &Node{
+		MinerThreads: MinerThreads,
+		PruneDepth:   PruneDepth,
+		WalletFile:   WalletFile,
+		Chain:        Chain,
+		ListenAddr:   ListenAddr,
+
+		DirectBlockRelay: DirectBlockRelay,
+		RPCToken:         RPCToken,
+		TCPAuth:          TCPAuth,
+		MaxPeers:         MaxPeers,
+
+		id:        nodeID,
+		address:   net.JoinHostPort("localhost", nodeID),
+		miner:     minerAddress,
+		peersFile: peersFile,
+		rpcPort:   rpcPort,
+		logger:    core.Log.With("node", nodeID),
+
+		mempool: core.NewMempool(),
+		events:  core.NewEventBus(),
+
+		peers:   newPeerSet(),
+		health:  newPeerHealth(),
+		bodies:  &bodiesInFlight{hashes: make(map[string]bool)},
+		transit: &blocksInTransit{peers: make(map[string]*transit)},
+		work:    &workTemplates{byID: make(map[uint64]*core.BlockTemplate)},
+
+		seenTxs:    newSeenSet(seenCapacity),
+		seenBlocks: newSeenSet(seenCapacity),
+
+		handlerSlots: make(chan struct{}, maxConnections),
+		listening:    make(chan struct{}),
+	}
+	n.mempool.SetEvents(n.events)
+	return n
+}
+
+// Start opens the node's chain and serves peers until ctx is cancelled. It then stops
+// accepting connections, waits for in-flight handlers and the mining loop, and closes the
+// DB before returning nil. A node is started at most once. It refuses to listen beyond
+// loopback without a token and TCPAuth (see CheckListenAuth).
+func (n *Node) Start(ctx context.Context) error {
+	if n.ListenAddr != "" {
+		n.address = n.ListenAddr
+	}
+	if err := CheckListenAuth(n.address, n.RPCToken, n.TCPAuth); err != nil {
+		return err
+	}
+	if err := n.peers.load(n.peersFile); err != nil {
+		return err
+	}
+	bc, err := core.InitBlockchainForNode(n.id, n.Chain)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = bc.Close() }()
+	bc.SetLogger(n.logger)
+	bc.SetEvents(n.events)
+	if err := bc.VerifyCheckpoints(); err != nil {
+		return fmt.Errorf("local chain is corrupt: %w", err)
+	}
+	n.bc = bc
+	n.loadMempool()
+
+	ln, err := net.Listen("tcp", n.address)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = ln.Close() }()
+	close(n.listening)
+
+	n.logger.Info("listening", "addr", n.address, "db", "blockchain_"+n.id+".db", "miner", n.miner)
+
+	// Saved once nothing can add to or mine from the mempool any more.
+	defer n.saveMempool()
+
+	// Everything that may touch the DB runs under inflight, so it is closed only once idle.
+	defer n.inflight.Wait()
+
+	if n.rpcPort != "" {
+		host, _, _ := net.SplitHostPort(n.address)
+		rpc := n.startRPCServer(net.JoinHostPort(host, n.rpcPort))
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			defer cancel()
+			_ = rpc.Shutdown(shutdownCtx)
+		}()
+	}
+
+	// Only nodes with a reward address mine; the others relay transactions to them.
+	if n.miner != "" {
+		n.inflight.Add(1)
+		go func() {
+			defer n.inflight.Done()
+			n.miningLoop(ctx)
+		}()
+	}
+
+	n.inflight.Add(2)
+	go func() {
+		defer n.inflight.Done()
+		n.probeLoop(ctx)
+	}()
+	go func() {
+		defer n.inflight.Done()
+		n.pingLoop(ctx)
+	}()
+
+	if n.PruneDepth > 0 {
+		n.inflight.Add(1)
+		go func() {
+			defer n.inflight.Done()
+			n.pruneLoop(ctx)
+		}()
+	}
+
+	// If we're not the bootstrap node, announce ourselves.
+	if bootstrap := n.bootstrapNode(); bootstrap != "" && n.address != bootstrap {
+		n.inflight.Add(1)
+		go func() {
+			defer n.inflight.Done()
+			n.sendVersion(bootstrap)
+			n.sendGetAddr(bootstrap)
+		}()
+	}
+
+	go func() {
+		<-ctx.Done()
+		_ = ln.Close()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		select {
+		case n.handlerSlots <- struct{}{}:
+		default:
+			n.logger.Warn("dropped connection: too many being handled", "from", conn.RemoteAddr().String(), "limit", maxConnections)
+			_ = conn.Close()
+			continue
+		}
+		n.inflight.Add(1)
+		go func() {
+			defer n.inflight.Done()
+			defer func() { <-n.handlerSlots }()
+			n.handleConnection(conn)
+		}()
+	}
+
+	n.logger.Info("shutting down")
+	return nil
+}
+
+// goInflight runs f in a goroutine that Start waits for before closing the DB. It is
+// called only from goroutines already counted in inflight, so the count is never zero.
+func (n *Node) goInflight(f func()) {
+	n.inflight.Add(1)
+	go func() {
+		defer n.inflight.Done()
+		f()
+	}()
+}
+
+// loadMempool restores the transactions pending at the last shutdown that are still valid.
+func (n *Node) loadMempool() {
+	path := core.MempoolFile(n.id)
+	kept, dropped, err := n.mempool.Load(path, n.bc)
+	if err != nil {
+		n.logger.Error("could not load the mempool", "file", path, "err", err)
+		return
+	}
+	if kept > 0 || dropped > 0 {
+		n.logger.Info("loaded mempool", "file", path, "kept", kept, "dropped", dropped)
+	}
+}
+
+// saveMempool writes the pending transactions to the node's mempool file.
+func (n *Node) saveMempool() {
+	path := core.MempoolFile(n.id)
+	if err := n.mempool.Save(path); err != nil {
+		n.logger.Error("could not save the mempool", "file", path, "err", err)
+		return
+	}
+	n.logger.Info("saved mempool", "file", path, "txs", n.mempool.Len())
+}
+
+// pruneLoop prunes the chain to PruneDepth now and after every new tip until ctx is done.
+func (n *Node) pruneLoop(ctx context.Context) {
+	blocks, unsubscribe := n.events.Subscribe(core.EventBlock)
+	defer unsubscribe()
+	for {
+		if pruned, err := n.bc.Prune(n.PruneDepth); err != nil {
+			n.logger.Error("pruning failed", "err", err)
+		} else if pruned > 0 {
+			n.logger.Info("pruned blocks", "count", pruned)
+		}
+		select {
+		case <-blocks:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
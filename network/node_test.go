@@ -0,0 +1,164 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"my-blockchain/core"
+	"my-blockchain/wallet"
+)
+
+func TestNodesInOneProcessKeepTheirOwnSettings(t *testing.T) {
+	chdirTemp(t)
+	listen := net.JoinHostPort("127.0.0.1", freePort(t))
+	a := NewNode("a", "", "", "")
+	a.ListenAddr = listen
+	a.Chain = core.RegtestConfig
+	b := NewNode(freePort(t), "", "", "")
+	b.Chain = core.TestConfig
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 2)
+	for _, n := range []*Node{a, b} {
+		go func(n *Node) { done <- n.Start(ctx) }(n)
+	}
+	defer func() {
+		cancel()
+		for range 2 {
+			if err := <-done; err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+
+	dialWithin(t, listen, 5*time.Second)
+	dialWithin(t, net.JoinHostPort("localhost", b.id), 5*time.Second)
+	<-a.listening
+	<-b.listening
+	if b.address == listen {
+		t.Fatalf("second node took the first node's listen address %s", listen)
+	}
+	if got := a.bc.Config().Name; got != core.RegtestConfig.Name {
+		t.Fatalf("first node's chain is %s, want %s", got, core.RegtestConfig.Name)
+	}
+	if got := b.bc.Config().Name; got != core.TestConfig.Name {
+		t.Fatalf("second node's chain is %s, want %s", got, core.TestConfig.Name)
+	}
+}
+
+func TestBlockMinedOnOneNodeReachesTwoOthers(t *testing.T) {
+	chdirTemp(t)
+	a := newTestNode(t)
+	fundedChain(t, a)
+	// B knows A, and C knows only B, so the block has to be relayed on from B.
+	b := newTestNode(t, a.address)
+	copyChain(t, a, b)
+	c := newTestNode(t, b.address)
+	copyChain(t, a, c)
+	for _, n := range []*Node{a, b, c} {
+		startNode(t, n)
+	}
+	waitFor(t, 5*time.Second, "A and B to learn their peers", func() bool {
+		return containsPeer(a.ListPeers(), b.address) && containsPeer(b.ListPeers(), c.address)
+	})
+
+	hashes, err := GenerateRequest(a.id, 1, string(wallet.NewWallet().GetAddress()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []*Node{b, c} {
+		waitFor(t, 5*time.Second, "the block to reach node "+n.id, func() bool {
+			return n.bc.HasBlock(hashes[0]) && n.bc.BestHeight() == 2
+		})
+	}
+}
+
+func TestCancelStopsNodeAndReleasesDB(t *testing.T) {
+	chdirTemp(t)
+	n := newTestNode(t)
+	n.miner = fundedChain(t, n)
+	n.rpcPort = freePort(t)
+	rpcAddr := net.JoinHostPort("localhost", n.rpcPort)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- n.Start(ctx) }()
+	dialWithin(t, n.address, 5*time.Second)
+	dialWithin(t, rpcAddr, 5*time.Second)
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Start returned %v after cancellation, want nil", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("node still running 10s after cancellation")
+	}
+
+	for _, addr := range []string{n.address, rpcAddr} {
+		if conn, err := net.DialTimeout("tcp", addr, time.Second); err == nil {
+			_ = conn.Close()
+			t.Errorf("%s still accepts connections", addr)
+		}
+	}
+	bc, err := core.OpenBlockchainForNode(n.id)
+	if err != nil {
+		t.Fatalf("reopening the chain after shutdown: %v", err)
+	}
+	_ = bc.Close()
+}
+
+func TestShutdownWaitsForPeerMessagesStillBeingSent(t *testing.T) {
+	chdirTemp(t)
+	n := newTestNode(t)
+	fundedChain(t, n)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- n.Start(ctx) }()
+	dialWithin(t, n.address, 5*time.Second)
+
+	// Stands in for a getaddr or version a handler sends on after replying.
+	release := make(chan struct{})
+	n.goInflight(func() { <-release })
+	cancel()
+	select {
+	case err := <-done:
+		t.Fatalf("Start returned %v while a send was in flight", err)
+	case <-time.After(300 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Start returned %v after cancellation, want nil", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("node still running 10s after the send finished")
+	}
+}
+
+func TestNodesSignWithTheirOwnWalletFiles(t *testing.T) {
+	chdirTemp(t)
+	a := newTestNode(t)
+	from := fundedChain(t, a)
+	b := newTestNode(t)
+	copyChain(t, a, b)
+	b.WalletFile = "wallets_" + b.id + ".dat"
+	startNode(t, a)
+	startNode(t, b)
+	to := string(wallet.NewWallet().GetAddress())
+
+	// Both nodes see the coins, but only A's wallet file holds the key to them.
+	if _, err := b.submitTx(from, to, 5, core.TxOptions{}); !errors.Is(err, core.ErrWalletNotFound) {
+		t.Fatalf("B sending from A's address: got %v, want ErrWalletNotFound", err)
+	}
+	if _, err := a.submitTx(from, to, 5, core.TxOptions{}); err != nil {
+		t.Fatalf("A sending from its own address: %v", err)
+	}
+}
@@ -0,0 +1,220 @@
+package network
+
+import (
+	"context"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// sendAttempts is how many times sendData tries to deliver a message to a live peer.
+	sendAttempts = 3
+	// retryBaseDelay is the wait before the first retry; it doubles for each one after.
+	retryBaseDelay = 200 * time.Millisecond
+	// deadAfterFailures consecutive failed deliveries mark a peer dead: messages to it are
+	// dropped without dialing until a probe reaches it again.
+	deadAfterFailures = 5
+	// probeInterval is how often dead peers are probed.
+	probeInterval = 30 * time.Second
+)
+
+// PeerStatus is the delivery health of one peer, as reported by the status command.
+type PeerStatus struct {
+	Address string
+	// Failures counts consecutive failed deliveries since the last success.
+	Failures int
+	Dead     bool
+	// LastSeen is the Unix time of the last successful delivery, or 0 if none.
+	LastSeen int64
+	// Latency is the round-trip time of the last answered ping, or 0 if none.
+	Latency time.Duration
+}
+
+type peerHealthEntry struct {
+	failures  int
+	dead      bool
+	lastSeen  time.Time
+	nextProbe time.Time
+	latency   time.Duration
+}
+
+// peerHealth tracks delivery failures per peer address, acting as a circuit breaker.
+type peerHealth struct {
+	mu    sync.Mutex
+	peers map[string]*peerHealthEntry
+}
+
+func newPeerHealth() *peerHealth {
+	return &peerHealth{peers: make(map[string]*peerHealthEntry)}
+}
+
+// entry returns addr's entry, creating it. The caller holds ph.mu.
+func (ph *peerHealth) entry(addr string) *peerHealthEntry {
+	e, ok := ph.peers[addr]
+	if !ok {
+		e = &peerHealthEntry{}
+		ph.peers[addr] = e
+	}
+	return e
+}
+
+// peerDead reports whether addr is marked dead.
+func (n *Node) peerDead(addr string) bool {
+	n.health.mu.Lock()
+	defer n.health.mu.Unlock()
+
+	e, ok := n.health.peers[addr]
+	return ok && e.dead
+}
+
+func (n *Node) recordSuccess(addr string) {
+	n.health.mu.Lock()
+	defer n.health.mu.Unlock()
+
+	e := n.health.entry(addr)
+	if e.dead {
+		n.logger.Info("peer reachable again", "peer", addr)
+	}
+	e.failures = 0
+	e.dead = false
+	e.lastSeen = time.Now()
+}
+
+// recordLatency records an answered ping with round-trip time rtt.
+func (n *Node) recordLatency(addr string, rtt time.Duration) {
+	n.recordSuccess(addr)
+
+	n.health.mu.Lock()
+	defer n.health.mu.Unlock()
+	n.health.entry(addr).latency = rtt
+}
+
+// markDead marks addr dead at once, e.g. after it failed to answer a ping.
+func (n *Node) markDead(addr string) {
+	n.health.mu.Lock()
+	defer n.health.mu.Unlock()
+
+	e := n.health.entry(addr)
+	e.failures++
+	e.dead = true
+	e.nextProbe = time.Now().Add(probeInterval)
+}
+
+func (n *Node) recordFailure(addr string) {
+	n.health.mu.Lock()
+	defer n.health.mu.Unlock()
+
+	e := n.health.entry(addr)
+	e.failures++
+	if !e.dead && e.failures >= deadAfterFailures {
+		n.logger.Warn("peer marked dead", "peer", addr, "failures", e.failures)
+		e.dead = true
+	}
+	if e.dead {
+		e.nextProbe = time.Now().Add(probeInterval)
+	}
+}
+
+// peerAtHost returns the known peer a connection from remote ("host:port") comes from.
+// Peers are known by their listen address, not the port they dial from, so this is the
+// only known peer on remote's host, or "" when there is none or several share the host,
+// as on a local test network.
+func (n *Node) peerAtHost(remote string) string {
+	host, _, err := net.SplitHostPort(remote)
+	if err != nil {
+		return ""
+	}
+	found := ""
+	for _, peer := range n.ListPeers() {
+		if peerHost, _, err := net.SplitHostPort(peer); err != nil || peerHost != host {
+			continue
+		}
+		if found != "" {
+			return ""
+		}
+		found = peer
+	}
+	return found
+}
+
+// deliver dials addr once and writes msg.
+func deliver(addr string, msg Message) error {
+	conn, err := net.DialTimeout("tcp", addr, 3*time.Second)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+
+	return writeMessage(conn, msg)
+}
+
+// duePeerProbes returns the dead peers whose next probe is due.
+func (n *Node) duePeerProbes(now time.Time) []string {
+	n.health.mu.Lock()
+	defer n.health.mu.Unlock()
+
+	var due []string
+	for addr, e := range n.health.peers {
+		if e.dead && !now.Before(e.nextProbe) {
+			due = append(due, addr)
+		}
+	}
+	return due
+}
+
+// probeLoop periodically probes dead peers until ctx is done.
+func (n *Node) probeLoop(ctx context.Context) {
+	ticker := time.NewTicker(probeInterval / 6)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			n.probeDeadPeers(now)
+		}
+	}
+}
+
+// probeDeadPeers pings each dead peer whose probe is due at now. A peer that answers is
+// marked alive and sent our version, which resumes syncing with it.
+func (n *Node) probeDeadPeers(now time.Time) {
+	for _, addr := range n.duePeerProbes(now) {
+		rtt, err := n.pingPeer(addr)
+		if err != nil {
+			n.recordFailure(addr)
+			continue
+		}
+		n.recordLatency(addr, rtt)
+		n.sendVersion(addr)
+	}
+}
+
+// peerStatuses returns the health of every known peer, sorted by address.
+func (n *Node) peerStatuses() []PeerStatus {
+	peers := n.ListPeers()
+
+	n.health.mu.Lock()
+	defer n.health.mu.Unlock()
+
+	var statuses []PeerStatus
+	for _, addr := range peers {
+		if addr == n.address {
+			continue
+		}
+		s := PeerStatus{Address: addr}
+		if e, ok := n.health.peers[addr]; ok {
+			s.Failures = e.failures
+			s.Dead = e.dead
+			if !e.lastSeen.IsZero() {
+				s.LastSeen = e.lastSeen.Unix()
+			}
+			s.Latency = e.latency
+		}
+		statuses = append(statuses, s)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Address < statuses[j].Address })
+	return statuses
+}
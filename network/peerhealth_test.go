@@ -0,0 +1,119 @@
+package network
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// peerStatus returns n's health record for addr.
+func peerStatus(t *testing.T, n *Node, addr string) PeerStatus {
+	t.Helper()
+	for _, s := range n.peerStatuses() {
+		if s.Address == addr {
+			return s
+		}
+	}
+	t.Fatalf("%s is not a known peer", addr)
+	return PeerStatus{}
+}
+
+// acceptMessage accepts one connection on ln and reads one message from it.
+func acceptMessage(t *testing.T, ln net.Listener) (net.Conn, Message) {
+	t.Helper()
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	msg, err := readMessage(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn, msg
+}
+
+func TestPeerReconnectsWithBackoff(t *testing.T) {
+	chdirTemp(t)
+	n := newTestNode(t)
+	fundedChain(t, n)
+	startNode(t, n)
+	peer := net.JoinHostPort("localhost", freePort(t))
+	if !n.AddPeer(peer) {
+		t.Fatal("peer not added")
+	}
+	msg, err := newMessage("ping", Ping{AddrFrom: n.address})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The peer comes online during the retries of a delivery.
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		n.sendData(peer, msg)
+	}()
+	time.Sleep(retryBaseDelay / 2)
+	ln, err := net.Listen("tcp", peer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, got := acceptMessage(t, ln)
+	_ = conn.Close()
+	<-sent
+	if got.Command != "ping" {
+		t.Fatalf("peer received %q, want the ping", got.Command)
+	}
+	if s := peerStatus(t, n, peer); s.Failures != 0 || s.Dead || s.LastSeen == 0 {
+		t.Fatalf("peer status after a retried delivery: %+v", s)
+	}
+
+	// Offline again, it is marked dead after deadAfterFailures failed deliveries.
+	_ = ln.Close()
+	for range deadAfterFailures - 1 {
+		n.recordFailure(peer)
+	}
+	n.sendData(peer, msg)
+	if s := peerStatus(t, n, peer); !s.Dead || s.Failures != deadAfterFailures {
+		t.Fatalf("peer status after %d failures: %+v", deadAfterFailures, s)
+	}
+
+	// Back online, a dead peer is not dialed until probed.
+	ln, err = net.Listen("tcp", peer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ln.Close() }()
+	n.sendData(peer, msg)
+	_ = ln.(*net.TCPListener).SetDeadline(time.Now().Add(300 * time.Millisecond))
+	if conn, err := ln.Accept(); err == nil {
+		_ = conn.Close()
+		t.Fatal("a dead peer was dialed before being probed")
+	}
+	_ = ln.(*net.TCPListener).SetDeadline(time.Time{})
+
+	// The probe finds it alive, and the node resumes by sending its version.
+	probed := make(chan struct{})
+	go func() {
+		defer close(probed)
+		n.probeDeadPeers(time.Now().Add(probeInterval))
+	}()
+	conn, ping := acceptMessage(t, ln)
+	var p Ping
+	if err := decodePayload(ping.Payload, &p); err != nil {
+		t.Fatal(err)
+	}
+	if err := replyWith(conn, "pong", Pong{Nonce: p.Nonce}); err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+	conn, version := acceptMessage(t, ln)
+	_ = conn.Close()
+	<-probed
+	if version.Command != "version" {
+		t.Fatalf("after the probe the peer received %q, want our version", version.Command)
+	}
+	if s := peerStatus(t, n, peer); s.Dead || s.Failures != 0 || s.Latency == 0 {
+		t.Fatalf("peer status after a successful probe: %+v", s)
+	}
+}
@@ -0,0 +1,206 @@
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultPeers seeds the peer set when no peers file exists yet. The first entry is the bootstrap node.
+var defaultPeers = []string{"localhost:3000", "localhost:3001", "localhost:3002"}
+
+// peerSet is the mutable, de-duplicated list of known peer addresses, persisted to a JSON file.
+type peerSet struct {
+	mu    sync.Mutex
+	addrs []string
+	file  string
+	// evicted counts the peers dropped to make room under the node's MaxPeers.
+	evicted int
+}
+
+func newPeerSet() *peerSet {
+	return &peerSet{addrs: append([]string(nil), defaultPeers...)}
+}
+
+// PeersFile returns the default peers file for a node.
+func PeersFile(nodeID string) string {
+	return fmt.Sprintf("peers_%s.json", nodeID)
+}
+
+// load points the peer set at path and loads it. A missing file keeps the default peers
+// and creates the file on the next change.
+func (ps *peerSet) load(path string) error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	ps.file = path
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var addrs []string
+	if err := json.Unmarshal(content, &addrs); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	ps.addrs = nil
+	for _, addr := range addrs {
+		if err := ValidatePeerAddr(addr); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if !containsPeer(ps.addrs, addr) {
+			ps.addrs = append(ps.addrs, addr)
+		}
+	}
+	return nil
+}
+
+// AddPeer adds addr to the node's peer set and saves it. It reports whether addr was added.
+// When the set already holds MaxPeers peers, the least recently successful evictable one
+// (see evictionCandidate) is dropped to make room; if there is none, addr is not added.
+func (n *Node) AddPeer(addr string) bool {
+	ps := n.peers
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if ValidatePeerAddr(addr) != nil || addr == n.address || containsPeer(ps.addrs, addr) {
+		return false
+	}
+	if n.MaxPeers > 0 && n.countPeers(ps.addrs) >= n.MaxPeers {
+		victim := n.evictionCandidate(ps.addrs)
+		if victim < 0 {
+			return false
+		}
+		n.logger.Debug("evicting peer", "peer", ps.addrs[victim], "for", addr)
+		ps.addrs = append(ps.addrs[:victim], ps.addrs[victim+1:]...)
+		ps.evicted++
+	}
+	ps.addrs = append(ps.addrs, addr)
+	ps.save()
+	return true
+}
+
+// countPeers returns how many of addrs are not the node itself.
+func (n *Node) countPeers(addrs []string) int {
+	count := 0
+	for _, addr := range addrs {
+		if addr != n.address {
+			count++
+		}
+	}
+	return count
+}
+
+// evictionCandidate returns the index in addrs of the peer to drop for a new one, or -1 if
+// every peer is protected. The bootstrap node (the first peer) and the default peers are
+// never evicted. Of the others, the one whose last successful delivery is oldest goes
+// first, a peer never reached before any that was, and the longest known on a tie.
+// Callers hold n.peers.mu.
+func (n *Node) evictionCandidate(addrs []string) int {
+	n.health.mu.Lock()
+	defer n.health.mu.Unlock()
+
+	victim := -1
+	var victimSeen time.Time
+	for i, addr := range addrs {
+		if i == 0 || addr == n.address || containsPeer(defaultPeers, addr) {
+			continue
+		}
+		var seen time.Time
+		if e, ok := n.health.peers[addr]; ok {
+			seen = e.lastSeen
+		}
+		if victim < 0 || seen.Before(victimSeen) {
+			victim, victimSeen = i, seen
+		}
+	}
+	return victim
+}
+
+// peersEvicted returns how many peers were evicted to respect MaxPeers since the node started.
+func (n *Node) peersEvicted() int {
+	n.peers.mu.Lock()
+	defer n.peers.mu.Unlock()
+
+	return n.peers.evicted
+}
+
+// RemovePeer drops addr from the node's peer set and saves it.
+func (n *Node) RemovePeer(addr string) {
+	ps := n.peers
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	for i, a := range ps.addrs {
+		if a == addr {
+			ps.addrs = append(ps.addrs[:i], ps.addrs[i+1:]...)
+			ps.save()
+			return
+		}
+	}
+}
+
+// ListPeers returns a copy of the node's known peer addresses, bootstrap node first.
+func (n *Node) ListPeers() []string {
+	n.peers.mu.Lock()
+	defer n.peers.mu.Unlock()
+
+	return append([]string(nil), n.peers.addrs...)
+}
+
+// bootstrapNode is the first known peer; non-bootstrap nodes announce themselves to it.
+func (n *Node) bootstrapNode() string {
+	peers := n.ListPeers()
+	if len(peers) == 0 {
+		return ""
+	}
+	return peers[0]
+}
+
+// save writes the peer set to its file. Callers must hold ps.mu.
+// Failures are not fatal: the in-memory set stays authoritative for this run.
+func (ps *peerSet) save() {
+	if ps.file == "" {
+		return
+	}
+	content, err := json.MarshalIndent(ps.addrs, "", "  ")
+	if err != nil {
+		return
+	}
+	_ = os.WriteFile(ps.file, content, 0o600)
+}
+
+// ValidatePeerAddr checks that addr is a host:port another machine can dial: a host name
+// or IP that is not unspecified (0.0.0.0 or ::), and a port from 1 to 65535.
+func ValidatePeerAddr(addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid peer address %q: %w", addr, err)
+	}
+	if host == "" {
+		return fmt.Errorf("invalid peer address %q: missing host", addr)
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsUnspecified() {
+		return fmt.Errorf("invalid peer address %q: %s is not an address peers can dial", addr, host)
+	}
+	if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+		return fmt.Errorf("invalid peer address %q: port must be from 1 to 65535", addr)
+	}
+	return nil
+}
+
+func containsPeer(addrs []string, addr string) bool {
+	for _, a := range addrs {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
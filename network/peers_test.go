@@ -0,0 +1,188 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestPeerLearnedThroughGossip(t *testing.T) {
+	chdirTemp(t)
+	a := newTestNode(t)
+	startNode(t, a)
+	b := newTestNode(t, a.address)
+	startNode(t, b)
+	c := newTestNode(t, b.address)
+	startNode(t, c)
+
+	waitFor(t, 5*time.Second, "C to learn about A from B", func() bool {
+		return containsPeer(c.ListPeers(), a.address)
+	})
+}
+
+func TestPeersPersistAcrossRestartWithoutDuplicates(t *testing.T) {
+	chdirTemp(t)
+	a := newTestNode(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- a.Start(ctx) }()
+	select {
+	case <-a.listening:
+	case err := <-done:
+		t.Fatalf("node stopped before listening: %v", err)
+	}
+	defer cancel()
+	b := newTestNode(t, a.address)
+	startNode(t, b)
+
+	waitFor(t, 5*time.Second, "A to learn about B from its version", func() bool {
+		return containsPeer(a.ListPeers(), b.address)
+	})
+	seed := "seed.example.com:3000"
+	if !a.AddPeer(seed) {
+		t.Fatalf("%s not added", seed)
+	}
+	for _, addr := range []string{seed, b.address} {
+		if a.AddPeer(addr) {
+			t.Errorf("%s added a second time", addr)
+		}
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := NewNode(a.id, "", PeersFile(a.id), "")
+	restarted.Chain = a.Chain
+	startNode(t, restarted)
+	peers := restarted.ListPeers()
+	for _, addr := range []string{seed, b.address} {
+		count := 0
+		for _, peer := range peers {
+			if peer == addr {
+				count++
+			}
+		}
+		if count != 1 {
+			t.Errorf("%s listed %d times after the restart, want once in %v", addr, count, peers)
+		}
+	}
+}
+
+func TestPeerSetStaysUnderCapAndKeepsBootstrap(t *testing.T) {
+	chdirTemp(t)
+	bootstrap := newTestNode(t)
+	startNode(t, bootstrap)
+	n := newTestNode(t, bootstrap.address)
+	n.MaxPeers = 3
+	startNode(t, n)
+
+	var added []string
+	for i := range 10 {
+		addr := net.JoinHostPort("peer"+strconv.Itoa(i)+".example.com", "3000")
+		if !n.AddPeer(addr) {
+			t.Fatalf("%s not added", addr)
+		}
+		added = append(added, addr)
+		if got := len(n.ListPeers()); got > n.MaxPeers {
+			t.Fatalf("%d peers after adding %s, over the cap of %d", got, addr, n.MaxPeers)
+		}
+	}
+	peers := n.ListPeers()
+	if len(peers) != n.MaxPeers || !containsPeer(peers, bootstrap.address) || !containsPeer(peers, added[len(added)-1]) {
+		t.Fatalf("peers %v, want %d including the bootstrap node and the last added", peers, n.MaxPeers)
+	}
+
+	// The bootstrap node and the first added peer filled the cap; each later peer evicted one.
+	status, err := GetStatusRequest(n.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Peers != n.MaxPeers || status.MaxPeers != n.MaxPeers || status.PeersEvicted != 8 {
+		t.Fatalf("status reports %d of %d peers and %d evicted, want %d of %d and 8", status.Peers, status.MaxPeers, status.PeersEvicted, n.MaxPeers, n.MaxPeers)
+	}
+}
+
+func TestValidatePeerAddr(t *testing.T) {
+	for _, addr := range []string{"seed.example.com:3000", "node-2.lan:8333", "localhost:3000", "10.0.0.5:3000", "[::1]:3000"} {
+		if err := ValidatePeerAddr(addr); err != nil {
+			t.Errorf("%s rejected: %v", addr, err)
+		}
+	}
+	for _, addr := range []string{"seed.example.com", ":3000", "0.0.0.0:3000", "[::]:3000", "seed.example.com:0", "seed.example.com:70000", "seed.example.com:http"} {
+		if err := ValidatePeerAddr(addr); err == nil {
+			t.Errorf("%s accepted", addr)
+		}
+	}
+}
+
+func TestPeersFileKeepsHostNames(t *testing.T) {
+	dir := t.TempDir()
+	path := dir + "/peers.json"
+	want := []string{"seed.example.com:3000", "10.0.0.5:3001"}
+	content, err := json.Marshal(append(want, "seed.example.com:3000"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ps := newPeerSet()
+	if err := ps.load(path); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(ps.addrs, want) {
+		t.Fatalf("loaded peers %v, want %v", ps.addrs, want)
+	}
+
+	if err := os.WriteFile(path, []byte(`["seed.example.com"]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := newPeerSet().load(path); err == nil {
+		t.Fatal("loaded a peer without a port")
+	}
+}
+
+func TestNodeBindsToNonLoopbackAddress(t *testing.T) {
+	var ip net.IP
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range addrs {
+		if ipNet, ok := a.(*net.IPNet); ok && ipNet.IP.To4() != nil && !ipNet.IP.IsLoopback() {
+			ip = ipNet.IP
+			break
+		}
+	}
+	if ip == nil {
+		t.Skip("no non-loopback IPv4 interface")
+	}
+
+	chdirTemp(t)
+	n := newTestNode(t)
+	listen := net.JoinHostPort(ip.String(), n.id)
+	n.ListenAddr = listen
+	fundedChain(t, n)
+	if err := n.Start(context.Background()); !errors.Is(err, ErrListenUnauthenticated) {
+		t.Fatalf("started on %s without auth: got %v, want ErrListenUnauthenticated", listen, err)
+	}
+	n = newTestNode(t)
+	listen = net.JoinHostPort(ip.String(), n.id)
+	n.ListenAddr = listen
+	n.RPCToken = "secret"
+	n.TCPAuth = true
+	fundedChain(t, n)
+	startNode(t, n)
+
+	if conn, err := net.DialTimeout("tcp", net.JoinHostPort("127.0.0.1", n.id), time.Second); err == nil {
+		_ = conn.Close()
+		t.Fatalf("node bound to %s also accepts connections on loopback", listen)
+	}
+}
@@ -0,0 +1,108 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// pingInterval is how often the node pings each live peer.
+	pingInterval = 20 * time.Second
+	// pingTimeout is how long a peer has to answer a ping before it is marked dead.
+	pingTimeout = 5 * time.Second
+)
+
+// Ping asks a peer to echo Nonce back in a Pong, to check it is alive and measure latency.
+type Ping struct {
+	AddrFrom string
+	Nonce    uint64
+}
+
+type Pong struct {
+	Nonce uint64
+}
+
+// pingPeer pings addr and returns the round-trip time.
+func (n *Node) pingPeer(addr string) (time.Duration, error) {
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", addr, pingTimeout)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(start.Add(pingTimeout))
+
+	nonce := rand.Uint64()
+	msg, err := newMessage("ping", Ping{AddrFrom: n.address, Nonce: nonce})
+	if err != nil {
+		return 0, err
+	}
+	if err := writeMessage(conn, msg); err != nil {
+		return 0, err
+	}
+	reply, err := readMessage(conn)
+	if err != nil {
+		return 0, err
+	}
+	if reply.Command != "pong" {
+		return 0, fmt.Errorf("unexpected reply: %s", reply.Command)
+	}
+	var pong Pong
+	if err := decodePayload(reply.Payload, &pong); err != nil {
+		return 0, err
+	}
+	if pong.Nonce != nonce {
+		return 0, fmt.Errorf("pong nonce %d does not match ping nonce %d", pong.Nonce, nonce)
+	}
+	return time.Since(start), nil
+}
+
+func handlePing(conn net.Conn, payloadBytes []byte) error {
+	var payload Ping
+	if err := decodePayload(payloadBytes, &payload); err != nil {
+		return err
+	}
+
+	return replyWith(conn, "pong", Pong{Nonce: payload.Nonce})
+}
+
+// pingLoop pings every live peer each pingInterval until ctx is done. Peers that do not
+// answer within pingTimeout are marked dead and left to probeLoop.
+func (n *Node) pingLoop(ctx context.Context) {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n.pingPeers()
+		}
+	}
+}
+
+// pingPeers pings the live peers concurrently and records the results.
+func (n *Node) pingPeers() {
+	var wg sync.WaitGroup
+	for _, addr := range n.ListPeers() {
+		if addr == n.address || n.peerDead(addr) {
+			continue
+		}
+		wg.Add(1)
+		go func(addr string) {
+			defer wg.Done()
+			rtt, err := n.pingPeer(addr)
+			if err != nil {
+				n.logger.Debug("peer did not answer ping", "peer", addr, "err", err)
+				n.markDead(addr)
+				return
+			}
+			n.recordLatency(addr, rtt)
+		}(addr)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,42 @@
+package network
+
+import (
+	"net"
+	"testing"
+)
+
+func TestStalledPeerIsDropped(t *testing.T) {
+	chdirTemp(t)
+	live := newTestNode(t)
+	fundedChain(t, live)
+	startNode(t, live)
+
+	// The stalled peer accepts connections but never answers them.
+	stalledAddr := net.JoinHostPort("localhost", freePort(t))
+	stalled, err := net.Listen("tcp", stalledAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = stalled.Close() }()
+	go func() {
+		for {
+			conn, err := stalled.Accept()
+			if err != nil {
+				return
+			}
+			defer func() { _ = conn.Close() }()
+		}
+	}()
+
+	n := newTestNode(t, live.address, stalledAddr)
+	fundedChain(t, n)
+	startNode(t, n)
+	n.pingPeers()
+
+	if s := peerStatus(t, n, stalledAddr); !s.Dead {
+		t.Fatalf("stalled peer status: %+v, want dead", s)
+	}
+	if s := peerStatus(t, n, live.address); s.Dead || s.Latency <= 0 {
+		t.Fatalf("live peer status: %+v, want alive with a latency", s)
+	}
+}
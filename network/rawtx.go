@@ -0,0 +1,120 @@
+package network
+
+import (
+	"fmt"
+	"net"
+
+	"my-blockchain/core"
+	"my-blockchain/wallet"
+)
+
+// RawTxRequest asks the node for a transaction by ID, mined or pending.
+type RawTxRequest struct {
+	AddrFrom string
+	TxID     []byte
+}
+
+type RawTxInput struct {
+	Txid      []byte
+	Vout      int
+	Signature []byte
+	PubKey    []byte
+}
+
+type RawTxOutput struct {
+	Value int
+	// Address is empty for a data output, which carries Data instead.
+	Address string
+	Data    []byte
+}
+
+// RawTx is a transaction in both serialized and decoded form.
+type RawTx struct {
+	ID          []byte
+	Raw         []byte
+	Coinbase    bool
+	LockTime    int
+	Replaceable bool
+	// BlockHash is empty and Confirmations 0 while the transaction is still in the mempool.
+	BlockHash     []byte
+	Confirmations int
+	Inputs        []RawTxInput
+	Outputs       []RawTxOutput
+}
+
+type RawTxResponse struct {
+	OK      bool
+	Message string
+	Tx      RawTx
+}
+
+// NewRawTx describes tx, which was mined in the block blockHash (nil if pending).
+func NewRawTx(tx *core.Transaction, blockHash []byte) RawTx {
+	raw := RawTx{
+		ID:          tx.ID,
+		Raw:         tx.Serialize(),
+		Coinbase:    tx.IsCoinbase(),
+		LockTime:    tx.LockTime,
+		Replaceable: tx.Replaceable,
+		BlockHash:   blockHash,
+	}
+	for _, in := range tx.Vin {
+		raw.Inputs = append(raw.Inputs, RawTxInput{Txid: in.Txid, Vout: in.Vout, Signature: in.Signature, PubKey: in.PubKey})
+	}
+	for _, out := range tx.Vout {
+		if out.IsData() {
+			raw.Outputs = append(raw.Outputs, RawTxOutput{Data: out.Data})
+			continue
+		}
+		address := wallet.AddressFromPubKeyHash(out.PubKeyHash)
+		if out.ScriptType == core.ScriptMultisig {
+			address = wallet.AddressFromScriptHash(out.PubKeyHash)
+		}
+		raw.Outputs = append(raw.Outputs, RawTxOutput{Value: out.Value, Address: address})
+	}
+	return raw
+}
+
+// GetRawTxRequest asks the running node at nodeAddr(nodeID) for a transaction.
+func GetRawTxRequest(nodeID string, txID []byte) (RawTx, error) {
+	addr := nodeAddr(nodeID)
+	payload := RawTxRequest{AddrFrom: addr, TxID: txID}
+	var res RawTxResponse
+	if err := request(addr, "getrawtx", payload, "rawtx", &res); err != nil {
+		return RawTx{}, err
+	}
+	if !res.OK {
+		return RawTx{}, &RemoteError{Message: res.Message}
+	}
+	return res.Tx, nil
+}
+
+func (n *Node) handleGetRawTx(conn net.Conn, payloadBytes []byte) error {
+	var payload RawTxRequest
+	if err := decodePayload(payloadBytes, &payload); err != nil {
+		return err
+	}
+
+	res := RawTxResponse{OK: true}
+	tx, err := n.lookupTx(payload.TxID)
+	if err != nil {
+		res = RawTxResponse{OK: false, Message: err.Error()}
+	}
+	res.Tx = tx
+	return replyWith(conn, "rawtx", res)
+}
+
+// lookupTx finds a transaction in the mempool or on the main chain.
+func (n *Node) lookupTx(txID []byte) (RawTx, error) {
+	bc := n.bc
+	if tx, ok := n.mempool.Get(txID); ok {
+		return NewRawTx(tx, nil), nil
+	}
+	tx, blockHash, height, err := bc.FindTransactionLocation(txID)
+	if err != nil {
+		return RawTx{}, fmt.Errorf("transaction %x: %w", txID, err)
+	}
+	raw := NewRawTx(&tx, blockHash)
+	raw.Confirmations = bc.BestHeight() - height + 1
+	return raw, nil
+}
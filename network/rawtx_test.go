@@ -0,0 +1,137 @@
+package network
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"my-blockchain/core"
+	"my-blockchain/wallet"
+)
+
+func TestGetRawTxForCoinbaseAndSpend(t *testing.T) {
+	chdirTemp(t)
+	n := newTestNode(t)
+	from := fundedChain(t, n)
+	startNode(t, n)
+
+	genesisHash, err := GetBlockHashRequest(n.id, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	genesis, err := GetBlockRequest(n.id, genesisHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coinbaseID := genesis.Txs[0].ID
+
+	to := string(wallet.NewWallet().GetAddress())
+	if _, err := SendTxRequest(n.id, from, to, 4, core.TxOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	spendID := n.mempool.Pending()[0].ID
+	pending, err := GetRawTxRequest(n.id, spendID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending.BlockHash != nil || pending.Confirmations != 0 {
+		t.Errorf("pending transaction reported in block %x with %d confirmations", pending.BlockHash, pending.Confirmations)
+	}
+
+	hashes, err := GenerateRequest(n.id, 1, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	coinbase, err := GetRawTxRequest(n.id, coinbaseID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !coinbase.Coinbase || !bytes.Equal(coinbase.BlockHash, genesisHash) || coinbase.Confirmations != 2 {
+		t.Errorf("coinbase: Coinbase %v, block %x, %d confirmations; want true, %x, 2",
+			coinbase.Coinbase, coinbase.BlockHash, coinbase.Confirmations, genesisHash)
+	}
+	if len(coinbase.Outputs) != 1 || coinbase.Outputs[0].Address != from {
+		t.Errorf("coinbase outputs %+v, want one paying %s", coinbase.Outputs, from)
+	}
+
+	spend, err := GetRawTxRequest(n.id, spendID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spend.Coinbase || !bytes.Equal(spend.BlockHash, hashes[0]) || spend.Confirmations != 1 {
+		t.Errorf("spend: Coinbase %v, block %x, %d confirmations; want false, %x, 1",
+			spend.Coinbase, spend.BlockHash, spend.Confirmations, hashes[0])
+	}
+	if len(spend.Inputs) != 1 || !bytes.Equal(spend.Inputs[0].Txid, coinbaseID) {
+		t.Errorf("spend inputs %+v, want the genesis coinbase", spend.Inputs)
+	}
+	if len(spend.Outputs) == 0 || spend.Outputs[0].Address != to || spend.Outputs[0].Value != 4 {
+		t.Errorf("spend outputs %+v, want 4 to %s first", spend.Outputs, to)
+	}
+	decoded, err := core.DecodeTransaction(spend.Raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decoded.Hash(), spendID) {
+		t.Errorf("raw transaction hashes to %x, want %x", decoded.Hash(), spendID)
+	}
+
+	var remote *RemoteError
+	if _, err := GetRawTxRequest(n.id, []byte("no such transaction")); !errors.As(err, &remote) {
+		t.Fatalf("unknown transaction: got %v, want a RemoteError", err)
+	}
+}
+
+func TestDataOutputStoredButNeverSpendable(t *testing.T) {
+	chdirTemp(t)
+	n := newTestNode(t)
+	from := fundedChain(t, n)
+	startNode(t, n)
+
+	to := string(wallet.NewWallet().GetAddress())
+	if _, err := SendTxRequest(n.id, from, to, 4, core.TxOptions{Data: []byte("anchor")}); err != nil {
+		t.Fatal(err)
+	}
+	txID := n.mempool.Pending()[0].ID
+	if _, err := GenerateRequest(n.id, 1, to); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := GetRawTxRequest(n.id, txID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataVout := -1
+	for i, out := range raw.Outputs {
+		if bytes.Equal(out.Data, []byte("anchor")) {
+			dataVout = i
+		}
+	}
+	if dataVout < 0 || raw.Confirmations != 1 {
+		t.Fatalf("mined transaction has outputs %+v and %d confirmations, want a data output and 1", raw.Outputs, raw.Confirmations)
+	}
+	if raw.Outputs[dataVout].Value != 0 || raw.Outputs[dataVout].Address != "" {
+		t.Errorf("data output %+v pays someone", raw.Outputs[dataVout])
+	}
+
+	if (core.UTXOSet{Blockchain: n.bc}).IsUnspent(txID, dataVout) {
+		t.Error("data output is in the UTXO set")
+	}
+	if _, ok := n.bc.FindAllUTXO()[hex.EncodeToString(txID)].Outputs[dataVout]; ok {
+		t.Error("data output found by a full scan of unspent outputs")
+	}
+	// Genesis and the generated block minted 10 each; the fee went to the miner.
+	total := 0
+	for _, address := range []string{from, to} {
+		balance, err := GetBalanceRequest(n.id, address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		total += balance
+	}
+	if total != 20 {
+		t.Fatalf("balances add up to %d, want the 20 minted", total)
+	}
+}
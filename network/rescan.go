@@ -0,0 +1,60 @@
+package network
+
+import (
+	"net"
+
+	"my-blockchain/core"
+	"my-blockchain/wallet"
+)
+
+// RescanRequest asks the node to walk its main chain from Start to Stop (the tip when 0)
+// for transactions of the addresses in its wallet file.
+type RescanRequest struct {
+	AddrFrom string
+	Start    int
+	Stop     int
+}
+
+// RescanResponse reports the blocks scanned and the transactions found.
+type RescanResponse struct {
+	OK      bool
+	Message string
+	Code    string
+	Result  core.RescanResult
+	// Addresses is how many wallet addresses were looked for.
+	Addresses int
+}
+
+// RescanBlockchainRequest asks the running node at nodeAddr(nodeID) to rescan its chain
+// for its wallet's addresses and returns the result and the number of addresses.
+func RescanBlockchainRequest(nodeID string, start, stop int) (core.RescanResult, int, error) {
+	addr := nodeAddr(nodeID)
+	payload := RescanRequest{AddrFrom: addr, Start: start, Stop: stop}
+	var res RescanResponse
+	if err := request(addr, "rescan", payload, "rescanresult", &res); err != nil {
+		return core.RescanResult{}, 0, err
+	}
+	if !res.OK {
+		return core.RescanResult{}, 0, &RemoteError{Message: res.Message, Code: res.Code}
+	}
+	return res.Result, res.Addresses, nil
+}
+
+func (n *Node) handleRescan(conn net.Conn, payloadBytes []byte) error {
+	var payload RescanRequest
+	if err := decodePayload(payloadBytes, &payload); err != nil {
+		return err
+	}
+
+	res := RescanResponse{OK: true}
+	ws, err := wallet.NewWalletsAt(n.WalletFile)
+	if err == nil {
+		pubKeyHashes := ws.PubKeyHashes()
+		res.Addresses = len(pubKeyHashes)
+		res.Result, err = n.bc.Rescan(pubKeyHashes, payload.Start, payload.Stop)
+	}
+	if err != nil {
+		res = RescanResponse{OK: false, Message: err.Error(), Code: errorCode(err)}
+	}
+	return replyWith(conn, "rescanresult", res)
+}
@@ -0,0 +1,70 @@
+package network
+
+import (
+	"errors"
+	"testing"
+
+	"my-blockchain/core"
+	"my-blockchain/wallet"
+)
+
+func TestRescanFindsImportedKeyFunds(t *testing.T) {
+	chdirTemp(t)
+	n := newTestNode(t)
+	fundedChain(t, n)
+	other, err := wallet.NewWalletsAt("other_wallets.dat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, err := other.CreateWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wif, err := other.ExportWIF(imported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Block 2 pays the key before the node's wallet holds it.
+	bc, err := core.OpenBlockchainForNode(n.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bc.AddBlock([]*core.Transaction{bc.Config().CoinbaseTx(imported, "", 2)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	startNode(t, n)
+
+	res, addresses, err := RescanBlockchainRequest(n.id, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addresses != 1 || res.StartHeight != 1 || res.StopHeight != 2 || res.Transactions != 1 {
+		t.Fatalf("rescan before the import: %d addresses, %+v; want 1 address and the genesis coinbase in blocks 1 to 2", addresses, res)
+	}
+
+	ws, err := wallet.NewWalletsAt(n.WalletFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ws.ImportWIF(wif); err != nil {
+		t.Fatal(err)
+	}
+	res, addresses, err = RescanBlockchainRequest(n.id, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addresses != 2 || res.StartHeight != 1 || res.StopHeight != 2 || res.Transactions != 2 {
+		t.Fatalf("rescan after the import: %d addresses, %+v; want 2 addresses and both coinbases in blocks 1 to 2", addresses, res)
+	}
+	// The chainstate covers every address, so the balance needs no rescan to show.
+	if got, err := GetBalanceRequest(n.id, imported); err != nil || got != 10 {
+		t.Fatalf("balance of the imported key: got %d, %v, want 10", got, err)
+	}
+
+	if _, _, err := RescanBlockchainRequest(n.id, 2, 3); !errors.Is(err, core.ErrHeightOutOfRange) {
+		t.Fatalf("rescan past the tip: got %v, want ErrHeightOutOfRange", err)
+	}
+}
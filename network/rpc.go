@@ -0,0 +1,324 @@
+package network
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"my-blockchain/core"
+	"my-blockchain/wallet"
+)
+
+// maxRPCBody bounds POST bodies; a transaction request is a few hundred bytes.
+const maxRPCBody = 1 << 16
+
+// JSON views of the RPC responses. Hashes and IDs are hex strings.
+type (
+	rpcError struct {
+		Error string `json:"error"`
+	}
+
+	rpcBalance struct {
+		Address string `json:"address"`
+		Balance int    `json:"balance"`
+	}
+
+	rpcBlock struct {
+		Index      int      `json:"index"`
+		Hash       string   `json:"hash"`
+		PrevHash   string   `json:"prevHash"`
+		Timestamp  int64    `json:"timestamp"`
+		Nonce      int      `json:"nonce"`
+		MerkleRoot string   `json:"merkleRoot"`
+		Bits       int      `json:"bits"`
+		TxIDs      []string `json:"txids"`
+		// Confirmations counts this block and every block built on it.
+		Confirmations int  `json:"confirmations"`
+		Pruned        bool `json:"pruned,omitempty"`
+	}
+
+	rpcChain struct {
+		Height int        `json:"height"`
+		Blocks []rpcBlock `json:"blocks"`
+	}
+
+	rpcTxInput struct {
+		TxID      string `json:"txid"`
+		Vout      int    `json:"vout"`
+		Signature string `json:"signature"`
+		PubKey    string `json:"pubKey"`
+	}
+
+	rpcTxOutput struct {
+		Value   int    `json:"value"`
+		Address string `json:"address,omitempty"`
+		Data    string `json:"data,omitempty"`
+	}
+
+	rpcTx struct {
+		ID            string        `json:"id"`
+		Coinbase      bool          `json:"coinbase"`
+		LockTime      int           `json:"locktime,omitempty"`
+		Replaceable   bool          `json:"replaceable,omitempty"`
+		BlockHash     string        `json:"blockHash,omitempty"`
+		Confirmations int           `json:"confirmations"`
+		Inputs        []rpcTxInput  `json:"inputs"`
+		Outputs       []rpcTxOutput `json:"outputs"`
+		Raw           string        `json:"raw"`
+	}
+
+	rpcSendRequest struct {
+		From        string `json:"from"`
+		To          string `json:"to"`
+		Amount      int    `json:"amount"`
+		Fee         int    `json:"fee"`
+		LockTime    int    `json:"locktime"`
+		Replaceable bool   `json:"replaceable"`
+		Data        string `json:"data"`
+		Coins       string `json:"coinselection"`
+	}
+
+	rpcSendResponse struct {
+		TxID string `json:"txid"`
+	}
+
+	rpcFundingInput struct {
+		TxID        string `json:"txid"`
+		Vout        int    `json:"vout"`
+		Value       int    `json:"value"`
+		Unconfirmed bool   `json:"unconfirmed,omitempty"`
+	}
+
+	rpcFunding struct {
+		Inputs     []rpcFundingInput `json:"inputs"`
+		InputValue int               `json:"inputValue"`
+		Amount     int               `json:"amount"`
+		Change     int               `json:"change"`
+		Fee        int               `json:"fee"`
+	}
+
+	rpcMempoolTx struct {
+		TxID string `json:"txid"`
+		Fee  int    `json:"fee"`
+		Size int    `json:"size"`
+	}
+
+	rpcMempool struct {
+		Count int            `json:"count"`
+		Bytes int            `json:"bytes"`
+		Fees  int            `json:"fees"`
+		Txs   []rpcMempoolTx `json:"txs"`
+	}
+)
+
+// startRPCServer serves the JSON API on addr in the background, sharing the node's chain.
+// The caller shuts the returned server down before closing the chain.
+func (n *Node) startRPCServer(addr string) *http.Server {
+	srv := &http.Server{Addr: addr, Handler: n.RPCHandler()}
+	go func() {
+		n.logger.Info("JSON RPC listening", "addr", addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			n.logger.Error("JSON RPC server stopped", "err", err)
+		}
+	}()
+	return srv
+}
+
+// RPCHandler returns the node's HTTP/JSON API, requiring the node's RPCToken on every
+// request when it is set. The node must be running:
+//
+//	GET  /balance/{address}
+//	GET  /chain
+//	POST /tx       {"from": ..., "to": ..., "amount": N, "fee": N}
+//	POST /testsend same body as POST /tx; reports the inputs, change and fee without sending
+//	GET  /tx/{id}
+//	GET  /mempool
+//	GET  /ws       WebSocket; send {"subscribe": ["block", "tx"]} to receive events
+//	GET  /metrics  Prometheus text format
+func (n *Node) RPCHandler() http.Handler {
+	bc := n.bc
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /balance/{address}", func(w http.ResponseWriter, r *http.Request) {
+		address := r.PathValue("address")
+		if !wallet.ValidateAddress(address) {
+			writeJSONError(w, http.StatusBadRequest, "invalid address")
+			return
+		}
+		writeJSON(w, http.StatusOK, BalanceJSON(address, balanceOf(bc, address)))
+	})
+
+	mux.HandleFunc("GET /chain", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, ChainJSON(ChainSnapshot(bc)))
+	})
+
+	mux.HandleFunc("POST /tx", func(w http.ResponseWriter, r *http.Request) {
+		req, ok := decodeSendRequest(w, r)
+		if !ok {
+			return
+		}
+		tx, err := n.submitTx(req.From, req.To, req.Amount, req.options())
+		if err != nil {
+			writeSendError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, rpcSendResponse{TxID: hex.EncodeToString(tx.ID)})
+	})
+
+	mux.HandleFunc("POST /testsend", func(w http.ResponseWriter, r *http.Request) {
+		req, ok := decodeSendRequest(w, r)
+		if !ok {
+			return
+		}
+		funding, err := n.testSend(req.From, map[string]int{req.To: req.Amount}, req.options())
+		if err != nil {
+			writeSendError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, newRPCFunding(funding))
+	})
+
+	mux.HandleFunc("GET /tx/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := hex.DecodeString(r.PathValue("id"))
+		if err != nil || len(id) == 0 {
+			writeJSONError(w, http.StatusBadRequest, "invalid transaction ID")
+			return
+		}
+		raw, err := n.lookupTx(id)
+		if errors.Is(err, core.ErrTransactionNotFound) {
+			writeJSONError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, newRPCTx(raw))
+	})
+
+	mux.HandleFunc("GET /mempool", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, newRPCMempool(n.mempoolInfo()))
+	})
+
+	mux.HandleFunc("GET /ws", handleWebSocket(n.events))
+
+	mux.HandleFunc("GET /metrics", n.handleMetrics)
+
+	if n.RPCToken != "" {
+		return n.requireToken(mux)
+	}
+	return mux
+}
+
+// decodeSendRequest reads the body of POST /tx or /testsend, answering 400 when it is invalid.
+func decodeSendRequest(w http.ResponseWriter, r *http.Request) (rpcSendRequest, bool) {
+	var req rpcSendRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRPCBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return req, false
+	}
+	return req, true
+}
+
+// options returns the transaction options req carries.
+func (req rpcSendRequest) options() core.TxOptions {
+	return core.TxOptions{Fee: req.Fee, LockTime: req.LockTime, Replaceable: req.Replaceable, Data: []byte(req.Data), CoinSelection: core.CoinSelection(req.Coins)}
+}
+
+// writeSendError answers a send that failed with err.
+func writeSendError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrBadRequest), errors.Is(err, ErrInvalidAddress):
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, core.ErrWalletNotFound), errors.Is(err, wallet.ErrWatchOnly), errors.Is(err, wallet.ErrWalletLocked):
+		writeJSONError(w, http.StatusForbidden, err.Error())
+	default:
+		writeJSONError(w, http.StatusUnprocessableEntity, err.Error())
+	}
+}
+
+// ChainJSON returns the JSON view of blocks that GET /chain serves.
+func ChainJSON(blocks []ChainBlock) any {
+	chain := rpcChain{Height: len(blocks), Blocks: make([]rpcBlock, 0, len(blocks))}
+	for _, b := range blocks {
+		txids := make([]string, 0, len(b.TxIDs))
+		for _, id := range b.TxIDs {
+			txids = append(txids, hex.EncodeToString(id))
+		}
+		chain.Blocks = append(chain.Blocks, rpcBlock{
+			Index:         b.Index,
+			Hash:          hex.EncodeToString(b.Hash),
+			PrevHash:      hex.EncodeToString(b.PrevHash),
+			Timestamp:     b.Timestamp,
+			Nonce:         b.Nonce,
+			MerkleRoot:    hex.EncodeToString(b.Merkle),
+			Bits:          b.Bits,
+			TxIDs:         txids,
+			Confirmations: b.Confirmations,
+			Pruned:        b.Pruned,
+		})
+	}
+	return chain
+}
+
+// BalanceJSON returns the JSON view of an address balance that GET /balance serves.
+func BalanceJSON(address string, balance int) any {
+	return rpcBalance{Address: address, Balance: balance}
+}
+
+func newRPCTx(raw RawTx) rpcTx {
+	tx := rpcTx{
+		ID:            hex.EncodeToString(raw.ID),
+		Coinbase:      raw.Coinbase,
+		LockTime:      raw.LockTime,
+		Replaceable:   raw.Replaceable,
+		BlockHash:     hex.EncodeToString(raw.BlockHash),
+		Confirmations: raw.Confirmations,
+		Inputs:        make([]rpcTxInput, 0, len(raw.Inputs)),
+		Outputs:       make([]rpcTxOutput, 0, len(raw.Outputs)),
+		Raw:           hex.EncodeToString(raw.Raw),
+	}
+	for _, in := range raw.Inputs {
+		tx.Inputs = append(tx.Inputs, rpcTxInput{
+			TxID:      hex.EncodeToString(in.Txid),
+			Vout:      in.Vout,
+			Signature: hex.EncodeToString(in.Signature),
+			PubKey:    hex.EncodeToString(in.PubKey),
+		})
+	}
+	for _, out := range raw.Outputs {
+		tx.Outputs = append(tx.Outputs, rpcTxOutput{Value: out.Value, Address: out.Address, Data: hex.EncodeToString(out.Data)})
+	}
+	return tx
+}
+
+func newRPCMempool(info MempoolInfo) rpcMempool {
+	mp := rpcMempool{Count: len(info.Txs), Bytes: info.Bytes, Fees: info.Fees, Txs: make([]rpcMempoolTx, 0, len(info.Txs))}
+	for _, tx := range info.Txs {
+		mp.Txs = append(mp.Txs, rpcMempoolTx{TxID: hex.EncodeToString(tx.ID), Fee: tx.Fee, Size: tx.Size})
+	}
+	return mp
+}
+
+func newRPCFunding(f *core.Funding) rpcFunding {
+	v := rpcFunding{Inputs: make([]rpcFundingInput, 0, len(f.Inputs)), InputValue: f.InputValue, Amount: f.Amount, Change: f.Change, Fee: f.Fee}
+	for _, in := range f.Inputs {
+		v.Inputs = append(v.Inputs, rpcFundingInput{TxID: hex.EncodeToString(in.TxID), Vout: in.Vout, Value: in.Value, Unconfirmed: in.Unconfirmed})
+	}
+	return v
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, rpcError{Error: msg})
+}
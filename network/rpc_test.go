@@ -0,0 +1,144 @@
+package network
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"my-blockchain/wallet"
+)
+
+// rpcCall sends a request to srv and decodes its JSON answer into v, failing unless the
+// status is wantStatus.
+func rpcCall(t *testing.T, srv *httptest.Server, method, path, body string, wantStatus int, v any) {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode != wantStatus {
+		t.Fatalf("%s %s: status %d, want %d", method, path, res.StatusCode, wantStatus)
+	}
+	if ct := res.Header.Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("%s %s: Content-Type %q", method, path, ct)
+	}
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+}
+
+func TestRPCEndpoints(t *testing.T) {
+	chdirTemp(t)
+	n := newTestNode(t)
+	from := fundedChain(t, n)
+	startNode(t, n)
+	srv := httptest.NewServer(n.RPCHandler())
+	t.Cleanup(srv.Close)
+	to := string(wallet.NewWallet().GetAddress())
+
+	var balance rpcBalance
+	rpcCall(t, srv, "GET", "/balance/"+from, "", http.StatusOK, &balance)
+	if balance.Address != from || balance.Balance != 10 {
+		t.Errorf("balance %+v, want 10 for %s", balance, from)
+	}
+	var rpcErr rpcError
+	rpcCall(t, srv, "GET", "/balance/nonsense", "", http.StatusBadRequest, &rpcErr)
+	if rpcErr.Error == "" {
+		t.Error("bad address answered without an error message")
+	}
+
+	var chain rpcChain
+	rpcCall(t, srv, "GET", "/chain", "", http.StatusOK, &chain)
+	if chain.Height != 1 || len(chain.Blocks) != 1 || len(chain.Blocks[0].TxIDs) != 1 {
+		t.Fatalf("chain %+v, want the genesis block with its coinbase", chain)
+	}
+	if _, err := hex.DecodeString(chain.Blocks[0].Hash); err != nil || len(chain.Blocks[0].Hash) != 64 {
+		t.Errorf("block hash %q is not 32 bytes of hex", chain.Blocks[0].Hash)
+	}
+
+	rpcCall(t, srv, "POST", "/tx", `{"from": "`+from+`", "to": "`+to+`", "amount": 11}`, http.StatusUnprocessableEntity, &rpcErr)
+	rpcCall(t, srv, "POST", "/tx", `{"from": "`+from+`", "amount": "4"}`, http.StatusBadRequest, &rpcErr)
+	rpcCall(t, srv, "POST", "/tx", `{"from": "`+from+`", "to": "`+to+`", "amount": 4, "colour": "red"}`, http.StatusBadRequest, &rpcErr)
+	var sent rpcSendResponse
+	rpcCall(t, srv, "POST", "/tx", `{"from": "`+from+`", "to": "`+to+`", "amount": 4}`, http.StatusAccepted, &sent)
+	if _, err := hex.DecodeString(sent.TxID); err != nil || sent.TxID == "" {
+		t.Fatalf("txid %q is not hex", sent.TxID)
+	}
+
+	var tx rpcTx
+	rpcCall(t, srv, "GET", "/tx/"+sent.TxID, "", http.StatusOK, &tx)
+	if tx.ID != sent.TxID || tx.Coinbase || tx.BlockHash != "" || len(tx.Inputs) != 1 || tx.Raw == "" {
+		t.Errorf("pending transaction %+v", tx)
+	}
+	if len(tx.Outputs) == 0 || tx.Outputs[0].Address != to || tx.Outputs[0].Value != 4 {
+		t.Errorf("outputs %+v, want 4 to %s first", tx.Outputs, to)
+	}
+	rpcCall(t, srv, "GET", "/tx/"+chain.Blocks[0].TxIDs[0], "", http.StatusOK, &tx)
+	if !tx.Coinbase || tx.BlockHash != chain.Blocks[0].Hash || tx.Confirmations != 1 {
+		t.Errorf("genesis coinbase %+v", tx)
+	}
+	rpcCall(t, srv, "GET", "/tx/zz", "", http.StatusBadRequest, &rpcErr)
+	rpcCall(t, srv, "GET", "/tx/"+strings.Repeat("ab", 32), "", http.StatusNotFound, &rpcErr)
+}
+
+func TestChainAndBalanceJSONUseHexStrings(t *testing.T) {
+	block := ChainBlock{
+		Index:         2,
+		Timestamp:     1700000000,
+		PrevHash:      []byte{0x00, 0xab},
+		Hash:          []byte{0x01, 0xcd},
+		Nonce:         7,
+		Merkle:        []byte{0xef},
+		Bits:          1,
+		TxIDs:         [][]byte{{0x0f, 0xf0}},
+		Confirmations: 1,
+	}
+	raw, err := json.Marshal(ChainJSON([]ChainBlock{block}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var chain struct {
+		Height int
+		Blocks []map[string]any
+	}
+	if err := json.Unmarshal(raw, &chain); err != nil {
+		t.Fatal(err)
+	}
+	if chain.Height != 1 || len(chain.Blocks) != 1 {
+		t.Fatalf("chain JSON %s, want height 1 and one block", raw)
+	}
+	got := chain.Blocks[0]
+	for field, want := range map[string]any{
+		"index":         2.0,
+		"hash":          "01cd",
+		"prevHash":      "00ab",
+		"merkleRoot":    "ef",
+		"nonce":         7.0,
+		"confirmations": 1.0,
+		"txids":         []any{"0ff0"},
+	} {
+		if !reflect.DeepEqual(got[field], want) {
+			t.Errorf("block field %q is %#v, want %#v", field, got[field], want)
+		}
+	}
+	if _, ok := got["pruned"]; ok {
+		t.Error("unpruned block carries a pruned field")
+	}
+
+	raw, err = json.Marshal(BalanceJSON("addr", 42))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(raw) != `{"address":"addr","balance":42}` {
+		t.Errorf("balance JSON %s", raw)
+	}
+}
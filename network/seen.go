@@ -0,0 +1,50 @@
+package network
+
+import (
+	"encoding/hex"
+	"sync"
+)
+
+// seenCapacity is how many transaction or block IDs a node remembers having seen.
+const seenCapacity = 10000
+
+// seenSet remembers the most recent IDs a node has handled, forgetting the oldest once
+// it holds capacity of them, so that an ID arriving again from another peer is neither
+// fetched nor relayed a second time. It is safe for concurrent use.
+type seenSet struct {
+	mu    sync.Mutex
+	ids   map[string]bool
+	order []string
+	next  int
+}
+
+func newSeenSet(capacity int) *seenSet {
+	return &seenSet{ids: make(map[string]bool, capacity), order: make([]string, 0, capacity)}
+}
+
+// add marks id seen and reports whether it was new.
+func (s *seenSet) add(id []byte) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := hex.EncodeToString(id)
+	if s.ids[key] {
+		return false
+	}
+	if len(s.order) < cap(s.order) {
+		s.order = append(s.order, key)
+	} else {
+		delete(s.ids, s.order[s.next])
+		s.order[s.next] = key
+		s.next = (s.next + 1) % len(s.order)
+	}
+	s.ids[key] = true
+	return true
+}
+
+func (s *seenSet) has(id []byte) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.ids[hex.EncodeToString(id)]
+}
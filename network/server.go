@@ -2,11 +2,16 @@ package network
 
 import (
 	"bytes"
+	"context"
 	"encoding/gob"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"log"
+	"io"
 	"net"
-	"strings"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"my-blockchain/core"
@@ -15,27 +20,76 @@ import (
 
 const protocolVersion = 1
 
-// For the milestone, we keep a simple fixed set of peers on localhost.
-var knownNodes = []string{"localhost:3000", "localhost:3001", "localhost:3002"}
+// Defaults for the settings of the same name on nodes made by NewNode.
+var (
+	// MinerThreads is how many goroutines a mining node searches for a block's nonce with.
+	MinerThreads = 1
+	// PruneDepth, when non-zero, makes the node discard the transactions of blocks more than
+	// that many blocks below the tip (see core.Blockchain.Prune).
+	PruneDepth = 0
+	// WalletFile is the wallet file the node signs /tx and sendtx requests with.
+	WalletFile = wallet.DefaultWalletFile
+	// Chain is the configuration a node with a new, empty DB adopts. A DB created earlier
+	// keeps the configuration stored in it.
+	Chain = core.MainConfig
+	// ListenAddr, when not empty, is the host:port a node listens on and announces to
+	// peers instead of localhost:<nodeID>. Requests to the node at any ID dial it too.
+	ListenAddr = ""
+	// DirectBlockRelay makes the node push the blocks it mines, and new blocks pushed to
+	// it, to its peers in full with sendblock, instead of announcing them with an inv that
+	// each peer must answer with getdata.
+	DirectBlockRelay = true
+	// RPCToken, when not empty, must be presented to a node's JSON API, and to its TCP
+	// client commands when TCPAuth is set. Requests to a node send it too.
+	RPCToken = ""
+	// TCPAuth makes the node refuse the commands the CLI sends over TCP, such as sendtx and
+	// mine, unless they carry RPCToken. Messages between peers are never gated.
+	TCPAuth = false
+	// MaxPeers caps how many peers a node keeps; learning of one more evicts the least
+	// recently successful of the others (see Node.AddPeer). 0 means no cap.
+	MaxPeers = 125
+)
 
-var nodeAddress string
-var blocksInTransit [][]byte
+// nodeAddr is the address of the node with ID nodeID: ListenAddr if set, else localhost:<nodeID>.
+func nodeAddr(nodeID string) string {
+	if ListenAddr != "" {
+		return ListenAddr
+	}
+	return net.JoinHostPort("localhost", nodeID)
+}
 
-var minerAddr string
+const (
+	miningInterval = 5 * time.Second
+	maxBlockTxs    = 100
+	// maxAddrPerMessage caps how many peer addresses an addr message carries or is read from.
+	maxAddrPerMessage = 50
+	// maxConnections caps concurrently handled inbound connections; extra ones are dropped.
+	maxConnections = 64
+	// maxMessageSize caps a frame's payload, well above a full block.
+	maxMessageSize = 4 << 20
+	// shutdownTimeout bounds how long the RPC server waits for open requests on shutdown.
+	shutdownTimeout = 5 * time.Second
+)
 
 type Message struct {
 	Command string
 	Payload []byte
+	// Token is the RPCToken a client request carries; peers leave it empty.
+	Token string
 }
 
 type Version struct {
-	Version    int
-	BestHeight int
-	AddrFrom   string
+	Version     int
+	BestHeight  int
+	AddrFrom    string
+	GenesisHash []byte
 }
 
+// GetBlocks asks a peer for the hashes of its main-chain blocks after the last one
+// Locator (see core.Blockchain.BlockLocator) shares with it; all of them if none.
 type GetBlocks struct {
 	AddrFrom string
+	Locator  [][]byte
 }
 
 type Inv struct {
@@ -48,6 +102,9 @@ type GetData struct {
 	AddrFrom string
 	Type     string
 	ID       []byte
+	// IDs requests several blocks at once (at most maxBlocksInFlight), sent back in order as
+	// separate block messages.
+	IDs [][]byte
 }
 
 type BlockData struct {
@@ -55,6 +112,23 @@ type BlockData struct {
 	Block    []byte
 }
 
+// TxData carries a serialized transaction, in reply to a getdata of type "tx".
+type TxData struct {
+	AddrFrom    string
+	Transaction []byte
+}
+
+// GetAddr asks a peer for the addresses it knows.
+type GetAddr struct {
+	AddrFrom string
+}
+
+// Addr shares known peer addresses, in reply to GetAddr.
+type Addr struct {
+	AddrFrom string
+	AddrList []string
+}
+
 // BalanceRequest asks the node to compute the UTXO balance for an address.
 type BalanceRequest struct {
 	AddrFrom string
@@ -64,6 +138,7 @@ type BalanceRequest struct {
 type BalanceResponse struct {
 	OK      bool
 	Message string
+	Code    string
 	Balance int
 }
 
@@ -79,7 +154,12 @@ type ChainBlock struct {
 	Hash      []byte
 	Nonce     int
 	Merkle    []byte
+	Bits      int
 	TxIDs     [][]byte
+	// Confirmations counts this block and every block built on it.
+	Confirmations int
+	// Pruned is set when the node discarded the block's transactions; TxIDs is then empty.
+	Pruned bool
 }
 
 type ChainResponse struct {
@@ -89,384 +169,806 @@ type ChainResponse struct {
 }
 
 // TxRequest is an RPC-style request asking the node to construct/sign a transaction
-// (using local wallets.dat), mine it into a block, and persist/broadcast the block.
+// (using local wallets.dat) and queue it in the mempool for the next mined block.
 type TxRequest struct {
 	AddrFrom string
 	From     string
 	To       string
 	Amount   int
+	Fee      int
+	// LockTime, when non-zero, keeps the transaction out of blocks below that height
+	// (or Unix time).
+	LockTime int
+	// Replaceable opts the transaction in to replace-by-fee.
+	Replaceable bool
+	// Data is embedded in a data output when set.
+	Data []byte
+	// Outputs, when set, pays several addresses in one transaction; To and Amount
+	// are then ignored.
+	Outputs map[string]int
+	// CoinSelection chooses the inputs (see core.CoinSelection).
+	CoinSelection string
+}
+
+// payments returns the addresses r pays: Outputs, or else To.
+func (r TxRequest) payments() map[string]int {
+	if len(r.Outputs) > 0 {
+		return r.Outputs
+	}
+	return map[string]int{r.To: r.Amount}
+}
+
+// options returns the transaction options r carries.
+func (r TxRequest) options() core.TxOptions {
+	return core.TxOptions{Fee: r.Fee, LockTime: r.LockTime, Replaceable: r.Replaceable, Data: r.Data, CoinSelection: core.CoinSelection(r.CoinSelection)}
 }
 
 // Result is a generic request/response payload.
 type Result struct {
 	OK      bool
 	Message string
+	// Code classifies a failure (see RemoteError).
+	Code string
 }
 
-func StartServer(nodeID string, minerAddress string) {
-	minerAddr = minerAddress
-
-	nodeAddress = fmt.Sprintf("localhost:%s", nodeID)
-	bc := core.InitBlockchainForNode(nodeID)
-	defer func() { _ = bc.Close() }()
-
-	ln, err := net.Listen("tcp", nodeAddress)
-	if err != nil {
-		log.Panic(err)
-	}
-	defer func() { _ = ln.Close() }()
-
-	if minerAddr != "" {
-		log.Printf("Node %s listening (db=%s, miner=%s)\n", nodeAddress, "blockchain_"+nodeID+".db", minerAddr)
-	} else {
-		log.Printf("Node %s listening (db=%s)\n", nodeAddress, "blockchain_"+nodeID+".db")
-	}
+// StartServer runs the node on nodeAddr(nodeID) until it receives SIGINT or SIGTERM. A
+// non-empty rpcPort also serves the HTTP/JSON API on rpcPort of the same host.
+func StartServer(nodeID string, minerAddress string, peersFile string, rpcPort string) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// If we're not the bootstrap node, announce ourselves.
-	if nodeAddress != knownNodes[0] {
-		go sendVersion(knownNodes[0], bc)
-	}
-
-	for {
-		conn, err := ln.Accept()
-		if err != nil {
-			continue
-		}
-		go handleConnection(conn, bc)
+	if err := StartServerContext(ctx, nodeID, minerAddress, peersFile, rpcPort); err != nil {
+		core.Log.Error("node stopped", "node", nodeID, "err", err)
+		os.Exit(1)
 	}
 }
 
-func currentNodeID() string {
-	return strings.TrimPrefix(nodeAddress, "localhost:")
+// StartServerContext is StartServer stopped by cancelling ctx instead of by a signal. On
+// cancellation it stops accepting connections, waits for in-flight handlers and the
+// mining loop, and closes the DB before returning nil.
+func StartServerContext(ctx context.Context, nodeID string, minerAddress string, peersFile string, rpcPort string) error {
+	return NewNode(nodeID, minerAddress, peersFile, rpcPort).Start(ctx)
 }
 
-func handleConnection(conn net.Conn, bc *core.Blockchain) {
+func (n *Node) handleConnection(conn net.Conn) {
+	bc := n.bc
 	defer func() { _ = conn.Close() }()
 	_ = conn.SetReadDeadline(time.Now().Add(30 * time.Second))
 
-	dec := gob.NewDecoder(conn)
-	var msg Message
-	if err := dec.Decode(&msg); err != nil {
+	msg, err := readMessage(conn)
+	if err != nil {
+		if !errors.Is(err, io.EOF) {
+			n.logger.Warn("rejected message", "from", conn.RemoteAddr().String(), "err", err)
+		}
+		return
+	}
+	n.logger.Debug("received message", "command", msg.Command, "from", conn.RemoteAddr().String())
+	if !n.authorized(msg) {
+		n.logger.Warn("refused unauthorized request", "command", msg.Command, "from", conn.RemoteAddr().String())
+		sendReply(conn, Message{Command: "unauthorized"})
 		return
 	}
 
 	switch msg.Command {
 	case "version":
-		handleVersion(msg.Payload, bc)
+		err = n.handleVersion(msg.Payload)
 	case "getblocks":
-		handleGetBlocks(msg.Payload, bc)
+		err = n.handleGetBlocks(msg.Payload)
+	case "getheaders":
+		err = n.handleGetHeaders(msg.Payload)
+	case "headers":
+		err = n.handleHeaders(msg.Payload)
+	case "getaddr":
+		err = n.handleGetAddr(msg.Payload)
+	case "addr":
+		err = n.handleAddr(msg.Payload)
 	case "inv":
-		handleInv(msg.Payload, bc)
+		err = n.handleInv(msg.Payload)
 	case "getdata":
-		handleGetData(msg.Payload, bc)
+		err = n.handleGetData(msg.Payload)
 	case "block":
-		handleBlock(msg.Payload, bc)
+		err = n.handleBlock(msg.Payload)
+	case "sendblock":
+		err = n.handleSendBlock(msg.Payload)
+	case "tx":
+		err = n.handleTx(msg.Payload)
 	case "sendtx":
-		handleSendTx(conn, msg.Payload, bc)
+		err = n.handleSendTx(conn, msg.Payload)
 	case "getbalance":
-		handleGetBalance(conn, msg.Payload, bc)
+		err = handleGetBalance(conn, msg.Payload, bc)
 	case "getchain":
-		handleGetChain(conn, msg.Payload, bc)
+		err = handleGetChain(conn, msg.Payload, bc)
+	case "getrawtx":
+		err = n.handleGetRawTx(conn, msg.Payload)
+	case "getmerkleproof":
+		err = handleGetMerkleProof(conn, msg.Payload, bc)
+	case "getblockhash":
+		err = handleGetBlockHash(conn, msg.Payload, bc)
+	case "getblock":
+		err = handleGetBlock(conn, msg.Payload, bc)
+	case "getwork":
+		err = n.handleGetWork(conn, msg.Payload)
+	case "submitwork":
+		err = n.handleSubmitWork(conn, msg.Payload)
+	case "status":
+		err = n.handleStatus(conn, msg.Payload)
+	case "getmempool":
+		err = n.handleGetMempool(conn, msg.Payload)
+	case "ping":
+		err = handlePing(conn, msg.Payload)
+	case "listtxs":
+		err = handleListTxs(conn, msg.Payload, bc)
+	case "listunspent":
+		err = handleListUnspent(conn, msg.Payload, bc)
+	case "testsend":
+		err = n.handleTestSend(conn, msg.Payload)
+	case "rescan":
+		err = n.handleRescan(conn, msg.Payload)
+	case "mine":
+		err = n.handleMine(conn, msg.Payload)
+	case "faucet":
+		err = n.handleFaucet(conn, msg.Payload)
+	case "walletpassphrase":
+		err = n.handleWalletPassphrase(conn, msg.Payload)
+	case "walletlock":
+		err = n.handleWalletLock(conn, msg.Payload)
 	default:
 		// ignore unknown
 	}
+	if err != nil {
+		n.dropMessage(conn, msg.Command, err)
+	}
+}
+
+// dropMessage logs a message the node could not handle. A malformed payload from a peer
+// counts as a failure against it, like a failed delivery.
+func (n *Node) dropMessage(conn net.Conn, command string, err error) {
+	from := conn.RemoteAddr().String()
+	n.logger.Warn("dropped message", "command", command, "from", from, "err", err)
+	if !errors.Is(err, ErrMalformedPayload) || clientCommands[command] {
+		return
+	}
+	if peer := n.peerAtHost(from); peer != "" {
+		n.recordFailure(peer)
+	}
 }
 
 func sendReply(conn net.Conn, msg Message) {
-	enc := gob.NewEncoder(conn)
-	_ = enc.Encode(msg)
+	_ = writeMessage(conn, msg)
 }
 
-func encodePayload(v any) []byte {
+func encodePayload(v any) ([]byte, error) {
 	var buf bytes.Buffer
 	enc := gob.NewEncoder(&buf)
 	if err := enc.Encode(v); err != nil {
-		log.Panic(err)
+		return nil, fmt.Errorf("encode %T: %w", v, err)
 	}
-	return buf.Bytes()
+	return buf.Bytes(), nil
 }
 
-func decodePayload(data []byte, out any) {
+// decodePayload decodes data into out. A payload that does not decode, such as one a
+// peer corrupted or built for another message, fails with ErrMalformedPayload.
+func decodePayload(data []byte, out any) error {
 	dec := gob.NewDecoder(bytes.NewReader(data))
 	if err := dec.Decode(out); err != nil {
-		log.Panic(err)
+		return fmt.Errorf("%w: %T: %v", ErrMalformedPayload, out, err)
 	}
+	return nil
 }
 
-func sendData(addr string, msg Message) {
-	conn, err := net.DialTimeout("tcp", addr, 3*time.Second)
+// newMessage returns a command message carrying payload.
+func newMessage(command string, payload any) (Message, error) {
+	data, err := encodePayload(payload)
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{Command: command, Payload: data}, nil
+}
+
+// replyWith answers a request on conn with a command message carrying payload.
+func replyWith(conn net.Conn, command string, payload any) error {
+	msg, err := newMessage(command, payload)
+	if err != nil {
+		return err
+	}
+	sendReply(conn, msg)
+	return nil
+}
+
+// sendPayload is sendData for a command message carrying payload. A payload that cannot
+// be encoded is logged and nothing is sent.
+func (n *Node) sendPayload(addr string, command string, payload any) {
+	msg, err := newMessage(command, payload)
 	if err != nil {
+		n.logger.Error("dropped outgoing message", "command", command, "peer", addr, "err", err)
 		return
 	}
-	defer func() { _ = conn.Close() }()
+	n.sendData(addr, msg)
+}
 
-	enc := gob.NewEncoder(conn)
-	_ = enc.Encode(msg)
+func (n *Node) sendData(addr string, msg Message) {
+	// Messages to dead peers are dropped; probeLoop finds out when they are back.
+	if n.peerDead(addr) {
+		return
+	}
+	delay := retryBaseDelay
+	for attempt := 1; attempt <= sendAttempts; attempt++ {
+		if err := deliver(addr, msg); err == nil {
+			n.recordSuccess(addr)
+			return
+		}
+		if attempt < sendAttempts {
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+	n.recordFailure(addr)
 }
 
+// replyTimeout is how long sendRequest waits for the reply.
+const replyTimeout = 10 * time.Second
+
 // sendRequest sends a message and waits for a single reply message.
 func sendRequest(addr string, msg Message) (*Message, error) {
+	return sendRequestTimeout(addr, msg, replyTimeout)
+}
+
+// request sends a command message carrying payload to the node at addr and decodes the
+// payload of its reply, which must be a want message, into out.
+func request(addr string, command string, payload any, want string, out any) error {
+	return requestTimeout(addr, command, payload, want, out, replyTimeout)
+}
+
+// requestTimeout is request waiting up to timeout for the reply.
+func requestTimeout(addr string, command string, payload any, want string, out any, timeout time.Duration) error {
+	msg, err := newMessage(command, payload)
+	if err != nil {
+		return err
+	}
+	reply, err := sendRequestTimeout(addr, msg, timeout)
+	if err != nil {
+		return err
+	}
+	if reply.Command != want {
+		return fmt.Errorf("unexpected reply: %s", reply.Command)
+	}
+	return decodePayload(reply.Payload, out)
+}
+
+// sendRequestTimeout is sendRequest waiting up to timeout for the reply, for requests the
+// node may take long to answer.
+func sendRequestTimeout(addr string, msg Message, timeout time.Duration) (*Message, error) {
 	conn, err := net.DialTimeout("tcp", addr, 3*time.Second)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %v", ErrNodeUnreachable, err)
 	}
 	defer func() { _ = conn.Close() }()
 
-	enc := gob.NewEncoder(conn)
-	if err := enc.Encode(msg); err != nil {
-		return nil, err
+	msg.Token = RPCToken
+	if err := writeMessage(conn, msg); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNodeUnreachable, err)
 	}
 
-	_ = conn.SetReadDeadline(time.Now().Add(10 * time.Second))
-	dec := gob.NewDecoder(conn)
-	var reply Message
-	if err := dec.Decode(&reply); err != nil {
-		return nil, err
+	_ = conn.SetReadDeadline(time.Now().Add(timeout))
+	reply, err := readMessage(conn)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNodeUnreachable, err)
+	}
+	if reply.Command == "unauthorized" {
+		return nil, ErrUnauthorized
 	}
 	return &reply, nil
 }
 
-// SendTxRequest asks the running node at localhost:<nodeID> to construct/sign/mine a transaction.
+// SendTxRequest asks the running node at nodeAddr(nodeID) to construct/sign a transaction and queue it for mining.
 // This avoids opening BoltDB from the CLI process while startnode owns the DB.
-func SendTxRequest(nodeID string, from string, to string, amount int) (string, error) {
-	addr := fmt.Sprintf("localhost:%s", nodeID)
-	payload := TxRequest{AddrFrom: addr, From: from, To: to, Amount: amount}
-	reply, err := sendRequest(addr, Message{Command: "sendtx", Payload: encodePayload(payload)})
-	if err != nil {
+func SendTxRequest(nodeID string, from string, to string, amount int, opts core.TxOptions) (string, error) {
+	addr := nodeAddr(nodeID)
+	return sendTxRequest(addr, TxRequest{AddrFrom: addr, From: from, To: to, Amount: amount, Fee: opts.Fee, LockTime: opts.LockTime, Replaceable: opts.Replaceable, Data: opts.Data, CoinSelection: string(opts.CoinSelection)})
+}
+
+// SendManyRequest is SendTxRequest for one transaction paying every address in outputs.
+func SendManyRequest(nodeID string, from string, outputs map[string]int, opts core.TxOptions) (string, error) {
+	addr := nodeAddr(nodeID)
+	return sendTxRequest(addr, TxRequest{AddrFrom: addr, From: from, Outputs: outputs, Fee: opts.Fee, LockTime: opts.LockTime, Replaceable: opts.Replaceable, Data: opts.Data, CoinSelection: string(opts.CoinSelection)})
+}
+
+func sendTxRequest(addr string, payload TxRequest) (string, error) {
+	var res Result
+	if err := request(addr, "sendtx", payload, "result", &res); err != nil {
 		return "", err
 	}
-	if reply.Command != "result" {
-		return "", fmt.Errorf("unexpected reply: %s", reply.Command)
-	}
-	var res Result
-	decodePayload(reply.Payload, &res)
 	if !res.OK {
-		return "", fmt.Errorf(res.Message)
+		return "", &RemoteError{Message: res.Message, Code: res.Code}
 	}
 	return res.Message, nil
 }
 
-// GetBalanceRequest asks the running node at localhost:<nodeID> for an address balance.
+// GetBalanceRequest asks the running node at nodeAddr(nodeID) for an address balance.
 func GetBalanceRequest(nodeID string, address string) (int, error) {
-	addr := fmt.Sprintf("localhost:%s", nodeID)
+	addr := nodeAddr(nodeID)
 	payload := BalanceRequest{AddrFrom: addr, Address: address}
-	reply, err := sendRequest(addr, Message{Command: "getbalance", Payload: encodePayload(payload)})
-	if err != nil {
+	var res BalanceResponse
+	if err := request(addr, "getbalance", payload, "balance", &res); err != nil {
 		return 0, err
 	}
-	if reply.Command != "balance" {
-		return 0, fmt.Errorf("unexpected reply: %s", reply.Command)
-	}
-	var res BalanceResponse
-	decodePayload(reply.Payload, &res)
 	if !res.OK {
-		return 0, fmt.Errorf(res.Message)
+		return 0, &RemoteError{Message: res.Message, Code: res.Code}
 	}
 	return res.Balance, nil
 }
 
-// GetChainRequest asks the running node at localhost:<nodeID> for a chain snapshot to print.
+// GetChainRequest asks the running node at nodeAddr(nodeID) for a chain snapshot to print.
 func GetChainRequest(nodeID string) ([]ChainBlock, string, error) {
-	addr := fmt.Sprintf("localhost:%s", nodeID)
+	addr := nodeAddr(nodeID)
 	payload := ChainRequest{AddrFrom: addr}
-	reply, err := sendRequest(addr, Message{Command: "getchain", Payload: encodePayload(payload)})
-	if err != nil {
+	var res ChainResponse
+	if err := request(addr, "getchain", payload, "chain", &res); err != nil {
 		return nil, "", err
 	}
-	if reply.Command != "chain" {
-		return nil, "", fmt.Errorf("unexpected reply: %s", reply.Command)
-	}
-	var res ChainResponse
-	decodePayload(reply.Payload, &res)
 	if !res.OK {
-		return nil, res.Message, fmt.Errorf(res.Message)
+		return nil, res.Message, &RemoteError{Message: res.Message}
 	}
 	return res.Blocks, res.Message, nil
 }
 
-func sendVersion(addr string, bc *core.Blockchain) {
-	payload := Version{Version: protocolVersion, BestHeight: bc.BestHeight(), AddrFrom: nodeAddress}
-	sendData(addr, Message{Command: "version", Payload: encodePayload(payload)})
+func (n *Node) sendVersion(addr string) {
+	payload := Version{Version: protocolVersion, BestHeight: n.bc.BestHeight(), AddrFrom: n.address, GenesisHash: n.bc.GenesisHash()}
+	n.sendPayload(addr, "version", payload)
+}
+
+func (n *Node) sendGetAddr(addr string) {
+	payload := GetAddr{AddrFrom: n.address}
+	n.sendPayload(addr, "getaddr", payload)
 }
 
-func sendGetBlocks(addr string) {
-	payload := GetBlocks{AddrFrom: nodeAddress}
-	sendData(addr, Message{Command: "getblocks", Payload: encodePayload(payload)})
+func (n *Node) sendAddr(addr string, addrs []string) {
+	payload := Addr{AddrFrom: n.address, AddrList: addrs}
+	n.sendPayload(addr, "addr", payload)
 }
 
-func sendInv(addr string, kind string, items [][]byte) {
-	payload := Inv{AddrFrom: nodeAddress, Type: kind, Items: items}
-	sendData(addr, Message{Command: "inv", Payload: encodePayload(payload)})
+func (n *Node) sendInv(addr string, kind string, items [][]byte) {
+	payload := Inv{AddrFrom: n.address, Type: kind, Items: items}
+	n.sendPayload(addr, "inv", payload)
 }
 
-func sendGetData(addr string, kind string, id []byte) {
-	payload := GetData{AddrFrom: nodeAddress, Type: kind, ID: id}
-	sendData(addr, Message{Command: "getdata", Payload: encodePayload(payload)})
+func (n *Node) sendGetData(addr string, kind string, id []byte) {
+	payload := GetData{AddrFrom: n.address, Type: kind, ID: id}
+	n.sendPayload(addr, "getdata", payload)
 }
 
-func sendBlock(addr string, blockBytes []byte) {
-	payload := BlockData{AddrFrom: nodeAddress, Block: blockBytes}
-	sendData(addr, Message{Command: "block", Payload: encodePayload(payload)})
+func (n *Node) sendGetDataBlocks(addr string, hashes [][]byte) {
+	payload := GetData{AddrFrom: n.address, Type: "block", IDs: hashes}
+	n.sendPayload(addr, "getdata", payload)
 }
 
-func handleVersion(payloadBytes []byte, bc *core.Blockchain) {
+func (n *Node) sendBlock(addr string, blockBytes []byte) {
+	payload := BlockData{AddrFrom: n.address, Block: blockBytes}
+	n.sendPayload(addr, "block", payload)
+}
+
+func (n *Node) sendTx(addr string, tx *core.Transaction) {
+	payload := TxData{AddrFrom: n.address, Transaction: tx.Serialize()}
+	n.sendPayload(addr, "tx", payload)
+}
+
+func (n *Node) handleVersion(payloadBytes []byte) error {
 	var payload Version
-	decodePayload(payloadBytes, &payload)
+	if err := decodePayload(payloadBytes, &payload); err != nil {
+		return err
+	}
+	if !compatibleGenesis(n.bc, payload.GenesisHash) {
+		n.logger.Warn("ignoring peer with another genesis", "peer", payload.AddrFrom, "genesis", hex.EncodeToString(payload.GenesisHash))
+		return nil
+	}
+	n.raiseSyncTarget(payload.BestHeight)
+	if n.AddPeer(payload.AddrFrom) {
+		n.logger.Info("discovered peer", "peer", payload.AddrFrom)
+		n.goInflight(func() { n.sendGetAddr(payload.AddrFrom) })
+	}
 
-	myBestHeight := bc.BestHeight()
+	myBestHeight := n.bc.BestHeight()
 	if myBestHeight < payload.BestHeight {
-		sendGetBlocks(payload.AddrFrom)
+		n.sendGetHeaders(payload.AddrFrom)
 	} else if myBestHeight > payload.BestHeight {
-		sendVersion(payload.AddrFrom, bc)
+		n.sendVersion(payload.AddrFrom)
 	}
+	return nil
 }
 
-func handleGetBlocks(payloadBytes []byte, bc *core.Blockchain) {
+// compatibleGenesis reports whether a peer's chain can be synced with ours. A node with
+// no blocks yet (or a peer that has none) accepts any genesis.
+func compatibleGenesis(bc *core.Blockchain, peerGenesis []byte) bool {
+	ours := bc.GenesisHash()
+	return len(ours) == 0 || len(peerGenesis) == 0 || bytes.Equal(ours, peerGenesis)
+}
+
+// handleGetAddr replies with up to maxAddrPerMessage known peers, leaving out the requester itself.
+func (n *Node) handleGetAddr(payloadBytes []byte) error {
+	var payload GetAddr
+	if err := decodePayload(payloadBytes, &payload); err != nil {
+		return err
+	}
+
+	addrs := make([]string, 0, maxAddrPerMessage)
+	for _, peer := range append(n.ListPeers(), n.address) {
+		if peer == payload.AddrFrom || containsPeer(addrs, peer) {
+			continue
+		}
+		if len(addrs) == maxAddrPerMessage {
+			break
+		}
+		addrs = append(addrs, peer)
+	}
+	n.sendAddr(payload.AddrFrom, addrs)
+	return nil
+}
+
+// handleAddr merges advertised peers into the peer set and introduces us to the new ones.
+func (n *Node) handleAddr(payloadBytes []byte) error {
+	var payload Addr
+	if err := decodePayload(payloadBytes, &payload); err != nil {
+		return err
+	}
+
+	addrs := payload.AddrList
+	if len(addrs) > maxAddrPerMessage {
+		addrs = addrs[:maxAddrPerMessage]
+	}
+	for _, addr := range addrs {
+		if n.AddPeer(addr) {
+			n.logger.Debug("learned peer", "peer", addr, "from", payload.AddrFrom)
+			n.goInflight(func() { n.sendVersion(addr) })
+		}
+	}
+	return nil
+}
+
+func (n *Node) handleGetBlocks(payloadBytes []byte) error {
 	var payload GetBlocks
-	decodePayload(payloadBytes, &payload)
+	if err := decodePayload(payloadBytes, &payload); err != nil {
+		return err
+	}
 
-	hashes := bc.GetBlockHashes()
-	sendInv(payload.AddrFrom, "block", hashes)
+	hashes := n.bc.GetBlockHashes()
+	if fork := n.bc.LocateFork(payload.Locator); fork <= len(hashes) {
+		hashes = hashes[fork:]
+	}
+	if len(hashes) > 0 {
+		n.sendInv(payload.AddrFrom, "block", hashes)
+	}
+	return nil
 }
 
-func handleInv(payloadBytes []byte, bc *core.Blockchain) {
+func (n *Node) handleInv(payloadBytes []byte) error {
 	var payload Inv
-	decodePayload(payloadBytes, &payload)
+	if err := decodePayload(payloadBytes, &payload); err != nil {
+		return err
+	}
+	if payload.Type == "tx" {
+		for _, id := range payload.Items {
+			if !n.mempool.Has(id) && !n.seenTxs.has(id) {
+				n.sendGetData(payload.AddrFrom, "tx", id)
+			}
+		}
+		return nil
+	}
 	if payload.Type != "block" {
-		return
+		return nil
 	}
 
 	// Request blocks we don't have, in the order provided.
-	blocksInTransit = nil
+	var missing [][]byte
 	for _, h := range payload.Items {
-		if !bc.HasBlock(h) {
-			blocksInTransit = append(blocksInTransit, h)
+		if !n.bc.HasBlock(h) && !n.seenBlocks.has(h) {
+			missing = append(missing, h)
 		}
 	}
-	if len(blocksInTransit) == 0 {
-		return
-	}
-
-	// Request the first missing block.
-	request := blocksInTransit[0]
-	blocksInTransit = blocksInTransit[1:]
-	sendGetData(payload.AddrFrom, "block", request)
+	n.queueBlocks(payload.AddrFrom, missing)
+	return nil
 }
 
-func handleGetData(payloadBytes []byte, bc *core.Blockchain) {
+func (n *Node) handleGetData(payloadBytes []byte) error {
 	var payload GetData
-	decodePayload(payloadBytes, &payload)
+	if err := decodePayload(payloadBytes, &payload); err != nil {
+		return err
+	}
+	if payload.Type == "tx" {
+		if tx, ok := n.mempool.Get(payload.ID); ok {
+			n.sendTx(payload.AddrFrom, tx)
+		}
+		return nil
+	}
 	if payload.Type != "block" {
-		return
+		return nil
+	}
+
+	hashes := payload.IDs
+	if len(payload.ID) > 0 {
+		hashes = append([][]byte{payload.ID}, hashes...)
 	}
+	for _, hash := range hashes[:min(len(hashes), maxBlocksInFlight)] {
+		if blockBytes, err := n.bc.GetBlock(hash); err == nil {
+			n.sendBlock(payload.AddrFrom, blockBytes)
+		}
+	}
+	return nil
+}
 
-	blockBytes, err := bc.GetBlock(payload.ID)
+// handleTx validates a relayed transaction, adds it to the mempool and passes it on.
+func (n *Node) handleTx(payloadBytes []byte) error {
+	var payload TxData
+	if err := decodePayload(payloadBytes, &payload); err != nil {
+		return err
+	}
+
+	tx, err := core.DecodeTransaction(payload.Transaction)
 	if err != nil {
-		return
+		return nil
+	}
+	// A body that doesn't hash to its ID says nothing about the real transaction, so it
+	// mustn't mark the ID seen and shut the genuine one out.
+	if !bytes.Equal(tx.ID, tx.Hash()) {
+		n.logger.Info("rejected transaction", "tx", hex.EncodeToString(tx.ID), "from", payload.AddrFrom, "err", core.ErrBadTransactionID)
+		return nil
 	}
-	sendBlock(payload.AddrFrom, blockBytes)
+	// Whether accepted or not, a transaction is handled once: another copy is dropped.
+	if n.mempool.Has(tx.ID) || !n.seenTxs.add(tx.ID) {
+		return nil
+	}
+	fee, err := n.bc.CheckPendingTx(tx, n.mempool)
+	if err != nil {
+		n.logger.Info("rejected transaction", "tx", hex.EncodeToString(tx.ID), "from", payload.AddrFrom, "err", err)
+		return nil
+	}
+	if err := n.mempool.Add(tx, fee); err != nil {
+		n.logger.Info("rejected transaction", "tx", hex.EncodeToString(tx.ID), "from", payload.AddrFrom, "err", err)
+		return nil
+	}
+	n.relayTx(tx, payload.AddrFrom)
+	return nil
 }
 
-func handleBlock(payloadBytes []byte, bc *core.Blockchain) {
+func (n *Node) handleBlock(payloadBytes []byte) error {
 	var payload BlockData
-	decodePayload(payloadBytes, &payload)
+	if err := decodePayload(payloadBytes, &payload); err != nil {
+		return err
+	}
 
-	bc.PutBlock(payload.Block)
+	var hash []byte
+	outstanding := 0
+	if block, err := core.DecodeBlock(payload.Block); err == nil {
+		hash = block.Hash
+		outstanding = n.finishBody(block.Hash)
+	}
+	// Ask for the next batch, if this completes one, before storing this block.
+	expecting := n.blockArrived(payload.AddrFrom, hash)
+	wasSyncing := n.syncStatus().Syncing
+	if err := n.bc.PutBlock(payload.Block); err != nil {
+		n.logger.Warn("rejected block", "from", payload.AddrFrom, "err", err)
+		return nil
+	}
+	n.publishSyncProgress(wasSyncing)
+	n.mempool.EvictSpent(n.bc)
+	if hash != nil && n.bc.HasBlock(hash) {
+		n.seenBlocks.add(hash)
+	}
 
-	if len(blocksInTransit) > 0 {
-		next := blocksInTransit[0]
-		blocksInTransit = blocksInTransit[1:]
-		sendGetData(payload.AddrFrom, "block", next)
-		return
+	if expecting || outstanding > 0 {
+		return nil
 	}
 
 	// After syncing, announce our version to the bootstrap so it can respond if needed.
-	if nodeAddress != knownNodes[0] {
-		sendVersion(knownNodes[0], bc)
+	if bootstrap := n.bootstrapNode(); bootstrap != "" && n.address != bootstrap {
+		n.sendVersion(bootstrap)
 	}
+	return nil
 }
 
-func handleSendTx(conn net.Conn, payloadBytes []byte, bc *core.Blockchain) {
+func (n *Node) handleSendTx(conn net.Conn, payloadBytes []byte) error {
 	var payload TxRequest
-	decodePayload(payloadBytes, &payload)
+	if err := decodePayload(payloadBytes, &payload); err != nil {
+		return err
+	}
 
-	if payload.Amount <= 0 {
-		sendReply(conn, Message{Command: "result", Payload: encodePayload(Result{OK: false, Message: "amount must be > 0"})})
-		return
+	tx, err := n.submitTxMulti(payload.From, payload.payments(), payload.options())
+	if err != nil {
+		return replyWith(conn, "result", Result{OK: false, Message: fmt.Sprintf("send failed: %v", err), Code: errorCode(err)})
 	}
-	if !wallet.ValidateAddress(payload.From) || !wallet.ValidateAddress(payload.To) {
-		sendReply(conn, Message{Command: "result", Payload: encodePayload(Result{OK: false, Message: "invalid from/to address"})})
-		return
+
+	msg := fmt.Sprintf("Success! Transaction %x accepted into the mempool and relayed to peers.", tx.ID)
+	if n.miner == "" {
+		msg += " (this node does not mine because no -miner was set; a miner peer will include it)"
+	} else {
+		msg += " It will be mined into the next block."
+	}
+	return replyWith(conn, "result", Result{OK: true, Message: msg})
+}
+
+// submitTx builds and signs a transaction from the node's wallets, queues it in the
+// mempool and relays it to peers.
+func (n *Node) submitTx(from, to string, amount int, opts core.TxOptions) (*core.Transaction, error) {
+	return n.submitTxMulti(from, map[string]int{to: amount}, opts)
+}
+
+// submitTxMulti is submitTx paying every address in outputs from one transaction.
+func (n *Node) submitTxMulti(from string, outputs map[string]int, opts core.TxOptions) (*core.Transaction, error) {
+	bc := n.bc
+	if err := checkSendRequest(from, outputs, opts); err != nil {
+		return nil, err
 	}
 
 	// Load wallets locally on the node and construct/sign the transaction.
-	ws, err := wallet.NewWallets()
+	ws, err := n.loadWallets()
 	if err != nil {
-		sendReply(conn, Message{Command: "result", Payload: encodePayload(Result{OK: false, Message: fmt.Sprintf("failed to load wallets: %v", err)})})
-		return
+		return nil, err
 	}
 
-	// Choose who receives coinbase. For usability, if the server wasn't started with -miner,
-	// fall back to paying the sender (previous project behavior).
-	coinbaseTo := minerAddr
-	if coinbaseTo == "" {
-		coinbaseTo = payload.From
+	// Create and sign the spend tx, then queue it for the next mined block.
+	tx, err := core.NewPendingUTXOTransactionMulti(from, outputs, opts, bc, ws, n.mempool)
+	if err != nil {
+		return nil, err
+	}
+	fee, err := bc.CheckPendingTx(tx, n.mempool)
+	if err != nil {
+		return nil, err
 	}
+	if err := n.mempool.Add(tx, fee); err != nil {
+		return nil, err
+	}
+	n.relayTx(tx, "")
+	return tx, nil
+}
 
-	// Create spend tx, mine into a block, persist, and broadcast.
-	var newTip []byte
-	func() {
-		defer func() {
-			if r := recover(); r != nil {
-				err = fmt.Errorf("%v", r)
+// checkSendRequest rejects a malformed send request with ErrBadRequest or ErrInvalidAddress.
+func checkSendRequest(from string, outputs map[string]int, opts core.TxOptions) error {
+	if len(outputs) == 0 {
+		return fmt.Errorf("%w: no outputs", ErrBadRequest)
+	}
+	for to, amount := range outputs {
+		if amount <= 0 {
+			return fmt.Errorf("%w: amount must be > 0", ErrBadRequest)
+		}
+		if err := wallet.CheckAddress(to); err != nil {
+			return fmt.Errorf("%w: to address %s: %v", ErrInvalidAddress, to, err)
+		}
+	}
+	if opts.Fee < 0 {
+		return fmt.Errorf("%w: fee must be >= 0", ErrBadRequest)
+	}
+	if opts.LockTime < 0 {
+		return fmt.Errorf("%w: locktime must be >= 0", ErrBadRequest)
+	}
+	if _, err := core.ParseCoinSelection(string(opts.CoinSelection)); err != nil {
+		return fmt.Errorf("%w: %v", ErrBadRequest, err)
+	}
+	if err := wallet.CheckAddress(from); err != nil {
+		return fmt.Errorf("%w: from address %s: %v", ErrInvalidAddress, from, err)
+	}
+	return nil
+}
+
+func (n *Node) miningLoop(ctx context.Context) {
+	ticker := time.NewTicker(miningInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n.mineMempool(ctx)
+		}
+	}
+}
+
+// mineMempool drains pending transactions into a single new block paying the miner
+// address. It mines nothing while the mempool is empty.
+func (n *Node) mineMempool(ctx context.Context) {
+	_, _ = n.mineBlock(ctx, n.miner, false)
+}
+
+// mineBlock drains up to maxBlockTxs pending transactions into a new block paying to, and
+// broadcasts it. With nothing pending it mines an empty block if allowEmpty, or returns
+// nil. Mining is abandoned, keeping the transactions pending, if ctx is cancelled or
+// another block becomes the tip first.
+func (n *Node) mineBlock(ctx context.Context, to string, allowEmpty bool) ([]byte, error) {
+	n.miningMu.Lock()
+	defer n.miningMu.Unlock()
+
+	// Blocks mined elsewhere may have spent what we were holding.
+	bc := n.bc
+	n.mempool.EvictSpent(bc)
+	txs := n.mempool.Collect(maxBlockTxs, bc.Config().MaxBlockSize)
+	if len(txs) == 0 && !allowEmpty {
+		return nil, nil
+	}
+
+	// Subscribe before reading the tip so no new block can slip in unnoticed.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	blocks, unsubscribe := n.events.Subscribe(core.EventBlock)
+	defer unsubscribe()
+	tip := bc.Tip()
+	go func() {
+		for {
+			select {
+			case _, ok := <-blocks:
+				// Other nodes in the process publish on the same bus: only our tip matters.
+				if !ok || !bytes.Equal(bc.Tip(), tip) {
+					cancel()
+					return
+				}
+			case <-ctx.Done():
+				return
 			}
-		}()
-		tx := core.NewUTXOTransaction(payload.From, payload.To, payload.Amount, bc, ws)
-		cb := core.CoinbaseTx(coinbaseTo, "")
-		newTip = bc.AddBlock([]*core.Transaction{cb, tx})
+		}
 	}()
-	if err != nil {
-		sendReply(conn, Message{Command: "result", Payload: encodePayload(Result{OK: false, Message: fmt.Sprintf("send failed: %v", err)})})
-		return
-	}
 
-	BroadcastNewBlock(currentNodeID(), newTip)
+	var newTip []byte
+	fees, err := bc.TotalFees(txs)
+	if err == nil {
+		cb := bc.Config().CoinbaseTxWithFees(to, "", bc.BestHeight()+1, fees)
+		newTip, err = bc.AddBlockContext(ctx, append([]*core.Transaction{cb}, txs...), n.MinerThreads)
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, core.ErrStaleTip) {
+		n.logger.Info("mining abandoned, keeping pending transactions", "txs", len(txs), "err", err)
+		return nil, err
+	}
 
-	msg := "Success! Transaction accepted and mined into a new block by node."
-	if minerAddr == "" {
-		msg += " (coinbase paid to sender because no -miner was set)"
+	ids := make([][]byte, 0, len(txs))
+	for _, tx := range txs {
+		ids = append(ids, tx.ID)
 	}
-	sendReply(conn, Message{Command: "result", Payload: encodePayload(Result{OK: true, Message: msg})})
+	n.mempool.Remove(ids)
+	if err != nil {
+		n.logger.Error("mining failed, dropped pending transactions", "txs", len(txs), "err", err)
+		return nil, err
+	}
+
+	n.logger.Info("mined block", "block", hex.EncodeToString(newTip), "txs", len(txs))
+	n.broadcastBlock(newTip)
+	return newTip, nil
 }
 
-func handleGetBalance(conn net.Conn, payloadBytes []byte, bc *core.Blockchain) {
+func handleGetBalance(conn net.Conn, payloadBytes []byte, bc *core.Blockchain) error {
 	var payload BalanceRequest
-	decodePayload(payloadBytes, &payload)
+	if err := decodePayload(payloadBytes, &payload); err != nil {
+		return err
+	}
 
 	if !wallet.ValidateAddress(payload.Address) {
-		sendReply(conn, Message{Command: "balance", Payload: encodePayload(BalanceResponse{OK: false, Message: "invalid address"})})
-		return
+		return replyWith(conn, "balance", BalanceResponse{OK: false, Message: "invalid address", Code: errorCode(ErrInvalidAddress)})
 	}
 
-	pubKeyHash := wallet.PubKeyHashFromAddress(payload.Address)
-	UTXOs := bc.FindUTXO(pubKeyHash)
+	return replyWith(conn, "balance", BalanceResponse{OK: true, Balance: balanceOf(bc, payload.Address)})
+}
+
+// balanceOf sums the unspent outputs locked to a valid address.
+func balanceOf(bc *core.Blockchain, address string) int {
+	pubKeyHash := wallet.PubKeyHashFromAddress(address)
+	UTXOs := core.UTXOSet{Blockchain: bc}.FindUTXO(pubKeyHash)
 	balance := 0
 	for _, out := range UTXOs {
 		balance += out.Value
 	}
-
-	sendReply(conn, Message{Command: "balance", Payload: encodePayload(BalanceResponse{OK: true, Balance: balance})})
+	return balance
 }
 
-func handleGetChain(conn net.Conn, payloadBytes []byte, bc *core.Blockchain) {
+func handleGetChain(conn net.Conn, payloadBytes []byte, bc *core.Blockchain) error {
 	var payload ChainRequest
-	decodePayload(payloadBytes, &payload)
+	if err := decodePayload(payloadBytes, &payload); err != nil {
+		return err
+	}
 
 	if len(bc.Tip()) == 0 {
-		sendReply(conn, Message{Command: "chain", Payload: encodePayload(ChainResponse{OK: true, Message: "chain is empty (no blocks yet)", Blocks: nil})})
-		return
+		return replyWith(conn, "chain", ChainResponse{OK: true, Message: "chain is empty (no blocks yet)", Blocks: nil})
 	}
 
+	return replyWith(conn, "chain", ChainResponse{OK: true, Blocks: ChainSnapshot(bc)})
+}
+
+// ChainSnapshot lists the main chain from the tip back to genesis.
+func ChainSnapshot(bc *core.Blockchain) []ChainBlock {
 	it := bc.Iterator()
 	blocks := make([]ChainBlock, 0)
 	index := 0
@@ -486,26 +988,55 @@ func handleGetChain(conn net.Conn, payloadBytes []byte, bc *core.Blockchain) {
 			Hash:      append([]byte(nil), b.Hash...),
 			Nonce:     b.Nonce,
 			Merkle:    append([]byte(nil), b.MerkleRoot...),
+			Bits:      b.Bits(),
 			TxIDs:     txids,
+			Pruned:    b.Pruned,
 		})
 		index++
 		if len(b.PrevBlockHash) == 0 {
 			break
 		}
 	}
+	// Blocks are listed tip first: the one at index i has height best-i and so
+	// best-(best-i)+1 = i+1 confirmations.
+	for i := range blocks {
+		blocks[i].Confirmations = i + 1
+	}
+	return blocks
+}
 
-	sendReply(conn, Message{Command: "chain", Payload: encodePayload(ChainResponse{OK: true, Blocks: blocks})})
+// relayTx sends a tx inventory to every peer except ourselves and skip (the peer we got it
+// from), or to every peer when skip is empty.
+func (n *Node) relayTx(tx *core.Transaction, skip string) {
+	n.seenTxs.add(tx.ID)
+	for _, peer := range n.ListPeers() {
+		if peer == n.address || peer == skip {
+			continue
+		}
+		n.sendInv(peer, "tx", [][]byte{tx.ID})
+	}
 }
 
-// BroadcastNewBlock sends an inventory announcement to known peers.
-func BroadcastNewBlock(nodeID string, blockHash []byte) {
-	fromAddr := fmt.Sprintf("localhost:%s", nodeID)
-	items := [][]byte{blockHash}
-	for _, peer := range knownNodes {
-		if peer == fromAddr {
+// broadcastBlock sends blockHash to known peers: the whole block when DirectBlockRelay is
+// set and the chain is open, an inventory announcement otherwise.
+func (n *Node) broadcastBlock(blockHash []byte) {
+	n.seenBlocks.add(blockHash)
+	if n.DirectBlockRelay && n.bc != nil {
+		if blockBytes, err := n.bc.GetBlock(blockHash); err == nil {
+			n.relayBlock(blockBytes, "")
+			return
+		}
+	}
+	for _, peer := range n.ListPeers() {
+		if peer == n.address {
 			continue
 		}
-		payload := Inv{AddrFrom: fromAddr, Type: "block", Items: items}
-		sendData(peer, Message{Command: "inv", Payload: encodePayload(payload)})
+		n.sendInv(peer, "block", [][]byte{blockHash})
 	}
 }
+
+// BroadcastNewBlock announces blockHash to the default peers on behalf of the node at
+// nodeAddr(nodeID), e.g. after a block was mined offline into its DB.
+func BroadcastNewBlock(nodeID string, blockHash []byte) {
+	NewNode(nodeID, "", "", "").broadcastBlock(blockHash)
+}
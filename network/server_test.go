@@ -0,0 +1,175 @@
+package network
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDecodePayloadRejectsGarbage(t *testing.T) {
+	var v Version
+	if err := decodePayload([]byte("not a gob payload"), &v); !errors.Is(err, ErrMalformedPayload) {
+		t.Fatalf("got %v, want ErrMalformedPayload", err)
+	}
+}
+
+func TestMalformedPeerMessageCountsAgainstPeer(t *testing.T) {
+	n := NewNode("malformed", "", "", "")
+	const peer = "127.0.0.1:3999"
+	if !n.AddPeer(peer) {
+		t.Fatal("peer not added")
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ln.Close() }()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		n.handleConnection(conn)
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeMessage(conn, Message{Command: "version", Payload: []byte("garbage")}); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	_ = conn.Close()
+
+	for _, s := range n.peerStatuses() {
+		if s.Address == peer && s.Failures != 1 {
+			t.Fatalf("%s has %d failures, want 1", peer, s.Failures)
+		}
+		if s.Address != peer && s.Failures != 0 {
+			t.Fatalf("%s, on another host, has %d failures", s.Address, s.Failures)
+		}
+	}
+}
+
+func TestConnectionFloodStaysWithinLimit(t *testing.T) {
+	chdirTemp(t)
+	n := newTestNode(t)
+	fundedChain(t, n)
+	startNode(t, n)
+	waitFor(t, 5*time.Second, "the startup probe's handler to finish", func() bool {
+		return len(n.handlerSlots) == 0
+	})
+
+	// Idle connections hold a handler until their read deadline; the rest are dropped.
+	conns := make([]net.Conn, 2*maxConnections)
+	for i := range conns {
+		conn, err := net.Dial("tcp", n.address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = conn.Close() }()
+		conns[i] = conn
+	}
+	waitFor(t, 5*time.Second, "every handler slot to fill", func() bool {
+		return len(n.handlerSlots) == maxConnections
+	})
+
+	var held atomic.Int32
+	var wg sync.WaitGroup
+	for _, conn := range conns {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = conn.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+			var b [1]byte
+			_, err := conn.Read(b[:])
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				held.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := held.Load(); got != maxConnections {
+		t.Fatalf("%d of %d connections were handled, want %d", got, len(conns), maxConnections)
+	}
+
+	for _, conn := range conns {
+		_ = conn.Close()
+	}
+	waitFor(t, 5*time.Second, "the handlers to finish", func() bool {
+		return len(n.handlerSlots) == 0
+	})
+	if _, err := GetStatusRequest(n.id); err != nil {
+		t.Fatalf("request after the flood: %v", err)
+	}
+}
+
+func TestOversizedFrameRejectedBeforeReading(t *testing.T) {
+	chdirTemp(t)
+	n := newTestNode(t)
+	fundedChain(t, n)
+	startNode(t, n)
+
+	conn, err := net.Dial("tcp", n.address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	header := append(frameMagic[:], frameVersion)
+	header = binary.BigEndian.AppendUint32(header, maxMessageSize+1)
+	if _, err := conn.Write(header); err != nil {
+		t.Fatal(err)
+	}
+	// The node hangs up without waiting for the 4 MiB it was promised.
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var b [1]byte
+	if _, err := conn.Read(b[:]); !errors.Is(err, io.EOF) {
+		t.Fatalf("reading after an oversized header: got %v, want EOF", err)
+	}
+	if _, err := GetStatusRequest(n.id); err != nil {
+		t.Fatalf("request after the oversized frame: %v", err)
+	}
+}
+
+func TestGenesisConfirmationsEqualChainHeight(t *testing.T) {
+	chdirTemp(t)
+	n := newTestNode(t)
+	fundedChain(t, n)
+	extendChain(t, n, 4)
+	startNode(t, n)
+
+	blocks, _, err := GetChainRequest(n.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	height := n.bc.BestHeight()
+	if height != 5 || len(blocks) != height {
+		t.Fatalf("chain of %d blocks at height %d, want 5", len(blocks), height)
+	}
+	genesis := blocks[len(blocks)-1]
+	if len(genesis.PrevHash) != 0 || genesis.Confirmations != height {
+		t.Fatalf("genesis block has %d confirmations, want the chain height %d", genesis.Confirmations, height)
+	}
+	for i, b := range blocks {
+		if b.Confirmations != i+1 {
+			t.Errorf("block %d from the tip has %d confirmations, want %d", i, b.Confirmations, i+1)
+		}
+	}
+
+	coinbase, err := GetRawTxRequest(n.id, genesis.TxIDs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if coinbase.Confirmations != height {
+		t.Fatalf("genesis coinbase has %d confirmations, want %d", coinbase.Confirmations, height)
+	}
+}
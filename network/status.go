@@ -0,0 +1,81 @@
+package network
+
+import (
+	"net"
+
+	"my-blockchain/core"
+)
+
+// StatusRequest asks the node for a summary of its state.
+type StatusRequest struct {
+	AddrFrom string
+}
+
+// StatusResponse describes the node: its protocol version, chain tip, known peers (not
+// counting itself), their cap and health, and pending transactions.
+type StatusResponse struct {
+	OK              bool
+	Message         string
+	ProtocolVersion int
+	BestHeight      int
+	Tip             []byte
+	Peers           int
+	// MaxPeers is the node's cap on Peers (0 if none); PeersEvicted counts the peers
+	// dropped to respect it since the node started.
+	MaxPeers     int
+	PeersEvicted int
+	PeerHealth   []PeerStatus
+	Mempool      int
+	Miner        string
+	// ReorgAlert is the last reorg refused as deeper than core.MaxReorgDepth, if any.
+	ReorgAlert *core.ReorgAlert
+	Sync       SyncStatus
+}
+
+// GetStatusRequest asks the running node at nodeAddr(nodeID) for its status.
+func GetStatusRequest(nodeID string) (StatusResponse, error) {
+	addr := nodeAddr(nodeID)
+	payload := StatusRequest{AddrFrom: addr}
+	var res StatusResponse
+	if err := request(addr, "status", payload, "statusinfo", &res); err != nil {
+		return StatusResponse{}, err
+	}
+	if !res.OK {
+		return res, &RemoteError{Message: res.Message}
+	}
+	return res, nil
+}
+
+func (n *Node) handleStatus(conn net.Conn, payloadBytes []byte) error {
+	var payload StatusRequest
+	if err := decodePayload(payloadBytes, &payload); err != nil {
+		return err
+	}
+
+	return replyWith(conn, "statusinfo", n.status())
+}
+
+func (n *Node) status() StatusResponse {
+	status := StatusResponse{
+		OK:              true,
+		ProtocolVersion: protocolVersion,
+		BestHeight:      n.bc.BestHeight(),
+		Tip:             n.bc.Tip(),
+		Peers:           n.peerCount(),
+		MaxPeers:        n.MaxPeers,
+		PeersEvicted:    n.peersEvicted(),
+		PeerHealth:      n.peerStatuses(),
+		Mempool:         n.mempool.Len(),
+		Miner:           n.miner,
+		Sync:            n.syncStatus(),
+	}
+	if alert, ok := n.bc.LastReorgAlert(); ok {
+		status.ReorgAlert = &alert
+	}
+	return status
+}
+
+// peerCount returns how many peers are known, not counting the node itself.
+func (n *Node) peerCount() int {
+	return n.countPeers(n.ListPeers())
+}
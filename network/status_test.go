@@ -0,0 +1,49 @@
+package network
+
+import (
+	"bytes"
+	"testing"
+
+	"my-blockchain/core"
+	"my-blockchain/wallet"
+)
+
+func TestStatusHeightFollowsBlocksMined(t *testing.T) {
+	chdirTemp(t)
+	n := newTestNode(t)
+	from := fundedChain(t, n)
+	startNode(t, n)
+
+	status, err := GetStatusRequest(n.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.BestHeight != 1 || status.ProtocolVersion != protocolVersion {
+		t.Fatalf("new chain reports height %d and protocol %d, want 1 and %d", status.BestHeight, status.ProtocolVersion, protocolVersion)
+	}
+
+	if _, err := SendTxRequest(n.id, from, string(wallet.NewWallet().GetAddress()), 3, core.TxOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if status, err = GetStatusRequest(n.id); err != nil {
+		t.Fatal(err)
+	}
+	if status.Mempool != 1 {
+		t.Fatalf("status reports %d pending transactions, want 1", status.Mempool)
+	}
+
+	const mined = 3
+	hashes, err := GenerateRequest(n.id, mined, string(wallet.NewWallet().GetAddress()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, err = GetStatusRequest(n.id); err != nil {
+		t.Fatal(err)
+	}
+	if status.BestHeight != 1+mined || !bytes.Equal(status.Tip, hashes[len(hashes)-1]) {
+		t.Fatalf("status reports height %d at %x, want %d at the last mined block %x", status.BestHeight, status.Tip, 1+mined, hashes[len(hashes)-1])
+	}
+	if status.Mempool != 0 {
+		t.Fatalf("status reports %d pending transactions after mining, want 0", status.Mempool)
+	}
+}
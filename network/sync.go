@@ -0,0 +1,200 @@
+package network
+
+import (
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"my-blockchain/core"
+)
+
+// maxBlocksInFlight is how many blocks are requested from a peer at once, in one getdata,
+// and the most a peer serves per getdata.
+const maxBlocksInFlight = 16
+
+// batchTimeout is how long a requested batch may stay incomplete before the blocks still
+// missing from it are requested again.
+const batchTimeout = 30 * time.Second
+
+// GetHeaders asks a peer for the headers of its main chain after the last block Locator
+// (see core.Blockchain.BlockLocator) shares with it, or from genesis if none.
+type GetHeaders struct {
+	AddrFrom string
+	Locator  [][]byte
+}
+
+// Headers carries a peer's main-chain headers in chain order, from genesis or from the
+// block after the fork point our locator showed.
+type Headers struct {
+	AddrFrom string
+	Headers  []core.BlockHeader
+}
+
+// bodiesInFlight tracks block bodies queued for download after a validated headers
+// message, so repeated handshakes do not request the same block twice.
+type bodiesInFlight struct {
+	mu     sync.Mutex
+	hashes map[string]bool
+}
+
+// blocksInTransit tracks, per peer address, the blocks still to be requested from it and
+// the batch requested from it but not received yet. A peer is asked for the next batch
+// once the last has arrived, so K blocks take about K/maxBlocksInFlight round trips, and
+// several peers can be synced from at once without losing each other's blocks.
+type blocksInTransit struct {
+	mu    sync.Mutex
+	peers map[string]*transit
+}
+
+type transit struct {
+	queue     [][]byte
+	inFlight  map[string]bool
+	requested time.Time
+}
+
+// queueBlocks adds hashes to the blocks to fetch from peer and, unless a batch from it is
+// still arriving, requests the next one.
+func (n *Node) queueBlocks(peer string, hashes [][]byte) {
+	n.transit.mu.Lock()
+	t := n.transit.peers[peer]
+	if t == nil {
+		t = &transit{inFlight: make(map[string]bool)}
+		n.transit.peers[peer] = t
+	}
+	queued := make(map[string]bool, len(t.queue))
+	for _, h := range t.queue {
+		queued[hex.EncodeToString(h)] = true
+	}
+	for _, h := range hashes {
+		key := hex.EncodeToString(h)
+		if !queued[key] && !t.inFlight[key] {
+			t.queue = append(t.queue, h)
+			queued[key] = true
+		}
+	}
+	// A batch that stopped arriving (the peer lacked a block, or a delivery failed) is
+	// requested again rather than blocking the queue.
+	if len(t.inFlight) > 0 && time.Since(t.requested) > batchTimeout {
+		var lost [][]byte
+		for key := range t.inFlight {
+			if h, err := hex.DecodeString(key); err == nil {
+				lost = append(lost, h)
+			}
+		}
+		t.queue = append(lost, t.queue...)
+		t.inFlight = make(map[string]bool)
+	}
+	batch := t.nextBatch()
+	n.transit.mu.Unlock()
+
+	if len(batch) > 0 {
+		n.sendGetDataBlocks(peer, batch)
+	}
+}
+
+// blockArrived records that peer sent the block hash and, if that completes its batch,
+// requests the next. It reports whether more blocks are still expected from peer.
+func (n *Node) blockArrived(peer string, hash []byte) bool {
+	n.transit.mu.Lock()
+	t := n.transit.peers[peer]
+	if t == nil {
+		n.transit.mu.Unlock()
+		return false
+	}
+	delete(t.inFlight, hex.EncodeToString(hash))
+	batch := t.nextBatch()
+	expecting := len(t.inFlight) > 0
+	if !expecting {
+		delete(n.transit.peers, peer)
+	}
+	n.transit.mu.Unlock()
+
+	if len(batch) > 0 {
+		n.sendGetDataBlocks(peer, batch)
+	}
+	return expecting
+}
+
+// nextBatch moves up to maxBlocksInFlight queued blocks in flight and returns them, or nil
+// while a batch is still arriving. The caller holds the blocksInTransit lock.
+func (t *transit) nextBatch() [][]byte {
+	if len(t.inFlight) > 0 || len(t.queue) == 0 {
+		return nil
+	}
+	n := min(len(t.queue), maxBlocksInFlight)
+	batch := t.queue[:n:n]
+	t.queue = t.queue[n:]
+	for _, h := range batch {
+		t.inFlight[hex.EncodeToString(h)] = true
+	}
+	t.requested = time.Now()
+	return batch
+}
+
+func (n *Node) sendGetHeaders(addr string) {
+	payload := GetHeaders{AddrFrom: n.address, Locator: n.bc.BlockLocator()}
+	n.sendPayload(addr, "getheaders", payload)
+}
+
+func (n *Node) sendHeaders(addr string, headers []core.BlockHeader) {
+	payload := Headers{AddrFrom: n.address, Headers: headers}
+	n.sendPayload(addr, "headers", payload)
+}
+
+func (n *Node) handleGetHeaders(payloadBytes []byte) error {
+	var payload GetHeaders
+	if err := decodePayload(payloadBytes, &payload); err != nil {
+		return err
+	}
+
+	n.sendHeaders(payload.AddrFrom, n.bc.HeadersAfter(n.bc.LocateFork(payload.Locator)))
+	return nil
+}
+
+// handleHeaders validates the advertised header chain, then fetches the bodies we are
+// missing in batches. Bodies may arrive out of order; PutBlock holds them as orphans until
+// their parent is stored.
+func (n *Node) handleHeaders(payloadBytes []byte) error {
+	var payload Headers
+	if err := decodePayload(payloadBytes, &payload); err != nil {
+		return err
+	}
+
+	// Headers sent after a fork point must start on a block we have.
+	var parent []byte
+	if len(payload.Headers) > 0 && n.bc.HasBlock(payload.Headers[0].PrevBlockHash) {
+		parent = payload.Headers[0].PrevBlockHash
+	}
+	if err := core.CheckHeaderChain(payload.Headers, parent); err != nil {
+		n.logger.Warn("rejected headers", "from", payload.AddrFrom, "err", err)
+		return nil
+	}
+	if len(payload.Headers) > 0 && parent == nil && !compatibleGenesis(n.bc, payload.Headers[0].Hash) {
+		n.logger.Warn("rejected headers with another genesis", "from", payload.AddrFrom, "genesis", hex.EncodeToString(payload.Headers[0].Hash))
+		return nil
+	}
+
+	var missing [][]byte
+	n.bodies.mu.Lock()
+	for _, h := range payload.Headers {
+		key := hex.EncodeToString(h.Hash)
+		if n.bodies.hashes[key] || n.bc.HasBlock(h.Hash) {
+			continue
+		}
+		n.bodies.hashes[key] = true
+		missing = append(missing, h.Hash)
+	}
+	n.bodies.mu.Unlock()
+
+	n.queueBlocks(payload.AddrFrom, missing)
+	return nil
+}
+
+// finishBody marks a requested body as received and reports how many are still outstanding.
+func (n *Node) finishBody(hash []byte) int {
+	n.bodies.mu.Lock()
+	defer n.bodies.mu.Unlock()
+
+	delete(n.bodies.hashes, hex.EncodeToString(hash))
+	return len(n.bodies.hashes)
+}
@@ -0,0 +1,189 @@
+package network
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"my-blockchain/core"
+)
+
+// lockedBuffer is a bytes.Buffer a node's goroutines can log to while a test reads it.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestNoBlocksExchangedAcrossGenesisHashes(t *testing.T) {
+	chdirTemp(t)
+	a := newTestNode(t)
+	fundedChain(t, a)
+	// B runs its own chain; C, starting from A's genesis, shows that A does serve its blocks.
+	b := newTestNode(t, a.address)
+	fundedChain(t, b)
+	c := newTestNode(t, a.address)
+	copyChain(t, a, c)
+	extendChain(t, a, 3)
+	startNode(t, a)
+	startNode(t, b)
+	startNode(t, c)
+
+	waitFor(t, 5*time.Second, "C to sync A's blocks", func() bool {
+		return c.bc.BestHeight() == 4
+	})
+	if got := b.bc.BestHeight(); got != 1 {
+		t.Fatalf("B on another genesis synced to height %d", got)
+	}
+	if bytes.Equal(b.bc.GenesisHash(), a.bc.GenesisHash()) {
+		t.Fatal("B adopted A's genesis")
+	}
+	if got := a.bc.BestHeight(); got != 4 {
+		t.Fatalf("A changed height to %d", got)
+	}
+	if containsPeer(a.ListPeers(), b.address) {
+		t.Fatal("A took B, on another genesis, as a peer")
+	}
+}
+
+func TestSyncFromTwoPeersAtOnce(t *testing.T) {
+	chdirTemp(t)
+	a := newTestNode(t)
+	fundedChain(t, a)
+	b := newTestNode(t)
+	c := newTestNode(t, a.address, b.address)
+	copyChain(t, a, c)
+	// B's chain extends A's, so C downloads the shared blocks from both at the same time.
+	extendChain(t, a, 9)
+	copyChain(t, a, b)
+	extendChain(t, b, 10)
+	startNode(t, a)
+	startNode(t, b)
+	startNode(t, c)
+
+	waitFor(t, 10*time.Second, "C to sync B's 20 blocks", func() bool {
+		return c.bc.BestHeight() == 20
+	})
+	for height := 1; height <= 20; height++ {
+		want, err := b.bc.GetBlockHash(height)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := c.bc.GetBlockHash(height)
+		if err != nil {
+			t.Fatalf("C has no block at height %d: %v", height, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("C has %x at height %d, want %x", got, height, want)
+		}
+	}
+}
+
+func TestSyncBatchesBlockRequests(t *testing.T) {
+	chdirTemp(t)
+	a := newTestNode(t)
+	fundedChain(t, a)
+	c := newTestNode(t, a.address)
+	copyChain(t, a, c)
+	extendChain(t, a, 30)
+	// A logs every message it receives, so the getdata round trips can be counted.
+	var received lockedBuffer
+	a.logger = core.NewLogger(&received, slog.LevelDebug)
+	startNode(t, a)
+	startNode(t, c)
+
+	waitFor(t, 10*time.Second, "C to sync A's 30 new blocks", func() bool {
+		return c.bc.BestHeight() == 31
+	})
+	// One block per request took 30 round trips; batches need ceil(30/maxBlocksInFlight).
+	want := (30 + maxBlocksInFlight - 1) / maxBlocksInFlight
+	if got := strings.Count(received.String(), "command=getdata"); got != want {
+		t.Fatalf("C sent %d getdata requests for 30 blocks, want %d", got, want)
+	}
+}
+
+func TestDivergedNodeFetchesOnlyBlocksAfterFork(t *testing.T) {
+	chdirTemp(t)
+	a := newTestNode(t)
+	fundedChain(t, a)
+	extendChain(t, a, 4)
+	b := newTestNode(t, a.address)
+	copyChain(t, a, b)
+	// The chains share blocks 1 to 5; A's branch after them is longer.
+	extendChain(t, a, 3)
+	extendChain(t, b, 2)
+	var received lockedBuffer
+	b.logger = core.NewLogger(&received, slog.LevelDebug)
+	startNode(t, a)
+	startNode(t, b)
+
+	aStatus, err := GetStatusRequest(a.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Ask over the network rather than read b.bc, which B's Start sets concurrently.
+	waitFor(t, 10*time.Second, "B to switch to A's branch", func() bool {
+		bStatus, err := GetStatusRequest(b.id)
+		return err == nil && bStatus.BestHeight == 8 && bytes.Equal(bStatus.Tip, aStatus.Tip)
+	})
+	if got := strings.Count(received.String(), "command=block "); got != 3 {
+		t.Fatalf("B received %d blocks, want the 3 after the fork", got)
+	}
+}
+
+func TestHeadersFirstSyncOfFiftyBlocks(t *testing.T) {
+	chdirTemp(t)
+	a := newTestNode(t)
+	fundedChain(t, a)
+	c := newTestNode(t, a.address)
+	copyChain(t, a, c)
+	extendChain(t, a, 49)
+	var received lockedBuffer
+	a.logger = core.NewLogger(&received, slog.LevelDebug)
+	startNode(t, a)
+	startNode(t, c)
+
+	aStatus, err := GetStatusRequest(a.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, 10*time.Second, "C to sync A's 50-block chain", func() bool {
+		cStatus, err := GetStatusRequest(c.id)
+		return err == nil && cStatus.BestHeight == 50 && bytes.Equal(cStatus.Tip, aStatus.Tip)
+	})
+	for height := 1; height <= 50; height++ {
+		want, err := a.bc.GetBlockHash(height)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := c.bc.GetBlockHash(height)
+		if err != nil {
+			t.Fatalf("C has no block at height %d: %v", height, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("C has %x at height %d, want %x", got, height, want)
+		}
+	}
+
+	// One getheaders covers the whole chain, and the 49 bodies come in batches rather than
+	// the 49 round trips of fetching one block per request.
+	log := received.String()
+	headers, batches := strings.Count(log, "command=getheaders"), strings.Count(log, "command=getdata")
+	if want := (49 + maxBlocksInFlight - 1) / maxBlocksInFlight; headers != 1 || batches != want {
+		t.Fatalf("C sent %d getheaders and %d getdata requests, want 1 and %d", headers, batches, want)
+	}
+}
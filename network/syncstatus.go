@@ -0,0 +1,50 @@
+package network
+
+import "my-blockchain/core"
+
+// SyncStatus is how far the node's chain is from the best height its peers announced.
+type SyncStatus struct {
+	Height int
+	// Target is the best height a peer announced in its version message, or the node's
+	// own height if that is higher.
+	Target  int
+	Percent float64
+	Syncing bool
+}
+
+// newSyncStatus describes a chain at height catching up to target.
+func newSyncStatus(height, target int) SyncStatus {
+	if target < height {
+		target = height
+	}
+	s := SyncStatus{Height: height, Target: target, Percent: 100, Syncing: height < target}
+	if target > 0 {
+		s.Percent = float64(height) * 100 / float64(target)
+	}
+	return s
+}
+
+// syncStatus reports the node's progress towards the best height its peers announced.
+func (n *Node) syncStatus() SyncStatus {
+	return newSyncStatus(n.bc.BestHeight(), int(n.syncTarget.Load()))
+}
+
+// raiseSyncTarget records a peer's announced best height if it beats the current target.
+func (n *Node) raiseSyncTarget(height int) {
+	for {
+		cur := n.syncTarget.Load()
+		if int64(height) <= cur || n.syncTarget.CompareAndSwap(cur, int64(height)) {
+			return
+		}
+	}
+}
+
+// publishSyncProgress announces the node's progress on its event bus after a block from a
+// peer was stored, while it is catching up and once more on reaching the target.
+func (n *Node) publishSyncProgress(wasSyncing bool) {
+	s := n.syncStatus()
+	if !wasSyncing && !s.Syncing {
+		return
+	}
+	n.events.Publish(core.Event{Type: core.EventSync, Hash: n.bc.Tip(), Height: s.Height, Target: s.Target})
+}
@@ -0,0 +1,66 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	"my-blockchain/core"
+)
+
+func TestNewSyncStatus(t *testing.T) {
+	tests := []struct {
+		height, target int
+		want           SyncStatus
+	}{
+		{0, 0, SyncStatus{Percent: 100}},
+		{5, 20, SyncStatus{Height: 5, Target: 20, Percent: 25, Syncing: true}},
+		{20, 20, SyncStatus{Height: 20, Target: 20, Percent: 100}},
+		{30, 20, SyncStatus{Height: 30, Target: 30, Percent: 100}},
+	}
+	for _, tt := range tests {
+		if got := newSyncStatus(tt.height, tt.target); got != tt.want {
+			t.Errorf("newSyncStatus(%d, %d) = %+v, want %+v", tt.height, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestSyncProgressAdvancesToComplete(t *testing.T) {
+	chdirTemp(t)
+	a := newTestNode(t)
+	fundedChain(t, a)
+	c := newTestNode(t, a.address)
+	copyChain(t, a, c)
+	extendChain(t, a, 20)
+	events, unsubscribe := c.events.Subscribe(core.EventSync)
+	defer unsubscribe()
+	startNode(t, a)
+	startNode(t, c)
+
+	var last SyncStatus
+	for last.Height < 21 {
+		select {
+		case e := <-events:
+			s := newSyncStatus(e.Height, e.Target)
+			if s.Target != 21 {
+				t.Fatalf("sync target %d, want A's height 21", s.Target)
+			}
+			if s.Height < last.Height || s.Percent < last.Percent {
+				t.Fatalf("progress went back from %+v to %+v", last, s)
+			}
+			last = s
+		case <-time.After(10 * time.Second):
+			t.Fatalf("no sync event after %+v", last)
+		}
+	}
+	if last.Percent != 100 || last.Syncing {
+		t.Fatalf("final progress %+v, want 100%% and done", last)
+	}
+
+	status, err := GetStatusRequest(c.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Sync != (SyncStatus{Height: 21, Target: 21, Percent: 100}) {
+		t.Fatalf("status reports %+v once synced", status.Sync)
+	}
+}
@@ -0,0 +1,61 @@
+package network
+
+import (
+	"net"
+
+	"my-blockchain/core"
+)
+
+// FundingResponse answers a testsend request with how the send would be paid for.
+type FundingResponse struct {
+	OK      bool
+	Message string
+	Code    string
+	Funding *core.Funding
+}
+
+// TestSendRequest asks the running node at nodeAddr(nodeID) how it would fund a send from
+// from paying outputs, without building, queueing or relaying the transaction. An
+// unfundable send fails with a RemoteError whose Code is "insufficient_funds".
+func TestSendRequest(nodeID string, from string, outputs map[string]int, opts core.TxOptions) (*core.Funding, error) {
+	addr := nodeAddr(nodeID)
+	payload := TxRequest{AddrFrom: addr, From: from, Outputs: outputs, Fee: opts.Fee, LockTime: opts.LockTime, Replaceable: opts.Replaceable, Data: opts.Data, CoinSelection: string(opts.CoinSelection)}
+	var res FundingResponse
+	if err := request(addr, "testsend", payload, "funding", &res); err != nil {
+		return nil, err
+	}
+	if !res.OK {
+		return nil, &RemoteError{Message: res.Message, Code: res.Code}
+	}
+	return res.Funding, nil
+}
+
+func (n *Node) handleTestSend(conn net.Conn, payloadBytes []byte) error {
+	var payload TxRequest
+	if err := decodePayload(payloadBytes, &payload); err != nil {
+		return err
+	}
+
+	res := FundingResponse{OK: true}
+	funding, err := n.testSend(payload.From, payload.payments(), payload.options())
+	if err != nil {
+		res = FundingResponse{OK: false, Message: err.Error(), Code: errorCode(err)}
+	} else {
+		res.Funding = funding
+	}
+	return replyWith(conn, "funding", res)
+}
+
+// testSend is submitTxMulti as a dry run: it checks the request and the node's wallets
+// and selects the inputs the send would spend, taking the mempool into account, but
+// signs and relays nothing.
+func (n *Node) testSend(from string, outputs map[string]int, opts core.TxOptions) (*core.Funding, error) {
+	if err := checkSendRequest(from, outputs, opts); err != nil {
+		return nil, err
+	}
+	ws, err := n.loadWallets()
+	if err != nil {
+		return nil, err
+	}
+	return core.FundTransaction(from, outputs, opts, n.bc, ws, n.mempool)
+}
@@ -0,0 +1,63 @@
+package network
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"my-blockchain/core"
+	"my-blockchain/wallet"
+)
+
+func TestTestSendReportsFundingWithoutSending(t *testing.T) {
+	chdirTemp(t)
+	n := newTestNode(t)
+	from := fundedChain(t, n)
+	startNode(t, n)
+	to := string(wallet.NewWallet().GetAddress())
+	unspent, err := ListUnspentRequest(n.id, from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unspent) != 1 {
+		t.Fatalf("%d unspent outputs, want the genesis coinbase", len(unspent))
+	}
+	genesis := unspent[0]
+
+	// A remainder of 1 is dust, so it goes to the fee instead of to change.
+	for _, tc := range []struct {
+		amount, change, fee int
+	}{
+		{amount: 4, change: 5, fee: 1},
+		{amount: 8, change: 0, fee: 2},
+	} {
+		funding, err := TestSendRequest(n.id, from, map[string]int{to: tc.amount}, core.TxOptions{Fee: 1})
+		if err != nil {
+			t.Fatalf("sending %d: %v", tc.amount, err)
+		}
+		if len(funding.Inputs) != 1 || !bytes.Equal(funding.Inputs[0].TxID, genesis.TxID) || funding.Inputs[0].Vout != genesis.Vout || funding.Inputs[0].Value != 10 {
+			t.Fatalf("sending %d spends %+v, want the genesis coinbase", tc.amount, funding.Inputs)
+		}
+		if funding.InputValue != 10 || funding.Amount != tc.amount || funding.Change != tc.change || funding.Fee != tc.fee {
+			t.Fatalf("sending %d: got %+v, want change %d and fee %d", tc.amount, funding, tc.change, tc.fee)
+		}
+	}
+
+	_, err = TestSendRequest(n.id, from, map[string]int{to: 10}, core.TxOptions{Fee: 1})
+	var remote *RemoteError
+	if !errors.As(err, &remote) || remote.Code != "insufficient_funds" {
+		t.Fatalf("sending 10 with a fee of 1 from 10: got %v, want insufficient_funds", err)
+	}
+
+	info, err := GetMempoolRequest(n.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err := GetStatusRequest(n.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Txs) != 0 || status.BestHeight != 1 {
+		t.Fatalf("testsend left %d pending transactions and height %d", len(info.Txs), status.BestHeight)
+	}
+}
@@ -0,0 +1,154 @@
+package network
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"my-blockchain/core"
+	"my-blockchain/wallet"
+)
+
+func TestTransactionReachesPeerMempoolBeforeMining(t *testing.T) {
+	chdirTemp(t)
+	a := newTestNode(t)
+	from := fundedChain(t, a)
+	b := newTestNode(t, a.address)
+	copyChain(t, a, b)
+	startNode(t, a)
+	startNode(t, b)
+	waitFor(t, 5*time.Second, "A to learn about B", func() bool {
+		return containsPeer(a.ListPeers(), b.address)
+	})
+
+	to := string(wallet.NewWallet().GetAddress())
+	if _, err := SendTxRequest(a.id, from, to, 5, core.TxOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	pending := a.mempool.Pending()
+	if len(pending) != 1 {
+		t.Fatalf("A holds %d pending transactions, want 1", len(pending))
+	}
+	id := pending[0].ID
+	waitFor(t, 5*time.Second, "the transaction in B's mempool", func() bool {
+		return b.mempool.Has(id)
+	})
+	if got := b.bc.BestHeight(); got != 1 {
+		t.Fatalf("B is at height %d, want only the genesis block", got)
+	}
+}
+
+func TestTransactionRelaysOnceAmongThreeConnectedNodes(t *testing.T) {
+	chdirTemp(t)
+	a := newTestNode(t)
+	from := fundedChain(t, a)
+	b := newTestNode(t, a.address)
+	copyChain(t, a, b)
+	c := newTestNode(t, a.address)
+	copyChain(t, a, c)
+	nodes := []*Node{a, b, c}
+	logs := make([]*lockedBuffer, len(nodes))
+	for i, n := range nodes {
+		logs[i] = &lockedBuffer{}
+		n.logger = core.NewLogger(logs[i], slog.LevelDebug)
+	}
+	// C learns of B from A, and introduces itself to B, once A knows B.
+	startNode(t, a)
+	startNode(t, b)
+	waitFor(t, 5*time.Second, "A to learn B", func() bool {
+		return containsPeer(a.ListPeers(), b.address)
+	})
+	startNode(t, c)
+	waitFor(t, 5*time.Second, "every node to learn the other two", func() bool {
+		for _, n := range nodes {
+			for _, other := range nodes {
+				if other != n && !containsPeer(n.ListPeers(), other.address) {
+					return false
+				}
+			}
+		}
+		return true
+	})
+	starts := make([]int, len(nodes))
+	for i := range nodes {
+		starts[i] = len(logs[i].String())
+	}
+	// received counts the messages of command each node got since the send.
+	received := func(command string) []int {
+		counts := make([]int, len(nodes))
+		for i := range nodes {
+			counts[i] = strings.Count(logs[i].String()[starts[i]:], "msg=\"received message\" command="+command+" ")
+		}
+		return counts
+	}
+
+	to := string(wallet.NewWallet().GetAddress())
+	if _, err := SendTxRequest(a.id, from, to, 5, core.TxOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	id := a.mempool.Pending()[0].ID
+	waitFor(t, 5*time.Second, "the transaction in B's and C's mempools", func() bool {
+		return b.mempool.Has(id) && c.mempool.Has(id)
+	})
+	// Give any repeated relaying time to show.
+	time.Sleep(500 * time.Millisecond)
+
+	// A announces to both peers and B and C each pass it on once, to all but their source.
+	invs := received("inv")
+	if total := invs[0] + invs[1] + invs[2]; total != 4 {
+		t.Fatalf("nodes received %v invs, %d in all, want 4", invs, total)
+	}
+	// Each node fetches the body at most once from each peer announcing it.
+	txs := received("tx")
+	if txs[0] != 0 || txs[1] < 1 || txs[1] > 2 || txs[2] < 1 || txs[2] > 2 {
+		t.Fatalf("nodes received the transaction %v times", txs)
+	}
+	for _, n := range nodes {
+		if got := n.mempool.Len(); got != 1 {
+			t.Errorf("node %s holds %d transactions, want 1", n.id, got)
+		}
+	}
+}
+
+func TestTransactionWithWrongIDDoesNotShutOutTheReal(t *testing.T) {
+	chdirTemp(t)
+	n := newTestNode(t)
+	from := fundedChain(t, n)
+	bc, err := core.OpenBlockchainForNode(n.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = bc.Close() })
+	n.bc = bc
+	ws, err := wallet.NewWalletsAt(n.WalletFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := core.NewUTXOTransaction(from, string(wallet.NewWallet().GetAddress()), 5, bc, ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// relay hands n the transaction as a peer would.
+	relay := func(tx *core.Transaction) {
+		t.Helper()
+		payload, err := encodePayload(TxData{AddrFrom: "127.0.0.1:3999", Transaction: tx.Serialize()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := n.handleTx(payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	junk := *tx
+	junk.Vout = []core.TxOutput{*core.NewTxOutput(tx.OutputValue(), from)}
+	relay(&junk)
+	if n.mempool.Has(tx.ID) || n.seenTxs.has(tx.ID) {
+		t.Fatal("a body not matching its ID was taken as the transaction")
+	}
+	relay(tx)
+	if !n.mempool.Has(tx.ID) {
+		t.Fatal("the genuine transaction was dropped after junk claiming its ID")
+	}
+}
@@ -0,0 +1,51 @@
+package network
+
+import (
+	"net"
+
+	"my-blockchain/core"
+	"my-blockchain/wallet"
+)
+
+// UnspentRequest asks the node for every unspent output locked to Address.
+type UnspentRequest struct {
+	AddrFrom string
+	Address  string
+}
+
+// UnspentResponse lists an address's unspent outputs, ordered by outpoint.
+type UnspentResponse struct {
+	OK      bool
+	Message string
+	Code    string
+	Outputs []core.UnspentOutput
+}
+
+// ListUnspentRequest asks the running node at nodeAddr(nodeID) for an address's unspent outputs.
+func ListUnspentRequest(nodeID string, address string) ([]core.UnspentOutput, error) {
+	addr := nodeAddr(nodeID)
+	payload := UnspentRequest{AddrFrom: addr, Address: address}
+	var res UnspentResponse
+	if err := request(addr, "listunspent", payload, "unspent", &res); err != nil {
+		return nil, err
+	}
+	if !res.OK {
+		return nil, &RemoteError{Message: res.Message, Code: res.Code}
+	}
+	return res.Outputs, nil
+}
+
+func handleListUnspent(conn net.Conn, payloadBytes []byte, bc *core.Blockchain) error {
+	var payload UnspentRequest
+	if err := decodePayload(payloadBytes, &payload); err != nil {
+		return err
+	}
+
+	res := UnspentResponse{OK: true}
+	if !wallet.ValidateAddress(payload.Address) {
+		res = UnspentResponse{OK: false, Message: "invalid address", Code: errorCode(ErrInvalidAddress)}
+	} else {
+		res.Outputs = bc.ListUTXOs(wallet.PubKeyHashFromAddress(payload.Address))
+	}
+	return replyWith(conn, "unspent", res)
+}
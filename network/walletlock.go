@@ -0,0 +1,160 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"my-blockchain/wallet"
+)
+
+// UnlockRequest asks the node to unlock its encrypted wallet file for Timeout seconds.
+type UnlockRequest struct {
+	AddrFrom   string
+	Passphrase string
+	Timeout    int
+}
+
+// LockRequest asks the node to lock its wallet file again at once.
+type LockRequest struct {
+	AddrFrom string
+}
+
+// walletUnlock holds the key of the node's encrypted wallet file while walletpassphrase
+// has it unlocked. The passphrase itself is never kept.
+type walletUnlock struct {
+	mu    sync.Mutex
+	key   []byte
+	until time.Time
+	timer *time.Timer
+	// gen tells a relock timer whether a later unlock or lock has replaced it.
+	gen uint64
+}
+
+// WalletPassphraseRequest asks the running node at nodeAddr(nodeID) to unlock its wallet
+// file with passphrase for timeout seconds, so it can sign sends.
+func WalletPassphraseRequest(nodeID string, passphrase string, timeout int) (string, error) {
+	addr := nodeAddr(nodeID)
+	payload := UnlockRequest{AddrFrom: addr, Passphrase: passphrase, Timeout: timeout}
+	return sendResultRequest(addr, "walletpassphrase", payload)
+}
+
+// WalletLockRequest asks the running node at nodeAddr(nodeID) to lock its wallet file.
+func WalletLockRequest(nodeID string) (string, error) {
+	addr := nodeAddr(nodeID)
+	return sendResultRequest(addr, "walletlock", LockRequest{AddrFrom: addr})
+}
+
+// sendResultRequest sends a command message carrying payload and returns the message of
+// the Result it is answered with.
+func sendResultRequest(addr string, command string, payload any) (string, error) {
+	var res Result
+	if err := request(addr, command, payload, "result", &res); err != nil {
+		return "", err
+	}
+	if !res.OK {
+		return "", &RemoteError{Message: res.Message, Code: res.Code}
+	}
+	return res.Message, nil
+}
+
+func (n *Node) handleWalletPassphrase(conn net.Conn, payloadBytes []byte) error {
+	var payload UnlockRequest
+	if err := decodePayload(payloadBytes, &payload); err != nil {
+		return err
+	}
+
+	res := Result{OK: true}
+	timeout := time.Duration(payload.Timeout) * time.Second
+	if err := n.unlockWallet(payload.Passphrase, timeout); err != nil {
+		res = Result{OK: false, Message: err.Error(), Code: errorCode(err)}
+	} else {
+		res.Message = fmt.Sprintf("Wallet unlocked for %s.", timeout)
+	}
+	return replyWith(conn, "result", res)
+}
+
+func (n *Node) handleWalletLock(conn net.Conn, payloadBytes []byte) error {
+	var payload LockRequest
+	if err := decodePayload(payloadBytes, &payload); err != nil {
+		return err
+	}
+
+	n.lockWallet()
+	return replyWith(conn, "result", Result{OK: true, Message: "Wallet locked."})
+}
+
+// unlockWallet checks passphrase against the node's wallet file and keeps its key for
+// timeout, replacing any earlier unlock.
+func (n *Node) unlockWallet(passphrase string, timeout time.Duration) error {
+	if timeout <= 0 {
+		return fmt.Errorf("%w: timeout must be > 0", ErrBadRequest)
+	}
+	ws, err := wallet.NewWalletsAt(n.WalletFile)
+	if err != nil {
+		return fmt.Errorf("failed to load wallets: %w", err)
+	}
+	key, err := ws.DeriveKey(passphrase)
+	if err != nil {
+		return err
+	}
+
+	u := &n.unlock
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.timer != nil {
+		u.timer.Stop()
+	}
+	u.gen++
+	gen := u.gen
+	u.key, u.until = key, time.Now().Add(timeout)
+	u.timer = time.AfterFunc(timeout, func() {
+		u.mu.Lock()
+		defer u.mu.Unlock()
+		if u.gen == gen {
+			u.clear()
+			n.logger.Info("wallet relocked after timeout")
+		}
+	})
+	return nil
+}
+
+// lockWallet forgets the wallet key at once.
+func (n *Node) lockWallet() {
+	u := &n.unlock
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.timer != nil {
+		u.timer.Stop()
+	}
+	u.gen++
+	u.clear()
+}
+
+// clear forgets the key; the caller holds mu.
+func (u *walletUnlock) clear() {
+	clear(u.key)
+	u.key, u.until, u.timer = nil, time.Time{}, nil
+}
+
+// loadWallets loads the node's wallet file, unlocked if it is encrypted and
+// walletpassphrase unlocked it. Locked wallets fail to sign with wallet.ErrWalletLocked.
+func (n *Node) loadWallets() (*wallet.Wallets, error) {
+	ws, err := wallet.NewWalletsAt(n.WalletFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load wallets: %w", err)
+	}
+	if !ws.IsEncrypted() {
+		return ws, nil
+	}
+	u := &n.unlock
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.key != nil && time.Now().Before(u.until) {
+		if err := ws.UnlockWithKey(u.key); err != nil {
+			return nil, err
+		}
+	}
+	return ws, nil
+}
@@ -0,0 +1,91 @@
+package network
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"my-blockchain/core"
+	"my-blockchain/wallet"
+)
+
+// lockedWalletNode returns a node whose encrypted wallet file holds from, which the
+// genesis block of its regtest chain pays, and to.
+func lockedWalletNode(t *testing.T) (n *Node, from, to string) {
+	t.Helper()
+	dir := chdirTemp(t)
+	walletFile := filepath.Join(dir, "wallets.dat")
+	ws, err := wallet.NewWalletsAt(walletFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from, err = ws.CreateWallet(); err != nil {
+		t.Fatal(err)
+	}
+	if to, err = ws.CreateWallet(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.Encrypt("secret"); err != nil {
+		t.Fatal(err)
+	}
+	bc, err := core.CreateBlockchainForNode(from, "walletlock", core.RegtestConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = bc.Close() })
+
+	n = NewNode("walletlock", "", "", "")
+	n.WalletFile = walletFile
+	n.bc = bc
+	return n, from, to
+}
+
+func TestSendWhileWalletLocked(t *testing.T) {
+	n, from, to := lockedWalletNode(t)
+	if _, err := n.submitTx(from, to, 5, core.TxOptions{}); !errors.Is(err, wallet.ErrWalletLocked) {
+		t.Fatalf("send while locked: got %v, want ErrWalletLocked", err)
+	}
+	if err := n.unlockWallet("wrong", time.Minute); !errors.Is(err, wallet.ErrWrongPassphrase) {
+		t.Fatalf("unlock with a wrong passphrase: got %v, want ErrWrongPassphrase", err)
+	}
+	if _, err := n.submitTx(from, to, 5, core.TxOptions{}); !errors.Is(err, wallet.ErrWalletLocked) {
+		t.Fatalf("send after a failed unlock: got %v, want ErrWalletLocked", err)
+	}
+}
+
+func TestUnlockThenSign(t *testing.T) {
+	n, from, to := lockedWalletNode(t)
+	if err := n.unlockWallet("secret", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	tx, err := n.submitTx(from, to, 5, core.TxOptions{})
+	if err != nil {
+		t.Fatalf("send while unlocked: %v", err)
+	}
+	if !n.mempool.Has(tx.ID) {
+		t.Fatal("transaction signed while unlocked is not in the mempool")
+	}
+	if err := n.bc.VerifyTransaction(tx); err != nil {
+		t.Fatalf("transaction signed while unlocked does not verify: %v", err)
+	}
+
+	n.lockWallet()
+	if _, err := n.submitTx(from, to, 5, core.TxOptions{}); !errors.Is(err, wallet.ErrWalletLocked) {
+		t.Fatalf("send after walletlock: got %v, want ErrWalletLocked", err)
+	}
+}
+
+func TestWalletRelocksAfterTimeout(t *testing.T) {
+	n, from, to := lockedWalletNode(t)
+	if err := n.unlockWallet("secret", 200*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if ws, err := n.loadWallets(); err != nil || ws.Locked() {
+		t.Fatalf("wallets locked right after unlock (err %v)", err)
+	}
+	time.Sleep(400 * time.Millisecond)
+	if _, err := n.submitTx(from, to, 5, core.TxOptions{}); !errors.Is(err, wallet.ErrWalletLocked) {
+		t.Fatalf("send after the timeout: got %v, want ErrWalletLocked", err)
+	}
+}
@@ -0,0 +1,214 @@
+package network
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"my-blockchain/core"
+)
+
+// A minimal RFC 6455 server: enough to push JSON text messages and read small client
+// messages (subscriptions, ping, close). Fragmented client messages are not supported.
+
+const (
+	wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	// wsMaxPayload bounds client messages; subscriptions are tiny.
+	wsMaxPayload = 4096
+
+	wsOpText  = 0x1
+	wsOpClose = 0x8
+	wsOpPing  = 0x9
+	wsOpPong  = 0xA
+)
+
+// wsSubscribe is the client message selecting event types, e.g. {"subscribe": ["block", "tx"]}.
+// An empty list subscribes to every type.
+type wsSubscribe struct {
+	Subscribe []string `json:"subscribe"`
+}
+
+// wsEvent is the JSON pushed to clients for each core.Event.
+type wsEvent struct {
+	Type   string `json:"type"`
+	Hash   string `json:"hash"`
+	Height int    `json:"height,omitempty"`
+	Target int    `json:"target,omitempty"`
+}
+
+type wsConn struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+	mu   sync.Mutex // serializes frame writes
+}
+
+// handleWebSocket upgrades the request and streams events from bus until the client
+// disconnects. Nothing is sent until the client's first subscribe message; a later one
+// replaces the subscription.
+func handleWebSocket(bus *core.EventBus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgradeWebSocket(w, r)
+		if err != nil {
+			return
+		}
+		defer func() { _ = ws.conn.Close() }()
+
+		subs, done := make(chan []string), make(chan struct{})
+		defer close(done)
+		go func() {
+			defer close(subs)
+			for {
+				op, payload, err := ws.readFrame()
+				if err != nil {
+					return
+				}
+				switch op {
+				case wsOpText:
+					var msg wsSubscribe
+					if json.Unmarshal(payload, &msg) != nil {
+						continue
+					}
+					select {
+					case subs <- msg.Subscribe:
+					case <-done:
+						return
+					}
+				case wsOpPing:
+					_ = ws.writeFrame(wsOpPong, payload)
+				case wsOpClose:
+					_ = ws.writeFrame(wsOpClose, nil)
+					return
+				}
+			}
+		}()
+
+		var events <-chan core.Event
+		cancel := func() {}
+		defer func() { cancel() }()
+		for {
+			select {
+			case types, ok := <-subs:
+				if !ok {
+					return
+				}
+				cancel()
+				events, cancel = bus.Subscribe(types...)
+			case e := <-events:
+				data, _ := json.Marshal(wsEvent{Type: e.Type, Hash: hex.EncodeToString(e.Hash), Height: e.Height, Target: e.Target})
+				if err := ws.writeFrame(wsOpText, data); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") || key == "" {
+		http.Error(w, "websocket upgrade required", http.StatusBadRequest)
+		return nil, errors.New("not a websocket request")
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return nil, errors.New("response does not support hijacking")
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha1.Sum([]byte(key + wsGUID))
+	_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return &wsConn{conn: conn, rw: rw}, nil
+}
+
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, part := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// readFrame reads one client frame and returns its opcode and unmasked payload.
+func (ws *wsConn) readFrame() (byte, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(ws.rw, head[:]); err != nil {
+		return 0, nil, err
+	}
+	op := head[0] & 0x0F
+	masked := head[1]&0x80 != 0
+	length := uint64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(ws.rw, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(ws.rw, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if !masked || length > wsMaxPayload {
+		return 0, nil, errors.New("invalid websocket frame")
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(ws.rw, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(ws.rw, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return op, payload, nil
+}
+
+// writeFrame writes one unfragmented, unmasked server frame.
+func (ws *wsConn) writeFrame(op byte, payload []byte) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	head := []byte{0x80 | op}
+	switch n := len(payload); {
+	case n < 126:
+		head = append(head, byte(n))
+	case n <= 0xFFFF:
+		head = append(head, 126, byte(n>>8), byte(n))
+	default:
+		head = append(head, 127)
+		head = binary.BigEndian.AppendUint64(head, uint64(n))
+	}
+	if _, err := ws.rw.Write(append(head, payload...)); err != nil {
+		return err
+	}
+	return ws.rw.Flush()
+}
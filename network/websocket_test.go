@@ -0,0 +1,151 @@
+package network
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"my-blockchain/wallet"
+)
+
+// wsTestClient is the client half of the WebSocket protocol, enough to talk to
+// handleWebSocket.
+type wsTestClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dialWebSocket(t *testing.T, srv *httptest.Server, path string) *wsTestClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+	req := "GET " + path + " HTTP/1.1\r\nHost: test\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n" +
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n"
+	if _, err := io.WriteString(conn, req); err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(conn)
+	res, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("upgrade answered %s", res.Status)
+	}
+	if got := res.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("Sec-WebSocket-Accept %q", got)
+	}
+	return &wsTestClient{conn: conn, r: r}
+}
+
+// send writes a masked client frame.
+func (c *wsTestClient) send(t *testing.T, op byte, payload []byte) {
+	t.Helper()
+	mask := [4]byte{1, 2, 3, 4}
+	frame := []byte{0x80 | op, 0x80 | byte(len(payload))}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// receive reads one server frame.
+func (c *wsTestClient) receive(t *testing.T) (byte, []byte) {
+	t.Helper()
+	var head [2]byte
+	if _, err := io.ReadFull(c.r, head[:]); err != nil {
+		t.Fatal(err)
+	}
+	length := uint64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			t.Fatal(err)
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		t.Fatal("unexpectedly large frame")
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		t.Fatal(err)
+	}
+	return head[0] & 0x0F, payload
+}
+
+func TestWebSocketReceivesBlockEvent(t *testing.T) {
+	chdirTemp(t)
+	n := newTestNode(t)
+	fundedChain(t, n)
+	startNode(t, n)
+	srv := httptest.NewServer(n.RPCHandler())
+	t.Cleanup(srv.Close)
+
+	c := dialWebSocket(t, srv, "/ws")
+	// The handler takes each subscription before reading the next message, so once the
+	// ping after a repeated subscription is answered the first one is in place.
+	sub := []byte(`{"subscribe": ["block"]}`)
+	c.send(t, wsOpText, sub)
+	c.send(t, wsOpText, sub)
+	c.send(t, wsOpPing, []byte("sync"))
+	if op, payload := c.receive(t); op != wsOpPong || string(payload) != "sync" {
+		t.Fatalf("got opcode %d %q, want the pong", op, payload)
+	}
+
+	hashes, err := GenerateRequest(n.id, 1, string(wallet.NewWallet().GetAddress()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		op, payload := c.receive(t)
+		if op != wsOpText {
+			t.Fatalf("got opcode %d, want a text event", op)
+		}
+		var e wsEvent
+		if err := json.Unmarshal(payload, &e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Type != "block" {
+			t.Fatalf("got a %q event on a block subscription", e.Type)
+		}
+		if e.Hash == hex.EncodeToString(hashes[0]) {
+			if e.Height != 2 {
+				t.Fatalf("block event at height %d, want 2", e.Height)
+			}
+			break
+		}
+	}
+
+	c.send(t, wsOpClose, nil)
+	if op, _ := c.receive(t); op != wsOpClose {
+		t.Fatalf("got opcode %d, want the close reply", op)
+	}
+}
+
+func TestWebSocketRequiresUpgrade(t *testing.T) {
+	srv := httptest.NewServer(handleWebSocket(nil))
+	t.Cleanup(srv.Close)
+	res, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("plain GET answered %d, want 400", res.StatusCode)
+	}
+}
@@ -0,0 +1,199 @@
+package network
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"my-blockchain/core"
+	"my-blockchain/wallet"
+)
+
+// maxWorkTemplates caps how many handed-out templates a node remembers for submitwork.
+const maxWorkTemplates = 16
+
+// GetWork asks the node for a block template to mine. Address receives the block reward;
+// when empty the node's own -miner address is used.
+type GetWork struct {
+	AddrFrom string
+	Address  string
+}
+
+// Work is a block template for an external miner: find a nonce for which
+// sha256(core.PowData(PrevBlockHash, MerkleRoot, Timestamp, TargetBits, nonce)) meets
+// TargetBits, then send it back with ID in a SubmitWork.
+type Work struct {
+	OK            bool
+	Message       string
+	ID            uint64
+	Height        int
+	PrevBlockHash []byte
+	MerkleRoot    []byte
+	Timestamp     int64
+	TargetBits    int
+}
+
+// SubmitWork returns a solved nonce for the template the node handed out as ID.
+type SubmitWork struct {
+	AddrFrom string
+	ID       uint64
+	Nonce    int
+}
+
+// workTemplates holds the templates handed out by getwork until they are solved or go stale.
+type workTemplates struct {
+	mu     sync.Mutex
+	byID   map[uint64]*core.BlockTemplate
+	nextID uint64
+}
+
+var errUnknownWork = errors.New("unknown or expired work ID")
+
+// Solves reports whether nonce is a valid proof of work for w.
+func (w Work) Solves(nonce int) bool {
+	hash := sha256.Sum256(core.PowData(w.PrevBlockHash, w.MerkleRoot, w.Timestamp, w.TargetBits, nonce))
+	return core.MeetsTarget(hash[:], w.TargetBits)
+}
+
+// GetWorkRequest asks the running node at nodeAddr(nodeID) for a block template paying address.
+func GetWorkRequest(nodeID string, address string) (Work, error) {
+	addr := nodeAddr(nodeID)
+	payload := GetWork{AddrFrom: addr, Address: address}
+	var work Work
+	if err := request(addr, "getwork", payload, "work", &work); err != nil {
+		return Work{}, err
+	}
+	if !work.OK {
+		return work, &RemoteError{Message: work.Message}
+	}
+	return work, nil
+}
+
+// SubmitWorkRequest hands a solved nonce back to the node at nodeAddr(nodeID) and returns
+// the new block's hash (hex) on acceptance.
+func SubmitWorkRequest(nodeID string, id uint64, nonce int) (string, error) {
+	addr := nodeAddr(nodeID)
+	payload := SubmitWork{AddrFrom: addr, ID: id, Nonce: nonce}
+	var res Result
+	if err := request(addr, "submitwork", payload, "result", &res); err != nil {
+		return "", err
+	}
+	if !res.OK {
+		return "", &RemoteError{Message: res.Message}
+	}
+	return res.Message, nil
+}
+
+func (n *Node) handleGetWork(conn net.Conn, payloadBytes []byte) error {
+	var payload GetWork
+	if err := decodePayload(payloadBytes, &payload); err != nil {
+		return err
+	}
+
+	work, err := n.newWork(payload.Address)
+	if err != nil {
+		work = Work{OK: false, Message: err.Error()}
+	}
+	return replyWith(conn, "work", work)
+}
+
+// newWork builds a template from the mempool, like the mining loop would, and remembers it
+// for submitwork.
+func (n *Node) newWork(address string) (Work, error) {
+	bc := n.bc
+	if address == "" {
+		address = n.miner
+	}
+	if address == "" {
+		return Work{}, fmt.Errorf("%w: no reward address given and the node has no -miner address", ErrBadRequest)
+	}
+	if !wallet.ValidateAddress(address) {
+		return Work{}, fmt.Errorf("%w: invalid reward address", ErrBadRequest)
+	}
+
+	n.mempool.EvictSpent(bc)
+	txs := n.mempool.Collect(maxBlockTxs, bc.Config().MaxBlockSize)
+	fees, err := bc.TotalFees(txs)
+	if err != nil {
+		return Work{}, err
+	}
+	cb := bc.Config().CoinbaseTxWithFees(address, "", bc.BestHeight()+1, fees)
+	tmpl, err := bc.NewBlockTemplate(append([]*core.Transaction{cb}, txs...))
+	if err != nil {
+		return Work{}, err
+	}
+
+	wt := n.work
+	wt.mu.Lock()
+	defer wt.mu.Unlock()
+	// Templates built on an old tip can never be accepted; beyond that, forget the oldest.
+	oldest := wt.nextID
+	for id, t := range wt.byID {
+		if !bytes.Equal(t.PrevBlockHash, tmpl.PrevBlockHash) {
+			delete(wt.byID, id)
+		} else if id < oldest {
+			oldest = id
+		}
+	}
+	if len(wt.byID) >= maxWorkTemplates {
+		delete(wt.byID, oldest)
+	}
+	id := wt.nextID
+	wt.nextID++
+	wt.byID[id] = tmpl
+
+	return Work{
+		OK:            true,
+		ID:            id,
+		Height:        tmpl.Height,
+		PrevBlockHash: tmpl.PrevBlockHash,
+		MerkleRoot:    tmpl.MerkleRoot,
+		Timestamp:     tmpl.Timestamp,
+		TargetBits:    tmpl.TargetBits,
+	}, nil
+}
+
+func (n *Node) handleSubmitWork(conn net.Conn, payloadBytes []byte) error {
+	var payload SubmitWork
+	if err := decodePayload(payloadBytes, &payload); err != nil {
+		return err
+	}
+
+	res := Result{OK: true}
+	hash, err := n.submitWork(payload.ID, payload.Nonce)
+	if err != nil {
+		res = Result{OK: false, Message: err.Error()}
+	} else {
+		res.Message = fmt.Sprintf("%x", hash)
+		n.logger.Info("accepted work", "id", payload.ID, "from", payload.AddrFrom, "block", hex.EncodeToString(hash))
+		n.broadcastBlock(hash)
+	}
+	return replyWith(conn, "result", res)
+}
+
+func (n *Node) submitWork(id uint64, nonce int) ([]byte, error) {
+	n.work.mu.Lock()
+	tmpl, ok := n.work.byID[id]
+	n.work.mu.Unlock()
+	if !ok {
+		return nil, errUnknownWork
+	}
+
+	hash, err := tmpl.SubmitSolution(nonce)
+	if err == nil || errors.Is(err, core.ErrStaleTip) {
+		n.work.mu.Lock()
+		delete(n.work.byID, id)
+		n.work.mu.Unlock()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The block's transactions are now spent from the mempool's point of view.
+	n.mempool.EvictSpent(n.bc)
+	return hash, nil
+}
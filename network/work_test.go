@@ -0,0 +1,66 @@
+package network
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"my-blockchain/wallet"
+)
+
+// solveWork brute-forces a nonce for w, as an external miner would.
+func solveWork(t *testing.T, w Work) int {
+	t.Helper()
+	for nonce := 0; nonce < 1<<24; nonce++ {
+		if w.Solves(nonce) {
+			return nonce
+		}
+	}
+	t.Fatalf("no nonce found for work %d", w.ID)
+	return 0
+}
+
+func TestExternalMinerSubmitsWork(t *testing.T) {
+	chdirTemp(t)
+	n := newTestNode(t)
+	fundedChain(t, n)
+	startNode(t, n)
+	to := string(wallet.NewWallet().GetAddress())
+
+	work, err := GetWorkRequest(n.id, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if work.Height != 2 || hex.EncodeToString(work.PrevBlockHash) != hex.EncodeToString(n.bc.Tip()) {
+		t.Fatalf("work for height %d on %x, want height 2 on the tip", work.Height, work.PrevBlockHash)
+	}
+	rival, err := GetWorkRequest(n.id, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nonce := solveWork(t, work)
+	wrong := nonce + 1
+	for work.Solves(wrong) {
+		wrong++
+	}
+	if _, err := SubmitWorkRequest(n.id, work.ID, wrong); err == nil {
+		t.Fatal("a nonce that does not solve the work was accepted")
+	}
+	hash, err := SubmitWorkRequest(n.id, work.ID, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != hex.EncodeToString(n.bc.Tip()) || n.bc.BestHeight() != 2 {
+		t.Fatalf("submitted block %s, but the tip is %x at height %d", hash, n.bc.Tip(), n.bc.BestHeight())
+	}
+	if got, err := GetBalanceRequest(n.id, to); err != nil || got != 10 {
+		t.Fatalf("reward address balance %d, %v; want 10", got, err)
+	}
+
+	if _, err := SubmitWorkRequest(n.id, rival.ID, solveWork(t, rival)); err == nil {
+		t.Fatal("work on the old tip was accepted after another block")
+	}
+	if _, err := SubmitWorkRequest(n.id, work.ID, nonce); err == nil {
+		t.Fatal("the same work was accepted twice")
+	}
+}
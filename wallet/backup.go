@@ -0,0 +1,123 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// walletBackup is the JSON form written by Dump. Keys are WIF-encoded, so single keys can
+// also be restored with ImportWIF.
+type walletBackup struct {
+	Keys      []backupKey       `json:"keys"`
+	Multisig  map[string]string `json:"multisig,omitempty"`
+	Watched   []string          `json:"watched,omitempty"`
+	Mnemonic  string            `json:"mnemonic,omitempty"`
+	NextIndex uint32            `json:"next_index,omitempty"`
+}
+
+type backupKey struct {
+	Address    string `json:"address"`
+	PrivateKey string `json:"private_key"`
+}
+
+// Dump returns a JSON backup of every address and its private key, the multisig scripts, the
+// watch-only addresses and the HD seed. Anyone holding it can spend the coins; store it
+// accordingly.
+func (ws *Wallets) Dump() ([]byte, error) {
+	if ws.Locked() {
+		return nil, ErrWalletLocked
+	}
+	backup := walletBackup{Mnemonic: ws.Mnemonic, NextIndex: ws.NextIndex}
+	addresses := ws.GetAddresses()
+	sort.Strings(addresses)
+	for _, address := range addresses {
+		w := ws.Wallets[address]
+		backup.Keys = append(backup.Keys, backupKey{Address: address, PrivateKey: EncodeWIF(w.PrivateKey, w.Compressed)})
+	}
+	if len(ws.Multisig) > 0 {
+		backup.Multisig = make(map[string]string, len(ws.Multisig))
+		for address, script := range ws.Multisig {
+			backup.Multisig[address] = hex.EncodeToString(script)
+		}
+	}
+	backup.Watched = ws.WatchedAddresses()
+	return json.MarshalIndent(backup, "", "  ")
+}
+
+// Import merges a backup written by Dump into the wallets and saves them. Addresses that
+// already exist are kept as they are. The backup's HD seed is adopted only when the wallets
+// have none. It returns how many addresses were added.
+func (ws *Wallets) Import(data []byte) (int, error) {
+	if ws.Locked() {
+		return 0, ErrWalletLocked
+	}
+	var backup walletBackup
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return 0, fmt.Errorf("invalid wallet backup: %w", err)
+	}
+
+	// Check the whole backup before changing anything.
+	keys := make(map[string]*Wallet, len(backup.Keys))
+	for _, k := range backup.Keys {
+		privKey, compressed, err := DecodeWIF(k.PrivateKey)
+		if err != nil {
+			return 0, fmt.Errorf("key for %s: %w", k.Address, err)
+		}
+		w, err := NewWalletFromPrivateKey(privKey)
+		if err != nil {
+			return 0, fmt.Errorf("key for %s: %w", k.Address, err)
+		}
+		w.Compressed = compressed
+		if string(w.GetAddress()) != k.Address {
+			return 0, fmt.Errorf("key for %s belongs to %s", k.Address, w.GetAddress())
+		}
+		keys[k.Address] = w
+	}
+	scripts := make(map[string][]byte, len(backup.Multisig))
+	for address, scriptHex := range backup.Multisig {
+		script, err := hex.DecodeString(scriptHex)
+		if err != nil || MultisigAddress(script) != address {
+			return 0, fmt.Errorf("invalid multisig script for %s", address)
+		}
+		scripts[address] = script
+	}
+	for _, address := range backup.Watched {
+		if !ValidateAddress(address) {
+			return 0, fmt.Errorf("invalid watch-only address %s", address)
+		}
+	}
+	if backup.Mnemonic != "" {
+		if err := validateMnemonic(backup.Mnemonic); err != nil {
+			return 0, err
+		}
+	}
+
+	added := 0
+	for address, w := range keys {
+		if _, ok := ws.Wallets[address]; !ok {
+			ws.Wallets[address] = w
+			added++
+		}
+	}
+	for address, script := range scripts {
+		if _, ok := ws.Multisig[address]; !ok {
+			ws.Multisig[address] = script
+			added++
+		}
+	}
+	for _, address := range backup.Watched {
+		if !ws.Watched[address] && !ws.Owns(address) {
+			ws.Watched[address] = true
+			added++
+		}
+	}
+	if ws.Mnemonic == "" && backup.Mnemonic != "" {
+		ws.Mnemonic = backup.Mnemonic
+		ws.NextIndex = backup.NextIndex
+	} else if ws.Mnemonic == backup.Mnemonic && backup.NextIndex > ws.NextIndex {
+		ws.NextIndex = backup.NextIndex
+	}
+	return added, ws.SaveToFile()
+}
@@ -0,0 +1,140 @@
+package wallet
+
+import (
+	"bytes"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// newTestWallets returns empty wallets saved to a file in a new temporary directory.
+func newTestWallets(t *testing.T) *Wallets {
+	t.Helper()
+	ws, err := NewWalletsAt(filepath.Join(t.TempDir(), "wallets.dat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ws
+}
+
+func TestDumpImportRoundTrip(t *testing.T) {
+	src := newTestWallets(t)
+	plain, err := src.CreateWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed, err := src.CreateCompressedWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	watched := string(NewWallet().GetAddress())
+	if err := src.ImportAddress(watched); err != nil {
+		t.Fatal(err)
+	}
+	backup, err := src.Dump()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := newTestWallets(t)
+	added, err := dst.Import(backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != 3 {
+		t.Fatalf("import added %d entries, want 3", added)
+	}
+	// The imported wallets were saved: read them back from the file.
+	reloaded, err := NewWalletsAt(dst.File())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, address := range []string{plain, compressed} {
+		w, ok := reloaded.GetWallet(address)
+		if !ok {
+			t.Fatalf("%s missing after import", address)
+		}
+		if !bytes.Equal(w.PrivateKey, src.Wallets[address].PrivateKey) || string(w.GetAddress()) != address {
+			t.Fatalf("%s imported with a different key", address)
+		}
+	}
+	if !reloaded.IsWatchOnly(watched) {
+		t.Fatalf("watch-only %s missing after import", watched)
+	}
+}
+
+func TestImportMergesWithExisting(t *testing.T) {
+	src := newTestWallets(t)
+	shared, err := src.CreateWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	onlyInBackup, err := src.CreateWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	backup, err := src.Dump()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := newTestWallets(t)
+	own, err := dst.CreateWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wif, err := src.ExportWIF(shared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dst.ImportWIF(wif); err != nil {
+		t.Fatal(err)
+	}
+
+	added, err := dst.Import(backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != 1 {
+		t.Fatalf("import added %d addresses, want only %s", added, onlyInBackup)
+	}
+	got := dst.GetAddresses()
+	sort.Strings(got)
+	want := []string{own, shared, onlyInBackup}
+	sort.Strings(want)
+	if len(got) != len(want) {
+		t.Fatalf("addresses after merge %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("addresses after merge %v, want %v", got, want)
+		}
+	}
+	if added, err := dst.Import(backup); err != nil || added != 0 {
+		t.Fatalf("importing the same backup again added %d, %v; want 0", added, err)
+	}
+}
+
+func TestImportRejectsBadBackups(t *testing.T) {
+	src := newTestWallets(t)
+	address, err := src.CreateWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	backup, err := src.Dump()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := string(NewWallet().GetAddress())
+	mislabelled := bytes.Replace(backup, []byte(address), []byte(other), 1)
+
+	dst := newTestWallets(t)
+	for name, data := range map[string][]byte{"not JSON": []byte("{"), "key under another address": mislabelled} {
+		if _, err := dst.Import(data); err == nil {
+			t.Errorf("%s: imported", name)
+		}
+	}
+	if n := len(dst.GetAddresses()); n != 0 {
+		t.Fatalf("rejected backups left %d addresses behind", n)
+	}
+}
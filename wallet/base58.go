@@ -2,11 +2,16 @@ package wallet
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"math/big"
 )
 
 var b58Alphabet = []byte("123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz")
 
+// ErrInvalidBase58 is returned by Base58DecodeChecked for a character outside the alphabet.
+var ErrInvalidBase58 = errors.New("invalid base58")
+
 func Base58Encode(input []byte) []byte {
 	x := new(big.Int).SetBytes(input)
 	base := big.NewInt(58)
@@ -19,7 +24,8 @@ func Base58Encode(input []byte) []byte {
 		result = append(result, b58Alphabet[mod.Int64()])
 	}
 
-	// Add '1' for each leading 0 byte.
+	// Add '1' for each leading 0 byte. The loop above writes nothing for them, and nothing
+	// at all for an all-zero input, so these '1's are its whole encoding.
 	for _, b := range input {
 		if b == 0x00 {
 			result = append(result, b58Alphabet[0])
@@ -32,14 +38,29 @@ func Base58Encode(input []byte) []byte {
 	return result
 }
 
+// Base58Decode is Base58DecodeChecked without the reason: it returns nil for invalid input.
 func Base58Decode(input []byte) []byte {
+	decoded, err := Base58DecodeChecked(input)
+	if err != nil {
+		return nil
+	}
+	return decoded
+}
+
+// Base58DecodeChecked decodes input, naming the first character outside the alphabet and
+// its position (counting from 1) when there is one. The alphabet leaves out 0, O, I and l,
+// which are easily mistaken for each other, so those get a hint saying so.
+func Base58DecodeChecked(input []byte) ([]byte, error) {
 	result := big.NewInt(0)
 	base := big.NewInt(58)
 
-	for _, b := range input {
+	for i, b := range input {
 		charIndex := bytes.IndexByte(b58Alphabet, b)
 		if charIndex < 0 {
-			return nil
+			if bytes.IndexByte([]byte("0OIl"), b) >= 0 {
+				return nil, fmt.Errorf("%w: invalid character %q at position %d (0, O, I and l are not used, to avoid confusing them)", ErrInvalidBase58, b, i+1)
+			}
+			return nil, fmt.Errorf("%w: invalid character %q at position %d", ErrInvalidBase58, b, i+1)
 		}
 		result.Mul(result, base)
 		result.Add(result, big.NewInt(int64(charIndex)))
@@ -47,7 +68,8 @@ func Base58Decode(input []byte) []byte {
 
 	decoded := result.Bytes()
 
-	// Restore leading zero bytes.
+	// Restore leading zero bytes. For all-'1' input the value is zero and decoded is empty,
+	// so the result is exactly one zero byte per '1', mirroring Base58Encode.
 	leadingOnes := 0
 	for _, b := range input {
 		if b == b58Alphabet[0] {
@@ -57,7 +79,7 @@ func Base58Decode(input []byte) []byte {
 		}
 	}
 
-	return append(bytes.Repeat([]byte{0x00}, leadingOnes), decoded...)
+	return append(bytes.Repeat([]byte{0x00}, leadingOnes), decoded...), nil
 }
 
 func ReverseBytes(data []byte) {
@@ -0,0 +1,55 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestBase58RoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		hex     string
+		encoded string
+	}{
+		{"empty", "", ""},
+		{"single zero byte", "00", "1"},
+		{"several zero bytes", "00000000000000000000", "1111111111"},
+		{"single byte", "61", "2g"},
+		{"one leading zero", "0061", "12g"},
+		{"three leading zeros", "00000001", "1112"},
+		{"no leading zeros", "626262", "a3gV"},
+		{"text", hex.EncodeToString([]byte("simply a long string")), "2cFupjhnEsSn59qHXstmK2ffpLv2"},
+		{"address", "00eb15231dfceb60925886b67d065299925915aeb172c06647", "1NS17iag9jJgTHD1VXjvLCEnZuQ3rJDE9L"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input, err := hex.DecodeString(tt.hex)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := Base58Encode(input); string(got) != tt.encoded {
+				t.Fatalf("Base58Encode(%s) = %q, want %q", tt.hex, got, tt.encoded)
+			}
+			decoded, err := Base58DecodeChecked([]byte(tt.encoded))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(decoded, input) {
+				t.Fatalf("Base58DecodeChecked(%q) = %x, want %s", tt.encoded, decoded, tt.hex)
+			}
+		})
+	}
+}
+
+func TestBase58DecodeRejectsCharactersOutsideTheAlphabet(t *testing.T) {
+	for _, input := range []string{"0", "1O1", "abIc", "l", "2g!"} {
+		if _, err := Base58DecodeChecked([]byte(input)); !errors.Is(err, ErrInvalidBase58) {
+			t.Errorf("Base58DecodeChecked(%q): got %v, want ErrInvalidBase58", input, err)
+		}
+		if got := Base58Decode([]byte(input)); got != nil {
+			t.Errorf("Base58Decode(%q) = %x, want nil", input, got)
+		}
+	}
+}
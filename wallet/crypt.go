@@ -0,0 +1,235 @@
+package wallet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+var (
+	// ErrWalletLocked is returned when an encrypted wallet's private keys or HD seed are
+	// needed while it is locked.
+	ErrWalletLocked = errors.New("wallet locked")
+	// ErrWrongPassphrase is returned by Unlock and DeriveKey for a passphrase that does not
+	// decrypt the wallet.
+	ErrWrongPassphrase = errors.New("wrong passphrase")
+	// ErrNotEncrypted is returned when unlocking wallets that were never encrypted.
+	ErrNotEncrypted = errors.New("wallet is not encrypted")
+)
+
+// scrypt cost parameters for turning a passphrase into the wallet key (as recommended for
+// interactive logins in 2017), and the size of the key: AES-256.
+const (
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	walletKeyLen = 32
+)
+
+// encryptionCheck is sealed with the wallet key so a wrong passphrase is told apart from
+// a corrupt key.
+var encryptionCheck = []byte("my-blockchain wallet")
+
+// Encryption is stored with encrypted wallets: the private keys and the HD seed are
+// sealed with AES-256-GCM under a key derived from the passphrase with scrypt, and only
+// the sealed forms are written to the wallet file.
+type Encryption struct {
+	Salt []byte
+	// Check is encryptionCheck sealed with the key.
+	Check []byte
+	// Mnemonic is the sealed HD seed phrase, if any.
+	Mnemonic []byte
+}
+
+// IsEncrypted reports whether the wallets were encrypted with Encrypt.
+func (ws *Wallets) IsEncrypted() bool {
+	return ws.Encryption != nil
+}
+
+// Locked reports whether the wallets are encrypted and their keys are not decrypted in
+// memory, so nothing can be signed.
+func (ws *Wallets) Locked() bool {
+	return ws.IsEncrypted() && ws.key == nil
+}
+
+// Encrypt seals every private key and the HD seed with passphrase, saves the wallets
+// without them and leaves the wallets locked. Encrypting twice is an error.
+func (ws *Wallets) Encrypt(passphrase string) error {
+	if ws.IsEncrypted() {
+		return errors.New("wallet is already encrypted")
+	}
+	if passphrase == "" {
+		return errors.New("empty passphrase")
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	key, err := deriveWalletKey(passphrase, salt)
+	if err != nil {
+		return err
+	}
+	check, err := seal(key, encryptionCheck)
+	if err != nil {
+		return err
+	}
+	ws.Encryption = &Encryption{Salt: salt, Check: check}
+	ws.key = key
+	if err := ws.SaveToFile(); err != nil {
+		ws.Encryption, ws.key = nil, nil
+		return err
+	}
+	ws.Lock()
+	return nil
+}
+
+// DeriveKey returns the key passphrase decrypts the wallets with, or ErrWrongPassphrase.
+// Holding the key rather than the passphrase lets UnlockWithKey skip the slow derivation.
+func (ws *Wallets) DeriveKey(passphrase string) ([]byte, error) {
+	if !ws.IsEncrypted() {
+		return nil, ErrNotEncrypted
+	}
+	key, err := deriveWalletKey(passphrase, ws.Encryption.Salt)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := open(key, ws.Encryption.Check); err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return key, nil
+}
+
+// Unlock decrypts the private keys and the HD seed into memory; the wallet file keeps
+// only their sealed forms.
+func (ws *Wallets) Unlock(passphrase string) error {
+	key, err := ws.DeriveKey(passphrase)
+	if err != nil {
+		return err
+	}
+	return ws.UnlockWithKey(key)
+}
+
+// UnlockWithKey is Unlock with a key from DeriveKey.
+func (ws *Wallets) UnlockWithKey(key []byte) error {
+	if !ws.IsEncrypted() {
+		return ErrNotEncrypted
+	}
+	if _, err := open(key, ws.Encryption.Check); err != nil {
+		return ErrWrongPassphrase
+	}
+	for address, w := range ws.Wallets {
+		privKey, err := open(key, w.EncryptedKey)
+		if err != nil {
+			ws.Lock()
+			return fmt.Errorf("%w: key for %s: %v", ErrCorruptWalletFile, address, err)
+		}
+		w.PrivateKey = privKey
+	}
+	if len(ws.Encryption.Mnemonic) > 0 {
+		mnemonic, err := open(key, ws.Encryption.Mnemonic)
+		if err != nil {
+			ws.Lock()
+			return fmt.Errorf("%w: HD seed: %v", ErrCorruptWalletFile, err)
+		}
+		ws.Mnemonic = string(mnemonic)
+	}
+	ws.key = append([]byte(nil), key...)
+	return nil
+}
+
+// Lock forgets the decrypted private keys and HD seed. It does nothing to wallets that
+// are not encrypted.
+func (ws *Wallets) Lock() {
+	if !ws.IsEncrypted() {
+		return
+	}
+	for _, w := range ws.Wallets {
+		clear(w.PrivateKey)
+		w.PrivateKey = nil
+	}
+	ws.Mnemonic = ""
+	clear(ws.key)
+	ws.key = nil
+}
+
+// sealed returns a copy of ws to write to the wallet file: for encrypted wallets every
+// private key and the HD seed are sealed with the key and the plaintext left out.
+func (ws *Wallets) sealed() (*Wallets, error) {
+	if !ws.IsEncrypted() {
+		return ws, nil
+	}
+	out := *ws
+	enc := *ws.Encryption
+	out.Encryption = &enc
+	out.Mnemonic = ""
+	out.Wallets = make(map[string]*Wallet, len(ws.Wallets))
+	for address, w := range ws.Wallets {
+		if len(w.EncryptedKey) == 0 {
+			if ws.key == nil {
+				return nil, ErrWalletLocked
+			}
+			sealedKey, err := seal(ws.key, w.PrivateKey)
+			if err != nil {
+				return nil, err
+			}
+			w.EncryptedKey = sealedKey
+		}
+		c := *w
+		c.PrivateKey = nil
+		out.Wallets[address] = &c
+	}
+	if ws.key != nil {
+		enc.Mnemonic = nil
+		if ws.Mnemonic != "" {
+			sealedMnemonic, err := seal(ws.key, []byte(ws.Mnemonic))
+			if err != nil {
+				return nil, err
+			}
+			enc.Mnemonic = sealedMnemonic
+		}
+		ws.Encryption.Mnemonic = enc.Mnemonic
+	}
+	return &out, nil
+}
+
+func deriveWalletKey(passphrase string, salt []byte) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, walletKeyLen)
+}
+
+// seal encrypts plaintext with AES-GCM under key, returning nonce | ciphertext.
+func seal(key, plaintext []byte) ([]byte, error) {
+	aead, err := newWalletAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// open reverses seal.
+func open(key, sealed []byte) ([]byte, error) {
+	aead, err := newWalletAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed data too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
+
+func newWalletAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
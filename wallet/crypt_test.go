@@ -0,0 +1,117 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncryptSealsKeysAndUnlockRestoresThem(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallets.dat")
+	ws, err := NewWalletsAt(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	address, err := ws.CreateWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	privKey := append([]byte(nil), ws.Wallets[address].PrivateKey...)
+
+	if err := ws.Encrypt("secret"); err != nil {
+		t.Fatal(err)
+	}
+	if !ws.Locked() {
+		t.Fatal("wallets not locked after Encrypt")
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(content, privKey) {
+		t.Fatal("wallet file still holds the private key in cleartext")
+	}
+
+	loaded, err := NewWalletsAt(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loaded.ExportWIF(address); !errors.Is(err, ErrWalletLocked) {
+		t.Fatalf("ExportWIF while locked: got %v, want ErrWalletLocked", err)
+	}
+	if _, err := loaded.CreateWallet(); !errors.Is(err, ErrWalletLocked) {
+		t.Fatalf("CreateWallet while locked: got %v, want ErrWalletLocked", err)
+	}
+	if err := loaded.Unlock("wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("Unlock with a wrong passphrase: got %v, want ErrWrongPassphrase", err)
+	}
+	if err := loaded.Unlock("secret"); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(loaded.Wallets[address].PrivateKey, privKey) {
+		t.Fatal("unlocked private key differs from the original")
+	}
+
+	// A key created while unlocked is sealed too and survives a reload.
+	second, err := loaded.CreateWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded.Lock()
+	if loaded.Wallets[address].PrivateKey != nil {
+		t.Fatal("Lock kept the private key")
+	}
+	reloaded, err := NewWalletsAt(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reloaded.Unlock("secret"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reloaded.GetWallet(second); !ok || len(reloaded.Wallets[second].PrivateKey) != privateKeyByteLen {
+		t.Fatal("key created while unlocked was not saved")
+	}
+}
+
+func TestEncryptSealsMnemonic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallets.dat")
+	ws, err := NewWalletsAt(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mnemonic, _, err := ws.CreateHDWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.Encrypt("secret"); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(content, []byte(mnemonic)) {
+		t.Fatal("wallet file still holds the mnemonic in cleartext")
+	}
+	if err := ws.Unlock("secret"); err != nil {
+		t.Fatal(err)
+	}
+	if ws.Mnemonic != mnemonic {
+		t.Fatalf("mnemonic after unlock = %q, want %q", ws.Mnemonic, mnemonic)
+	}
+}
+
+func TestEncryptTwiceFails(t *testing.T) {
+	ws, err := NewWalletsAt(filepath.Join(t.TempDir(), "wallets.dat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.Encrypt("secret"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.Encrypt("other"); err == nil {
+		t.Fatal("encrypting twice succeeded")
+	}
+}
@@ -0,0 +1,158 @@
+package wallet
+
+import (
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	_ "embed"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// english.txt is the BIP39 English wordlist (2048 words).
+//
+//go:embed english.txt
+var englishWordlist string
+
+var wordlist = strings.Fields(englishWordlist)
+
+const (
+	mnemonicEntropyLen = 16 // 128 bits -> 12 words
+	seedIterations     = 2048
+	hardenedOffset     = uint32(0x80000000)
+)
+
+// masterKeySalt is the SLIP-0010 HMAC key for deriving a P-256 master key from a seed.
+var masterKeySalt = []byte("Nist256p1 seed")
+
+var ErrInvalidMnemonic = errors.New("invalid mnemonic")
+
+// HDWallet derives any number of P-256 keys deterministically from a BIP39 mnemonic,
+// following SLIP-0010 (hardened derivation only).
+type HDWallet struct {
+	Mnemonic  string
+	masterKey []byte
+	chainCode []byte
+}
+
+// NewMnemonic returns a fresh 12-word BIP39 mnemonic.
+func NewMnemonic() (string, error) {
+	entropy := make([]byte, mnemonicEntropyLen)
+	if _, err := rand.Read(entropy); err != nil {
+		return "", err
+	}
+	return entropyToMnemonic(entropy), nil
+}
+
+func entropyToMnemonic(entropy []byte) string {
+	hash := sha256.Sum256(entropy)
+	checksumBits := len(entropy) * 8 / 32
+
+	bits := new(big.Int).SetBytes(entropy)
+	bits.Lsh(bits, uint(checksumBits))
+	bits.Or(bits, big.NewInt(int64(hash[0]>>(8-checksumBits))))
+
+	wordCount := (len(entropy)*8 + checksumBits) / 11
+	words := make([]string, wordCount)
+	mask := big.NewInt(2047)
+	for i := wordCount - 1; i >= 0; i-- {
+		idx := new(big.Int).And(bits, mask)
+		words[i] = wordlist[idx.Int64()]
+		bits.Rsh(bits, 11)
+	}
+	return strings.Join(words, " ")
+}
+
+// validateMnemonic checks that every word is in the wordlist and the checksum matches.
+func validateMnemonic(mnemonic string) error {
+	words := strings.Fields(mnemonic)
+	if len(words) < 12 || len(words) > 24 || len(words)%3 != 0 {
+		return fmt.Errorf("%w: expected 12, 15, 18, 21 or 24 words", ErrInvalidMnemonic)
+	}
+
+	bits := new(big.Int)
+	for _, word := range words {
+		idx := -1
+		for i, w := range wordlist {
+			if w == word {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			return fmt.Errorf("%w: unknown word %q", ErrInvalidMnemonic, word)
+		}
+		bits.Lsh(bits, 11)
+		bits.Or(bits, big.NewInt(int64(idx)))
+	}
+
+	checksumBits := len(words) * 11 / 33
+	entropyLen := (len(words)*11 - checksumBits) / 8
+	checksum := new(big.Int).And(bits, big.NewInt(int64(1<<checksumBits-1)))
+	bits.Rsh(bits, uint(checksumBits))
+
+	entropy := bits.FillBytes(make([]byte, entropyLen))
+	hash := sha256.Sum256(entropy)
+	if checksum.Int64() != int64(hash[0]>>(8-checksumBits)) {
+		return fmt.Errorf("%w: bad checksum", ErrInvalidMnemonic)
+	}
+	return nil
+}
+
+// NewHDWallet validates mnemonic and derives its SLIP-0010 master key.
+func NewHDWallet(mnemonic string) (*HDWallet, error) {
+	mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+	if err := validateMnemonic(mnemonic); err != nil {
+		return nil, err
+	}
+	seed := pbkdf2.Key([]byte(mnemonic), []byte("mnemonic"), seedIterations, 64, sha512.New)
+
+	n := elliptic.P256().Params().N
+	i := hmacSHA512(masterKeySalt, seed)
+	for {
+		k := new(big.Int).SetBytes(i[:32])
+		if k.Sign() != 0 && k.Cmp(n) < 0 {
+			break
+		}
+		i = hmacSHA512(masterKeySalt, i)
+	}
+	return &HDWallet{Mnemonic: mnemonic, masterKey: i[:32], chainCode: i[32:]}, nil
+}
+
+// DeriveChild returns the wallet for the hardened child m/index'.
+func (hd *HDWallet) DeriveChild(index uint32) (*Wallet, error) {
+	if index >= hardenedOffset {
+		return nil, fmt.Errorf("child index %d out of range", index)
+	}
+	n := elliptic.P256().Params().N
+	parent := new(big.Int).SetBytes(hd.masterKey)
+
+	var ser32 [4]byte
+	binary.BigEndian.PutUint32(ser32[:], index+hardenedOffset)
+
+	data := append(append([]byte{0x00}, hd.masterKey...), ser32[:]...)
+	for {
+		i := hmacSHA512(hd.chainCode, data)
+		il := new(big.Int).SetBytes(i[:32])
+		child := new(big.Int).Add(il, parent)
+		child.Mod(child, n)
+		if il.Cmp(n) < 0 && child.Sign() != 0 {
+			return NewWalletFromPrivateKey(child.FillBytes(make([]byte, privateKeyByteLen)))
+		}
+		// SLIP-0010: retry with the right half when the key is invalid.
+		data = append(append([]byte{0x01}, i[32:]...), ser32[:]...)
+	}
+}
+
+func hmacSHA512(key, data []byte) []byte {
+	mac := hmac.New(sha512.New, key)
+	_, _ = mac.Write(data)
+	return mac.Sum(nil)
+}
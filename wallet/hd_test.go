@@ -0,0 +1,60 @@
+package wallet
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRestoreMnemonicRegeneratesAddressesInOrder(t *testing.T) {
+	dir := t.TempDir()
+	orig, err := NewWalletsAt(filepath.Join(dir, "orig.dat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mnemonic, first, err := orig.CreateHDWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{first}
+	for i := 0; i < 2; i++ {
+		address, err := orig.CreateWallet()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, address)
+	}
+
+	restored, err := NewWalletsAt(filepath.Join(dir, "restored.dat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := restored.RestoreHDWallet(mnemonic, len(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("restored %d addresses, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("address %d: restored %s, want %s", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRestoreRejectsMnemonicWithBadChecksum(t *testing.T) {
+	ws, err := NewWalletsAt(filepath.Join(t.TempDir(), "wallets.dat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The BIP39 test vector ends in "about"; with "abandon" the checksum no longer matches.
+	bad := strings.Repeat("abandon ", 11) + "abandon"
+	if _, err := ws.RestoreHDWallet(bad, 1); !errors.Is(err, ErrInvalidMnemonic) {
+		t.Fatalf("bad checksum: got %v, want ErrInvalidMnemonic", err)
+	}
+	if _, err := ws.RestoreHDWallet(strings.Repeat("abandon ", 11)+"about", 1); err != nil {
+		t.Fatalf("valid mnemonic after the rejected one: %v", err)
+	}
+}
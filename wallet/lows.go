@@ -0,0 +1,47 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/asn1"
+	"math/big"
+)
+
+// ecdsaSignature is the ASN.1 layout of an ECDSA signature.
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// SignLowS signs hash with priv like ecdsa.SignASN1, but always returns the low-S form:
+// (r, s) and (r, n-s) are both valid, so an S above n/2 is replaced by n-s. Without that a
+// third party could flip S and change a transaction's ID without invalidating it.
+func SignLowS(priv *ecdsa.PrivateKey, hash []byte) ([]byte, error) {
+	r, s, err := ecdsa.Sign(rand.Reader, priv, hash)
+	if err != nil {
+		return nil, err
+	}
+	n := priv.Curve.Params().N
+	if s.Cmp(new(big.Int).Rsh(n, 1)) > 0 {
+		s.Sub(n, s)
+	}
+	return asn1.Marshal(ecdsaSignature{R: r, S: s})
+}
+
+// IsLowS reports whether sig is a DER-encoded signature, in its one canonical encoding,
+// whose S is at most half the order of pub's curve.
+func IsLowS(pub *ecdsa.PublicKey, sig []byte) bool {
+	var parsed ecdsaSignature
+	rest, err := asn1.Unmarshal(sig, &parsed)
+	if err != nil || len(rest) > 0 || parsed.R == nil || parsed.S == nil {
+		return false
+	}
+	if canonical, err := asn1.Marshal(parsed); err != nil || string(canonical) != string(sig) {
+		return false
+	}
+	return parsed.S.Sign() > 0 && parsed.S.Cmp(new(big.Int).Rsh(pub.Curve.Params().N, 1)) <= 0
+}
+
+// VerifyLowS is ecdsa.VerifyASN1 that also rejects high-S and non-canonical signatures.
+func VerifyLowS(pub *ecdsa.PublicKey, hash, sig []byte) bool {
+	return IsLowS(pub, sig) && ecdsa.VerifyASN1(pub, hash, sig)
+}
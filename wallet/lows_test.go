@@ -0,0 +1,47 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+)
+
+// flipS returns sig with S replaced by n-S: the other valid signature for the same hash.
+func flipS(t *testing.T, pub *ecdsa.PublicKey, sig []byte) []byte {
+	t.Helper()
+	var parsed ecdsaSignature
+	if _, err := asn1.Unmarshal(sig, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	parsed.S = new(big.Int).Sub(pub.Curve.Params().N, parsed.S)
+	flipped, err := asn1.Marshal(parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return flipped
+}
+
+func TestVerifyLowSRejectsHighS(t *testing.T) {
+	w := NewWallet()
+	priv := w.PrivateECDSA()
+	hash := sha256.Sum256([]byte("message"))
+
+	for range 20 {
+		sig, err := SignLowS(priv, hash[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !IsLowS(&priv.PublicKey, sig) || !VerifyLowS(&priv.PublicKey, hash[:], sig) {
+			t.Fatal("SignLowS made a signature VerifyLowS rejects")
+		}
+		high := flipS(t, &priv.PublicKey, sig)
+		if !ecdsa.VerifyASN1(&priv.PublicKey, hash[:], high) {
+			t.Fatal("flipped signature is not valid ECDSA")
+		}
+		if IsLowS(&priv.PublicKey, high) || VerifyLowS(&priv.PublicKey, hash[:], high) {
+			t.Fatal("high-S signature accepted")
+		}
+	}
+}
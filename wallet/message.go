@@ -0,0 +1,63 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+)
+
+// messageMagic domain-separates signed messages from transaction signatures.
+const messageMagic = "my-blockchain Signed Message:\n"
+
+// messageHash is the double SHA-256 of the magic prefix and the length-prefixed message.
+func messageHash(message string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(messageMagic)
+	var length [8]byte
+	binary.BigEndian.PutUint64(length[:], uint64(len(message)))
+	buf.Write(length[:])
+	buf.WriteString(message)
+
+	first := sha256.Sum256(buf.Bytes())
+	second := sha256.Sum256(first[:])
+	return second[:]
+}
+
+// SignMessage signs message with the key of address. The signature embeds the public key
+// (len | pubkey | ASN.1 signature) so anyone can verify it against the address alone.
+func (ws *Wallets) SignMessage(address, message string) ([]byte, error) {
+	if ws.Locked() {
+		return nil, ErrWalletLocked
+	}
+	w, ok := ws.GetWallet(address)
+	if !ok {
+		return nil, fmt.Errorf("no key for address %s", address)
+	}
+	sig, err := SignLowS(w.PrivateECDSA(), messageHash(message))
+	if err != nil {
+		return nil, err
+	}
+	pubKey := w.PubKey()
+	out := append([]byte{byte(len(pubKey))}, pubKey...)
+	return append(out, sig...), nil
+}
+
+// VerifyMessage reports whether sig is a signature of message by the key behind address.
+func VerifyMessage(address, message string, sig []byte) bool {
+	if len(sig) < 1 || len(sig) < 1+int(sig[0]) {
+		return false
+	}
+	pubKey := sig[1 : 1+int(sig[0])]
+	pubKeyHash := PubKeyHashFromAddress(address)
+	if pubKeyHash == nil || !bytes.Equal(HashPubKey(pubKey), pubKeyHash) {
+		return false
+	}
+
+	pub, err := ParsePubKey(pubKey)
+	if err != nil {
+		return false
+	}
+	return ecdsa.VerifyASN1(pub, messageHash(message), sig[1+int(sig[0]):])
+}
@@ -0,0 +1,55 @@
+package wallet
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSignAndVerifyMessage(t *testing.T) {
+	ws, err := NewWalletsAt(filepath.Join(t.TempDir(), "wallets.dat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice, err := ws.CreateWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := ws.CreateWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	const message = "I control this address"
+	sig, err := ws.SignMessage(alice, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobSig, err := ws.SignMessage(bob, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		address string
+		message string
+		sig     []byte
+		want    bool
+	}{
+		{"valid", alice, message, sig, true},
+		{"tampered message", alice, message + "!", sig, false},
+		{"checked against another address", bob, message, sig, false},
+		{"signed by another address", alice, message, bobSig, false},
+		{"truncated", alice, message, sig[:len(sig)-1], false},
+		{"empty", alice, message, nil, false},
+		{"invalid address", "not an address", message, sig, false},
+	}
+	for _, tt := range tests {
+		if got := VerifyMessage(tt.address, tt.message, tt.sig); got != tt.want {
+			t.Errorf("%s: VerifyMessage = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := ws.SignMessage(string(NewWallet().GetAddress()), message); err == nil {
+		t.Fatal("signed for an address without a key in the wallet file")
+	}
+}
@@ -0,0 +1,124 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	multisigVersion = byte(0x05)
+	maxMultisigKeys = 16
+	pubKeyHashLen   = 20
+)
+
+// NewMultisigScript encodes an m-of-n policy over the given public key hashes as
+// m | n | pubKeyHash... Outputs lock to the hash of this script, and spenders reveal it.
+func NewMultisigScript(m int, pubKeyHashes [][]byte) ([]byte, error) {
+	n := len(pubKeyHashes)
+	if n == 0 || n > maxMultisigKeys {
+		return nil, fmt.Errorf("multisig needs 1 to %d keys, got %d", maxMultisigKeys, n)
+	}
+	if m < 1 || m > n {
+		return nil, fmt.Errorf("required signatures must be between 1 and %d", n)
+	}
+
+	script := []byte{byte(m), byte(n)}
+	for _, pkh := range pubKeyHashes {
+		if len(pkh) != pubKeyHashLen {
+			return nil, errors.New("invalid public key hash")
+		}
+		script = append(script, pkh...)
+	}
+	return script, nil
+}
+
+// ParseMultisigScript decodes a script built by NewMultisigScript.
+func ParseMultisigScript(script []byte) (int, [][]byte, error) {
+	if len(script) < 2 {
+		return 0, nil, errors.New("multisig script too short")
+	}
+	m, n := int(script[0]), int(script[1])
+	if n == 0 || n > maxMultisigKeys || m < 1 || m > n || len(script) != 2+n*pubKeyHashLen {
+		return 0, nil, errors.New("malformed multisig script")
+	}
+
+	pubKeyHashes := make([][]byte, 0, n)
+	for i := 0; i < n; i++ {
+		off := 2 + i*pubKeyHashLen
+		pubKeyHashes = append(pubKeyHashes, script[off:off+pubKeyHashLen])
+	}
+	return m, pubKeyHashes, nil
+}
+
+// MultisigAddress returns the Base58Check address (version 0x05) of a multisig script.
+func MultisigAddress(script []byte) string {
+	return AddressFromScriptHash(HashPubKey(script))
+}
+
+// AddressFromScriptHash encodes a multisig script hash, as stored in an output, as an address.
+func AddressFromScriptHash(scriptHash []byte) string {
+	versionedPayload := append([]byte{multisigVersion}, scriptHash...)
+	checksum := checksum(versionedPayload)
+	fullPayload := append(versionedPayload, checksum...)
+	return string(Base58Encode(fullPayload))
+}
+
+// IsMultisigAddress reports whether address is a valid multisig (version 0x05) address.
+func IsMultisigAddress(address string) bool {
+	if !ValidateAddress(address) {
+		return false
+	}
+	decoded := Base58Decode([]byte(address))
+	return decoded[0] == multisigVersion
+}
+
+// AddMultisig creates an m-of-n address over existing P2PKH addresses and remembers its
+// script so the wallets can spend from it. Only the keys that sign need to be local.
+func (ws *Wallets) AddMultisig(m int, addresses []string) (string, error) {
+	pubKeyHashes := make([][]byte, 0, len(addresses))
+	for _, address := range addresses {
+		if !ValidateAddress(address) || IsMultisigAddress(address) {
+			return "", fmt.Errorf("invalid address %q", address)
+		}
+		pubKeyHashes = append(pubKeyHashes, PubKeyHashFromAddress(address))
+	}
+	script, err := NewMultisigScript(m, pubKeyHashes)
+	if err != nil {
+		return "", err
+	}
+
+	address := MultisigAddress(script)
+	ws.Multisig[address] = script
+	return address, ws.SaveToFile()
+}
+
+// GetMultisigScript returns the script behind a multisig address created by AddMultisig.
+func (ws *Wallets) GetMultisigScript(address string) ([]byte, bool) {
+	script, ok := ws.Multisig[address]
+	return script, ok
+}
+
+// MultisigSigners returns up to m local wallets holding keys of the script, in script order.
+func (ws *Wallets) MultisigSigners(script []byte) ([]*Wallet, error) {
+	if ws.Locked() {
+		return nil, ErrWalletLocked
+	}
+	m, pubKeyHashes, err := ParseMultisigScript(script)
+	if err != nil {
+		return nil, err
+	}
+
+	var signers []*Wallet
+	for _, pkh := range pubKeyHashes {
+		if w, ok := ws.GetWallet(AddressFromPubKeyHash(pkh)); ok {
+			signers = append(signers, w)
+			if len(signers) == m {
+				break
+			}
+		}
+	}
+	if len(signers) < m {
+		return nil, fmt.Errorf("need %d signing keys, have %d", m, len(signers))
+	}
+	return signers, nil
+}
@@ -6,6 +6,7 @@ import (
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/sha256"
+	"errors"
 	"math/big"
 
 	"golang.org/x/crypto/ripemd160"
@@ -22,6 +23,11 @@ type Wallet struct {
 	PrivateKey []byte
 	// Uncompressed public key bytes (elliptic.Marshal)
 	PublicKey []byte
+	// Compressed selects the 33-byte public key form for the address and for spending.
+	Compressed bool
+	// EncryptedKey is PrivateKey sealed with the wallet key once the wallets are encrypted.
+	// PrivateKey is then only set while they are unlocked.
+	EncryptedKey []byte
 }
 
 func NewWallet() *Wallet {
@@ -41,6 +47,47 @@ func NewWallet() *Wallet {
 	return &Wallet{PrivateKey: privKeyBytes, PublicKey: pubKey}
 }
 
+// NewWalletFromPrivateKey rebuilds a wallet from its 32-byte private scalar.
+func NewWalletFromPrivateKey(privKey []byte) (*Wallet, error) {
+	curve := elliptic.P256()
+	d := new(big.Int).SetBytes(privKey)
+	if len(privKey) != privateKeyByteLen || d.Sign() == 0 || d.Cmp(curve.Params().N) >= 0 {
+		return nil, errors.New("private key out of range for P-256")
+	}
+	x, y := curve.ScalarBaseMult(privKey)
+	pubKey := elliptic.Marshal(curve, x, y)
+	return &Wallet{PrivateKey: append([]byte(nil), privKey...), PublicKey: pubKey}, nil
+}
+
+// CompressedPubKey returns the 33-byte compressed form of the public key.
+func (w *Wallet) CompressedPubKey() []byte {
+	x, y := elliptic.Unmarshal(elliptic.P256(), w.PublicKey)
+	return elliptic.MarshalCompressed(elliptic.P256(), x, y)
+}
+
+// PubKey returns the public key in the form the wallet's address commits to.
+func (w *Wallet) PubKey() []byte {
+	if w.Compressed {
+		return w.CompressedPubKey()
+	}
+	return w.PublicKey
+}
+
+// ParsePubKey decodes a P-256 public key in either compressed (33-byte) or uncompressed form.
+func ParsePubKey(pubKey []byte) (*ecdsa.PublicKey, error) {
+	curve := elliptic.P256()
+	var x, y *big.Int
+	if len(pubKey) == 33 {
+		x, y = elliptic.UnmarshalCompressed(curve, pubKey)
+	} else {
+		x, y = elliptic.Unmarshal(curve, pubKey)
+	}
+	if x == nil {
+		return nil, errors.New("invalid public key")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
 func (w *Wallet) PrivateECDSA() *ecdsa.PrivateKey {
 	curve := elliptic.P256()
 	d := new(big.Int).SetBytes(w.PrivateKey)
@@ -51,7 +98,8 @@ func (w *Wallet) PrivateECDSA() *ecdsa.PrivateKey {
 	}
 }
 
-// HashPubKey performs SHA256 then RIPEMD160 (Bitcoin-style).
+// HashPubKey performs SHA256 then RIPEMD160 (Bitcoin-style). It hashes whichever
+// encoding it is given, so compressed and uncompressed keys have different hashes.
 func HashPubKey(pubKey []byte) []byte {
 	publicSHA := sha256.Sum256(pubKey)
 	ri := ripemd160.New()
@@ -60,22 +108,38 @@ func HashPubKey(pubKey []byte) []byte {
 }
 
 func (w *Wallet) GetAddress() []byte {
-	pubKeyHash := HashPubKey(w.PublicKey)
+	return []byte(AddressFromPubKeyHash(HashPubKey(w.PubKey())))
+}
+
+// AddressFromPubKeyHash encodes a public key hash as a Base58Check address.
+func AddressFromPubKeyHash(pubKeyHash []byte) string {
 	versionedPayload := append([]byte{addressVersion}, pubKeyHash...)
 	checksum := checksum(versionedPayload)
 	fullPayload := append(versionedPayload, checksum...)
-	return Base58Encode(fullPayload)
+	return string(Base58Encode(fullPayload))
 }
 
 func ValidateAddress(address string) bool {
-	decoded := Base58Decode([]byte(address))
-	if decoded == nil || len(decoded) < 1+addressChecksumLen {
-		return false
+	return CheckAddress(address) == nil
+}
+
+// CheckAddress is ValidateAddress that says what is wrong with an invalid address.
+func CheckAddress(address string) error {
+	if address == "" {
+		return errors.New("empty address")
+	}
+	decoded, err := Base58DecodeChecked([]byte(address))
+	if err != nil {
+		return err
+	}
+	if len(decoded) < 1+addressChecksumLen {
+		return errors.New("address too short")
 	}
 	payload := decoded[:len(decoded)-addressChecksumLen]
-	actualChecksum := decoded[len(decoded)-addressChecksumLen:]
-	expectedChecksum := checksum(payload)
-	return bytes.Equal(actualChecksum, expectedChecksum)
+	if !bytes.Equal(decoded[len(decoded)-addressChecksumLen:], checksum(payload)) {
+		return errors.New("address checksum mismatch (mistyped address?)")
+	}
+	return nil
 }
 
 func PubKeyHashFromAddress(address string) []byte {
@@ -88,6 +152,16 @@ func PubKeyHashFromAddress(address string) []byte {
 	return pubKeyHash
 }
 
+// DecodeAddress splits a Base58Check address into its version byte, public key hash
+// and checksum, checking the checksum on the way.
+func DecodeAddress(address string) (version byte, pubKeyHash, check []byte, err error) {
+	if err := CheckAddress(address); err != nil {
+		return 0, nil, nil, err
+	}
+	decoded := Base58Decode([]byte(address))
+	return decoded[0], PubKeyHashFromAddress(address), decoded[len(decoded)-addressChecksumLen:], nil
+}
+
 func checksum(payload []byte) []byte {
 	first := sha256.Sum256(payload)
 	second := sha256.Sum256(first[:])
@@ -0,0 +1,64 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDecodeAddressMatchesKey(t *testing.T) {
+	ws := newTestWallets(t)
+	plain, err := ws.CreateWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed, err := ws.CreateCompressedWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, address := range []string{plain, compressed} {
+		w, _ := ws.GetWallet(address)
+		version, pubKeyHash, check, err := DecodeAddress(address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(pubKeyHash, PubKeyHashFromAddress(address)) || !bytes.Equal(pubKeyHash, HashPubKey(w.PubKey())) {
+			t.Fatalf("%s: decoded public key hash %x, want %x", address, pubKeyHash, HashPubKey(w.PubKey()))
+		}
+		if len(pubKeyHash) != 20 || version != addressVersion {
+			t.Fatalf("%s: %d-byte hash with version %#x", address, len(pubKeyHash), version)
+		}
+		if want := checksum(append([]byte{version}, pubKeyHash...)); !bytes.Equal(check, want) {
+			t.Fatalf("%s: checksum %x, want %x", address, check, want)
+		}
+	}
+
+	// Changing the last character breaks the checksum.
+	last := plain[len(plain)-1]
+	swapped := byte('2')
+	if last == swapped {
+		swapped = '3'
+	}
+	if _, _, _, err := DecodeAddress(plain[:len(plain)-1] + string(swapped)); err == nil {
+		t.Fatal("decoded an address with a bad checksum")
+	}
+}
+
+func TestAddressWithAmbiguousCharacterNamesIt(t *testing.T) {
+	address := string(NewWallet().GetAddress())
+	for _, c := range []byte("0OIl") {
+		bad := address[:6] + string(c) + address[7:]
+		err := CheckAddress(bad)
+		if !errors.Is(err, ErrInvalidBase58) {
+			t.Fatalf("%s: got %v, want ErrInvalidBase58", bad, err)
+		}
+		if want := fmt.Sprintf("invalid character %q at position 7", c); !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: error %q does not say %q", bad, err, want)
+		}
+		if ValidateAddress(bad) {
+			t.Errorf("%s validated", bad)
+		}
+	}
+}
@@ -3,18 +3,46 @@ package wallet
 import (
 	"bytes"
 	"encoding/gob"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
 )
 
-const walletFile = "wallets.dat"
+// DefaultWalletFile is where NewWallets keeps the wallets, relative to the working directory.
+const DefaultWalletFile = "wallets.dat"
+
+// ErrCorruptWalletFile is returned when the wallet file exists but cannot be decoded.
+var ErrCorruptWalletFile = errors.New("wallet file is corrupt")
 
 type Wallets struct {
 	Wallets map[string]*Wallet
+	// Mnemonic is the HD seed phrase, if any. When set, new addresses are derived
+	// from it at NextIndex instead of being generated at random.
+	Mnemonic  string
+	NextIndex uint32
+	// Multisig maps multisig addresses to their scripts (see AddMultisig).
+	Multisig map[string][]byte
+	// Watched holds addresses tracked without their keys (see ImportAddress).
+	Watched map[string]bool
+	// Encryption is set once the wallets are encrypted (see Encrypt).
+	Encryption *Encryption
+
+	// path is the file the wallets are loaded from and saved to.
+	path string
+	// key decrypts the sealed keys while encrypted wallets are unlocked.
+	key []byte
 }
 
 func NewWallets() (*Wallets, error) {
-	ws := &Wallets{Wallets: make(map[string]*Wallet)}
-	if _, err := os.Stat(walletFile); err == nil {
+	return NewWalletsAt(DefaultWalletFile)
+}
+
+// NewWalletsAt loads the wallets stored at path, or starts an empty set that will be
+// saved there.
+func NewWalletsAt(path string) (*Wallets, error) {
+	ws := &Wallets{Wallets: make(map[string]*Wallet), Multisig: make(map[string][]byte), Watched: make(map[string]bool), path: path}
+	if _, err := os.Stat(path); err == nil {
 		if err := ws.LoadFromFile(); err != nil {
 			return nil, err
 		}
@@ -23,12 +51,80 @@ func NewWallets() (*Wallets, error) {
 }
 
 func (ws *Wallets) CreateWallet() (string, error) {
+	return ws.createWallet(false)
+}
+
+// CreateCompressedWallet is CreateWallet for an address over the 33-byte compressed public key.
+func (ws *Wallets) CreateCompressedWallet() (string, error) {
+	return ws.createWallet(true)
+}
+
+func (ws *Wallets) createWallet(compressed bool) (string, error) {
+	if ws.Locked() {
+		return "", ErrWalletLocked
+	}
 	w := NewWallet()
+	if ws.Mnemonic != "" {
+		hd, err := NewHDWallet(ws.Mnemonic)
+		if err != nil {
+			return "", err
+		}
+		if w, err = hd.DeriveChild(ws.NextIndex); err != nil {
+			return "", err
+		}
+		ws.NextIndex++
+	}
+	w.Compressed = compressed
 	address := string(w.GetAddress())
 	ws.Wallets[address] = w
 	return address, ws.SaveToFile()
 }
 
+// CreateHDWallet generates a mnemonic for these wallets and derives the first address from it.
+// It returns the mnemonic, which is the only backup needed to restore derived addresses.
+func (ws *Wallets) CreateHDWallet() (string, string, error) {
+	if ws.Locked() {
+		return "", "", ErrWalletLocked
+	}
+	if ws.Mnemonic != "" {
+		return "", "", errors.New("wallets already have an HD seed")
+	}
+	mnemonic, err := NewMnemonic()
+	if err != nil {
+		return "", "", err
+	}
+	ws.Mnemonic = mnemonic
+	ws.NextIndex = 0
+	address, err := ws.CreateWallet()
+	return mnemonic, address, err
+}
+
+// RestoreHDWallet re-derives the first count addresses of mnemonic, in order.
+func (ws *Wallets) RestoreHDWallet(mnemonic string, count int) ([]string, error) {
+	if ws.Locked() {
+		return nil, ErrWalletLocked
+	}
+	hd, err := NewHDWallet(mnemonic)
+	if err != nil {
+		return nil, err
+	}
+	if ws.Mnemonic != "" && ws.Mnemonic != hd.Mnemonic {
+		return nil, errors.New("wallets already have a different HD seed")
+	}
+	ws.Mnemonic = hd.Mnemonic
+	ws.NextIndex = 0
+
+	addresses := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		address, err := ws.CreateWallet()
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, address)
+	}
+	return addresses, nil
+}
+
 func (ws *Wallets) GetAddresses() []string {
 	addresses := make([]string, 0, len(ws.Wallets))
 	for addr := range ws.Wallets {
@@ -37,33 +133,99 @@ func (ws *Wallets) GetAddresses() []string {
 	return addresses
 }
 
+// PubKeyHashes returns the pubkey hash of every address ws knows: its keys, multisig
+// addresses and watch-only addresses, in no particular order.
+func (ws *Wallets) PubKeyHashes() [][]byte {
+	hashes := make([][]byte, 0, len(ws.Wallets)+len(ws.Multisig)+len(ws.Watched))
+	for address := range ws.Wallets {
+		hashes = append(hashes, PubKeyHashFromAddress(address))
+	}
+	for address := range ws.Multisig {
+		hashes = append(hashes, PubKeyHashFromAddress(address))
+	}
+	for _, address := range ws.WatchedAddresses() {
+		hashes = append(hashes, PubKeyHashFromAddress(address))
+	}
+	return hashes
+}
+
 func (ws *Wallets) GetWallet(address string) (*Wallet, bool) {
 	w, ok := ws.Wallets[address]
 	return w, ok
 }
 
+// File returns the path the wallets are saved to.
+func (ws *Wallets) File() string {
+	if ws.path == "" {
+		return DefaultWalletFile
+	}
+	return ws.path
+}
+
 func (ws *Wallets) LoadFromFile() error {
-	content, err := os.ReadFile(walletFile)
+	content, err := os.ReadFile(ws.File())
 	if err != nil {
 		return err
 	}
 	decoder := gob.NewDecoder(bytes.NewReader(content))
 	var loaded Wallets
 	if err := decoder.Decode(&loaded); err != nil {
-		return err
+		return fmt.Errorf("%w: %s (%d bytes): %v; restore it from a backup", ErrCorruptWalletFile, ws.File(), len(content), err)
 	}
 	ws.Wallets = loaded.Wallets
+	ws.Mnemonic = loaded.Mnemonic
+	ws.NextIndex = loaded.NextIndex
+	ws.Multisig = loaded.Multisig
+	ws.Watched = loaded.Watched
+	ws.Encryption = loaded.Encryption
+	ws.key = nil
 	if ws.Wallets == nil {
 		ws.Wallets = make(map[string]*Wallet)
 	}
+	if ws.Multisig == nil {
+		ws.Multisig = make(map[string][]byte)
+	}
+	if ws.Watched == nil {
+		ws.Watched = make(map[string]bool)
+	}
 	return nil
 }
 
+// SaveToFile replaces the wallet file atomically: the wallets are written and synced to a
+// temporary file in the same directory, which is then renamed over the old file. A crash
+// leaves either the old file or the new one, never a partial write. Encrypted wallets are
+// written with their keys sealed, so new keys can only be saved while they are unlocked.
 func (ws *Wallets) SaveToFile() error {
+	out, err := ws.sealed()
+	if err != nil {
+		return err
+	}
 	var buf bytes.Buffer
 	encoder := gob.NewEncoder(&buf)
-	if err := encoder.Encode(ws); err != nil {
+	if err := encoder.Encode(out); err != nil {
+		return err
+	}
+	return writeFileAtomic(ws.File(), buf.Bytes())
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	// Once renamed, the temporary name no longer exists and Remove is a no-op.
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
 		return err
 	}
-	return os.WriteFile(walletFile, buf.Bytes(), 0o600)
+	return os.Rename(tmp.Name(), path)
 }
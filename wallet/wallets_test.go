@@ -0,0 +1,108 @@
+package wallet
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWalletFilesStayIndependent(t *testing.T) {
+	dir := t.TempDir()
+	first, err := NewWalletsAt(filepath.Join(dir, "first.dat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := NewWalletsAt(filepath.Join(dir, "second.dat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := first.CreateWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := second.CreateWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		file       string
+		has, hasnt string
+	}{
+		{"first.dat", a, b},
+		{"second.dat", b, a},
+	} {
+		ws, err := NewWalletsAt(filepath.Join(dir, tt.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ws.GetAddresses(); len(got) != 1 || got[0] != tt.has {
+			t.Errorf("%s holds %v, want only %s", tt.file, got, tt.has)
+		}
+		if _, ok := ws.GetWallet(tt.hasnt); ok {
+			t.Errorf("%s holds %s from the other file", tt.file, tt.hasnt)
+		}
+	}
+}
+
+func TestInterruptedSaveKeepsPreviousFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallets.dat")
+	ws, err := NewWalletsAt(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	address, err := ws.CreateWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	good, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A save that crashed mid-write leaves half a temporary file next to the real one.
+	if err := os.WriteFile(path+".tmp-crashed", good[:len(good)/2], 0o600); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := NewWalletsAt(path)
+	if err != nil {
+		t.Fatalf("loading after an interrupted save: %v", err)
+	}
+	if _, ok := reloaded.GetWallet(address); !ok {
+		t.Fatalf("%s lost after an interrupted save", address)
+	}
+
+	// A later save replaces the file whole and leaves no temporary file of its own.
+	if _, err := reloaded.CreateWallet(); err != nil {
+		t.Fatal(err)
+	}
+	matches, err := filepath.Glob(path + ".tmp-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 || matches[0] != path+".tmp-crashed" {
+		t.Fatalf("temporary files after a save: %v", matches)
+	}
+}
+
+func TestTruncatedWalletFileReportedAsCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallets.dat")
+	ws, err := NewWalletsAt(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ws.CreateWallet(); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, content[:len(content)/2], 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewWalletsAt(path); !errors.Is(err, ErrCorruptWalletFile) {
+		t.Fatalf("loading a truncated file: got %v, want ErrCorruptWalletFile", err)
+	}
+}
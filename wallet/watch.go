@@ -0,0 +1,47 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrWatchOnly is returned when an address the wallets only watch is used to sign.
+var ErrWatchOnly = errors.New("address is watch-only")
+
+// ImportAddress adds address to the watch list, so its balance is tracked without its key.
+// Importing an address the wallets can already sign for is an error.
+func (ws *Wallets) ImportAddress(address string) error {
+	if err := CheckAddress(address); err != nil {
+		return fmt.Errorf("invalid address: %w", err)
+	}
+	if ws.Owns(address) {
+		return errors.New("address is already in the wallet with its key")
+	}
+	ws.Watched[address] = true
+	return ws.SaveToFile()
+}
+
+// IsWatchOnly reports whether address is watched without a key to spend from it.
+func (ws *Wallets) IsWatchOnly(address string) bool {
+	return ws.Watched[address] && !ws.Owns(address)
+}
+
+// WatchedAddresses returns the watch-only addresses, sorted.
+func (ws *Wallets) WatchedAddresses() []string {
+	addresses := make([]string, 0, len(ws.Watched))
+	for address := range ws.Watched {
+		if ws.IsWatchOnly(address) {
+			addresses = append(addresses, address)
+		}
+	}
+	sort.Strings(addresses)
+	return addresses
+}
+
+// Owns reports whether the wallets hold the key, or the multisig script, for address.
+func (ws *Wallets) Owns(address string) bool {
+	_, key := ws.Wallets[address]
+	_, script := ws.Multisig[address]
+	return key || script
+}
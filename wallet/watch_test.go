@@ -0,0 +1,42 @@
+package wallet
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestImportAddressWatchesWithoutKey(t *testing.T) {
+	ws := newTestWallets(t)
+	owned, err := ws.CreateWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	watched := string(NewWallet().GetAddress())
+
+	if err := ws.ImportAddress(watched); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.ImportAddress(owned); err == nil {
+		t.Error("imported an address the wallets hold the key for")
+	}
+	if err := ws.ImportAddress("not an address"); err == nil {
+		t.Error("imported an invalid address")
+	}
+
+	reloaded, err := NewWalletsAt(ws.File())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reloaded.WatchedAddresses(); !slices.Equal(got, []string{watched}) {
+		t.Fatalf("watched after reload %v, want [%s]", got, watched)
+	}
+	if !reloaded.IsWatchOnly(watched) || reloaded.IsWatchOnly(owned) {
+		t.Fatal("watch-only status wrong after reload")
+	}
+	if slices.Contains(reloaded.GetAddresses(), watched) {
+		t.Fatal("watched address listed among the owned ones")
+	}
+	if _, ok := reloaded.GetWallet(watched); ok {
+		t.Fatal("watched address has a signing wallet")
+	}
+}
@@ -0,0 +1,77 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+const (
+	// wifVersion is the version byte prefixed to private keys in Wallet Import Format.
+	wifVersion = byte(0x80)
+	// wifCompressed is appended to the key when its address uses the compressed public key.
+	wifCompressed = byte(0x01)
+)
+
+var ErrInvalidWIF = errors.New("invalid WIF private key")
+
+// EncodeWIF encodes a private scalar as version | key | [0x01 if compressed] | checksum in Base58Check.
+func EncodeWIF(privKey []byte, compressed bool) string {
+	payload := append([]byte{wifVersion}, privKey...)
+	if compressed {
+		payload = append(payload, wifCompressed)
+	}
+	return string(Base58Encode(append(payload, checksum(payload)...)))
+}
+
+// DecodeWIF returns the private scalar encoded in wif and whether it is marked compressed,
+// rejecting a bad checksum, version byte or key length.
+func DecodeWIF(wif string) ([]byte, bool, error) {
+	decoded := Base58Decode([]byte(wif))
+	if len(decoded) < addressChecksumLen {
+		return nil, false, fmt.Errorf("%w: wrong length", ErrInvalidWIF)
+	}
+	payload := decoded[:len(decoded)-addressChecksumLen]
+	if !bytes.Equal(decoded[len(decoded)-addressChecksumLen:], checksum(payload)) {
+		return nil, false, fmt.Errorf("%w: bad checksum", ErrInvalidWIF)
+	}
+	compressed := len(payload) == 1+privateKeyByteLen+1 && payload[len(payload)-1] == wifCompressed
+	if len(payload) != 1+privateKeyByteLen && !compressed {
+		return nil, false, fmt.Errorf("%w: wrong length", ErrInvalidWIF)
+	}
+	if payload[0] != wifVersion {
+		return nil, false, fmt.Errorf("%w: unknown version byte 0x%02x", ErrInvalidWIF, payload[0])
+	}
+	return payload[1 : 1+privateKeyByteLen], compressed, nil
+}
+
+// ExportWIF returns the private key of a stored address in WIF.
+func (ws *Wallets) ExportWIF(address string) (string, error) {
+	if ws.Locked() {
+		return "", ErrWalletLocked
+	}
+	w, ok := ws.GetWallet(address)
+	if !ok {
+		return "", fmt.Errorf("no key for address %s", address)
+	}
+	return EncodeWIF(w.PrivateKey, w.Compressed), nil
+}
+
+// ImportWIF adds the key encoded in wif to the wallets, saves them, and returns its address.
+func (ws *Wallets) ImportWIF(wif string) (string, error) {
+	if ws.Locked() {
+		return "", ErrWalletLocked
+	}
+	privKey, compressed, err := DecodeWIF(wif)
+	if err != nil {
+		return "", err
+	}
+	w, err := NewWalletFromPrivateKey(privKey)
+	if err != nil {
+		return "", err
+	}
+	w.Compressed = compressed
+	address := string(w.GetAddress())
+	ws.Wallets[address] = w
+	return address, ws.SaveToFile()
+}
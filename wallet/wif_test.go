@@ -0,0 +1,55 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestWIFRoundTrip(t *testing.T) {
+	w := NewWallet()
+	for _, compressed := range []bool{false, true} {
+		privKey, gotCompressed, err := DecodeWIF(EncodeWIF(w.PrivateKey, compressed))
+		if err != nil {
+			t.Fatalf("compressed=%v: %v", compressed, err)
+		}
+		if !bytes.Equal(privKey, w.PrivateKey) || gotCompressed != compressed {
+			t.Errorf("compressed=%v: decoded (%x, %v), want (%x, %v)", compressed, privKey, gotCompressed, w.PrivateKey, compressed)
+		}
+	}
+
+	// Importing an exported key gives back the same address.
+	dir := t.TempDir()
+	src, err := NewWalletsAt(filepath.Join(dir, "src.dat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	address, err := src.CreateWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wif, err := src.ExportWIF(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := NewWalletsAt(filepath.Join(dir, "dst.dat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, err := dst.ImportWIF(wif)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported != address {
+		t.Fatalf("imported address %s, want %s", imported, address)
+	}
+}
+
+func TestWIFWithBadChecksumRejected(t *testing.T) {
+	decoded := Base58Decode([]byte(EncodeWIF(NewWallet().PrivateKey, true)))
+	decoded[len(decoded)-1] ^= 0xff
+	if _, _, err := DecodeWIF(string(Base58Encode(decoded))); !errors.Is(err, ErrInvalidWIF) {
+		t.Fatalf("bad checksum: got %v, want ErrInvalidWIF", err)
+	}
+}